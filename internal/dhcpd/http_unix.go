@@ -112,10 +112,15 @@ type leaseDynamic struct {
 	IP       netip.Addr `json:"ip"`
 	Hostname string     `json:"hostname"`
 	Expiry   string     `json:"expires"`
+
+	// ExpiresIn is the number of seconds left until the lease expires.  It's
+	// zero for the expired leases.
+	ExpiresIn uint64 `json:"expires_in"`
 }
 
-// leasesToDynamic converts list of leases to their JSON form.
-func leasesToDynamic(leases []*dhcpsvc.Lease) (dynamic []*leaseDynamic) {
+// leasesToDynamic converts list of leases to their JSON form.  now is used to
+// calculate the remaining time of the leases.
+func leasesToDynamic(leases []*dhcpsvc.Lease, now time.Time) (dynamic []*leaseDynamic) {
 	dynamic = make([]*leaseDynamic, len(leases))
 
 	for i, l := range leases {
@@ -127,7 +132,8 @@ func leasesToDynamic(leases []*dhcpsvc.Lease) (dynamic []*leaseDynamic) {
 			// value.
 			//
 			// See https://github.com/AdguardTeam/AdGuardHome/issues/2692.
-			Expiry: l.Expiry.Format(time.RFC3339),
+			Expiry:    l.Expiry.Format(time.RFC3339),
+			ExpiresIn: uint64(l.RemainingTTL(now) / time.Second),
 		}
 	}
 
@@ -164,7 +170,7 @@ func (s *server) handleDHCPStatus(w http.ResponseWriter, r *http.Request) {
 		dynamicIdx = len(leases)
 	}
 
-	status.Leases = leasesToDynamic(leases[dynamicIdx:])
+	status.Leases = leasesToDynamic(leases[dynamicIdx:], time.Now())
 	status.StaticLeases = leasesToStatic(leases[:dynamicIdx])
 
 	aghhttp.WriteJSONResponseOK(w, r, status)
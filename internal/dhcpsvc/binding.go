@@ -0,0 +1,94 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+
+	"golang.org/x/exp/slices"
+)
+
+// Binding is a pair of an address leased to a client and the hardware address
+// of the client, e.g. to be mirrored into a firewall set.
+type Binding struct {
+	// IP is the leased address.
+	IP netip.Addr
+
+	// MAC is the hardware address of the client.
+	MAC net.HardwareAddr
+}
+
+// Bindings returns the current bindings of the leased addresses sorted by
+// address.  The expired dynamic leases aren't bound, and neither are the
+// addresses outside of the subnets of the served network interfaces.  Use
+// [DHCPServer.BindingChanges] to keep a copy up to date with the events.
+func (srv *DHCPServer) Bindings() (bs []*Binding) {
+	now := srv.now()
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		if !l.IsStatic && !now.Before(l.Expiry) {
+			return true
+		}
+
+		if b := srv.newBinding(l); b != nil {
+			bs = append(bs, b)
+		}
+
+		return true
+	})
+
+	slices.SortFunc(bs, func(a, b *Binding) (res int) {
+		return a.IP.Compare(b.IP)
+	})
+
+	return bs
+}
+
+// BindingChanges returns the changes of the bindings described by ev, see
+// [DHCPServer.Bindings].  removed is the binding to remove, if any, and added
+// is the one to add or replace the binding of the same address with, if any:
+//
+//   - [EventTypeAdded] adds the binding of the lease;
+//   - [EventTypeUpdated] replaces it, and removes the binding of [Event.Prev]
+//     if the address has changed;
+//   - [EventTypeRemoved] and [EventTypeExpired] remove it.
+//
+// The other types of events don't change the bindings.  removed should only
+// be applied if the address is still bound to the same hardware address.
+func (srv *DHCPServer) BindingChanges(ev *Event) (removed, added *Binding) {
+	switch ev.Type {
+	case EventTypeAdded:
+		return nil, srv.newBinding(ev.Lease)
+	case EventTypeUpdated:
+		if ev.Prev != nil && ev.Prev.IP != ev.Lease.IP {
+			removed = srv.newBinding(ev.Prev)
+		}
+
+		return removed, srv.newBinding(ev.Lease)
+	case EventTypeRemoved, EventTypeExpired:
+		return srv.newBinding(ev.Lease), nil
+	default:
+		return nil, nil
+	}
+}
+
+// newBinding returns the binding of l.  b is nil if the address of l isn't
+// within the subnet of its network interface or l has no hardware address.
+func (srv *DHCPServer) newBinding(l *Lease) (b *Binding) {
+	iface := srv.ifaceByName(l.InterfaceName, l.IP.Is4())
+	if iface == nil || !iface.subnet.Contains(l.IP) {
+		return nil
+	}
+
+	mac := l.mac()
+	if mac == nil {
+		return nil
+	}
+
+	return &Binding{
+		IP:  l.IP,
+		MAC: slices.Clone(mac),
+	}
+}
@@ -0,0 +1,220 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bindingMirror is an example consumer of the bindings, which keeps a copy of
+// those up to date with the events, e.g. as a firewall set would.
+type bindingMirror map[netip.Addr]net.HardwareAddr
+
+// apply applies the changes of the bindings described by ev.
+func (m bindingMirror) apply(srv *DHCPServer, ev *Event) {
+	removed, added := srv.BindingChanges(ev)
+	if removed != nil && bytes.Equal(m[removed.IP], removed.MAC) {
+		delete(m, removed.IP)
+	}
+
+	if added != nil {
+		m[added.IP] = added.MAC
+	}
+}
+
+// bindings returns the contents of m in the form of [DHCPServer.Bindings].
+func (m bindingMirror) bindings() (bs []*Binding) {
+	for ip, mac := range m {
+		bs = append(bs, &Binding{IP: ip, MAC: mac})
+	}
+
+	return bs
+}
+
+func TestDHCPServer_Bindings(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+
+		clientsNum = 8
+		opsNum     = 300
+	)
+
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	ch := make(chan *Event, opsNum*4)
+	srv.Subscribe(ch)
+	t.Cleanup(func() { srv.Unsubscribe(ch) })
+
+	macs := make([]net.HardwareAddr, clientsNum)
+	for i := range macs {
+		macs[i] = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, byte(i)}
+	}
+
+	staticMAC := func(i int) (mac net.HardwareAddr) {
+		return net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x01, byte(i)}
+	}
+	staticIP := func(i int) (ip netip.Addr) {
+		return netip.AddrFrom4([4]byte{192, 168, 0, byte(100 + i)})
+	}
+	statics := map[int]netip.Addr{}
+
+	handle := func(mac net.HardwareAddr, typ layers.DHCPMsgType, ip netip.Addr) (resp *layers.DHCPv4) {
+		req := newTestRequest4(mac, typ)
+		if typ == layers.DHCPMsgTypeRelease {
+			req.ClientIP = ip.AsSlice()
+		} else if ip.IsValid() {
+			req.Options = append(req.Options, layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice()))
+		}
+
+		resp, err := srv.handle4(ifaceName, req)
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	// Use the fixed seed to make the failures reproducible.
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < opsNum; i++ {
+		mac := macs[r.Intn(clientsNum)]
+		switch op := r.Intn(6); op {
+		case 0, 1:
+			reqIP := netip.AddrFrom4([4]byte{192, 168, 0, byte(2 + r.Intn(16))})
+			offer := handle(mac, layers.DHCPMsgTypeDiscover, reqIP)
+			if offer != nil {
+				ip, _ := netip.AddrFromSlice(offer.YourClientIP.To4())
+				handle(mac, layers.DHCPMsgTypeRequest, ip)
+			}
+		case 2:
+			l, ok := srv.iface4ByName(ifaceName).leases[leaseKey{mac: macToKey(mac)}]
+			if ok {
+				handle(mac, layers.DHCPMsgTypeRelease, l.IP)
+			}
+		case 3:
+			n := r.Intn(4)
+			if ip, ok := statics[n]; ok {
+				require.NoError(t, srv.RemoveStaticLease(&Lease{IP: ip, HWAddr: staticMAC(n)}))
+				delete(statics, n)
+			} else {
+				require.NoError(t, srv.AddStaticLease(&Lease{IP: staticIP(n), HWAddr: staticMAC(n)}))
+				statics[n] = staticIP(n)
+			}
+		case 4:
+			n := r.Intn(4)
+			if ip, ok := statics[n]; ok {
+				newIP := staticIP(n + 4*(1+r.Intn(2)))
+				err := srv.UpdateStaticLease(
+					&Lease{IP: ip, HWAddr: staticMAC(n)},
+					&Lease{IP: newIP, HWAddr: staticMAC(n)},
+				)
+				if err == nil {
+					statics[n] = newIP
+				}
+			}
+		default:
+			now = now.Add(time.Duration(r.Int63n(int64(30 * time.Minute))))
+		}
+	}
+
+	// Make the server report the leases expired since the last message.
+	handle(macs[0], layers.DHCPMsgTypeInform, netip.Addr{})
+
+	mirror := bindingMirror{}
+	for len(ch) > 0 {
+		mirror.apply(srv, <-ch)
+	}
+
+	want := srv.Bindings()
+	require.NotEmpty(t, want)
+
+	assert.ElementsMatch(t, want, mirror.bindings())
+
+	subnet := srv.iface4ByName(ifaceName).subnet
+	for _, b := range want {
+		assert.True(t, subnet.Contains(b.IP))
+	}
+}
+
+func TestDHCPServer_BindingChanges(t *testing.T) {
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	inside := &Lease{
+		IP:            netip.MustParseAddr("192.168.0.2"),
+		HWAddr:        mac,
+		InterfaceName: "eth0",
+	}
+	moved := &Lease{
+		IP:            netip.MustParseAddr("192.168.0.3"),
+		HWAddr:        mac,
+		InterfaceName: "eth0",
+	}
+	outside := &Lease{
+		IP:            netip.MustParseAddr("10.0.0.2"),
+		HWAddr:        mac,
+		InterfaceName: "eth0",
+	}
+
+	binding := func(l *Lease) (b *Binding) {
+		return &Binding{IP: l.IP, MAC: l.HWAddr}
+	}
+
+	testCases := []struct {
+		ev          *Event
+		wantRemoved *Binding
+		wantAdded   *Binding
+		name        string
+	}{{
+		ev:          &Event{Lease: inside, Type: EventTypeAdded},
+		wantRemoved: nil,
+		wantAdded:   binding(inside),
+		name:        "added",
+	}, {
+		ev:          &Event{Lease: moved, Prev: inside, Type: EventTypeUpdated},
+		wantRemoved: binding(inside),
+		wantAdded:   binding(moved),
+		name:        "updated_moved",
+	}, {
+		ev:          &Event{Lease: inside, Prev: inside, Type: EventTypeUpdated},
+		wantRemoved: nil,
+		wantAdded:   binding(inside),
+		name:        "updated_same",
+	}, {
+		ev:          &Event{Lease: inside, Type: EventTypeRemoved},
+		wantRemoved: binding(inside),
+		wantAdded:   nil,
+		name:        "removed",
+	}, {
+		ev:          &Event{Lease: inside, Type: EventTypeExpired},
+		wantRemoved: binding(inside),
+		wantAdded:   nil,
+		name:        "expired",
+	}, {
+		ev:          &Event{Lease: inside, Type: EventTypeRenamed},
+		wantRemoved: nil,
+		wantAdded:   nil,
+		name:        "renamed",
+	}, {
+		ev:          &Event{Lease: outside, Type: EventTypeAdded},
+		wantRemoved: nil,
+		wantAdded:   nil,
+		name:        "outside_subnet",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			removed, added := srv.BindingChanges(tc.ev)
+			assert.Equal(t, tc.wantRemoved, removed)
+			assert.Equal(t, tc.wantAdded, added)
+		})
+	}
+}
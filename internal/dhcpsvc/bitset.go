@@ -0,0 +1,33 @@
+package dhcpsvc
+
+import "math/bits"
+
+// bitsPerWord is the number of bits in a single word of [bitSet].
+const bitsPerWord = 64
+
+// bitSet is a sparse set of non-negative integers.
+type bitSet struct {
+	// words are the words of the set indexed by their numbers.
+	words map[uint64]uint64
+}
+
+// newBitSet returns a new empty bitSet.
+func newBitSet() (s *bitSet) {
+	return &bitSet{
+		words: map[uint64]uint64{},
+	}
+}
+
+// set adds n to s.
+func (s *bitSet) set(n uint64) {
+	s.words[n/bitsPerWord] |= 1 << (n % bitsPerWord)
+}
+
+// count returns the number of integers in s.
+func (s *bitSet) count() (n uint64) {
+	for _, w := range s.words {
+		n += uint64(bits.OnesCount64(w))
+	}
+
+	return n
+}
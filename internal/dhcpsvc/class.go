@@ -0,0 +1,127 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+// LeaseClass is a class of DHCPv4 clients, which are granted leases of their
+// own duration.  A client belongs to the class if it's classified so by
+// [Config.Classifier], or if it matches any of VendorClass and MACPrefix, which
+// are set.
+type LeaseClass struct {
+	// Name is the name of the class, e.g. "guest".  It's matched against the
+	// result of [Config.Classifier].
+	Name string
+
+	// VendorClass is the Vendor Class Identifier option sent by the clients of
+	// the class, e.g. "android-dhcp-13".  It's ignored if empty.
+	VendorClass string
+
+	// MACPrefix is the prefix of the hardware addresses of the clients of the
+	// class, e.g. the OUI of a vendor.  It's ignored if empty.
+	MACPrefix net.HardwareAddr
+
+	// LeaseDuration is the TTL of the leases granted to the clients of the
+	// class.
+	LeaseDuration time.Duration
+}
+
+// ClassifierFunc returns the class of the client described by req on the
+// network interface with the given name.  The class names the [LeaseClass] and
+// the [OptionProfile] of the client and may make the client ignored, see
+// [Config.IgnoredClasses].  class is empty if the client isn't classified, so
+// it's classified by its properties only.  req must not be modified.  It's
+// called once per message, must be safe for concurrent use, and should return
+// quickly.
+type ClassifierFunc func(ifaceName string, req *ClientHint) (class string)
+
+// classify4 returns the class of the client sent req on iface, see
+// [Config.Classifier].  class is empty if there is no classifier.
+func (srv *DHCPServer) classify4(iface *iface4, req *layers.DHCPv4) (class string) {
+	if srv.conf.Classifier == nil {
+		return ""
+	}
+
+	return srv.conf.Classifier(iface.name, newClientHint4(req))
+}
+
+// validate returns an error if c can't be used.
+func (c *LeaseClass) validate() (err error) {
+	switch {
+	case c == nil:
+		return errNilConfig
+	case c.Name == "" && c.VendorClass == "" && len(c.MACPrefix) == 0:
+		return fmt.Errorf("no name, vendor class, or mac prefix")
+	case len(c.MACPrefix) > 20:
+		return newMustErr("mac prefix", "not be longer than 20 bytes", c.MACPrefix)
+	case c.LeaseDuration <= 0:
+		return newMustErr("lease duration", "be positive", c.LeaseDuration)
+	default:
+		return nil
+	}
+}
+
+// validateClasses4 returns an error if any of classes can't be used.
+func validateClasses4(classes []*LeaseClass) (err error) {
+	for i, c := range classes {
+		err = c.validate()
+		if err != nil {
+			return fmt.Errorf("lease class at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if the client with mac sent vendorClass matches c.
+func (c *LeaseClass) matches(vendorClass []byte, mac net.HardwareAddr) (ok bool) {
+	if c.VendorClass != "" && string(vendorClass) == c.VendorClass {
+		return true
+	}
+
+	return len(c.MACPrefix) > 0 && bytes.HasPrefix(mac, c.MACPrefix)
+}
+
+// leaseTTL4 returns the lease duration for the client of the given class sent
+// req on iface, see [DHCPServer.classify4].  The class takes precedence over
+// the ones matched by the properties of req.  The first matching class applies.
+// srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) leaseTTL4(iface *iface4, req *layers.DHCPv4, class string) (ttl time.Duration) {
+	if len(iface.classes) == 0 {
+		return iface.leaseTTL
+	}
+
+	if class != "" {
+		for _, c := range iface.classes {
+			if c.Name == class {
+				return c.LeaseDuration
+			}
+		}
+	}
+
+	vendorClass := optData4(req, layers.DHCPOptClassID)
+	for _, c := range iface.classes {
+		if c.matches(vendorClass, req.ClientHWAddr) {
+			return c.LeaseDuration
+		}
+	}
+
+	return iface.leaseTTL
+}
+
+// setLeaseTime4 sets the IP Address Lease Time option of resp to ttl.  The
+// option is always replaced, since the one within the reply options of the
+// network interface contains the lease duration it was created with, which may
+// have been updated since then.
+func setLeaseTime4(resp *layers.DHCPv4, ttl time.Duration) {
+	setOpt4(resp, layers.NewDHCPOption(
+		layers.DHCPOptLeaseTime,
+		binary.BigEndian.AppendUint32(nil, uint32(ttl/time.Second)),
+	))
+}
@@ -0,0 +1,214 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_leaseTTL4(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+
+		guestTTL   = 10 * time.Minute
+		trustedTTL = 24 * time.Hour
+	)
+
+	guestMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	trustedMAC := net.HardwareAddr{0xaa, 0x00, 0x00, 0x00, 0x00, 0x01}
+	vendorMAC := net.HardwareAddr{0xbb, 0x00, 0x00, 0x00, 0x00, 0x01}
+	otherMAC := net.HardwareAddr{0xbb, 0x00, 0x00, 0x00, 0x00, 0x02}
+
+	conf := newTestIPv4Config()
+	conf.LeaseClasses = []*LeaseClass{{
+		Name:          "guest",
+		VendorClass:   "guest-device",
+		LeaseDuration: guestTTL,
+	}, {
+		Name:          "trusted",
+		MACPrefix:     net.HardwareAddr{0xaa},
+		LeaseDuration: trustedTTL,
+	}}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Classifier: func(_ string, req *ClientHint) (class string) {
+			if req.MAC[0] == guestMAC[0] {
+				return "guest"
+			}
+
+			return ""
+		},
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	vendorClassOpt := layers.NewDHCPOption(layers.DHCPOptClassID, []byte("guest-device"))
+
+	testCases := []struct {
+		mac     net.HardwareAddr
+		opts    []layers.DHCPOption
+		name    string
+		ip      netip.Addr
+		wantTTL time.Duration
+	}{{
+		mac:     guestMAC,
+		opts:    nil,
+		name:    "guest_classifier",
+		ip:      netip.MustParseAddr("192.168.0.2"),
+		wantTTL: guestTTL,
+	}, {
+		mac:     vendorMAC,
+		opts:    []layers.DHCPOption{vendorClassOpt},
+		name:    "guest_vendor_class",
+		ip:      netip.MustParseAddr("192.168.0.3"),
+		wantTTL: guestTTL,
+	}, {
+		mac:     trustedMAC,
+		opts:    nil,
+		name:    "trusted_mac_prefix",
+		ip:      netip.MustParseAddr("192.168.0.4"),
+		wantTTL: trustedTTL,
+	}, {
+		mac:     otherMAC,
+		opts:    nil,
+		name:    "default",
+		ip:      netip.MustParseAddr("192.168.0.5"),
+		wantTTL: conf.LeaseDuration,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			wantLeaseTime := binary.BigEndian.AppendUint32(nil, uint32(tc.wantTTL/time.Second))
+
+			offer, hErr := srv.handle4(ifaceName, newTestRequest4(tc.mac, layers.DHCPMsgTypeDiscover, tc.opts...))
+			require.NoError(t, hErr)
+			require.NotNil(t, offer)
+
+			assert.Equal(t, wantLeaseTime, optData4(offer, layers.DHCPOptLeaseTime))
+
+			opts := append([]layers.DHCPOption{
+				layers.NewDHCPOption(layers.DHCPOptRequestIP, tc.ip.AsSlice()),
+			}, tc.opts...)
+
+			start := time.Now()
+			ack, hErr := srv.handle4(ifaceName, newTestRequest4(tc.mac, layers.DHCPMsgTypeRequest, opts...))
+			require.NoError(t, hErr)
+			require.NotNil(t, ack)
+			require.Equal(t, layers.DHCPMsgTypeAck, msgType4(ack))
+
+			assert.Equal(t, wantLeaseTime, optData4(ack, layers.DHCPOptLeaseTime))
+
+			l := srv.leases.byAddr[tc.ip]
+			require.NotNil(t, l)
+
+			assert.WithinDuration(t, start.Add(tc.wantTTL), l.Expiry, time.Second)
+		})
+	}
+}
+
+func TestDHCPServer_handle4_classifier(t *testing.T) {
+	const ifaceName = "eth0"
+
+	printersDNS := []byte{10, 0, 0, 53}
+	phonesDNS := []byte{10, 0, 1, 53}
+
+	var calls atomic.Uint32
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Classifier: func(name string, req *ClientHint) (class string) {
+			calls.Add(1)
+
+			assert.Equal(t, ifaceName, name)
+
+			switch req.VendorClass {
+			case "printer":
+				return "printers"
+			case "phone":
+				return "phones"
+			case "intruder":
+				return "blocked"
+			default:
+				return ""
+			}
+		},
+		IgnoredClasses: []string{"blocked"},
+		OptionProfiles: []*OptionProfile{{
+			Name:    "printers",
+			Options: []Option{OptionBytes(uint8(layers.DHCPOptDNS), printersDNS)},
+		}, {
+			Name:    "phones",
+			Options: []Option{OptionBytes(uint8(layers.DHCPOptDNS), phonesDNS)},
+		}},
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		vendorClass string
+		wantDNS     []byte
+		mac         net.HardwareAddr
+	}{{
+		name:        "printers",
+		vendorClass: "printer",
+		wantDNS:     printersDNS,
+		mac:         net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+	}, {
+		name:        "phones",
+		vendorClass: "phone",
+		wantDNS:     phonesDNS,
+		mac:         net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+	}, {
+		name:        "default",
+		vendorClass: "laptop",
+		wantDNS:     []byte{192, 168, 0, 1},
+		mac:         net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x03},
+	}, {
+		name:        "ignored",
+		vendorClass: "intruder",
+		wantDNS:     nil,
+		mac:         net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x04},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vendorClassOpt := layers.NewDHCPOption(layers.DHCPOptClassID, []byte(tc.vendorClass))
+
+			before := calls.Load()
+			offer, hErr := srv.handle4(ifaceName, newTestRequest4(tc.mac, layers.DHCPMsgTypeDiscover, vendorClassOpt))
+			require.NoError(t, hErr)
+
+			assert.Equal(t, before+1, calls.Load())
+
+			if tc.wantDNS == nil {
+				assert.Nil(t, offer)
+
+				return
+			}
+
+			require.NotNil(t, offer)
+
+			assert.Equal(t, tc.wantDNS, optData4(offer, layers.DHCPOptDNS))
+		})
+	}
+}
@@ -1,9 +1,17 @@
 package dhcpsvc
 
 import (
+	"fmt"
+	"math"
+	"net"
 	"net/netip"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/mapsutil"
+	"github.com/AdguardTeam/golibs/netutil"
 	"github.com/google/gopacket/layers"
 )
 
@@ -14,16 +22,397 @@ type Config struct {
 	Interfaces map[string]*InterfaceConfig
 
 	// LocalDomainName is the top-level domain name to use for resolving DHCP
-	// clients' hostnames.
+	// clients' hostnames.  It's matched case-insensitively and may have a
+	// trailing dot.
 	LocalDomainName string
 
+	// ExtraSearchDomains are the domain names for the clients to search after
+	// LocalDomainName.  Those are sent within the DHCPv4 Domain Search and the
+	// DHCPv6 Domain Search List options, unless the options are configured
+	// explicitly.  The duplicates are removed case-insensitively.
+	ExtraSearchDomains []string
+
+	// ReservedHostnames are the hostnames never assigned to the clients
+	// requesting them, so that the clients can't hijack the DNS answers for
+	// the network infrastructure.  Static leases may still have those.  They
+	// are matched case-insensitively.  If nil, the hostname of the machine,
+	// "gateway", and "router" are reserved.
+	ReservedHostnames []string
+
+	// OptionProfiles are the named sets of DHCPv4 options sent to the groups
+	// of clients on every network interface.  The clients belonging to no
+	// profile are sent the options of the network interface only.
+	OptionProfiles []*OptionProfile
+
+	// DBFilePath is the path to the database file containing the DHCP leases.
+	// A relative path is resolved against WorkDir, if it's set.  If empty, the
+	// leases are persisted into "leases.json" within WorkDir, or aren't
+	// persisted at all if WorkDir is also empty.  The directory of the file is
+	// created with mode 0700 if it doesn't exist, and the file itself is
+	// written with mode 0600.
+	DBFilePath string
+
+	// WorkDir is the working directory of AdGuard Home, which is
+	// platform-specific and chosen by the caller.  It's not encoded into JSON.
+	WorkDir string
+
+	// AllowShortLeases lifts the floor of [MinLeaseDuration] for the lease
+	// durations, so that the leases expiring within seconds or less can be
+	// observed.  The expired leases are also swept more often then.  It's
+	// intended for testing and debugging only, so it's not encoded into JSON
+	// and can't be set within a configuration file.  Note that the lease times
+	// sent to the clients are truncated to whole seconds.
+	AllowShortLeases bool
+
+	// Listener is used to open network connections for serving the
+	// interfaces.  If nil, [NetListener] is used.
+	Listener Listener
+
+	// DNSAddrs provides the addresses of the DNS server to advertise to the
+	// clients, unless the corresponding option is configured explicitly.  It's
+	// called on each reply, so the changes take effect immediately.  If nil,
+	// the address of the network interface is advertised.
+	DNSAddrs DNSAddrsFunc
+
+	// StaticResolver is consulted for the fixed IPv4 address of each DHCPv4
+	// client without a lease before allocating a dynamic one.  If nil, the
+	// addresses are always allocated dynamically.
+	StaticResolver StaticResolverFunc
+
+	// Classifier is used to classify DHCPv4 clients to choose the duration of
+	// their leases and the options sent to them, see [IPv4Config.LeaseClasses]
+	// and OptionProfiles.  If nil, the clients are classified only by their
+	// properties.
+	Classifier ClassifierFunc
+
+	// IgnoredClasses are the classes of DHCPv4 clients, as returned by
+	// Classifier, which are never replied.  Those must not be empty.
+	IgnoredClasses []string
+
+	// Vendor is used to resolve the vendors of the clients' network
+	// interfaces.  If nil, the vendors aren't resolved.
+	Vendor VendorFunc
+
+	// MaxLeases is the maximum number of leases stored by the server.  When
+	// it's reached, no new dynamic leases are granted, while the existing ones
+	// may still be renewed and the static ones may still be added.  The expired
+	// dynamic leases aren't counted.  Zero means no limit.
+	MaxLeases uint
+
+	// LeaseJitter is the percentage of the lease duration, within which each
+	// granted lease time is randomized in both directions, so that the clients
+	// leased at once don't renew at once as well.  It must be less than 100.
+	// Zero means the lease times are exact.
+	LeaseJitter uint
+
+	// MaxProbes is the maximum number of candidate addresses probed with
+	// ConflictProber for a single DHCPDISCOVER, see ProbeBudget.  Zero means
+	// the default of 3.
+	MaxProbes uint
+
+	// LeaseQueryRequestors are the IPv4 addresses of the relay agents allowed
+	// to query the leases using DHCPLEASEQUERY.  If empty, the queries aren't
+	// answered.
+	LeaseQueryRequestors []netip.Addr
+
+	// ConflictProber is used to check if the DHCPv4 addresses are in use
+	// before offering them, see [IPv4Config.CheckConflicts].  If nil, the
+	// addresses aren't probed.
+	ConflictProber ConflictProberFunc
+
 	// ICMPTimeout is the timeout for checking another DHCP server's presence.
+	// It's also the timeout for probing an address with ConflictProber, and
+	// the probing is only enabled by default if it's positive.
 	ICMPTimeout time.Duration
 
+	// ProbeBudget is the total time for probing the candidate addresses with
+	// ConflictProber for a single DHCPDISCOVER, so that the offer arrives
+	// before the client retransmits it.  Once it's spent, or MaxProbes
+	// addresses are probed, the first free candidate is offered without
+	// probing.  Zero means the default of 1.5s.
+	ProbeBudget time.Duration
+
+	// OfferTimeout is the time an address offered within DHCPOFFER stays
+	// reserved for the client, which hasn't requested it yet.  Once it's
+	// passed, the address is returned to the pool.  Zero means the default of
+	// 10s.
+	OfferTimeout time.Duration
+
+	// ForceRenewInterval is the minimum interval between the DHCPFORCERENEW
+	// messages sent by [DHCPServer.ForceRenew].  Zero means no limit.
+	ForceRenewInterval time.Duration
+
+	// RenewalUpdateInterval is the minimum interval between the reports of the
+	// renewals of a dynamic DHCPv4 lease, which only extend its expiration
+	// time.  The renewals within it are granted, but the subscribers aren't
+	// notified, and the database is only written within a minute, unless the
+	// lease changes otherwise, the interval passes, or the server is shut
+	// down.  Zero means every renewal is reported.  The renewals changing
+	// nothing are never reported.
+	RenewalUpdateInterval time.Duration
+
+	// ServerPort is the UDP port the DHCPv4 server listens on and replies to
+	// the relay agents on.  Zero means the well-known port 67.  It's only
+	// supposed to be changed for testing or when the traffic is redirected
+	// from the well-known port, so that the server needs no privileges.
+	ServerPort int
+
+	// ClientPort is the UDP port the DHCPv4 clients are replied on.  Zero
+	// means the well-known port 68.  See ServerPort.
+	ClientPort int
+
+	// WrongFamilyMode defines how the messages of the address family disabled
+	// on the network interface are treated.  Those are never replied.
+	WrongFamilyMode WrongFamilyMode
+
+	// SourcePortMode defines how the messages received from unexpected UDP
+	// source ports are treated.
+	SourcePortMode SourcePortMode
+
+	// UnknownClients defines how the DHCPv4 clients having no static lease are
+	// treated.
+	UnknownClients UnknownClientsPolicy
+
+	// ExpiryPolicy defines how the existing dynamic leases are treated when
+	// the lease durations are changed by [DHCPServer.UpdateConfig].
+	ExpiryPolicy ExpiryPolicy
+
+	// Authoritative defines if the server is the only one on the networks of
+	// the interfaces by default, see [InterfaceConfig.Authoritative].
+	Authoritative bool
+
+	// ReleaseOnExit, if true, makes [DHCPServer.Shutdown] mark the database,
+	// so that the next instance of the server treats the dynamic DHCPv4 leases
+	// of this one as unconfirmed.  Those are still resolved, but once the
+	// server is started, their addresses are probed with ConflictProber, and
+	// the ones not in use are released.  It's intended for the short-lived
+	// environments, e.g. containers, where the clients of the previous
+	// instance may be gone.
+	ReleaseOnExit bool
+
 	// Enabled is the state of the service, whether it is enabled or not.
 	Enabled bool
 }
 
+// DNSAddrsFunc returns the addresses of the DNS server to advertise to the
+// clients of the network interface with the given name.  is4 tells if IPv4
+// addresses are requested, the addresses of the other family are ignored.  If
+// addrs is empty, the address of the network interface is advertised.  It's
+// called for each reply with the leases unlocked, and it must be safe for
+// concurrent use.
+type DNSAddrsFunc func(ifaceName string, is4 bool) (addrs []netip.Addr)
+
+// StaticResolverFunc returns the fixed IPv4 address for the client with mac.
+// ok is false if there is no such address, so that the address is allocated
+// dynamically.  The address is validated against the subnet of the network
+// interface at request time.  It's called with the leases unlocked, so that a
+// slow lookup doesn't delay serving other clients, and it must be safe for
+// concurrent use.
+type StaticResolverFunc func(mac net.HardwareAddr) (ip netip.Addr, ok bool)
+
+// Validate returns an error in conf if any.  It only checks conf itself, so
+// its result doesn't depend on the machine, see [Config.ValidateOnHost].
+func (conf *Config) Validate() (err error) {
+	switch {
+	case conf == nil:
+		return errNilConfig
+	case !conf.Enabled:
+		return nil
+	case conf.ICMPTimeout < 0:
+		return newMustErr("icmp timeout", "be non-negative", conf.ICMPTimeout)
+	case conf.ProbeBudget < 0:
+		return newMustErr("probe budget", "be non-negative", conf.ProbeBudget)
+	case conf.OfferTimeout < 0:
+		return newMustErr("offer timeout", "be non-negative", conf.OfferTimeout)
+	case conf.ForceRenewInterval < 0:
+		return newMustErr("force renew interval", "be non-negative", conf.ForceRenewInterval)
+	case conf.RenewalUpdateInterval < 0:
+		return newMustErr("renewal update interval", "be non-negative", conf.RenewalUpdateInterval)
+	case conf.WrongFamilyMode > WrongFamilyModeLog:
+		return newMustErr("wrong family mode", "be either count or log", conf.WrongFamilyMode)
+	case conf.SourcePortMode > SourcePortModeStrict:
+		return newMustErr("source port mode", "be either permissive or strict", conf.SourcePortMode)
+	case conf.UnknownClients > UnknownClientsPolicyDeny:
+		return newMustErr("unknown clients policy", "be either allow or deny", conf.UnknownClients)
+	case conf.ExpiryPolicy > ExpiryPolicyKeep:
+		return newMustErr("expiry policy", "be either cap or keep", conf.ExpiryPolicy)
+	case conf.LeaseJitter >= maxLeaseJitter:
+		return fmt.Errorf("lease jitter %d must be less than %d", conf.LeaseJitter, maxLeaseJitter)
+	case conf.ServerPort < 0 || conf.ServerPort > math.MaxUint16:
+		return fmt.Errorf("server port %d must be in range [0, %d]", conf.ServerPort, math.MaxUint16)
+	case conf.ClientPort < 0 || conf.ClientPort > math.MaxUint16:
+		return fmt.Errorf("client port %d must be in range [0, %d]", conf.ClientPort, math.MaxUint16)
+	}
+
+	if server, client := conf.ports4(); server == client {
+		return fmt.Errorf("server port %d must differ from client port", server)
+	}
+
+	err = netutil.ValidateDomainName(normalizeDomainName(conf.LocalDomainName))
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	for i, name := range conf.ReservedHostnames {
+		err = netutil.ValidateHostname(normalizeDomainName(name))
+		if err != nil {
+			return fmt.Errorf("reserved hostname at index %d: %w", i, err)
+		}
+	}
+
+	for i, class := range conf.IgnoredClasses {
+		if class == "" {
+			return fmt.Errorf("ignored class at index %d: %w", i, errors.Error("empty"))
+		}
+	}
+
+	err = validateProfiles4(conf.OptionProfiles)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	for _, addr := range conf.LeaseQueryRequestors {
+		if !addr.Is4() {
+			return newMustErr("lease query requestor", "be an ipv4 address", addr)
+		}
+	}
+
+	if len(conf.Interfaces) == 0 {
+		return errNoInterfaces
+	}
+
+	err = mapsutil.OrderedRangeError(
+		conf.Interfaces,
+		func(name string, ic *InterfaceConfig) (err error) { return ic.Validate(name) },
+	)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	err = conf.validateLeaseDurations()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	err = conf.validateSearchDomains()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	if _, ok := conf.Listener.(LinkLocalListener); conf.Listener != nil && !ok && conf.bindsLinkLocal() {
+		return errors.Error("listener must be a LinkLocalListener to bind to link-local addresses")
+	}
+
+	conf.warnConflictChecks()
+
+	return nil
+}
+
+// MinLeaseDuration is the minimum duration of a lease allowed, unless
+// [Config.AllowShortLeases] is set.
+const MinLeaseDuration = 1 * time.Minute
+
+// validateLeaseDurations returns an error if any of the lease durations of the
+// enabled address families and the lease classes of conf is less than
+// [MinLeaseDuration], unless conf allows short leases.  The interfaces are
+// expected to be validated.
+func (conf *Config) validateLeaseDurations() (err error) {
+	if conf.AllowShortLeases {
+		return nil
+	}
+
+	return mapsutil.OrderedRangeError(
+		conf.Interfaces,
+		func(name string, ic *InterfaceConfig) (err error) {
+			defer func() { err = errors.Annotate(err, "interface %q: %w", name) }()
+
+			if c4 := ic.IPv4; c4.Enabled {
+				err = validateMinLeaseDuration(c4.LeaseDuration)
+				if err != nil {
+					return fmt.Errorf("ipv4: %w", err)
+				}
+
+				for i, c := range c4.LeaseClasses {
+					err = validateMinLeaseDuration(c.LeaseDuration)
+					if err != nil {
+						return fmt.Errorf("ipv4: lease class at index %d: %w", i, err)
+					}
+				}
+			}
+
+			if c6 := ic.IPv6; c6.Enabled {
+				err = validateMinLeaseDuration(c6.LeaseDuration)
+				if err != nil {
+					return fmt.Errorf("ipv6: %w", err)
+				}
+			}
+
+			return nil
+		},
+	)
+}
+
+// validateMinLeaseDuration returns an error if d is less than
+// [MinLeaseDuration].
+func validateMinLeaseDuration(d time.Duration) (err error) {
+	if d < MinLeaseDuration {
+		return newMustErr("lease duration", "not be less than "+MinLeaseDuration.String(), d)
+	}
+
+	return nil
+}
+
+// shortestLeaseDuration returns the shortest lease duration of the enabled
+// address families and the lease classes of conf.  d is zero if there are
+// none.
+func (conf *Config) shortestLeaseDuration() (d time.Duration) {
+	update := func(ld time.Duration) {
+		if d == 0 || ld < d {
+			d = ld
+		}
+	}
+
+	for _, ic := range conf.Interfaces {
+		if c4 := ic.IPv4; c4 != nil && c4.Enabled {
+			update(c4.LeaseDuration)
+			for _, c := range c4.LeaseClasses {
+				update(c.LeaseDuration)
+			}
+		}
+
+		if c6 := ic.IPv6; c6 != nil && c6.Enabled {
+			update(c6.LeaseDuration)
+		}
+	}
+
+	return d
+}
+
+// validateIfaceName returns an error if name can't be the name of a network
+// interface.  Spaces, quotes, and non-ASCII characters are allowed, since those
+// are common on Windows, e.g. "Ethernet 2", but control characters aren't,
+// since those break the logs and the errors.
+func validateIfaceName(name string) (err error) {
+	if name == "" {
+		return errors.Error("name must not be empty")
+	} else if !utf8.ValidString(name) {
+		return errors.Error("name must be valid utf-8")
+	}
+
+	for i, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("name must not contain control characters, found %q at index %d", r, i)
+		}
+	}
+
+	return nil
+}
+
 // InterfaceConfig is the configuration of a single DHCP interface.
 type InterfaceConfig struct {
 	// IPv4 is the configuration of DHCP protocol for IPv4.
@@ -31,6 +420,61 @@ type InterfaceConfig struct {
 
 	// IPv6 is the configuration of DHCP protocol for IPv6.
 	IPv6 *IPv6Config
+
+	// Authoritative defines if the server is the only one on the network of
+	// the interface.  The authoritative server replies with DHCPNAK to the
+	// DHCPREQUEST messages for the addresses outside of the interface's
+	// network, e.g. from the clients moved from another network, while the
+	// non-authoritative one silently drops those.  If nil,
+	// [Config.Authoritative] is used.
+	Authoritative *bool
+}
+
+// authoritative returns the effective value of ic.Authoritative, which inherits
+// the global value if unset.
+func (ic *InterfaceConfig) authoritative(global bool) (ok bool) {
+	if ic.Authoritative != nil {
+		return *ic.Authoritative
+	}
+
+	return global
+}
+
+// Validate returns an error in ic, if any.  name is the name of the network
+// interface ic is applied to, it's only used to annotate errors.  ic is
+// validated in isolation, so it can be used to check the configuration of a
+// single interface without assembling the whole [Config].
+func (ic *InterfaceConfig) Validate(name string) (err error) {
+	defer func() { err = errors.Annotate(err, "interface %q: %w", name) }()
+
+	err = validateIfaceName(name)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	if ic == nil {
+		return errNilConfig
+	}
+
+	// Check the required fields of the enabled families upfront, since the
+	// errors about the particular fields are confusing for an empty config.
+	switch {
+	case ic.IPv4 != nil && ic.IPv4.Enabled && !ic.IPv4.rangeConfigured():
+		return errNoRange4
+	case ic.IPv6 != nil && ic.IPv6.Enabled && !ic.IPv6.RangeStart.IsValid():
+		return errNoRange6
+	}
+
+	if err = validateV4(ic.IPv4); err != nil {
+		return fmt.Errorf("ipv4: %w", err)
+	}
+
+	if err = validateV6(ic.IPv6); err != nil {
+		return fmt.Errorf("ipv6: %w", err)
+	}
+
+	return nil
 }
 
 // IPv4Config is the interface-specific configuration for DHCPv4.
@@ -41,21 +485,97 @@ type IPv4Config struct {
 	GatewayIP netip.Addr
 
 	// SubnetMask is the IPv4 subnet mask of the network.  It should be a valid
-	// IPv4 subnet mask (i.e. all 1s followed by all 0s).
+	// IPv4 subnet mask (i.e. all 1s followed by all 0s).  It may be omitted if
+	// Subnet is set.
 	SubnetMask netip.Addr
 
+	// Subnet is the IPv4 network of the interface.  If set, it's used instead
+	// of the network derived from GatewayIP and SubnetMask, which must be
+	// consistent with it in that case.
+	Subnet netip.Prefix
+
 	// RangeStart is the first address in the range to assign to DHCP clients.
+	// It's mutually exclusive with RangeStartOffset.
 	RangeStart netip.Addr
 
 	// RangeEnd is the last address in the range to assign to DHCP clients.
+	// It's mutually exclusive with RangeEndOffset.
 	RangeEnd netip.Addr
 
-	// Options is the list of DHCP options to send to DHCP clients.
-	Options layers.DHCPOptions
+	// RangeStartOffset is the offset of the first address in the range from
+	// the network address of the subnet, e.g. 100 for 192.168.0.100 within
+	// 192.168.0.0/24.  It's useful for the configurations shared by the
+	// networks with different subnets.  Zero means it's unset.
+	RangeStartOffset uint32
+
+	// RangeEndOffset is the offset of the last address in the range from the
+	// network address of the subnet, see RangeStartOffset.  Zero means it's
+	// unset.
+	RangeEndOffset uint32
+
+	// Options is the list of DHCP options to send to DHCP clients.  The string
+	// values may contain the "{{ .ServerIP }}" and "{{ .GatewayIP }}"
+	// templates, which are replaced with the respective addresses of the
+	// network interface.
+	Options []Option
+
+	// DHCPOptions is the list of DHCP options sent after Options.
+	//
+	// Deprecated: Use Options.  It will be removed in the next release.
+	DHCPOptions layers.DHCPOptions
+
+	// TimezonePOSIX is the timezone of the clients as the POSIX TZ string, e.g.
+	// "EST5EDT4,M3.2.0/02:00,M11.1.0/02:00".  If set, it's sent within the
+	// PCode option.
+	TimezonePOSIX string
+
+	// TimezoneTZDB is the timezone of the clients as the name of the TZ
+	// database entry, e.g. "Europe/Zurich".  If set, it's sent within the
+	// TCode option.
+	TimezoneTZDB string
+
+	// CiscoTFTPServers are the IPv4 addresses of the TFTP servers for Cisco IP
+	// phones.  If set, those are sent within the TFTP Server Address option,
+	// which is distinct from the TFTP Server Name one.
+	CiscoTFTPServers []netip.Addr
+
+	// LeaseClasses are the classes of clients granted leases with durations
+	// other than LeaseDuration.  The first matching class applies.
+	LeaseClasses []*LeaseClass
+
+	// Netboot is the configuration of network booting of the clients.  It may
+	// be nil.
+	Netboot *NetbootConfig
 
 	// LeaseDuration is the TTL of a DHCP lease.
 	LeaseDuration time.Duration
 
+	// CheckConflicts defines if the addresses should be probed with
+	// [Config.ConflictProber] before being offered.  If nil, those are probed
+	// if [Config.ICMPTimeout] is positive.
+	CheckConflicts *bool
+
+	// MaxHops is the maximum value of the hops field of the requests to
+	// serve, i.e. the number of relay agents those have passed.  The requests
+	// exceeding it are dropped to protect against relay loops.  Zero means
+	// the default of 4 recommended by RFC 1542.  It must not exceed 16.
+	MaxHops uint8
+
+	// AllocDirection defines the order in which the free addresses of the
+	// range are allocated.
+	AllocDirection AllocDirection
+
+	// EchoHostname defines if the effective hostname of the client should be
+	// sent back to it within the Host Name option of acknowledgements.  It's
+	// useful for clients which hostname has been changed by the server, e.g.
+	// normalized or replaced due to a conflict with another client.
+	EchoHostname bool
+
+	// Paused defines if serving DHCPv4 on the interface is paused, see
+	// [DHCPServer.DisableInterface].  The leases of a paused interface are
+	// kept, but no messages are received on it.
+	Paused bool
+
 	// Enabled is the state of the DHCPv4 service, whether it is enabled or not
 	// on the specific interface.
 	Enabled bool
@@ -66,12 +586,22 @@ type IPv6Config struct {
 	// RangeStart is the first address in the range to assign to DHCP clients.
 	RangeStart netip.Addr
 
-	// Options is the list of DHCP options to send to DHCP clients.
-	Options layers.DHCPOptions
+	// Options is the list of DHCPv6 options to send to DHCP clients within
+	// Advertise and Reply messages.  Those override the ones sent by default.
+	// The options controlled by the server, e.g. Server Identifier or IA_NA,
+	// must not be specified.  The options should be created with
+	// [layers.NewDHCPv6Option] to have the correct length.
+	Options layers.DHCPv6Options
 
-	// LeaseDuration is the TTL of a DHCP lease.
+	// LeaseDuration is the TTL of a DHCP lease.  It's the valid lifetime of
+	// the leased addresses.
 	LeaseDuration time.Duration
 
+	// PreferredDuration is the preferred lifetime of the leased addresses,
+	// after which those are deprecated.  It must not exceed LeaseDuration.
+	// Zero means it's equal to LeaseDuration.
+	PreferredDuration time.Duration
+
 	// RASlaacOnly defines whether the DHCP clients should only use SLAAC for
 	// address assignment.
 	RASLAACOnly bool
@@ -80,7 +610,35 @@ type IPv6Config struct {
 	// assignment.
 	RAAllowSLAAC bool
 
+	// RapidCommit defines whether the addresses are committed right upon the
+	// Solicit messages containing the Rapid Commit option, replying with Reply
+	// instead of Advertise.
+	RapidCommit bool
+
+	// Paused defines if serving DHCPv6 on the interface is paused, see
+	// [DHCPServer.DisableInterface].  The leases of a paused interface are
+	// kept, but no messages are received on it.
+	Paused bool
+
 	// Enabled is the state of the DHCPv6 service, whether it is enabled or not
 	// on the specific interface.
 	Enabled bool
+
+	// BindLinkLocal defines if the messages are sent from the link-local
+	// address of the interface, which is discovered on start, and received via
+	// the All_DHCP_Relay_Agents_and_Servers multicast group.  [Config.Listener]
+	// must implement [LinkLocalListener] then.
+	BindLinkLocal bool
+}
+
+// bindsLinkLocal returns true if DHCPv6 is served via the link-local address on
+// at least a single network interface of conf.
+func (conf *Config) bindsLinkLocal() (ok bool) {
+	for _, ic := range conf.Interfaces {
+		if ic.IPv6.Enabled && ic.IPv6.BindLinkLocal {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,717 @@
+package dhcpsvc_test
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	// Each of the domains takes 22 bytes within the search list, so that
+	// together with the local domain name they take 271 bytes.
+	longSearchList := make([]string, 0, 12)
+	for i := 0; i < cap(longSearchList); i++ {
+		longSearchList = append(longSearchList, fmt.Sprintf("search-%x.example.com", i))
+	}
+
+	shortLeaseConf := map[string]*dhcpsvc.InterfaceConfig{
+		"eth0": {
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 200 * time.Millisecond,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+	}
+
+	testCases := []struct {
+		name       string
+		conf       *dhcpsvc.Config
+		wantErrMsg string
+	}{{
+		name:       "nil_config",
+		conf:       nil,
+		wantErrMsg: "config is nil",
+	}, {
+		name:       "disabled",
+		conf:       &dhcpsvc.Config{},
+		wantErrMsg: "",
+	}, {
+		name: "empty",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+		},
+		wantErrMsg: "no interfaces specified",
+	}, {
+		name: "negative_icmp_timeout",
+		conf: &dhcpsvc.Config{
+			Enabled:     true,
+			ICMPTimeout: -1 * time.Second,
+		},
+		wantErrMsg: "icmp timeout -1s must be non-negative",
+	}, {
+		name: "ipv6_lease_query_requestor",
+		conf: &dhcpsvc.Config{
+			Enabled:              true,
+			LocalDomainName:      testLocalTLD,
+			LeaseQueryRequestors: []netip.Addr{netip.MustParseAddr("2001:db8::1")},
+		},
+		wantErrMsg: "lease query requestor 2001:db8::1 must be an ipv4 address",
+	}, {
+		name: "negative_force_renew_interval",
+		conf: &dhcpsvc.Config{
+			Enabled:            true,
+			ForceRenewInterval: -1 * time.Second,
+		},
+		wantErrMsg: "force renew interval -1s must be non-negative",
+	}, {
+		name: "negative_renewal_update_interval",
+		conf: &dhcpsvc.Config{
+			Enabled:               true,
+			RenewalUpdateInterval: -1 * time.Second,
+		},
+		wantErrMsg: "renewal update interval -1s must be non-negative",
+	}, {
+		name: "bad_wrong_family_mode",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			WrongFamilyMode: dhcpsvc.WrongFamilyModeLog + 1,
+		},
+		wantErrMsg: "wrong family mode !bad_wrong_family_mode_2 must be either count or log",
+	}, {
+		name: "bad_reserved_hostname",
+		conf: &dhcpsvc.Config{
+			Enabled:           true,
+			LocalDomainName:   "local",
+			ReservedHostnames: []string{"router", "bad_host"},
+		},
+		wantErrMsg: `reserved hostname at index 1: bad hostname "bad_host": ` +
+			`bad top-level domain name label "bad_host": ` +
+			`bad top-level domain name label rune '_'`,
+	}, {
+		name: "bad_unknown_clients_policy",
+		conf: &dhcpsvc.Config{
+			Enabled:        true,
+			UnknownClients: dhcpsvc.UnknownClientsPolicyDeny + 1,
+		},
+		wantErrMsg: "unknown clients policy !bad_unknown_clients_policy_2 must be either allow or deny",
+	}, {
+		name: "bad_source_port_mode",
+		conf: &dhcpsvc.Config{
+			Enabled:        true,
+			SourcePortMode: dhcpsvc.SourcePortModeStrict + 1,
+		},
+		wantErrMsg: "source port mode !bad_source_port_mode_2 must be either permissive or strict",
+	}, {
+		name: "bad_expiry_policy",
+		conf: &dhcpsvc.Config{
+			Enabled:      true,
+			ExpiryPolicy: dhcpsvc.ExpiryPolicyKeep + 1,
+		},
+		wantErrMsg: "expiry policy !bad_expiry_policy_2 must be either cap or keep",
+	}, {
+		name: "bad_lease_jitter",
+		conf: &dhcpsvc.Config{
+			Enabled:     true,
+			LeaseJitter: 100,
+		},
+		wantErrMsg: "lease jitter 100 must be less than 100",
+	}, {
+		name: "bad_domain",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: "-",
+		},
+		wantErrMsg: `bad domain name "-": bad top-level domain name label "-": ` +
+			`bad top-level domain name label rune '-'`,
+	}, {
+		name: "absent_interface",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": nil,
+			},
+		},
+		wantErrMsg: `interface "eth0": config is nil`,
+	}, {
+		name: "empty_interface",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv4: config is nil`,
+	}, {
+		name: "empty_ipv4",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{Enabled: true},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv4 enabled but range not configured`,
+	}, {
+		name: "bad_gateway",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{
+						Enabled:    true,
+						RangeStart: netip.MustParseAddr("192.168.0.2"),
+						RangeEnd:   netip.MustParseAddr("192.168.0.254"),
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv4: gateway ip invalid IP must be a valid ipv4`,
+	}, {
+		name: "bad_subnet_mask",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{
+						Enabled:       true,
+						GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+						SubnetMask:    netip.MustParseAddr("255.0.255.0"),
+						RangeStart:    netip.MustParseAddr("192.168.0.2"),
+						RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+						LeaseDuration: 1 * time.Hour,
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv4: subnet mask 255.0.255.0 must be a valid ` +
+			`ipv4 cidr mask`,
+	}, {
+		name: "bad_lease_class",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{
+						Enabled:       true,
+						GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+						SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+						RangeStart:    netip.MustParseAddr("192.168.0.2"),
+						RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+						LeaseDuration: 1 * time.Hour,
+						LeaseClasses: []*dhcpsvc.LeaseClass{{
+							Name: "guest",
+						}},
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv4: lease class at index 0: lease duration ` +
+			`0s must be positive`,
+	}, {
+		name: "empty_netboot_rules",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{
+						Enabled:       true,
+						GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+						SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+						RangeStart:    netip.MustParseAddr("192.168.0.2"),
+						RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+						LeaseDuration: 1 * time.Hour,
+						Netboot:       &dhcpsvc.NetbootConfig{Enabled: true},
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv4: no netboot rules`,
+	}, {
+		name: "empty_ipv6",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+					IPv6: &dhcpsvc.IPv6Config{Enabled: true},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv6 enabled but range not configured`,
+	}, {
+		name: "forbidden_ipv6_option",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+					IPv6: &dhcpsvc.IPv6Config{
+						Enabled:       true,
+						RangeStart:    netip.MustParseAddr("2001:db8::1"),
+						LeaseDuration: 1 * time.Hour,
+						Options: layers.DHCPv6Options{
+							layers.NewDHCPv6Option(layers.DHCPv6OptServerID, []byte{1}),
+						},
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv6: option at index 0: ServerID is controlled ` +
+			`by the server`,
+	}, {
+		name: "bad_ipv6_option_length",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+					IPv6: &dhcpsvc.IPv6Config{
+						Enabled:       true,
+						RangeStart:    netip.MustParseAddr("2001:db8::1"),
+						LeaseDuration: 1 * time.Hour,
+						Options: layers.DHCPv6Options{{
+							Code: layers.DHCPv6OptSNTPServers,
+							Data: []byte("ntp.example"),
+						}},
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv6: option at index 0: length 0 must be equal ` +
+			`to the data length 11, consider using layers.NewDHCPv6Option`,
+	}, {
+		name: "bad_ipv6_lease_duration",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+					IPv6: &dhcpsvc.IPv6Config{
+						Enabled:    true,
+						RangeStart: netip.MustParseAddr("2001:db8::1"),
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv6: lease duration 0s must be positive`,
+	}, {
+		name: "bad_ipv6_preferred_duration",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+					IPv6: &dhcpsvc.IPv6Config{
+						Enabled:           true,
+						RangeStart:        netip.MustParseAddr("2001:db8::1"),
+						LeaseDuration:     time.Hour,
+						PreferredDuration: 2 * time.Hour,
+					},
+				},
+			},
+		},
+		wantErrMsg: `interface "eth0": ipv6: preferred duration 2h0m0s must ` +
+			`not exceed lease duration 1h0m0s`,
+	}, {
+		name: "bad_extra_search_domain",
+		conf: &dhcpsvc.Config{
+			Enabled:            true,
+			LocalDomainName:    testLocalTLD,
+			ExtraSearchDomains: []string{"example.com", "-"},
+			Interfaces:         testInterfaceConf,
+		},
+		wantErrMsg: `extra search domain at index 1: bad domain name "-": ` +
+			`bad top-level domain name label "-": bad top-level domain name label rune '-'`,
+	}, {
+		name: "empty_ignored_class",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			IgnoredClasses:  []string{"blocked", ""},
+			Interfaces:      testInterfaceConf,
+		},
+		wantErrMsg: "ignored class at index 1: empty",
+	}, {
+		name: "search_list_too_long",
+		conf: &dhcpsvc.Config{
+			Enabled:            true,
+			LocalDomainName:    testLocalTLD,
+			ExtraSearchDomains: longSearchList,
+			Interfaces:         testInterfaceConf,
+		},
+		wantErrMsg: "search domains: dhcpv4 option length 271 must not exceed 255",
+	}, {
+		name: "bad_server_port",
+		conf: &dhcpsvc.Config{
+			Enabled:    true,
+			ServerPort: 65_536,
+		},
+		wantErrMsg: "server port 65536 must be in range [0, 65535]",
+	}, {
+		name: "negative_client_port",
+		conf: &dhcpsvc.Config{
+			Enabled:    true,
+			ClientPort: -1,
+		},
+		wantErrMsg: "client port -1 must be in range [0, 65535]",
+	}, {
+		name: "same_ports",
+		conf: &dhcpsvc.Config{
+			Enabled:    true,
+			ServerPort: 68,
+		},
+		wantErrMsg: "server port 68 must differ from client port",
+	}, {
+		name: "short_lease_duration",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces:      shortLeaseConf,
+		},
+		wantErrMsg: `interface "eth0": ipv4: lease duration 200ms must not be less than 1m0s`,
+	}, {
+		name: "short_lease_duration_allowed",
+		conf: &dhcpsvc.Config{
+			Enabled:          true,
+			LocalDomainName:  testLocalTLD,
+			Interfaces:       shortLeaseConf,
+			AllowShortLeases: true,
+		},
+		wantErrMsg: "",
+	}, {
+		name: "valid",
+		conf: &dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: testLocalTLD,
+			Interfaces:      testInterfaceConf,
+		},
+		wantErrMsg: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.conf.Validate())
+		})
+	}
+}
+
+func TestInterfaceConfig_Validate(t *testing.T) {
+	const ifaceName = "eth0"
+
+	testCases := []struct {
+		conf       *dhcpsvc.InterfaceConfig
+		name       string
+		wantErrMsg string
+	}{{
+		conf:       nil,
+		name:       "nil",
+		wantErrMsg: `interface "eth0": config is nil`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "disabled",
+		wantErrMsg: "",
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: nil,
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "nil_ipv4",
+		wantErrMsg: `interface "eth0": ipv4: config is nil`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+			IPv6: nil,
+		},
+		name:       "nil_ipv6",
+		wantErrMsg: `interface "eth0": ipv6: config is nil`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 0,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "bad_ipv4_lease_duration",
+		wantErrMsg: `interface "eth0": ipv4: lease duration 0s must be positive`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+				MaxHops:       17,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "bad_ipv4_max_hops",
+		wantErrMsg: `interface "eth0": ipv4: max hops 17 must not exceed 16`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:        true,
+				GatewayIP:      netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:     netip.MustParseAddr("255.255.255.0"),
+				RangeStart:     netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:       netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration:  1 * time.Hour,
+				AllocDirection: dhcpsvc.AllocDirectionDescending + 1,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name: "bad_ipv4_alloc_direction",
+		wantErrMsg: `interface "eth0": ipv4: alloc direction !bad_alloc_direction_2 ` +
+			`must be either ascending or descending`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+			IPv6: &dhcpsvc.IPv6Config{
+				Enabled:       true,
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				LeaseDuration: 1 * time.Hour,
+			},
+		},
+		name:       "bad_ipv6_range_start",
+		wantErrMsg: `interface "eth0": ipv6: range start 192.168.0.2 must be a valid ipv6`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.0"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name: "gateway_is_network",
+		wantErrMsg: `interface "eth0": ipv4: gateway ip 192.168.0.0 must not be ` +
+			`the network address of 192.168.0.0/24`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.255"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name: "gateway_is_broadcast",
+		wantErrMsg: `interface "eth0": ipv4: gateway ip 192.168.0.255 must not be ` +
+			`the broadcast address of 192.168.0.0/24`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.1.1"),
+				Subnet:        netip.MustParsePrefix("192.168.0.0/24"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name: "gateway_outside_subnet",
+		wantErrMsg: `interface "eth0": ipv4: gateway ip 192.168.1.1 must be ` +
+			`within 192.168.0.0/24`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.0.0"),
+				Subnet:        netip.MustParsePrefix("192.168.0.0/24"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name: "subnet_mask_mismatch",
+		wantErrMsg: `interface "eth0": ipv4: subnet mask 255.255.0.0 must match ` +
+			`subnet 192.168.0.0/24`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				Subnet:        netip.MustParsePrefix("192.168.0.0/24"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "valid_subnet",
+		wantErrMsg: "",
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				TimezonePOSIX: " ",
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "blank_timezone_posix",
+		wantErrMsg: `interface "eth0": ipv4: timezone posix " " must not be blank`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				TimezoneTZDB:  "\t",
+				LeaseDuration: 1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "blank_timezone_tzdb",
+		wantErrMsg: `interface "eth0": ipv4: timezone tzdb "\t" must not be blank`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:       true,
+				GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+				RangeStart:    netip.MustParseAddr("192.168.0.2"),
+				RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+				LeaseDuration: 1 * time.Hour,
+				CiscoTFTPServers: []netip.Addr{
+					netip.MustParseAddr("192.168.0.10"),
+					netip.MustParseAddr("2001:db8::10"),
+				},
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "bad_cisco_tftp_server",
+		wantErrMsg: `interface "eth0": ipv4: cisco tftp server 2001:db8::10 must be a valid ipv4`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:          true,
+				GatewayIP:        netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:       netip.MustParseAddr("255.255.255.0"),
+				RangeStart:       netip.MustParseAddr("192.168.0.2"),
+				RangeStartOffset: 2,
+				RangeEndOffset:   254,
+				LeaseDuration:    1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name: "range_start_and_offset",
+		wantErrMsg: `interface "eth0": ipv4: range start and range start offset ` +
+			`are mutually exclusive`,
+	}, {
+		conf: &dhcpsvc.InterfaceConfig{
+			IPv4: &dhcpsvc.IPv4Config{
+				Enabled:          true,
+				GatewayIP:        netip.MustParseAddr("192.168.0.1"),
+				SubnetMask:       netip.MustParseAddr("255.255.255.0"),
+				RangeStartOffset: 2,
+				LeaseDuration:    1 * time.Hour,
+			},
+			IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+		},
+		name:       "no_range_end",
+		wantErrMsg: `interface "eth0": ipv4 enabled but range not configured`,
+	}, {
+		conf:       testInterfaceConf["eth0"],
+		name:       "valid",
+		wantErrMsg: "",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.conf.Validate(ifaceName))
+		})
+	}
+}
+
+func TestInterfaceConfig_Validate_name(t *testing.T) {
+	conf := &dhcpsvc.InterfaceConfig{
+		IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+		IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+	}
+
+	testCases := []struct {
+		name       string
+		ifaceName  string
+		wantErrMsg string
+	}{{
+		name:       "space",
+		ifaceName:  "wlan0 guest",
+		wantErrMsg: "",
+	}, {
+		name:       "quote",
+		ifaceName:  `eth "0"`,
+		wantErrMsg: "",
+	}, {
+		name:       "unicode",
+		ifaceName:  "Подключение 2",
+		wantErrMsg: "",
+	}, {
+		name:       "empty",
+		ifaceName:  "",
+		wantErrMsg: `interface "": name must not be empty`,
+	}, {
+		name:      "control",
+		ifaceName: "eth\t0",
+		wantErrMsg: `interface "eth\t0": name must not contain control characters, ` +
+			`found '\t' at index 3`,
+	}, {
+		name:       "bad_utf8",
+		ifaceName:  "eth\xff",
+		wantErrMsg: `interface "eth\xff": name must be valid utf-8`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, conf.Validate(tc.ifaceName))
+		})
+	}
+}
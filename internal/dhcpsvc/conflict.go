@@ -0,0 +1,197 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/mapsutil"
+)
+
+// ConflictProberFunc checks if ip is already in use on the network interface
+// with the given name, e.g. by sending an ICMP Echo Request or an ARP request.
+// It should return once ctx is done.  It must be safe for concurrent use.
+type ConflictProberFunc func(ctx context.Context, ifaceName string, ip netip.Addr) (inUse bool, err error)
+
+// checkConflicts returns the effective value of conf.CheckConflicts, which
+// inherits the global behavior, i.e. probing with a positive icmpTimeout, if
+// unset.
+func (conf *IPv4Config) checkConflicts(icmpTimeout time.Duration) (ok bool) {
+	if conf.CheckConflicts != nil {
+		return *conf.CheckConflicts
+	}
+
+	return icmpTimeout > 0
+}
+
+// warnConflictChecks logs a warning for every network interface of conf, which
+// explicitly enables conflict checking with no time for it.
+func (conf *Config) warnConflictChecks() {
+	if conf.ICMPTimeout > 0 {
+		return
+	}
+
+	mapsutil.OrderedRange(conf.Interfaces, func(name string, ic *InterfaceConfig) (cont bool) {
+		if c := ic.IPv4; c.Enabled && c.CheckConflicts != nil && *c.CheckConflicts {
+			log.Info(
+				"dhcpsvc: warning: interface %q: conflict checking enabled, but icmp timeout is zero",
+				name,
+			)
+		}
+
+		return true
+	})
+}
+
+const (
+	// defaultProbeBudget is the default total time for probing the candidate
+	// addresses for a single DHCPDISCOVER, see [Config.ProbeBudget].  It's
+	// less than the first retransmission delay of the clients.
+	defaultProbeBudget = 1500 * time.Millisecond
+
+	// defaultMaxProbes is the default maximum number of candidate addresses
+	// probed for a single DHCPDISCOVER, see [Config.MaxProbes].
+	defaultMaxProbes uint = 3
+)
+
+// probeBudget limits the probing of the candidate addresses for a single
+// DHCPDISCOVER.  It's not safe for concurrent use.
+type probeBudget struct {
+	// ctx is done once the time for probing is spent.
+	ctx context.Context
+
+	// left is the number of probes left.
+	left uint
+
+	// exceeded is true if the budget has been found exceeded.
+	exceeded bool
+}
+
+// newProbeBudget returns a new budget for probing the candidate addresses for a
+// single DHCPDISCOVER according to srv's configuration.  cancel must be called
+// once the candidate is chosen.
+func (srv *DHCPServer) newProbeBudget() (b *probeBudget, cancel context.CancelFunc) {
+	timeout := srv.conf.ProbeBudget
+	if timeout == 0 {
+		timeout = defaultProbeBudget
+	}
+
+	maxProbes := srv.conf.MaxProbes
+	if maxProbes == 0 {
+		maxProbes = defaultMaxProbes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+
+	return &probeBudget{
+		ctx:  ctx,
+		left: maxProbes,
+	}, cancel
+}
+
+// take returns true if another address may be probed within b and accounts for
+// it.
+func (b *probeBudget) take() (ok bool) {
+	if b.exceeded || b.left == 0 || b.ctx.Err() != nil {
+		b.exceeded = true
+
+		return false
+	}
+
+	b.left--
+
+	return true
+}
+
+// addrInUse4 returns true if ip is found in use on the network of iface.  The
+// probing errors are logged and treated as if ip isn't in use.  Once budget is
+// exceeded, ip isn't probed, so it's offered as is.
+func (srv *DHCPServer) addrInUse4(iface *iface4, ip netip.Addr, budget *probeBudget) (ok bool) {
+	if !iface.checkConflicts {
+		return false
+	} else if !budget.take() {
+		srv.probeFallback(iface, ip)
+
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(budget.ctx, srv.conf.ICMPTimeout)
+	defer cancel()
+
+	ok, err := srv.conf.ConflictProber(ctx, iface.name, ip)
+	if err != nil {
+		if budget.ctx.Err() != nil {
+			// The probing has been cut short by the budget.
+			budget.exceeded = true
+			srv.probeFallback(iface, ip)
+		} else {
+			log.Debug("dhcpsvc: interface %q: probing %s: %s", iface.name, ip, err)
+		}
+
+		return false
+	} else if ok {
+		log.Info("dhcpsvc: warning: interface %q: address %s is in use", iface.name, ip)
+	}
+
+	return ok
+}
+
+// probeFallback counts and logs offering ip on iface without probing due to the
+// exceeded probe budget.
+func (srv *DHCPServer) probeFallback(iface *iface4, ip netip.Addr) {
+	srv.probeFallbacks.Add(1)
+
+	log.Info(
+		"dhcpsvc: interface %q: probe budget exceeded, offering %s without probing",
+		iface.name,
+		ip,
+	)
+}
+
+// EffectiveInterfaceConfig is the configuration of a network interface served
+// by [DHCPServer] with the inherited properties resolved.
+type EffectiveInterfaceConfig struct {
+	// LeaseDuration4 is the current default duration of DHCPv4 leases.  It's
+	// zero if DHCPv4 isn't served on the interface.
+	LeaseDuration4 time.Duration
+
+	// LeaseDuration6 is the current duration of DHCPv6 leases.  It's zero if
+	// DHCPv6 isn't served on the interface.
+	LeaseDuration6 time.Duration
+
+	// CheckConflicts is true if the DHCPv4 addresses are probed before being
+	// offered.
+	CheckConflicts bool
+
+	// Authoritative is true if the DHCPv4 requests for the addresses outside
+	// of the interface's network are rejected instead of being dropped.
+	Authoritative bool
+}
+
+// EffectiveInterfaceConfig returns the effective configuration of the network
+// interface with the given name.
+func (srv *DHCPServer) EffectiveInterfaceConfig(ifaceName string) (conf *EffectiveInterfaceConfig, err error) {
+	iface4, iface6 := srv.iface4ByName(ifaceName), srv.iface6ByName(ifaceName)
+	if iface4 == nil && iface6 == nil {
+		return nil, fmt.Errorf("no interface %q", ifaceName)
+	}
+
+	conf = &EffectiveInterfaceConfig{}
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	if iface4 != nil {
+		conf.LeaseDuration4 = iface4.leaseTTL
+		conf.CheckConflicts = iface4.checkConflicts
+		conf.Authoritative = iface4.authoritative
+	}
+
+	if iface6 != nil {
+		conf.LeaseDuration6 = iface6.leaseTTL
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,262 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handle4_checkConflicts(t *testing.T) {
+	const ifaceName = "eth0"
+
+	inUse := netip.MustParseAddr("192.168.0.2")
+	free := netip.MustParseAddr("192.168.0.3")
+
+	boolPtr := func(v bool) (p *bool) { return &v }
+
+	testCases := []struct {
+		checkConflicts *bool
+		name           string
+		wantProbed     []netip.Addr
+		wantOffered    netip.Addr
+		icmpTimeout    time.Duration
+		wantEffective  bool
+	}{{
+		checkConflicts: nil,
+		name:           "inherit_enabled",
+		wantProbed:     []netip.Addr{inUse, free},
+		wantOffered:    free,
+		icmpTimeout:    time.Second,
+		wantEffective:  true,
+	}, {
+		checkConflicts: nil,
+		name:           "inherit_disabled",
+		wantProbed:     nil,
+		wantOffered:    inUse,
+		icmpTimeout:    0,
+		wantEffective:  false,
+	}, {
+		checkConflicts: boolPtr(false),
+		name:           "forced_off",
+		wantProbed:     nil,
+		wantOffered:    inUse,
+		icmpTimeout:    time.Second,
+		wantEffective:  false,
+	}, {
+		checkConflicts: boolPtr(true),
+		name:           "forced_on",
+		wantProbed:     []netip.Addr{inUse, free},
+		wantOffered:    free,
+		icmpTimeout:    0,
+		wantEffective:  true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu := &sync.Mutex{}
+			var probed []netip.Addr
+			prober := func(_ context.Context, name string, ip netip.Addr) (ok bool, err error) {
+				assert.Equal(t, ifaceName, name)
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				probed = append(probed, ip)
+
+				return ip == inUse, nil
+			}
+
+			conf := newTestIPv4Config()
+			conf.CheckConflicts = tc.checkConflicts
+
+			srv, err := New(&Config{
+				Enabled:         true,
+				LocalDomainName: "local",
+				ConflictProber:  prober,
+				ICMPTimeout:     tc.icmpTimeout,
+				Interfaces: map[string]*InterfaceConfig{
+					ifaceName: {
+						IPv4: conf,
+						IPv6: &IPv6Config{Enabled: false},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			effective, err := srv.EffectiveInterfaceConfig(ifaceName)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantEffective, effective.CheckConflicts)
+
+			mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+			offer, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+			require.NoError(t, err)
+			require.NotNil(t, offer)
+
+			offered, _ := netip.AddrFromSlice(offer.YourClientIP.To4())
+			assert.Equal(t, tc.wantOffered, offered)
+			assert.Equal(t, tc.wantProbed, probed)
+		})
+	}
+}
+
+func TestDHCPServer_handle4_probeUnlocked(t *testing.T) {
+	const ifaceName = "eth0"
+
+	taken := netip.MustParseAddr("192.168.0.2")
+	free := netip.MustParseAddr("192.168.0.3")
+
+	var srv *DHCPServer
+	var probed []netip.Addr
+
+	// prober finds no address in use, but the first one is quarantined while
+	// it's being probed, which requires the leases to be unlocked.
+	prober := func(_ context.Context, _ string, ip netip.Addr) (ok bool, err error) {
+		probed = append(probed, ip)
+		if ip == taken {
+			require.NoError(t, srv.Quarantine(ip, time.Hour))
+		}
+
+		return false, nil
+	}
+
+	conf := newTestIPv4Config()
+	checkConflicts := true
+	conf.CheckConflicts = &checkConflicts
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		ConflictProber:  prober,
+		ICMPTimeout:     time.Second,
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	offer, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+	require.NoError(t, err)
+	require.NotNil(t, offer)
+
+	offered, _ := netip.AddrFromSlice(offer.YourClientIP.To4())
+	assert.Equal(t, free, offered)
+	assert.Equal(t, []netip.Addr{taken, free}, probed)
+}
+
+func TestDHCPServer_handle4_probeBudget(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+
+		icmpTimeout = 1 * time.Second
+		probeDelay  = 60 * time.Millisecond
+		probeBudget = 100 * time.Millisecond
+	)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	testCases := []struct {
+		name        string
+		wantProbed  []netip.Addr
+		wantOffered netip.Addr
+		maxProbes   uint
+		delay       time.Duration
+	}{{
+		name: "max_probes",
+		wantProbed: []netip.Addr{
+			netip.MustParseAddr("192.168.0.2"),
+			netip.MustParseAddr("192.168.0.3"),
+		},
+		wantOffered: netip.MustParseAddr("192.168.0.4"),
+		maxProbes:   2,
+		delay:       0,
+	}, {
+		name: "time",
+		wantProbed: []netip.Addr{
+			netip.MustParseAddr("192.168.0.2"),
+			netip.MustParseAddr("192.168.0.3"),
+		},
+		wantOffered: netip.MustParseAddr("192.168.0.3"),
+		maxProbes:   10,
+		delay:       probeDelay,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu := &sync.Mutex{}
+			var probed []netip.Addr
+
+			// prober finds every address in use after the delay.
+			prober := func(ctx context.Context, _ string, ip netip.Addr) (ok bool, err error) {
+				mu.Lock()
+				probed = append(probed, ip)
+				mu.Unlock()
+
+				select {
+				case <-ctx.Done():
+					return false, ctx.Err()
+				case <-time.After(tc.delay):
+					return true, nil
+				}
+			}
+
+			conf := newTestIPv4Config()
+			checkConflicts := true
+			conf.CheckConflicts = &checkConflicts
+
+			srv, err := New(&Config{
+				Enabled:         true,
+				LocalDomainName: "local",
+				ConflictProber:  prober,
+				ICMPTimeout:     icmpTimeout,
+				ProbeBudget:     probeBudget,
+				MaxProbes:       tc.maxProbes,
+				Interfaces: map[string]*InterfaceConfig{
+					ifaceName: {
+						IPv4: conf,
+						IPv6: &IPv6Config{Enabled: false},
+					},
+				},
+			})
+			require.NoError(t, err)
+
+			start := time.Now()
+			offer, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+			require.NoError(t, err)
+			require.NotNil(t, offer)
+
+			assert.Less(t, time.Since(start), icmpTimeout)
+
+			offered, _ := netip.AddrFromSlice(offer.YourClientIP.To4())
+			assert.Equal(t, tc.wantOffered, offered)
+			assert.Equal(t, tc.wantProbed, probed)
+			assert.Equal(t, uint64(1), srv.Stats().ProbeFallbacks)
+		})
+	}
+}
+
+func TestDHCPServer_EffectiveInterfaceConfig(t *testing.T) {
+	srv := newTestServer6(t, netip.MustParseAddr("2001:db8::1"))
+
+	conf, err := srv.EffectiveInterfaceConfig("eth0")
+	require.NoError(t, err)
+
+	assert.Equal(t, &EffectiveInterfaceConfig{
+		LeaseDuration6: 1 * time.Hour,
+	}, conf)
+
+	_, err = srv.EffectiveInterfaceConfig("eth1")
+	testutil.AssertErrorMsg(t, `no interface "eth1"`, err)
+}
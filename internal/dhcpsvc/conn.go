@@ -0,0 +1,101 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// Well-known ports of DHCP.
+const (
+	// serverPort4 is the port DHCPv4 servers listen on.
+	serverPort4 = 67
+
+	// clientPort4 is the port DHCPv4 clients listen on.
+	clientPort4 = 68
+
+	// clientPort6 is the port DHCPv6 clients listen on.
+	clientPort6 = 546
+
+	// serverPort6 is the port DHCPv6 servers and relay agents listen on.
+	serverPort6 = 547
+)
+
+// ports4 returns the DHCPv4 ports of the server and the clients, see
+// [Config.ServerPort] and [Config.ClientPort].
+func (conf *Config) ports4() (server, client uint16) {
+	server, client = serverPort4, clientPort4
+	if conf.ServerPort != 0 {
+		server = uint16(conf.ServerPort)
+	}
+
+	if conf.ClientPort != 0 {
+		client = uint16(conf.ClientPort)
+	}
+
+	return server, client
+}
+
+// Listener opens network connections for the DHCP server.
+type Listener interface {
+	// ListenPacket returns a new connection bound to the network interface
+	// with the given name and listening on laddr.
+	ListenPacket(ctx context.Context, ifaceName string, laddr netip.AddrPort) (conn net.PacketConn, err error)
+}
+
+// allDHCPAgents6 is the All_DHCP_Relay_Agents_and_Servers multicast address,
+// which DHCPv6 clients send their messages to.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-7.1.
+var allDHCPAgents6 = netip.MustParseAddr("ff02::1:2")
+
+// LinkLocalListener is a [Listener], which is also able to open connections
+// for serving DHCPv6 via the link-local address of a network interface.
+type LinkLocalListener interface {
+	Listener
+
+	// ListenLinkLocal returns a new connection bound to the network interface
+	// with the given name, listening on port, receiving the messages sent to
+	// the multicast group, and sending the messages from the link-local
+	// address of the interface.  laddr is that link-local address, which is
+	// discovered on each call.
+	ListenLinkLocal(
+		ctx context.Context,
+		ifaceName string,
+		group netip.Addr,
+		port uint16,
+	) (conn net.PacketConn, laddr netip.Addr, err error)
+}
+
+// NetListener is the [Listener] using the network stack of the operating
+// system.  Connections are bound to the network interface on Linux only.
+//
+// TODO(e.burkov):  Bind to network interfaces on other platforms.
+type NetListener struct{}
+
+// type check
+var _ LinkLocalListener = NetListener{}
+
+// ListenPacket implements the [Listener] interface for NetListener.
+func (NetListener) ListenPacket(
+	ctx context.Context,
+	ifaceName string,
+	laddr netip.AddrPort,
+) (conn net.PacketConn, err error) {
+	network := "udp6"
+	if laddr.Addr().Is4() {
+		network = "udp4"
+	}
+
+	lc := &net.ListenConfig{
+		Control: newControlFunc(ifaceName),
+	}
+
+	conn, err = lc.ListenPacket(ctx, network, laddr.String())
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s at %q: %w", laddr, ifaceName, err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,257 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLinkLocalListener is a [LinkLocalListener] for tests.
+type testLinkLocalListener struct {
+	// testListener is embedded here to implement the [Listener] interface.
+	testListener
+
+	// onListenLinkLocal is called by ListenLinkLocal.
+	onListenLinkLocal func(
+		ctx context.Context,
+		ifaceName string,
+		group netip.Addr,
+		port uint16,
+	) (conn net.PacketConn, laddr netip.Addr, err error)
+}
+
+// type check
+var _ LinkLocalListener = (*testLinkLocalListener)(nil)
+
+// ListenLinkLocal implements the [LinkLocalListener] interface for
+// *testLinkLocalListener.
+func (l *testLinkLocalListener) ListenLinkLocal(
+	ctx context.Context,
+	ifaceName string,
+	group netip.Addr,
+	port uint16,
+) (conn net.PacketConn, laddr netip.Addr, err error) {
+	return l.onListenLinkLocal(ctx, ifaceName, group, port)
+}
+
+// newLinkLocalTestConfig returns a new configuration of a DHCP server serving
+// DHCPv6 on "eth0" via the link-local address using l.
+func newLinkLocalTestConfig(l Listener) (conf *Config) {
+	return &Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Listener:        l,
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: &IPv4Config{Enabled: false},
+				IPv6: &IPv6Config{
+					Enabled:       true,
+					RangeStart:    netip.MustParseAddr("2001:db8::1"),
+					LeaseDuration: 1 * time.Hour,
+					BindLinkLocal: true,
+				},
+			},
+		},
+	}
+}
+
+func TestDHCPServer_Start_linkLocal(t *testing.T) {
+	linkLocal := netip.MustParseAddr("fe80::1%eth0")
+	clientAddr := &net.UDPAddr{IP: net.ParseIP("fe80::2"), Port: 546, Zone: "eth0"}
+
+	reads := make(chan *testRead, 1)
+	writes := make(chan net.Addr, 1)
+	closed := make(chan struct{})
+	conn := &fakenet.PacketConn{
+		OnClose: func() (err error) {
+			close(closed)
+
+			return nil
+		},
+		OnLocalAddr: func() (addr net.Addr) {
+			return net.UDPAddrFromAddrPort(netip.AddrPortFrom(linkLocal, serverPort6))
+		},
+		OnReadFrom: func(b []byte) (n int, addr net.Addr, err error) {
+			select {
+			case r := <-reads:
+				return copy(b, r.data), r.from, nil
+			case <-closed:
+				return 0, nil, net.ErrClosed
+			}
+		},
+		OnWriteTo: func(b []byte, addr net.Addr) (n int, err error) {
+			writes <- addr
+
+			return len(b), nil
+		},
+	}
+
+	var gotGroup netip.Addr
+	var gotPort uint16
+	l := &testLinkLocalListener{
+		testListener: newTestListener(nil, nil),
+		onListenLinkLocal: func(
+			_ context.Context,
+			ifaceName string,
+			group netip.Addr,
+			port uint16,
+		) (c net.PacketConn, laddr netip.Addr, err error) {
+			require.Equal(t, "eth0", ifaceName)
+			gotGroup, gotPort = group, port
+
+			return conn, linkLocal, nil
+		},
+	}
+
+	srv, err := New(newLinkLocalTestConfig(l))
+	require.NoError(t, err)
+
+	startTestServer(t, srv)
+
+	assert.Equal(t, allDHCPAgents6, gotGroup)
+	assert.Equal(t, uint16(serverPort6), gotPort)
+
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	buf := gopacket.NewSerializeBuffer()
+	err = gopacket.SerializeLayers(
+		buf,
+		gopacket.SerializeOptions{FixLengths: true},
+		newTestRequest6(layers.DHCPv6MsgTypeSolicit, duid, 1),
+	)
+	require.NoError(t, err)
+
+	reads <- &testRead{
+		from: clientAddr,
+		data: buf.Bytes(),
+	}
+
+	to, _ := testutil.RequireReceive(t, writes, time.Second)
+	assert.Equal(t, clientAddr, to)
+}
+
+func TestConfig_Validate_linkLocal(t *testing.T) {
+	conf := newLinkLocalTestConfig(newTestListener(nil, nil))
+	err := conf.Validate()
+	testutil.AssertErrorMsg(
+		t,
+		"listener must be a LinkLocalListener to bind to link-local addresses",
+		err,
+	)
+
+	conf.Listener = nil
+	require.NoError(t, conf.Validate())
+}
+
+func TestDHCPServer_Start_ports(t *testing.T) {
+	const (
+		serverPort = 10_067
+		clientPort = 10_068
+	)
+
+	reads := make(chan *testRead, 1)
+	writes := make(chan *testWrite, 1)
+
+	var gotLAddr netip.AddrPort
+	l := testListener(func(
+		_ context.Context,
+		_ string,
+		laddr netip.AddrPort,
+	) (conn net.PacketConn, err error) {
+		// The connection handling DHCPv6 messages as the wrong family ones
+		// never receives anything.
+		var connReads chan *testRead
+		if laddr.Addr().Is4() {
+			gotLAddr, connReads = laddr, reads
+		}
+
+		closed := make(chan struct{})
+
+		return &fakenet.PacketConn{
+			OnClose: func() (err error) {
+				close(closed)
+
+				return nil
+			},
+			OnLocalAddr: func() (addr net.Addr) { return net.UDPAddrFromAddrPort(laddr) },
+			OnReadFrom: func(b []byte) (n int, addr net.Addr, err error) {
+				select {
+				case r := <-connReads:
+					return copy(b, r.data), r.from, nil
+				case <-closed:
+					return 0, nil, net.ErrClosed
+				}
+			},
+			OnWriteTo: func(b []byte, addr net.Addr) (n int, err error) {
+				writes <- &testWrite{to: addr, data: append([]byte{}, b...)}
+
+				return len(b), nil
+			},
+		}, nil
+	})
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Listener:        l,
+		ServerPort:      serverPort,
+		ClientPort:      clientPort,
+		SourcePortMode:  SourcePortModeStrict,
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	startTestServer(t, srv)
+
+	assert.Equal(t, netip.AddrPortFrom(netip.IPv4Unspecified(), serverPort), gotLAddr)
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	from := &net.UDPAddr{IP: net.IPv4zero, Port: clientPort}
+	wantTo := &net.UDPAddr{IP: net.IPv4bcast, Port: clientPort}
+
+	var offered netip.Addr
+	for _, tc := range []struct {
+		typ  layers.DHCPMsgType
+		want layers.DHCPMsgType
+	}{{
+		typ:  layers.DHCPMsgTypeDiscover,
+		want: layers.DHCPMsgTypeOffer,
+	}, {
+		typ:  layers.DHCPMsgTypeRequest,
+		want: layers.DHCPMsgTypeAck,
+	}} {
+		var opts []layers.DHCPOption
+		if offered.IsValid() {
+			opts = append(opts, layers.NewDHCPOption(layers.DHCPOptRequestIP, offered.AsSlice()))
+		}
+
+		reads <- &testRead{
+			from: from,
+			data: serializeTestMsg(t, newTestRequest4(mac, tc.typ, opts...)),
+		}
+
+		w, _ := testutil.RequireReceive(t, writes, time.Second)
+		assert.Equal(t, wantTo, w.to)
+
+		resp := &layers.DHCPv4{}
+		require.NoError(t, resp.DecodeFromBytes(w.data, gopacket.NilDecodeFeedback))
+		require.Equal(t, tc.want, msgType4(resp))
+
+		offered, _ = netip.AddrFromSlice(resp.YourClientIP.To4())
+	}
+
+	assert.NotNil(t, srv.MACByIP(offered))
+}
@@ -0,0 +1,130 @@
+//go:build linux
+
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+)
+
+// newControlFunc returns the function setting the socket options required for
+// serving DHCP on the network interface with the given name.
+func newControlFunc(ifaceName string) (f func(network, address string, c syscall.RawConn) (err error)) {
+	return func(_, _ string, c syscall.RawConn) (err error) {
+		ctrlErr := c.Control(func(fd uintptr) {
+			err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEADDR, 1)
+			if err != nil {
+				return
+			}
+
+			err = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_BROADCAST, 1)
+			if err != nil {
+				return
+			}
+
+			err = unix.BindToDevice(int(fd), ifaceName)
+		})
+		if ctrlErr != nil {
+			return ctrlErr
+		}
+
+		return err
+	}
+}
+
+// ListenLinkLocal implements the [LinkLocalListener] interface for
+// NetListener.  The connection listens on the unspecified address, since Linux
+// doesn't deliver the multicast messages to the sockets bound to a unicast one,
+// and sets the source address of each message sent instead.
+func (l NetListener) ListenLinkLocal(
+	ctx context.Context,
+	ifaceName string,
+	group netip.Addr,
+	port uint16,
+) (conn net.PacketConn, laddr netip.Addr, err error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, netip.Addr{}, err
+	}
+
+	laddr, err = linkLocalAddr(iface)
+	if err != nil {
+		return nil, netip.Addr{}, fmt.Errorf("interface %q: %w", ifaceName, err)
+	}
+
+	conn, err = l.ListenPacket(ctx, ifaceName, netip.AddrPortFrom(netip.IPv6Unspecified(), port))
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, netip.Addr{}, err
+	}
+
+	pconn := ipv6.NewPacketConn(conn)
+	err = pconn.JoinGroup(iface, &net.UDPAddr{IP: group.AsSlice()})
+	if err != nil {
+		err = fmt.Errorf("joining %s at %q: %w", group, ifaceName, err)
+
+		return nil, netip.Addr{}, errors.WithDeferred(err, conn.Close())
+	}
+
+	return &linkLocalConn{
+		PacketConn: conn,
+		pconn:      pconn,
+		ctrlMsg: &ipv6.ControlMessage{
+			Src:     laddr.AsSlice(),
+			IfIndex: iface.Index,
+		},
+	}, laddr, nil
+}
+
+// linkLocalAddr returns the IPv6 link-local unicast address of iface with the
+// zone set to the name of iface.
+func linkLocalAddr(iface *net.Interface) (addr netip.Addr, err error) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("getting addresses: %w", err)
+	}
+
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.To4() != nil || !ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+
+		addr, ok = netip.AddrFromSlice(ipNet.IP)
+		if ok {
+			return addr.WithZone(iface.Name), nil
+		}
+	}
+
+	return netip.Addr{}, errors.Error("no ipv6 link-local address")
+}
+
+// linkLocalConn is the connection sending the messages from the link-local
+// address of the network interface.
+type linkLocalConn struct {
+	// PacketConn is embedded here to provide the reading and closing.
+	net.PacketConn
+
+	// pconn is the IPv6 wrapper of PacketConn used for sending.
+	pconn *ipv6.PacketConn
+
+	// ctrlMsg sets the source address and the network interface of each
+	// message sent.  It must not be modified.
+	ctrlMsg *ipv6.ControlMessage
+}
+
+// type check
+var _ net.PacketConn = (*linkLocalConn)(nil)
+
+// WriteTo implements the [net.PacketConn] interface for *linkLocalConn.
+func (c *linkLocalConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
+	return c.pconn.WriteTo(b, c.ctrlMsg, addr)
+}
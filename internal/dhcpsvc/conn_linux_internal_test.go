@@ -0,0 +1,38 @@
+//go:build linux
+
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetListener_ListenLinkLocal(t *testing.T) {
+	t.Run("no_interface", func(t *testing.T) {
+		conn, laddr, err := NetListener{}.ListenLinkLocal(
+			context.Background(),
+			"no_such_iface",
+			allDHCPAgents6,
+			serverPort6,
+		)
+		testutil.AssertErrorMsg(t, "route ip+net: no such network interface", err)
+
+		assert.Nil(t, conn)
+		assert.False(t, laddr.IsValid())
+	})
+
+	t.Run("loopback", func(t *testing.T) {
+		lo, err := net.InterfaceByName("lo")
+		if err != nil {
+			t.Skipf("no loopback interface: %s", err)
+		}
+
+		// Loopback interfaces have no link-local addresses on Linux.
+		_, err = linkLocalAddr(lo)
+		testutil.AssertErrorMsg(t, "no ipv6 link-local address", err)
+	})
+}
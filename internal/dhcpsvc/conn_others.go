@@ -0,0 +1,33 @@
+//go:build !linux
+
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// newControlFunc returns the function setting the socket options required for
+// serving DHCP on the network interface with the given name.
+//
+// TODO(e.burkov):  Implement.
+func newControlFunc(_ string) (f func(network, address string, c syscall.RawConn) (err error)) {
+	return nil
+}
+
+// ListenLinkLocal implements the [LinkLocalListener] interface for
+// NetListener.
+//
+// TODO(e.burkov):  Implement.
+func (NetListener) ListenLinkLocal(
+	_ context.Context,
+	_ string,
+	_ netip.Addr,
+	_ uint16,
+) (conn net.PacketConn, laddr netip.Addr, err error) {
+	return nil, netip.Addr{}, errors.Error("link-local binding is only supported on linux")
+}
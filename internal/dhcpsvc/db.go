@@ -0,0 +1,513 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"golang.org/x/exp/slices"
+)
+
+// dataVersion is the current version of the stored DHCP leases structure.
+const dataVersion = 1
+
+// dataLeases is the structure of the stored DHCP leases.
+type dataLeases struct {
+	// Leases is the list containing stored DHCP leases.
+	Leases []*dbLease `json:"leases"`
+
+	// Quarantined is the list of the addresses unavailable for allocation, see
+	// [DHCPServer.Quarantine].
+	Quarantined []*dbQuarantined `json:"quarantined,omitempty"`
+
+	// Epoch is the identifier of the server instance, which has written the
+	// structure on shutdown, see [Config.ReleaseOnExit].  It's empty if the
+	// structure has been written while the server was running.
+	Epoch string `json:"epoch,omitempty"`
+
+	// Version is the current version of the structure.
+	Version int `json:"version"`
+}
+
+// dbLease is the structure of stored lease.
+type dbLease struct {
+	Expiry      string     `json:"expires"`
+	Preferred   string     `json:"preferred_until,omitempty"`
+	IP          netip.Addr `json:"ip"`
+	Hostname    string     `json:"hostname"`
+	HWAddr      string     `json:"mac"`
+	Comment     string     `json:"comment,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	ClientID    string     `json:"client_id,omitempty"`
+	Interface   string     `json:"interface"`
+	IAID        uint32     `json:"iaid,omitempty"`
+	IsStatic    bool       `json:"static"`
+	Unconfirmed bool       `json:"unconfirmed,omitempty"`
+}
+
+// dbQuarantined is the structure of a stored quarantined address.
+type dbQuarantined struct {
+	// IP is the quarantined address.
+	IP netip.Addr `json:"ip"`
+
+	// Until is the time the address becomes available again in RFC 3339
+	// format with nanoseconds, so that it's restored exactly.  It's empty if
+	// the address is quarantined indefinitely.
+	Until string `json:"until,omitempty"`
+}
+
+// fromLease converts *Lease to *dbLease.
+func fromLease(l *Lease) (dl *dbLease) {
+	var expiryStr string
+	if !l.IsStatic {
+		// The front-end is waiting for RFC 3999 format of the time value.  It
+		// also shouldn't got an Expiry field for static leases.  Keep the
+		// nanoseconds, so that the lease is restored exactly.
+		//
+		// See https://github.com/AdguardTeam/AdGuardHome/issues/2692.
+		expiryStr = l.Expiry.Format(time.RFC3339Nano)
+	}
+
+	var preferredStr string
+	if !l.PreferredUntil.IsZero() {
+		preferredStr = l.PreferredUntil.Format(time.RFC3339Nano)
+	}
+
+	return &dbLease{
+		Expiry:      expiryStr,
+		Preferred:   preferredStr,
+		Hostname:    l.Hostname,
+		HWAddr:      l.HWAddr.String(),
+		Comment:     l.Comment,
+		Fingerprint: l.Fingerprint,
+		ClientID:    hex.EncodeToString(l.ClientID),
+		IP:          l.IP,
+		Interface:   l.InterfaceName,
+		IAID:        l.IAID,
+		IsStatic:    l.IsStatic,
+		Unconfirmed: l.unconfirmed,
+	}
+}
+
+// toInternal converts dl to *Lease.
+func (dl *dbLease) toInternal() (l *Lease, err error) {
+	mac, err := net.ParseMAC(dl.HWAddr)
+	if err != nil {
+		return nil, fmt.Errorf("parsing hardware address: %w", err)
+	}
+
+	clientID, err := hex.DecodeString(dl.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("parsing client id: %w", err)
+	}
+
+	expiry := time.Time{}
+	if !dl.IsStatic {
+		expiry, err = time.Parse(time.RFC3339Nano, dl.Expiry)
+		if err != nil {
+			return nil, fmt.Errorf("parsing expiry time: %w", err)
+		}
+	}
+
+	preferred := time.Time{}
+	if dl.Preferred != "" {
+		preferred, err = time.Parse(time.RFC3339Nano, dl.Preferred)
+		if err != nil {
+			return nil, fmt.Errorf("parsing preferred lifetime end: %w", err)
+		}
+	}
+
+	if len(clientID) == 0 {
+		clientID = nil
+	}
+
+	return &Lease{
+		Expiry:         expiry,
+		PreferredUntil: preferred,
+		IP:             dl.IP,
+		Hostname:       dl.Hostname,
+		HWAddr:         mac,
+		Comment:        dl.Comment,
+		Fingerprint:    dl.Fingerprint,
+		ClientID:       clientID,
+		InterfaceName:  dl.Interface,
+		IAID:           dl.IAID,
+		IsStatic:       dl.IsStatic,
+		unconfirmed:    dl.Unconfirmed && !dl.IsStatic && dl.IP.Is4(),
+	}, nil
+}
+
+// dbLoad loads stored leases.  It must only be called before the server
+// starts.
+func (srv *DHCPServer) dbLoad() (err error) {
+	if srv.dbFilePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(srv.dbFilePath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("reading db: %w", err)
+		}
+
+		log.Debug("dhcpsvc: db file %q not found", srv.dbFilePath)
+
+		return nil
+	}
+
+	warnDBFileMode(srv.dbFilePath)
+
+	dl := &dataLeases{}
+	err = json.Unmarshal(data, dl)
+	if err != nil {
+		log.Info("dhcpsvc: warning: decoding db: %s; recovering leases", err)
+
+		var dropped int
+		dl.Leases, dropped = recoverLeases(data)
+		log.Info("dhcpsvc: warning: dropped %d corrupt records from db", dropped)
+
+		srv.backupCorruptDB(data)
+	}
+
+	unconfirm := srv.conf.ReleaseOnExit && dl.Epoch != "" && dl.Epoch != srv.epoch
+	if unconfirm {
+		log.Info("dhcpsvc: db written by previous instance on exit, leases are unconfirmed")
+	}
+
+	leases := srv.loadedLeases(dl.Leases, unconfirm)
+
+	srv.leasesMu.Lock()
+	defer srv.leasesMu.Unlock()
+
+	added := 0
+	srv.duplicates = nil
+	for i, l := range leases {
+		if l == nil {
+			continue
+		}
+
+		err = srv.addLease(l)
+		if err != nil {
+			log.Info("dhcpsvc: skipping lease at index %d: %s", i, err)
+
+			dup := &DuplicateLease{}
+			if errors.As(err, &dup) {
+				srv.duplicates = append(srv.duplicates, dup)
+			}
+
+			continue
+		}
+
+		added++
+	}
+
+	log.Info("dhcpsvc: loaded %d of %d leases from db", added, len(dl.Leases))
+
+	srv.loadQuarantined(dl.Quarantined)
+
+	return nil
+}
+
+// loadQuarantined restores the quarantined addresses from qs.  The ones, which
+// quarantine has elapsed while the server was down, are pruned.  srv.leasesMu
+// is expected to be locked.
+func (srv *DHCPServer) loadQuarantined(qs []*dbQuarantined) {
+	now := srv.now()
+	restored := 0
+	for i, q := range qs {
+		until := time.Time{}
+		if q.Until != "" {
+			var err error
+			until, err = time.Parse(time.RFC3339Nano, q.Until)
+			if err != nil {
+				log.Info("dhcpsvc: skipping quarantined address at index %d: parsing until: %s", i, err)
+
+				continue
+			} else if !now.Before(until) {
+				continue
+			}
+		}
+
+		iface := srv.ifaceForRange(q.IP)
+		if iface == nil {
+			log.Info("dhcpsvc: skipping quarantined address %s: not within any range", q.IP)
+
+			continue
+		}
+
+		iface.quarantined[q.IP] = until
+		restored++
+	}
+
+	log.Debug("dhcpsvc: restored %d of %d quarantined addresses from db", restored, len(qs))
+}
+
+// dbRecordSepRe matches the separator between the records of the leases
+// within the database file.  The records contain no nested objects, so it
+// may only appear within a string value of a corrupt record, which is dropped
+// anyway.
+var dbRecordSepRe = regexp.MustCompile(`}\s*,\s*{`)
+
+// recoverLeases decodes the leases from data, which is the database file that
+// can't be decoded as a whole, e.g. truncated due to a power loss while being
+// written.  The records, which can't be decoded, are skipped up to the next
+// record separator.  dropped is the number of skipped records.
+func recoverLeases(data []byte) (leases []*dbLease, dropped int) {
+	_, rest, ok := bytes.Cut(data, []byte(`"leases"`))
+	if ok {
+		_, rest, ok = bytes.Cut(rest, []byte("["))
+	}
+
+	if !ok {
+		return nil, 1
+	}
+
+	for {
+		rest = bytes.TrimLeft(rest, " \t\r\n,")
+		if len(rest) == 0 || rest[0] == ']' {
+			return leases, dropped
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(rest))
+		dbl := &dbLease{}
+		err := dec.Decode(dbl)
+		if err == nil {
+			leases = append(leases, dbl)
+			rest = rest[dec.InputOffset():]
+
+			continue
+		}
+
+		dropped++
+		loc := dbRecordSepRe.FindIndex(rest)
+		if loc == nil {
+			return leases, dropped
+		}
+
+		// Keep the opening brace of the next record.
+		rest = rest[loc[1]-1:]
+	}
+}
+
+// backupCorruptDB writes data, which is the corrupt database file, next to
+// it, so that it can be inspected later.  The errors are only logged, since the
+// server is able to run without the backup.
+func (srv *DHCPServer) backupCorruptDB(data []byte) {
+	path := srv.dbFilePath + ".corrupt-" + srv.now().UTC().Format("20060102T150405Z")
+	err := os.WriteFile(path, data, dbFilePerm)
+	if err != nil {
+		log.Error("dhcpsvc: backing up corrupt db: %s", err)
+
+		return
+	}
+
+	log.Info("dhcpsvc: warning: corrupt db backed up to %q", path)
+}
+
+// loadedLeases converts dbls into leases and looks up their vendors.
+// unconfirm is true if all the leases should be marked unconfirmed.  The
+// leases which can't be converted are logged and left nil.  srv.leasesMu must
+// not be locked, since the vendor lookup may be slow.
+func (srv *DHCPServer) loadedLeases(dbls []*dbLease, unconfirm bool) (leases []*Lease) {
+	leases = make([]*Lease, len(dbls))
+	for i, dbl := range dbls {
+		dbl.Unconfirmed = srv.conf.ReleaseOnExit && (dbl.Unconfirmed || unconfirm)
+		l, err := dbl.toInternal()
+		if err != nil {
+			log.Info("dhcpsvc: skipping lease at index %d: %s", i, err)
+
+			continue
+		}
+
+		l.Vendor = srv.vendor(l.mac())
+		leases[i] = l
+	}
+
+	return leases
+}
+
+// addLease adds l to the server, re-validating it against the network
+// interface it was granted on.  If there is no such interface anymore, e.g. it
+// was renamed, the lease is attributed to the interface serving the lease's
+// address.  l.Vendor is expected to be set already, since the vendor lookup
+// may be slow.  l must not be used after calling addLease.  srv.leasesMu is
+// expected to be locked.
+func (srv *DHCPServer) addLease(l *Lease) (err error) {
+	iface := srv.ifaceByName(l.InterfaceName, l.IP.Is4())
+	if iface == nil {
+		iface, err = srv.ifaceForAddr(l.IP)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		}
+
+		if l.InterfaceName != "" {
+			log.Info(
+				"dhcpsvc: lease for %s was granted on interface %q, attributing it to %q",
+				l.IP,
+				l.InterfaceName,
+				iface.name,
+			)
+		}
+
+		l.InterfaceName = iface.name
+	}
+
+	err = iface.validateLease(l)
+	if err != nil {
+		return fmt.Errorf("interface %q: %w", iface.name, err)
+	}
+
+	err = netutil.ValidateMAC(l.HWAddr)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	if dup := srv.duplicateOf(l, iface); dup != nil {
+		return dup
+	}
+
+	return srv.leases.add(l, iface)
+}
+
+// dbQuarantined returns the addresses currently quarantined on the served
+// network interfaces sorted by address.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) dbQuarantined() (qs []*dbQuarantined) {
+	now := srv.now()
+	appendQuarantined := func(iface *netInterface) {
+		for ip, until := range iface.quarantined {
+			if !iface.isQuarantined(ip, now) {
+				continue
+			}
+
+			q := &dbQuarantined{IP: ip}
+			if !until.IsZero() {
+				q.Until = until.Format(time.RFC3339Nano)
+			}
+
+			qs = append(qs, q)
+		}
+	}
+
+	for _, iface := range srv.interfaces4 {
+		appendQuarantined(&iface.netInterface)
+	}
+
+	for _, iface := range srv.interfaces6 {
+		appendQuarantined(&iface.netInterface)
+	}
+
+	slices.SortFunc(qs, func(a, b *dbQuarantined) (res int) {
+		return a.IP.Compare(b.IP)
+	})
+
+	return qs
+}
+
+// dbState is the state of the server to be written into the database file,
+// see [DHCPServer.dbSnapshot].
+type dbState struct {
+	// data is the structure to encode into the database file.
+	data *dataLeases
+
+	// seq is the sequence number of the state, the greater ones are taken
+	// later.
+	seq uint64
+}
+
+// dbSnapshot takes the current state of the leases and the quarantined
+// addresses to be written into the database file with [DHCPServer.dbWrite] once
+// srv.leasesMu is unlocked.  st is nil if there is no database file.
+// srv.leasesMu is expected to be locked for writing.
+func (srv *DHCPServer) dbSnapshot() (st *dbState) {
+	if srv.dbFilePath == "" {
+		return nil
+	}
+
+	// Use an empty slice here as opposed to nil so that it doesn't write
+	// "null" into the database file if leases are empty.  Preallocate it to
+	// avoid growing it for large deployments.
+	leases := make([]*dbLease, 0, srv.leases.len())
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		leases = append(leases, fromLease(l))
+
+		return true
+	})
+
+	// Sort the leases to make the resulting file stable.
+	slices.SortFunc(leases, func(a, b *dbLease) (res int) {
+		return a.IP.Compare(b.IP)
+	})
+
+	srv.dbSeq++
+	srv.dbDirty = false
+	st = &dbState{
+		data: &dataLeases{
+			Leases:      leases,
+			Quarantined: srv.dbQuarantined(),
+			Epoch:       srv.exitEpoch,
+			Version:     dataVersion,
+		},
+		seq: srv.dbSeq,
+	}
+	srv.dbLatest.Store(st)
+
+	return st
+}
+
+// dbWrite writes the latest state taken by [DHCPServer.dbSnapshot] into the
+// database file, unless a state at least as recent as st has already been
+// written, so that the concurrent writes are coalesced.  It does nothing if st
+// is nil.  srv.leasesMu must not be locked, so that the disk I/O doesn't block
+// handling the messages.
+func (srv *DHCPServer) dbWrite(st *dbState) (err error) {
+	if st == nil {
+		return nil
+	}
+
+	srv.dbWriteMu.Lock()
+	defer srv.dbWriteMu.Unlock()
+
+	if st.seq <= srv.dbWritten {
+		return nil
+	}
+
+	defer func() { err = errors.Annotate(err, "writing db: %w") }()
+
+	latest := srv.dbLatest.Load()
+
+	buf := srv.dbBufPool.Get().(*bytes.Buffer)
+	defer srv.dbBufPool.Put(buf)
+
+	buf.Reset()
+	err = json.NewEncoder(buf).Encode(latest.data)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	err = writeFileDurably(srv.dbFS, srv.dbFilePath, buf.Bytes(), dbFilePerm)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	srv.dbWritten = latest.seq
+
+	storedAt := srv.now()
+	srv.lastStored.Store(&storedAt)
+
+	log.Debug("dhcpsvc: stored %d leases in %q", len(latest.data.Leases), srv.dbFilePath)
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package dhcpsvc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func BenchmarkDHCPServer_dbWrite(b *testing.B) {
+	const leasesNum = 50_000
+
+	srv, _ := newBenchServer4(b, leasesNum)
+	srv.dbFilePath = filepath.Join(b.TempDir(), "leases.json")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		srv.leasesMu.Lock()
+		st := srv.dbSnapshot()
+		srv.leasesMu.Unlock()
+
+		errSink = srv.dbWrite(st)
+	}
+
+	require.NoError(b, errSink)
+
+	// Most recent results, on a virtual machine with an Intel Xeon CPU:
+	//
+	//	goos: linux
+	//	goarch: amd64
+	//	pkg: github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc
+	//	cpu: Intel(R) Xeon(R) Processor
+	//	BenchmarkDHCPServer_dbWrite   	      20	 164238981 ns/op	12786107 B/op	  150024 allocs/op
+}
@@ -0,0 +1,445 @@
+package dhcpsvc_test
+
+import (
+	"io/fs"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slices"
+)
+
+// testDBData is the database written by a server serving testInterfaceConf.
+// The last lease is outside of any configured network.
+const testDBData = `{
+  "leases": [{
+    "expires": "",
+    "ip": "192.168.0.5",
+    "hostname": "static",
+    "mac": "aa:aa:aa:aa:aa:01",
+    "interface": "eth0",
+    "static": true
+  }, {
+    "expires": "2042-01-02T03:04:05Z",
+    "ip": "192.168.0.10",
+    "hostname": "dynamic4",
+    "mac": "aa:aa:aa:aa:aa:02",
+    "interface": "eth0",
+    "static": false
+  }, {
+    "expires": "2042-01-02T03:04:05Z",
+    "ip": "172.16.0.10",
+    "hostname": "other",
+    "mac": "aa:aa:aa:aa:aa:03",
+    "interface": "eth1",
+    "static": false
+  }, {
+    "expires": "2042-01-02T03:04:05Z",
+    "ip": "2001:db8::10",
+    "hostname": "dynamic6",
+    "mac": "aa:aa:aa:aa:aa:04",
+    "interface": "eth0",
+    "static": false
+  }, {
+    "expires": "2042-01-02T03:04:05Z",
+    "ip": "10.0.0.10",
+    "hostname": "stray",
+    "mac": "aa:aa:aa:aa:aa:05",
+    "interface": "eth0",
+    "static": false
+  }],
+  "version": 1
+}`
+
+func TestDHCPServer_dbLoad(t *testing.T) {
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+	err := os.WriteFile(dbFilePath, []byte(testDBData), 0o644)
+	require.NoError(t, err)
+
+	// Rename eth0 to br0 keeping its configuration.
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+			"br0":  testInterfaceConf["eth0"],
+			"eth1": testInterfaceConf["eth1"],
+		},
+	})
+	require.NoError(t, err)
+
+	ifaceNames := map[string]string{}
+	for _, l := range srv.Leases() {
+		ifaceNames[l.Hostname] = l.InterfaceName
+	}
+
+	assert.Equal(t, map[string]string{
+		"static":   "br0",
+		"dynamic4": "br0",
+		"dynamic6": "br0",
+		"other":    "eth1",
+	}, ifaceNames)
+
+	assert.Equal(t, &dhcpsvc.Status{
+		Interfaces: []*dhcpsvc.InterfaceStatus{{
+			IPv4: &dhcpsvc.FamilyStatus{DynamicLeases: 1, StaticLeases: 1, FreeAddrs: 251},
+			IPv6: &dhcpsvc.FamilyStatus{DynamicLeases: 1, FreeAddrs: 254},
+			Name: "br0",
+		}, {
+			IPv4: &dhcpsvc.FamilyStatus{DynamicLeases: 1, FreeAddrs: 252},
+			IPv6: &dhcpsvc.FamilyStatus{FreeAddrs: 255},
+			Name: "eth1",
+		}},
+	}, srv.Status())
+}
+
+func TestDHCPServer_dbLoad_corrupt(t *testing.T) {
+	testCases := []struct {
+		name      string
+		data      string
+		wantHosts []string
+	}{{
+		name:      "truncated",
+		data:      testDBData[:strings.Index(testDBData, `"hostname": "dynamic6"`)],
+		wantHosts: []string{"dynamic4", "other", "static"},
+	}, {
+		name:      "corrupt_record",
+		data:      strings.Replace(testDBData, `"dynamic4"`, `dynamic4`, 1),
+		wantHosts: []string{"dynamic6", "other", "static"},
+	}, {
+		name:      "garbage",
+		data:      "\x00\x00\x00",
+		wantHosts: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+			err := os.WriteFile(dbFilePath, []byte(tc.data), 0o600)
+			require.NoError(t, err)
+
+			srv, err := dhcpsvc.New(&dhcpsvc.Config{
+				Enabled:         true,
+				LocalDomainName: testLocalTLD,
+				DBFilePath:      dbFilePath,
+				Interfaces:      testInterfaceConf,
+			})
+			require.NoError(t, err)
+
+			var hosts []string
+			for _, l := range srv.Leases() {
+				hosts = append(hosts, l.Hostname)
+			}
+			slices.Sort(hosts)
+
+			assert.Equal(t, tc.wantHosts, hosts)
+
+			backups, err := filepath.Glob(dbFilePath + ".corrupt-*")
+			require.NoError(t, err)
+			require.Len(t, backups, 1)
+
+			backup, err := os.ReadFile(backups[0])
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.data, string(backup))
+		})
+	}
+}
+
+// testDuplicatesDBData is the database containing the leases duplicating the
+// first one by IP address and by hardware address.
+const testDuplicatesDBData = `{
+  "leases": [{
+    "expires": "",
+    "ip": "192.168.0.5",
+    "hostname": "first",
+    "mac": "aa:aa:aa:aa:aa:01",
+    "interface": "eth0",
+    "static": true
+  }, {
+    "expires": "",
+    "ip": "192.168.0.5",
+    "hostname": "same-ip",
+    "mac": "aa:aa:aa:aa:aa:02",
+    "interface": "eth0",
+    "static": true
+  }, {
+    "expires": "",
+    "ip": "192.168.0.6",
+    "hostname": "same-mac",
+    "mac": "aa:aa:aa:aa:aa:01",
+    "interface": "eth0",
+    "static": true
+  }, {
+    "expires": "",
+    "ip": "2001:db8::5",
+    "hostname": "other-family",
+    "mac": "aa:aa:aa:aa:aa:01",
+    "interface": "eth0",
+    "static": true
+  }],
+  "version": 1
+}`
+
+func TestDHCPServer_dbLoad_duplicates(t *testing.T) {
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+	err := os.WriteFile(dbFilePath, []byte(testDuplicatesDBData), 0o644)
+	require.NoError(t, err)
+
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces:      testInterfaceConf,
+	})
+	require.NoError(t, err)
+
+	hostnames := map[netip.Addr]string{}
+	for _, l := range srv.Leases() {
+		hostnames[l.IP] = l.Hostname
+	}
+
+	assert.Equal(t, map[netip.Addr]string{
+		netip.MustParseAddr("192.168.0.5"): "first",
+		netip.MustParseAddr("2001:db8::5"): "other-family",
+	}, hostnames)
+
+	dups := srv.DuplicateLeases()
+	require.Len(t, dups, 2)
+
+	assert.Equal(t, dhcpsvc.DuplicateReasonIP, dups[0].Reason)
+	assert.Equal(t, "same-ip", dups[0].Skipped.Hostname)
+	assert.Equal(t, "first", dups[0].Kept.Hostname)
+
+	assert.Equal(t, dhcpsvc.DuplicateReasonMAC, dups[1].Reason)
+	assert.Equal(t, "same-mac", dups[1].Skipped.Hostname)
+	assert.Equal(t, "first", dups[1].Kept.Hostname)
+
+	err = srv.ReplaceLeases([]*dhcpsvc.Lease{dups[1].Kept, dups[1].Skipped})
+	require.ErrorAs(t, err, new(*dhcpsvc.DuplicateLease))
+
+	assert.Len(t, srv.Leases(), 2)
+}
+
+func TestDHCPServer_dbStore(t *testing.T) {
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces:      testInterfaceConf,
+	})
+	require.NoError(t, err)
+
+	l := &dhcpsvc.Lease{
+		IP:       netip.MustParseAddr("172.16.0.5"),
+		Hostname: "host",
+		HWAddr:   mustParseMAC("aa:bb:cc:dd:ee:ff"),
+		Comment:  "Living room TV",
+	}
+	err = srv.AddStaticLease(l)
+	require.NoError(t, err)
+
+	leases := srv.Leases()
+	require.Len(t, leases, 1)
+
+	assert.Equal(t, l.Comment, leases[0].Comment)
+
+	srv, err = dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces:      testInterfaceConf,
+	})
+	require.NoError(t, err)
+
+	leases = srv.Leases()
+	require.Len(t, leases, 1)
+
+	assert.Equal(t, l.IP, leases[0].IP)
+	assert.Equal(t, l.Comment, leases[0].Comment)
+	assert.Equal(t, "eth1", leases[0].InterfaceName)
+	assert.True(t, leases[0].IsStatic)
+}
+
+func TestDHCPServer_dbStore_nanoseconds(t *testing.T) {
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+	conf := &dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces:      testInterfaceConf,
+	}
+
+	srv, err := dhcpsvc.New(conf)
+	require.NoError(t, err)
+
+	expiry := time.Date(2042, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	leases := []*dhcpsvc.Lease{{
+		Expiry:        expiry,
+		IP:            netip.MustParseAddr("192.168.0.10"),
+		Hostname:      "dynamic4",
+		HWAddr:        mustParseMAC("aa:aa:aa:aa:aa:02"),
+		InterfaceName: "eth0",
+	}, {
+		Expiry:         expiry,
+		PreferredUntil: expiry.Add(-time.Nanosecond),
+		IP:             netip.MustParseAddr("2001:db8::10"),
+		Hostname:       "dynamic6",
+		HWAddr:         mustParseMAC("aa:aa:aa:aa:aa:04"),
+		InterfaceName:  "eth0",
+	}}
+	err = srv.ReplaceLeases(leases)
+	require.NoError(t, err)
+
+	srv, err = dhcpsvc.New(conf)
+	require.NoError(t, err)
+
+	got := srv.Leases()
+	require.Len(t, got, len(leases))
+
+	for i, l := range leases {
+		assert.True(t, l.Expiry.Equal(got[i].Expiry), "expiry of lease at index %d", i)
+		assert.True(t, l.PreferredUntil.Equal(got[i].PreferredUntil), "preferred of lease at index %d", i)
+	}
+}
+
+func TestDHCPServer_dbStore_unwritable(t *testing.T) {
+	dbDir := filepath.Join(t.TempDir(), "data")
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      filepath.Join(dbDir, "leases.json"),
+		Interfaces:      testInterfaceConf,
+	})
+	require.NoError(t, err)
+
+	ch := make(chan *dhcpsvc.Event, 10)
+	srv.Subscribe(ch)
+	t.Cleanup(func() { srv.Unsubscribe(ch) })
+
+	// Make the directory of the database file unwritable even for root by
+	// replacing it with a regular file.
+	require.NoError(t, os.RemoveAll(dbDir))
+	require.NoError(t, os.WriteFile(dbDir, nil, 0o600))
+
+	l := &dhcpsvc.Lease{
+		IP:       netip.MustParseAddr("172.16.0.5"),
+		Hostname: "host",
+		HWAddr:   mustParseMAC("aa:bb:cc:dd:ee:ff"),
+	}
+	updated := &dhcpsvc.Lease{
+		IP:       netip.MustParseAddr("172.16.0.6"),
+		Hostname: "host",
+		HWAddr:   l.HWAddr,
+	}
+
+	testCases := []struct {
+		change   func() (err error)
+		name     string
+		wantType dhcpsvc.EventType
+		wantLen  int
+	}{{
+		change:   func() (err error) { return srv.AddStaticLease(l) },
+		name:     "add",
+		wantType: dhcpsvc.EventTypeAdded,
+		wantLen:  1,
+	}, {
+		change:   func() (err error) { return srv.UpdateStaticLease(l, updated) },
+		name:     "update",
+		wantType: dhcpsvc.EventTypeUpdated,
+		wantLen:  1,
+	}, {
+		change:   func() (err error) { return srv.RemoveStaticLease(updated) },
+		name:     "remove",
+		wantType: dhcpsvc.EventTypeRemoved,
+		wantLen:  0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err = tc.change()
+			assert.ErrorIs(t, err, dhcpsvc.ErrStaticLeaseStore)
+
+			// The change is applied and notified about nevertheless.
+			ev, _ := testutil.RequireReceive(t, ch, testTimeout)
+			require.NotNil(t, ev)
+
+			assert.Equal(t, tc.wantType, ev.Type)
+			assert.Len(t, srv.Leases(), tc.wantLen)
+		})
+	}
+}
+
+func TestDHCPServer_Flush(t *testing.T) {
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces:      testInterfaceConf,
+	})
+	require.NoError(t, err)
+
+	err = srv.AddStaticLease(&dhcpsvc.Lease{
+		IP:       netip.MustParseAddr("172.16.0.5"),
+		Hostname: "host",
+		HWAddr:   mustParseMAC("aa:bb:cc:dd:ee:ff"),
+	})
+	require.NoError(t, err)
+
+	stored, err := os.ReadFile(dbFilePath)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(dbFilePath))
+
+	require.NoError(t, srv.Flush(newTestContext(t)))
+
+	flushed, err := os.ReadFile(dbFilePath)
+	require.NoError(t, err)
+
+	assert.Equal(t, stored, flushed)
+}
+
+func TestDHCPServer_dbStore_permissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions aren't supported on windows")
+	}
+
+	workDir := t.TempDir()
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      filepath.Join("data", "leases.json"),
+		WorkDir:         workDir,
+		Interfaces:      testInterfaceConf,
+	})
+	require.NoError(t, err)
+
+	dir := filepath.Join(workDir, "data")
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, fs.FileMode(0o700), fi.Mode().Perm())
+
+	err = srv.AddStaticLease(&dhcpsvc.Lease{
+		IP:       netip.MustParseAddr("172.16.0.5"),
+		Hostname: "host",
+		HWAddr:   mustParseMAC("aa:bb:cc:dd:ee:ff"),
+	})
+	require.NoError(t, err)
+
+	fi, err = os.Stat(filepath.Join(dir, "leases.json"))
+	require.NoError(t, err)
+
+	assert.Equal(t, fs.FileMode(0o600), fi.Mode().Perm())
+}
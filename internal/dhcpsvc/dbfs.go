@@ -0,0 +1,134 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// dbFS is the file system the database is written into.
+type dbFS interface {
+	// CreateTemp creates a new temporary file in dir as [os.CreateTemp] does.
+	CreateTemp(dir, pattern string) (f dbFile, err error)
+
+	// Rename renames oldpath to newpath as [os.Rename] does.
+	Rename(oldpath, newpath string) (err error)
+
+	// Remove removes the named file as [os.Remove] does.
+	Remove(name string) (err error)
+
+	// SyncDir commits the entries of the directory to stable storage.
+	SyncDir(dir string) (err error)
+}
+
+// dbFile is a file opened for writing within dbFS.
+type dbFile interface {
+	io.WriteCloser
+
+	// Chmod changes the mode of the file.
+	Chmod(mode fs.FileMode) (err error)
+
+	// Name returns the name of the file.
+	Name() (name string)
+
+	// Sync commits the contents of the file to stable storage.
+	Sync() (err error)
+}
+
+// osFS is the dbFS implementation using the file system of the OS.
+type osFS struct{}
+
+// type check
+var _ dbFS = osFS{}
+
+// CreateTemp implements the [dbFS] interface for osFS.
+func (osFS) CreateTemp(dir, pattern string) (f dbFile, err error) {
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Rename implements the [dbFS] interface for osFS.
+func (osFS) Rename(oldpath, newpath string) (err error) { return os.Rename(oldpath, newpath) }
+
+// Remove implements the [dbFS] interface for osFS.
+func (osFS) Remove(name string) (err error) { return os.Remove(name) }
+
+// SyncDir implements the [dbFS] interface for osFS.
+func (osFS) SyncDir(dir string) (err error) { return syncDir(dir) }
+
+// writeFileDurably writes data into the file at path within fsys, so that the
+// file contains either its previous contents or data, even if the writing is
+// interrupted at any point.  The data is written into a temporary file, which
+// is synced and then renamed to path, and then the directory is synced to
+// persist the rename.
+func writeFileDurably(fsys dbFS, path string, data []byte, perm fs.FileMode) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := fsys.CreateTemp(dir, base+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+
+	tmpPath := f.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			err = errors.WithDeferred(err, fsys.Remove(tmpPath))
+		}
+	}()
+
+	err = writeAndClose(f, data, perm)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	err = fsys.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+
+	renamed = true
+
+	err = fsys.SyncDir(dir)
+	if err != nil {
+		return fmt.Errorf("syncing directory: %w", err)
+	}
+
+	return nil
+}
+
+// writeAndClose writes data into f, sets its mode to perm, syncs, and closes
+// it.  f is closed even if an error occurs.
+func writeAndClose(f dbFile, data []byte, perm fs.FileMode) (err error) {
+	defer func() { err = errors.WithDeferred(err, f.Close()) }()
+
+	_, err = f.Write(data)
+	if err != nil {
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	err = f.Chmod(perm)
+	if err != nil {
+		return fmt.Errorf("changing mode of temporary file: %w", err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+
+	return nil
+}
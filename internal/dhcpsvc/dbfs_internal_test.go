@@ -0,0 +1,162 @@
+package dhcpsvc
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// errInjected is the error returned by faultFS at the faulty step.
+const errInjected errors.Error = "injected fault"
+
+// Steps of writing a file durably in the order of execution.
+const (
+	stepCreateTemp = iota
+	stepWrite
+	stepChmod
+	stepSync
+	stepClose
+	stepRename
+	stepSyncDir
+	stepsNum
+)
+
+// faultFS is a dbFS failing at a certain step of writing a file, which
+// simulates a crash.
+type faultFS struct {
+	osFS
+
+	// failAt is the step to fail at.
+	failAt int
+}
+
+// type check
+var _ dbFS = (*faultFS)(nil)
+
+// CreateTemp implements the [dbFS] interface for *faultFS.
+func (fsys *faultFS) CreateTemp(dir, pattern string) (f dbFile, err error) {
+	if fsys.failAt == stepCreateTemp {
+		return nil, errInjected
+	}
+
+	f, err = fsys.osFS.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &faultFile{dbFile: f, failAt: fsys.failAt}, nil
+}
+
+// Rename implements the [dbFS] interface for *faultFS.
+func (fsys *faultFS) Rename(oldpath, newpath string) (err error) {
+	if fsys.failAt == stepRename {
+		return errInjected
+	}
+
+	return fsys.osFS.Rename(oldpath, newpath)
+}
+
+// SyncDir implements the [dbFS] interface for *faultFS.
+func (fsys *faultFS) SyncDir(dir string) (err error) {
+	if fsys.failAt == stepSyncDir {
+		return errInjected
+	}
+
+	return fsys.osFS.SyncDir(dir)
+}
+
+// faultFile is a dbFile failing at a certain step of writing.
+type faultFile struct {
+	dbFile
+
+	// failAt is the step to fail at.
+	failAt int
+}
+
+// type check
+var _ dbFile = (*faultFile)(nil)
+
+// Write implements the [dbFile] interface for *faultFile.  It writes only the
+// half of b when failing.
+func (f *faultFile) Write(b []byte) (n int, err error) {
+	if f.failAt == stepWrite {
+		n, _ = f.dbFile.Write(b[:len(b)/2])
+
+		return n, errInjected
+	}
+
+	return f.dbFile.Write(b)
+}
+
+// Chmod implements the [dbFile] interface for *faultFile.
+func (f *faultFile) Chmod(mode fs.FileMode) (err error) {
+	if f.failAt == stepChmod {
+		return errInjected
+	}
+
+	return f.dbFile.Chmod(mode)
+}
+
+// Sync implements the [dbFile] interface for *faultFile.
+func (f *faultFile) Sync() (err error) {
+	if f.failAt == stepSync {
+		return errInjected
+	}
+
+	return f.dbFile.Sync()
+}
+
+// Close implements the [dbFile] interface for *faultFile.  It closes the
+// underlying file anyway.
+func (f *faultFile) Close() (err error) {
+	err = f.dbFile.Close()
+	if f.failAt == stepClose {
+		return errInjected
+	}
+
+	return err
+}
+
+func TestWriteFileDurably(t *testing.T) {
+	oldData := []byte(`{"leases":[],"version":1}` + "\n")
+	newData := []byte(`{"leases":[{"ip":"192.168.0.2"}],"version":1}` + "\n")
+
+	for failAt := stepCreateTemp; failAt <= stepsNum; failAt++ {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "leases.json")
+
+		require.NoError(t, writeFileDurably(osFS{}, path, oldData, 0o644))
+
+		err := writeFileDurably(&faultFS{failAt: failAt}, path, newData, 0o644)
+
+		want := oldData
+		switch {
+		case failAt == stepsNum:
+			require.NoError(t, err)
+
+			want = newData
+		case failAt == stepSyncDir:
+			// The file has already been renamed.
+			require.ErrorIs(t, err, errInjected)
+
+			want = newData
+		default:
+			require.ErrorIs(t, err, errInjected)
+		}
+
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		assert.Equalf(t, want, got, "failed at step %d", failAt)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+
+		assert.Lenf(t, entries, 1, "temporary file left at step %d", failAt)
+	}
+}
@@ -0,0 +1,133 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// defaultDBFileName is the name of the database file within [Config.WorkDir],
+// when [Config.DBFilePath] is empty.
+const defaultDBFileName = "leases.json"
+
+const (
+	// dbFilePerm is the mode of the database file, which contains the
+	// addresses and hardware addresses of the clients, so it's only readable
+	// by the owner.
+	dbFilePerm fs.FileMode = 0o600
+
+	// dbDirPerm is the mode of the directory of the database file, when it's
+	// created by the server.
+	dbDirPerm fs.FileMode = 0o700
+)
+
+// dbFilePath returns the path to the database file resolved against the
+// working directory, see [Config.DBFilePath] and [Config.WorkDir].  path is
+// empty if the leases aren't persisted.
+func (conf *Config) dbFilePath() (path string) {
+	path = conf.DBFilePath
+	if conf.WorkDir == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	if path == "" {
+		path = defaultDBFileName
+	}
+
+	return filepath.Join(conf.WorkDir, path)
+}
+
+// validateDBDir returns an error if the directory of the database file can't
+// be written, see [Config.ValidateOnHost].  If the directory doesn't exist
+// yet, its nearest existing ancestor is checked instead, since the directory is
+// created by [New].
+func (conf *Config) validateDBDir() (err error) {
+	path := conf.dbFilePath()
+	if path == "" {
+		return nil
+	}
+
+	defer func() { err = errors.Annotate(err, "db file path %q: %w", path) }()
+
+	dir := filepath.Dir(path)
+	for {
+		var fi fs.FileInfo
+		fi, err = os.Stat(dir)
+		switch {
+		case err == nil:
+			if !fi.IsDir() {
+				return fmt.Errorf("%q is not a directory", dir)
+			}
+
+			return checkDirWritable(dir, defaultDBFileName+".check-*")
+		case !errors.Is(err, os.ErrNotExist):
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		}
+
+		dir = parent
+	}
+}
+
+// checkDirWritable returns an error if a file can't be created in dir, which
+// is checked by creating a temporary file named after pattern and removing it.
+func checkDirWritable(dir, pattern string) (err error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
+
+// ensureDBDir creates the directory of the database file at path, if it
+// doesn't exist yet.  The existing directories are left as is.
+func ensureDBDir(path string) (err error) {
+	if path == "" {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), dbDirPerm)
+	if err != nil {
+		return fmt.Errorf("creating db directory: %w", err)
+	}
+
+	return nil
+}
+
+// warnDBFileMode logs a warning if the existing database file at path is
+// accessible by users other than its owner.  The mode of the file is fixed on
+// the next write, see [dbFilePerm].  The permission bits aren't meaningful on
+// Windows, so nothing is checked there.
+func warnDBFileMode(path string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		log.Debug("dhcpsvc: getting db file info: %s", err)
+
+		return
+	}
+
+	if mode := fi.Mode().Perm(); mode&0o077 != 0 {
+		log.Info(
+			"dhcpsvc: warning: db file %q is accessible by other users with mode %s",
+			path,
+			mode,
+		)
+	}
+}
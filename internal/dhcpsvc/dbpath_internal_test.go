@@ -0,0 +1,56 @@
+package dhcpsvc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_dbFilePath(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "work")
+	absPath := filepath.Join(t.TempDir(), "leases.json")
+
+	testCases := []struct {
+		name       string
+		dbFilePath string
+		workDir    string
+		want       string
+	}{{
+		name:       "empty",
+		dbFilePath: "",
+		workDir:    "",
+		want:       "",
+	}, {
+		name:       "default",
+		dbFilePath: "",
+		workDir:    workDir,
+		want:       filepath.Join(workDir, defaultDBFileName),
+	}, {
+		name:       "relative",
+		dbFilePath: filepath.Join("data", "leases.json"),
+		workDir:    workDir,
+		want:       filepath.Join(workDir, "data", "leases.json"),
+	}, {
+		name:       "relative_no_work_dir",
+		dbFilePath: "leases.json",
+		workDir:    "",
+		want:       "leases.json",
+	}, {
+		name:       "absolute",
+		dbFilePath: absPath,
+		workDir:    workDir,
+		want:       absPath,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &Config{
+				DBFilePath: tc.dbFilePath,
+				WorkDir:    tc.workDir,
+			}
+
+			assert.Equal(t, tc.want, conf.dbFilePath())
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+const (
+	// DefaultLocalDomainName is the default value of [Config.LocalDomainName].
+	DefaultLocalDomainName = "lan"
+
+	// DefaultICMPTimeout is the default value of [Config.ICMPTimeout].
+	DefaultICMPTimeout = 1 * time.Second
+
+	// DefaultLeaseDuration is the default value of [IPv4Config.LeaseDuration]
+	// and [IPv6Config.LeaseDuration].
+	DefaultLeaseDuration = 24 * time.Hour
+)
+
+// maxDefaultsPrefixLen4 is the maximum length of an IPv4 prefix to derive the
+// configuration of a network interface from, so that the subnet contains the
+// gateway and at least a single address to lease.
+const maxDefaultsPrefixLen4 = 30
+
+// NewDefaultConfig returns a new enabled configuration with the default
+// values.  Its network interfaces are empty, so those should be added, e.g.
+// with [Config.AddInterfaceDefaults], for it to pass [Config.Validate].
+func NewDefaultConfig() (conf *Config) {
+	return &Config{
+		Interfaces:      map[string]*InterfaceConfig{},
+		LocalDomainName: DefaultLocalDomainName,
+		ICMPTimeout:     DefaultICMPTimeout,
+		Enabled:         true,
+	}
+}
+
+// AddInterfaceDefaults enables serving the address family of prefix on the
+// network interface with the given name, which is added to conf if missing.
+// The configuration is derived from prefix with [DefaultLeaseDuration]:
+//
+//   - for IPv4, the first address of the subnet is the gateway, and the rest
+//     of it, except for the broadcast address, is leased;
+//   - for IPv6, the addresses starting from the second one of the subnet are
+//     leased, since the first one is usually the router's.
+//
+// The configuration of the other address family of the network interface is
+// kept.
+func (conf *Config) AddInterfaceDefaults(name string, prefix netip.Prefix) (err error) {
+	if !prefix.IsValid() {
+		return fmt.Errorf("interface %q: prefix must be valid", name)
+	}
+
+	ic := conf.Interfaces[name]
+	if ic == nil {
+		ic = &InterfaceConfig{
+			IPv4: &IPv4Config{Enabled: false},
+			IPv6: &IPv6Config{Enabled: false},
+		}
+	}
+
+	subnet := prefix.Masked()
+	if subnet.Addr().Is4() {
+		if subnet.Bits() > maxDefaultsPrefixLen4 {
+			return fmt.Errorf(
+				"interface %q: prefix length %d must not exceed %d",
+				name,
+				subnet.Bits(),
+				maxDefaultsPrefixLen4,
+			)
+		}
+
+		gateway := subnet.Addr().Next()
+		ic.IPv4 = &IPv4Config{
+			GatewayIP:     gateway,
+			Subnet:        subnet,
+			RangeStart:    gateway.Next(),
+			RangeEnd:      broadcast4(subnet).Prev(),
+			LeaseDuration: DefaultLeaseDuration,
+			Enabled:       true,
+		}
+	} else {
+		ic.IPv6 = &IPv6Config{
+			RangeStart:    subnet.Addr().Next().Next(),
+			LeaseDuration: DefaultLeaseDuration,
+			Enabled:       true,
+		}
+	}
+
+	if conf.Interfaces == nil {
+		conf.Interfaces = map[string]*InterfaceConfig{}
+	}
+
+	conf.Interfaces[name] = ic
+
+	return nil
+}
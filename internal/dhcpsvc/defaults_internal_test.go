@@ -0,0 +1,122 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_AddInterfaceDefaults(t *testing.T) {
+	t.Run("no_interfaces", func(t *testing.T) {
+		testutil.AssertErrorMsg(t, string(errNoInterfaces), NewDefaultConfig().Validate())
+	})
+
+	testCases := []struct {
+		want4      *IPv4Config
+		want6      *IPv6Config
+		name       string
+		wantErrMsg string
+		prefix     netip.Prefix
+	}{{
+		want4: &IPv4Config{
+			GatewayIP:     netip.MustParseAddr("192.168.1.1"),
+			Subnet:        netip.MustParsePrefix("192.168.1.0/24"),
+			RangeStart:    netip.MustParseAddr("192.168.1.2"),
+			RangeEnd:      netip.MustParseAddr("192.168.1.254"),
+			LeaseDuration: DefaultLeaseDuration,
+			Enabled:       true,
+		},
+		want6:      &IPv6Config{Enabled: false},
+		name:       "ipv4",
+		wantErrMsg: "",
+		prefix:     netip.MustParsePrefix("192.168.1.10/24"),
+	}, {
+		want4: &IPv4Config{Enabled: false},
+		want6: &IPv6Config{
+			RangeStart:    netip.MustParseAddr("fd00::2"),
+			LeaseDuration: DefaultLeaseDuration,
+			Enabled:       true,
+		},
+		name:       "ipv6",
+		wantErrMsg: "",
+		prefix:     netip.MustParsePrefix("fd00::/64"),
+	}, {
+		want4:      nil,
+		want6:      nil,
+		name:       "too_long",
+		wantErrMsg: `interface "eth0": prefix length 31 must not exceed 30`,
+		prefix:     netip.MustParsePrefix("192.168.1.0/31"),
+	}, {
+		want4:      nil,
+		want6:      nil,
+		name:       "invalid",
+		wantErrMsg: `interface "eth0": prefix must be valid`,
+		prefix:     netip.Prefix{},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := NewDefaultConfig()
+
+			err := conf.AddInterfaceDefaults("eth0", tc.prefix)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+			if tc.wantErrMsg != "" {
+				assert.Empty(t, conf.Interfaces)
+
+				return
+			}
+
+			require.NoError(t, conf.Validate())
+
+			ic := conf.Interfaces["eth0"]
+			require.NotNil(t, ic)
+
+			assert.Equal(t, tc.want4, ic.IPv4)
+			assert.Equal(t, tc.want6, ic.IPv6)
+		})
+	}
+}
+
+func TestNewDefaultConfig_dora(t *testing.T) {
+	conf := NewDefaultConfig()
+	conf.ICMPTimeout = 0
+	require.NoError(t, conf.AddInterfaceDefaults("eth0", netip.MustParsePrefix("10.0.0.0/24")))
+
+	srv, err := New(conf)
+	require.NoError(t, err)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	wantIP := netip.MustParseAddr("10.0.0.2")
+
+	offer, err := srv.handle4("eth0", newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+	require.NoError(t, err)
+	require.NotNil(t, offer)
+
+	offered, ok := netip.AddrFromSlice(offer.YourClientIP.To4())
+	require.True(t, ok)
+
+	assert.Equal(t, wantIP, offered)
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), optIP4(offer, layers.DHCPOptServerID))
+
+	ack, err := srv.handle4("eth0", newTestRequest4(
+		mac,
+		layers.DHCPMsgTypeRequest,
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, offered.AsSlice()),
+	))
+	require.NoError(t, err)
+	require.NotNil(t, ack)
+
+	assert.Equal(t, layers.DHCPMsgTypeAck, msgType4(ack))
+
+	leases := srv.Leases()
+	require.Len(t, leases, 1)
+
+	assert.Equal(t, wantIP, leases[0].IP)
+	assert.Equal(t, DefaultLeaseDuration, leases[0].Expiry.Sub(srv.now()).Round(time.Hour))
+}
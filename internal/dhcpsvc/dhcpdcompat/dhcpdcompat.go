@@ -0,0 +1,131 @@
+// Package dhcpdcompat provides the adapter of [dhcpsvc.DHCPServer] to the
+// interface of the legacy DHCP server, so that the callers of [dhcpd.Interface]
+// can be switched to [dhcpsvc] before the legacy package is removed.
+package dhcpdcompat
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+)
+
+// shutdownTimeout is the timeout for shutting down the wrapped server within
+// [Server.Stop], since [dhcpd.Interface] doesn't provide a context.
+const shutdownTimeout = 5 * time.Second
+
+// Server adapts *dhcpsvc.DHCPServer to [dhcpd.Interface] and to the methods of
+// [dhcpd.DHCPServer] operating the legacy leases.
+type Server struct {
+	// srv is the wrapped server.
+	srv *dhcpsvc.DHCPServer
+
+	// diskConf is the legacy configuration srv has been created from.  It's
+	// written back by [Server.WriteDiskConfig].
+	diskConf *dhcpd.ServerConfig
+}
+
+// New returns a new adapter of srv.  diskConf is the legacy configuration srv
+// has been created from, it must not be nil.
+func New(srv *dhcpsvc.DHCPServer, diskConf *dhcpd.ServerConfig) (s *Server) {
+	return &Server{
+		srv:      srv,
+		diskConf: diskConf,
+	}
+}
+
+// type check
+var _ dhcpd.Interface = (*Server)(nil)
+
+// Start implements the [dhcpd.Interface] interface for *Server.
+func (s *Server) Start() (err error) {
+	return s.srv.Start()
+}
+
+// Stop implements the [dhcpd.Interface] interface for *Server.  It waits for
+// the wrapped server to shut down for at most [shutdownTimeout].
+func (s *Server) Stop() (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	return s.srv.Shutdown(ctx)
+}
+
+// Enabled implements the [dhcpd.Interface] interface for *Server.
+func (s *Server) Enabled() (ok bool) {
+	return s.srv.Enabled()
+}
+
+// Leases implements the [dhcpd.Interface] interface for *Server.
+func (s *Server) Leases() (leases []*dhcpsvc.Lease) {
+	return s.srv.Leases()
+}
+
+// MACByIP implements the [dhcpd.Interface] interface for *Server.
+func (s *Server) MACByIP(ip netip.Addr) (mac net.HardwareAddr) {
+	return s.srv.MACByIP(ip)
+}
+
+// HostByIP implements the [dhcpd.Interface] interface for *Server.
+func (s *Server) HostByIP(ip netip.Addr) (host string) {
+	return s.srv.HostByIP(ip)
+}
+
+// IPByHost implements the [dhcpd.Interface] interface for *Server.
+func (s *Server) IPByHost(host string) (ip netip.Addr) {
+	return s.srv.IPByHost(host)
+}
+
+// WriteDiskConfig implements the [dhcpd.Interface] interface for *Server.  The
+// enabled state and the local domain name are taken from the wrapped server,
+// while the rest of the properties are written as they were in the legacy
+// configuration passed to [New], since those can't be converted back.
+func (s *Server) WriteDiskConfig(c *dhcpd.ServerConfig) {
+	conf := s.srv.Config()
+
+	c.Enabled = conf.Enabled
+	c.InterfaceName = s.diskConf.InterfaceName
+	c.LocalDomainName = conf.LocalDomainName
+	c.Conf4 = s.diskConf.Conf4
+	c.Conf6 = s.diskConf.Conf6
+}
+
+// GetLeases returns the leases of the wrapped server in the legacy form, see
+// [LeaseToLegacy].  The dynamic leases are only returned if those aren't
+// expired.  leases are never nil, as the legacy server's are.
+func (s *Server) GetLeases(flags dhcpd.GetLeasesFlags) (leases []*dhcpd.Lease) {
+	leases = []*dhcpd.Lease{}
+
+	getDynamic := flags&dhcpd.LeasesDynamic != 0
+	getStatic := flags&dhcpd.LeasesStatic != 0
+
+	now := time.Now()
+	for _, l := range s.srv.Leases() {
+		if l.IsStatic && getStatic || !l.IsStatic && getDynamic && l.Expiry.After(now) {
+			leases = append(leases, LeaseToLegacy(l))
+		}
+	}
+
+	return leases
+}
+
+// AddStaticLease adds the legacy static lease l to the wrapped server, see
+// [LeaseFromLegacy].
+func (s *Server) AddStaticLease(l *dhcpd.Lease) (err error) {
+	return s.srv.AddStaticLease(LeaseFromLegacy(l))
+}
+
+// RemoveStaticLease removes the legacy static lease l from the wrapped server,
+// see [LeaseFromLegacy].
+func (s *Server) RemoveStaticLease(l *dhcpd.Lease) (err error) {
+	return s.srv.RemoveStaticLease(LeaseFromLegacy(l))
+}
+
+// FindMACbyIP returns the MAC address by the IP address of its lease, if there
+// is one.  It's the legacy name of [Server.MACByIP].
+func (s *Server) FindMACbyIP(ip netip.Addr) (mac net.HardwareAddr) {
+	return s.srv.MACByIP(ip)
+}
@@ -0,0 +1,98 @@
+package dhcpdcompat_test
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc/dhcpdcompat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer returns a new adapter of the DHCP server serving DHCPv4 on a
+// single network interface and the legacy configuration it's adapted from.
+func newTestServer(t *testing.T) (s *dhcpdcompat.Server, diskConf *dhcpd.ServerConfig) {
+	t.Helper()
+
+	diskConf = &dhcpd.ServerConfig{
+		Enabled:         true,
+		InterfaceName:   "eth0",
+		LocalDomainName: "local",
+		Conf4: dhcpd.V4ServerConf{
+			Enabled:       true,
+			GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+			SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+			RangeStart:    netip.MustParseAddr("192.168.0.2"),
+			RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+			LeaseDuration: 3600,
+		},
+	}
+
+	srv, err := dhcpsvc.New(&dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: diskConf.LocalDomainName,
+		Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+			diskConf.InterfaceName: {
+				IPv4: &dhcpsvc.IPv4Config{
+					Enabled:       true,
+					GatewayIP:     diskConf.Conf4.GatewayIP,
+					SubnetMask:    diskConf.Conf4.SubnetMask,
+					RangeStart:    diskConf.Conf4.RangeStart,
+					RangeEnd:      diskConf.Conf4.RangeEnd,
+					LeaseDuration: 1 * time.Hour,
+				},
+				IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return dhcpdcompat.New(srv, diskConf), diskConf
+}
+
+func TestServer_staticLeases(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	l := &dhcpd.Lease{
+		Expiry:   time.Unix(1, 0),
+		Hostname: "host",
+		HWAddr:   testMAC,
+		IP:       testIP,
+		IsStatic: true,
+	}
+	require.NoError(t, s.AddStaticLease(l))
+
+	want := dhcpdcompat.LeaseToLegacy(dhcpdcompat.LeaseFromLegacy(l))
+	assert.Equal(t, []*dhcpd.Lease{want}, s.GetLeases(dhcpd.LeasesAll))
+	assert.Equal(t, []*dhcpd.Lease{want}, s.GetLeases(dhcpd.LeasesStatic))
+	assert.Empty(t, s.GetLeases(dhcpd.LeasesDynamic))
+
+	assert.Equal(t, testMAC, s.FindMACbyIP(testIP))
+	assert.Equal(t, testMAC, s.MACByIP(testIP))
+	assert.Equal(t, "host", s.HostByIP(testIP))
+	assert.Equal(t, testIP, s.IPByHost("host.local"))
+
+	require.NoError(t, s.RemoveStaticLease(l))
+
+	assert.NotNil(t, s.GetLeases(dhcpd.LeasesAll))
+	assert.Empty(t, s.GetLeases(dhcpd.LeasesAll))
+	assert.Empty(t, s.Leases())
+}
+
+func TestServer_WriteDiskConfig(t *testing.T) {
+	s, diskConf := newTestServer(t)
+
+	modified := func() {}
+	c := &dhcpd.ServerConfig{ConfigModified: modified}
+	s.WriteDiskConfig(c)
+
+	assert.NotNil(t, c.ConfigModified)
+	assert.Equal(t, diskConf.Enabled, c.Enabled)
+	assert.Equal(t, diskConf.InterfaceName, c.InterfaceName)
+	assert.Equal(t, diskConf.LocalDomainName, c.LocalDomainName)
+	assert.Equal(t, diskConf.Conf4, c.Conf4)
+	assert.Equal(t, diskConf.Conf6, c.Conf6)
+}
@@ -0,0 +1,55 @@
+package dhcpdcompat
+
+import (
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"golang.org/x/exp/slices"
+)
+
+// LeaseFromLegacy converts the legacy lease l into a new one.  The expiry of a
+// static lease is dropped, since the static leases don't expire, while the
+// legacy ones may have the sentinel expiry of a second after the Unix epoch
+// migrated from the legacy database.  The properties missing from the legacy
+// lease, e.g. the client identifier and the network interface name, are left
+// empty.  nl is nil if l is nil.
+func LeaseFromLegacy(l *dhcpd.Lease) (nl *dhcpsvc.Lease) {
+	if l == nil {
+		return nil
+	}
+
+	nl = &dhcpsvc.Lease{
+		Hostname: l.Hostname,
+		HWAddr:   slices.Clone(l.HWAddr),
+		IP:       l.IP,
+		IsStatic: l.IsStatic,
+	}
+
+	if !l.IsStatic {
+		nl.Expiry = l.Expiry
+	}
+
+	return nl
+}
+
+// LeaseToLegacy converts l into a legacy lease.  A static lease gets the zero
+// expiry, as those added by the legacy server do.  The properties missing from
+// the legacy lease, e.g. the client identifier, the network interface name,
+// the comment, and the DHCPv6-specific ones, are lost.  ll is nil if l is nil.
+func LeaseToLegacy(l *dhcpsvc.Lease) (ll *dhcpd.Lease) {
+	if l == nil {
+		return nil
+	}
+
+	ll = &dhcpd.Lease{
+		Hostname: l.Hostname,
+		HWAddr:   slices.Clone(l.HWAddr),
+		IP:       l.IP,
+		IsStatic: l.IsStatic,
+	}
+
+	if !l.IsStatic {
+		ll.Expiry = l.Expiry
+	}
+
+	return ll
+}
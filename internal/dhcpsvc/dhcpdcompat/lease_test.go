@@ -0,0 +1,120 @@
+package dhcpdcompat_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc/dhcpdcompat"
+	"github.com/stretchr/testify/assert"
+)
+
+// testMAC is the common hardware address for tests.
+var testMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+// testIP is the common IP address for tests.
+var testIP = netip.MustParseAddr("192.168.0.2")
+
+func TestLeaseFromLegacy(t *testing.T) {
+	expiry := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		l    *dhcpd.Lease
+		want *dhcpsvc.Lease
+		name string
+	}{{
+		l:    nil,
+		want: nil,
+		name: "nil",
+	}, {
+		l: &dhcpd.Lease{
+			Expiry:   expiry,
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+		},
+		want: &dhcpsvc.Lease{
+			Expiry:   expiry,
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+		},
+		name: "dynamic",
+	}, {
+		l: &dhcpd.Lease{
+			Expiry:   time.Unix(1, 0),
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+			IsStatic: true,
+		},
+		want: &dhcpsvc.Lease{
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+			IsStatic: true,
+		},
+		name: "static_sentinel",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, dhcpdcompat.LeaseFromLegacy(tc.l))
+		})
+	}
+}
+
+func TestLeaseToLegacy(t *testing.T) {
+	expiry := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		l    *dhcpsvc.Lease
+		want *dhcpd.Lease
+		name string
+	}{{
+		l:    nil,
+		want: nil,
+		name: "nil",
+	}, {
+		l: &dhcpsvc.Lease{
+			Expiry:        expiry,
+			Hostname:      "host",
+			HWAddr:        testMAC,
+			IP:            testIP,
+			ClientID:      []byte{0x01, 0x02},
+			InterfaceName: "eth0",
+			Comment:       "lost",
+		},
+		want: &dhcpd.Lease{
+			Expiry:   expiry,
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+		},
+		name: "dynamic_lossy",
+	}, {
+		l: &dhcpsvc.Lease{
+			Expiry:   expiry,
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+			IsStatic: true,
+		},
+		want: &dhcpd.Lease{
+			Hostname: "host",
+			HWAddr:   testMAC,
+			IP:       testIP,
+			IsStatic: true,
+		},
+		name: "static",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, dhcpdcompat.LeaseToLegacy(tc.l))
+		})
+	}
+}
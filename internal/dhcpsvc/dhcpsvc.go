@@ -1,39 +1,67 @@
 // Package dhcpsvc contains the AdGuard Home DHCP service.
 //
-// TODO(e.burkov): Add tests.
+// # Durability
+//
+// The leases are written into the database file on each change as well as on
+// [DHCPServer.Flush] and [DHCPServer.Shutdown].  The state to write is taken
+// while the leases are locked, but it's written once they're unlocked, so that
+// the disk I/O never delays serving the clients, and the concurrent writes are
+// coalesced into a single one.  The file is written into a temporary file,
+// which is synced to stable storage and then renamed over the database file,
+// after which the directory is synced as well.  So that a crash or a power
+// loss at any point leaves either the previous or the new complete database
+// file, but never a truncated one.  Still, if the database file can't be
+// decoded on loading, the readable leases are recovered from it, the corrupt
+// records are dropped, and the original file is backed up next to it.
+//
+// # Concurrency
+//
+// [DHCPServer] is safe for concurrent use.  The locks are listed below in the
+// order they're taken in, i.e. a goroutine holding one of them only takes the
+// ones listed after it:
+//
+//   - The lease index, the leases of every network interface, and the state of
+//     address allocation, including the lease durations, are protected by a
+//     single RWMutex.  It's held for reading while picking the addresses to
+//     offer and answering lookups, and for writing while reserving the offered
+//     addresses, committing leases, and taking the state to write into the
+//     database file.  It's never held while probing the addresses for
+//     conflicts.
+//
+//   - The addresses offered on each network interface are protected by a
+//     mutex of the interface, which is taken while holding the RWMutex above,
+//     but never the other way around.
+//
+//   - Writing the database file is serialized by a separate mutex, which is
+//     never held while taking another lock.
+//
+//   - The connections of the network interfaces, as well as the start and bind
+//     times, are protected by a separate mutex.  It's never held while
+//     handling messages or while taking the lease RWMutex, but it's held while
+//     reading the foreign server trackers for the status.
+//
+//   - Whether the server is enabled, the counters of allocation failures, and
+//     the counters of messages of the wrong address family and from unexpected
+//     source ports are atomic.
+//
+//   - The subscribers, the foreign server trackers, the sets of logged wrong
+//     family clients, the transaction history, and the pending clients have a
+//     mutex each, which is never held while taking another lock.
+//
+// The subscribers are notified only after the lease lock is released, so that
+// a slow subscriber never delays serving the clients.
 package dhcpsvc
 
 import (
 	"context"
 	"net"
 	"net/netip"
-	"time"
 
 	"github.com/AdguardTeam/AdGuardHome/internal/next/agh"
 )
 
-// Lease is a DHCP lease.
-//
-// TODO(e.burkov):  Consider it to [agh], since it also may be needed in
-// [websvc].  Also think of implementing iterating methods with appropriate
-// signatures.
-type Lease struct {
-	// IP is the IP address leased to the client.
-	IP netip.Addr
-
-	// Expiry is the expiration time of the lease.
-	Expiry time.Time
-
-	// Hostname of the client.
-	Hostname string
-
-	// HWAddr is the physical hardware address (MAC address).
-	HWAddr net.HardwareAddr
-
-	// IsStatic defines if the lease is static.
-	IsStatic bool
-}
-
+// Interface is the DHCP service as the other parts of AdGuard Home see it.
+// It's implemented by [*DHCPServer] and [Empty].
 type Interface interface {
 	agh.ServiceWithConfig[*Config]
 
@@ -59,39 +87,53 @@ type Interface interface {
 	// Leases returns all the DHCP leases.
 	Leases() (leases []*Lease)
 
-	// AddLease adds a new DHCP lease.  It returns an error if the lease is
-	// invalid or already exists.
-	AddLease(l *Lease) (err error)
+	// AddStaticLease adds a new static DHCP lease.  It returns an error if the
+	// lease is invalid or already exists.  The address of the lease must be
+	// within the subnet of a served network interface, and it may be within
+	// its range, as with the legacy DHCP server, in which case it's no longer
+	// allocated dynamically.
+	AddStaticLease(l *Lease) (err error)
 
-	// EditLease changes an existing DHCP lease.  It returns an error if there
-	// is no lease equal to old or if new is invalid or already exists.
-	EditLease(old, new *Lease) (err error)
+	// UpdateStaticLease changes an existing static DHCP lease.  It returns an
+	// error if there is no static lease equal to old or if new is invalid or
+	// already exists.
+	UpdateStaticLease(old, new *Lease) (err error)
 
-	// RemoveLease removes an existing DHCP lease.  It returns an error if there
-	// is no lease equal to l.
-	RemoveLease(l *Lease) (err error)
+	// RemoveStaticLease removes an existing static DHCP lease.  It returns an
+	// error if there is no static lease equal to l.
+	RemoveStaticLease(l *Lease) (err error)
 
 	// Reset removes all the DHCP leases.
 	Reset() (err error)
+
+	// Subscribe makes ch receive the events about changes of the DHCP leases.
+	// Sending to ch never blocks, so the events are dropped if ch isn't ready
+	// to receive them.  The received events must not be modified.
+	Subscribe(ch chan<- *Event)
+
+	// Unsubscribe stops sending the events to ch.
+	Unsubscribe(ch chan<- *Event)
 }
 
-// Empty is an [Interface] implementation that does nothing.
+// Empty is an [Interface] implementation that does nothing.  It's used when
+// the DHCP service is disabled or unavailable.
 type Empty struct{}
 
 // type check
-var _ Interface = Empty{}
+var _ agh.ServiceWithConfig[*Config] = Empty{}
 
-// Start implements the [Service] interface for Empty.
+// Start implements the [agh.Service] interface for Empty.
 func (Empty) Start() (err error) { return nil }
 
-// Shutdown implements the [Service] interface for Empty.
+// Shutdown implements the [agh.Service] interface for Empty.
 func (Empty) Shutdown(_ context.Context) (err error) { return nil }
 
-var _ agh.ServiceWithConfig[*Config] = Empty{}
-
-// Config implements the [ServiceWithConfig] interface for Empty.
+// Config implements the [agh.ServiceWithConfig] interface for Empty.
 func (Empty) Config() (conf *Config) { return nil }
 
+// type check
+var _ Interface = Empty{}
+
 // Enabled implements the [Interface] interface for Empty.
 func (Empty) Enabled() (ok bool) { return false }
 
@@ -104,20 +146,23 @@ func (Empty) MACByIP(_ netip.Addr) (mac net.HardwareAddr) { return nil }
 // IPByHost implements the [Interface] interface for Empty.
 func (Empty) IPByHost(_ string) (ip netip.Addr) { return netip.Addr{} }
 
-// type check
-var _ Interface = Empty{}
-
 // Leases implements the [Interface] interface for Empty.
 func (Empty) Leases() (leases []*Lease) { return nil }
 
-// AddLease implements the [Interface] interface for Empty.
-func (Empty) AddLease(_ *Lease) (err error) { return nil }
+// AddStaticLease implements the [Interface] interface for Empty.
+func (Empty) AddStaticLease(_ *Lease) (err error) { return nil }
 
-// EditLease implements the [Interface] interface for Empty.
-func (Empty) EditLease(_, _ *Lease) (err error) { return nil }
+// UpdateStaticLease implements the [Interface] interface for Empty.
+func (Empty) UpdateStaticLease(_, _ *Lease) (err error) { return nil }
 
-// RemoveLease implements the [Interface] interface for Empty.
-func (Empty) RemoveLease(_ *Lease) (err error) { return nil }
+// RemoveStaticLease implements the [Interface] interface for Empty.
+func (Empty) RemoveStaticLease(_ *Lease) (err error) { return nil }
 
 // Reset implements the [Interface] interface for Empty.
 func (Empty) Reset() (err error) { return nil }
+
+// Subscribe implements the [Interface] interface for Empty.
+func (Empty) Subscribe(_ chan<- *Event) {}
+
+// Unsubscribe implements the [Interface] interface for Empty.
+func (Empty) Unsubscribe(_ chan<- *Event) {}
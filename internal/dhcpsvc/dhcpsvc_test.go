@@ -0,0 +1,172 @@
+package dhcpsvc_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	testutil.DiscardLogOutput(m)
+}
+
+// testLocalTLD is a common local TLD for tests.
+const testLocalTLD = "local"
+
+// testTimeout is a common timeout for tests and contexts.
+const testTimeout = 10 * time.Second
+
+// testInterfaceConf is a common set of interface configurations for tests.
+var testInterfaceConf = map[string]*dhcpsvc.InterfaceConfig{
+	"eth0": {
+		IPv4: &dhcpsvc.IPv4Config{
+			Enabled:       true,
+			GatewayIP:     netip.MustParseAddr("192.168.0.1"),
+			SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+			RangeStart:    netip.MustParseAddr("192.168.0.2"),
+			RangeEnd:      netip.MustParseAddr("192.168.0.254"),
+			LeaseDuration: 1 * time.Hour,
+		},
+		IPv6: &dhcpsvc.IPv6Config{
+			Enabled:       true,
+			RangeStart:    netip.MustParseAddr("2001:db8::1"),
+			LeaseDuration: 1 * time.Hour,
+			RAAllowSLAAC:  true,
+			RASLAACOnly:   true,
+		},
+	},
+	"eth1": {
+		IPv4: &dhcpsvc.IPv4Config{
+			Enabled:       true,
+			GatewayIP:     netip.MustParseAddr("172.16.0.1"),
+			SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+			RangeStart:    netip.MustParseAddr("172.16.0.2"),
+			RangeEnd:      netip.MustParseAddr("172.16.0.254"),
+			LeaseDuration: 1 * time.Hour,
+		},
+		IPv6: &dhcpsvc.IPv6Config{
+			Enabled:       true,
+			RangeStart:    netip.MustParseAddr("2001:db9::1"),
+			LeaseDuration: 1 * time.Hour,
+			RAAllowSLAAC:  true,
+			RASLAACOnly:   true,
+		},
+	},
+}
+
+// newTestContext returns a new context with a timeout for tests.
+func newTestContext(t testing.TB) (ctx context.Context) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	return ctx
+}
+
+// testListener is a [dhcpsvc.Listener] for tests.
+type testListener struct {
+	onListenPacket func(
+		ctx context.Context,
+		ifaceName string,
+		laddr netip.AddrPort,
+	) (conn net.PacketConn, err error)
+}
+
+// type check
+var _ dhcpsvc.Listener = (*testListener)(nil)
+
+// ListenPacket implements the [dhcpsvc.Listener] interface for *testListener.
+func (l *testListener) ListenPacket(
+	ctx context.Context,
+	ifaceName string,
+	laddr netip.AddrPort,
+) (conn net.PacketConn, err error) {
+	return l.onListenPacket(ctx, ifaceName, laddr)
+}
+
+// newIdleListener returns a new *testListener opening connections that never
+// receive anything until closed.
+func newIdleListener() (l *testListener) {
+	return &testListener{
+		onListenPacket: func(
+			_ context.Context,
+			_ string,
+			laddr netip.AddrPort,
+		) (conn net.PacketConn, err error) {
+			return newIdleConn(laddr), nil
+		},
+	}
+}
+
+// newIdleConn returns a new *fakenet.PacketConn bound to laddr, which blocks
+// on reading until closed.
+func newIdleConn(laddr netip.AddrPort) (conn *fakenet.PacketConn) {
+	closed := make(chan struct{})
+	closeOnce := &sync.Once{}
+
+	return &fakenet.PacketConn{
+		OnClose: func() (err error) {
+			closeOnce.Do(func() { close(closed) })
+
+			return nil
+		},
+		OnLocalAddr: func() (addr net.Addr) { return net.UDPAddrFromAddrPort(laddr) },
+		OnReadFrom: func(_ []byte) (n int, addr net.Addr, err error) {
+			<-closed
+
+			return 0, nil, net.ErrClosed
+		},
+		OnWriteTo: func(b []byte, _ net.Addr) (n int, err error) { return len(b), nil },
+	}
+}
+
+// mustParseMAC is a helper that parses a MAC address and panics on error.
+func mustParseMAC(s string) (mac net.HardwareAddr) {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return mac
+}
+
+func TestEmpty(t *testing.T) {
+	var svc dhcpsvc.Interface = dhcpsvc.Empty{}
+
+	ip := netip.MustParseAddr("192.168.0.2")
+	l := &dhcpsvc.Lease{
+		IP:       ip,
+		Hostname: "host",
+		HWAddr:   mustParseMAC("01:02:03:04:05:06"),
+	}
+
+	assert.NoError(t, svc.Start())
+	assert.NoError(t, svc.Shutdown(newTestContext(t)))
+
+	assert.Nil(t, svc.Config())
+	assert.False(t, svc.Enabled())
+
+	assert.NoError(t, svc.AddStaticLease(l))
+	assert.NoError(t, svc.UpdateStaticLease(l, l))
+
+	assert.Empty(t, svc.HostByIP(ip))
+	assert.Nil(t, svc.MACByIP(ip))
+	assert.Equal(t, netip.Addr{}, svc.IPByHost(l.Hostname))
+	assert.Empty(t, svc.Leases())
+
+	assert.NoError(t, svc.RemoveStaticLease(l))
+	assert.NoError(t, svc.Reset())
+
+	ch := make(chan *dhcpsvc.Event, 1)
+	svc.Subscribe(ch)
+	svc.Unsubscribe(ch)
+}
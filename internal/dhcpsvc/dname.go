@@ -0,0 +1,162 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+)
+
+// maxDomainNameWireLen is the maximum length of a domain name encoded in the
+// wire format, including the length octets and the terminating root label.
+//
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-3.1.
+const maxDomainNameWireLen = 255
+
+// Kinds of labels of the domain names encoded in the wire format, defined by
+// the two most significant bits of the length octet.
+const (
+	// labelKindMask is the mask of the kind bits of the length octet.
+	labelKindMask byte = 0b1100_0000
+
+	// labelKindPointer is the kind of the compression pointer.
+	labelKindPointer byte = 0b1100_0000
+)
+
+// decodeDomainName decodes the domain name encoded in the wire format starting
+// at off within data, which may be compressed, see RFC 1035.  The compression
+// pointers are the offsets within data, and each of them must point before the
+// start of the labels it follows, so that the pointers strictly decrease, which
+// rules out the pointer loops.  name has no trailing dot, next is the offset
+// following the encoded name within data.
+//
+// See https://datatracker.ietf.org/doc/html/rfc1035#section-4.1.4.
+func decodeDomainName(data []byte, off int) (name string, next int, err error) {
+	labels := make([]string, 0, 4)
+	wireLen := 0
+	next = -1
+
+	// start is the offset of the labels being decoded.
+	start := off
+
+	for {
+		if off >= len(data) {
+			return "", 0, fmt.Errorf("offset %d: unexpected end of data", off)
+		}
+
+		l := data[off]
+		switch kind := l & labelKindMask; kind {
+		case 0:
+			// Go on.
+		case labelKindPointer:
+			if off+1 >= len(data) {
+				return "", 0, fmt.Errorf("offset %d: truncated pointer", off)
+			}
+
+			ptr := int(l&^labelKindMask)<<8 | int(data[off+1])
+			if ptr >= start {
+				return "", 0, fmt.Errorf("offset %d: pointer to %d must point before %d", off, ptr, start)
+			}
+
+			if next < 0 {
+				next = off + 2
+			}
+
+			off, start = ptr, ptr
+
+			continue
+		default:
+			return "", 0, fmt.Errorf("offset %d: unsupported label kind %#02x", off, kind)
+		}
+
+		wireLen += int(l) + 1
+		if wireLen > maxDomainNameWireLen {
+			return "", 0, fmt.Errorf("name must not be longer than %d bytes", maxDomainNameWireLen)
+		} else if l == 0 {
+			break
+		} else if off+1+int(l) > len(data) {
+			return "", 0, fmt.Errorf("offset %d: truncated label", off)
+		}
+
+		labels = append(labels, string(data[off+1:off+1+int(l)]))
+		off += 1 + int(l)
+	}
+
+	if next < 0 {
+		next = off + 1
+	}
+
+	return strings.Join(labels, "."), next, nil
+}
+
+// decodeDomainNames decodes the list of domain names encoded in the wire format
+// within data, e.g. the Domain Search option, see [decodeDomainName].
+//
+// See https://datatracker.ietf.org/doc/html/rfc3397#section-2.
+func decodeDomainNames(data []byte) (names []string, err error) {
+	for off := 0; off < len(data); {
+		var name string
+		name, off, err = decodeDomainName(data, off)
+		if err != nil {
+			return nil, fmt.Errorf("name at index %d: %w", len(names), err)
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// dhcpOptClientFQDN is the option containing the fully qualified domain name of
+// the client.  It's not defined in [layers].
+//
+// See https://datatracker.ietf.org/doc/html/rfc4702.
+const dhcpOptClientFQDN layers.DHCPOpt = 81
+
+// Flags of the Client FQDN option.
+const (
+	// fqdnFlagE means that the domain name is encoded in the wire format,
+	// otherwise it's in the deprecated ASCII encoding.
+	fqdnFlagE byte = 0b0000_0100
+)
+
+// clientFQDN4 returns the domain name sent by the client within the Client
+// FQDN option of msg.  fqdn is empty if there is no such option.
+//
+// See https://datatracker.ietf.org/doc/html/rfc4702#section-2.
+func clientFQDN4(msg *layers.DHCPv4) (fqdn string, err error) {
+	data := optData4(msg, dhcpOptClientFQDN)
+	switch {
+	case data == nil:
+		return "", nil
+	case len(data) < 3:
+		return "", fmt.Errorf("client fqdn option length %d must be at least 3", len(data))
+	case data[0]&fqdnFlagE == 0:
+		return strings.TrimSuffix(string(data[3:]), "."), nil
+	case len(data) == 3:
+		return "", nil
+	}
+
+	fqdn, _, err = decodeDomainName(data[3:], 0)
+	if err != nil {
+		return "", fmt.Errorf("client fqdn: %w", err)
+	}
+
+	return fqdn, nil
+}
+
+// requestedHostname4 returns the hostname requested by the client within msg.
+// The Host Name option takes precedence over the Client FQDN one.
+func requestedHostname4(msg *layers.DHCPv4) (hostname string) {
+	if data := optData4(msg, layers.DHCPOptHostname); data != nil {
+		return string(data)
+	}
+
+	hostname, err := clientFQDN4(msg)
+	if err != nil {
+		log.Debug("dhcpsvc: client %s: %s", msg.ClientHWAddr, err)
+	}
+
+	return hostname
+}
@@ -0,0 +1,158 @@
+package dhcpsvc
+
+import (
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeDomainName(t *testing.T) {
+	// compressed contains "host.example.org" at offset 0 and "www" followed by
+	// a pointer to "example.org" at offset 18.
+	compressed := []byte{
+		4, 'h', 'o', 's', 't',
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
+		3, 'o', 'r', 'g',
+		0,
+		3, 'w', 'w', 'w',
+		0xC0, 5,
+	}
+
+	testCases := []struct {
+		name       string
+		wantName   string
+		wantErrMsg string
+		data       []byte
+		off        int
+		wantNext   int
+	}{{
+		name:       "uncompressed",
+		wantName:   "host.example.org",
+		wantErrMsg: "",
+		data:       compressed,
+		off:        0,
+		wantNext:   18,
+	}, {
+		name:       "compressed",
+		wantName:   "www.example.org",
+		wantErrMsg: "",
+		data:       compressed,
+		off:        18,
+		wantNext:   24,
+	}, {
+		name:       "root",
+		wantName:   "",
+		wantErrMsg: "",
+		data:       []byte{0},
+		off:        0,
+		wantNext:   1,
+	}, {
+		name:       "loop",
+		wantName:   "",
+		wantErrMsg: "offset 4: pointer to 0 must point before 0",
+		data:       []byte{3, 'w', 'w', 'w', 0xC0, 0},
+		off:        4,
+		wantNext:   0,
+	}, {
+		name:       "self_loop",
+		wantName:   "",
+		wantErrMsg: "offset 0: pointer to 0 must point before 0",
+		data:       []byte{0xC0, 0},
+		off:        0,
+		wantNext:   0,
+	}, {
+		name:       "truncated_label",
+		wantName:   "",
+		wantErrMsg: "offset 0: truncated label",
+		data:       []byte{4, 'h', 'o'},
+		off:        0,
+		wantNext:   0,
+	}, {
+		name:       "no_root",
+		wantName:   "",
+		wantErrMsg: "offset 4: unexpected end of data",
+		data:       []byte{3, 'w', 'w', 'w'},
+		off:        0,
+		wantNext:   0,
+	}, {
+		name:       "bad_kind",
+		wantName:   "",
+		wantErrMsg: "offset 0: unsupported label kind 0x40",
+		data:       []byte{0x41, 'a', 0},
+		off:        0,
+		wantNext:   0,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			name, next, err := decodeDomainName(tc.data, tc.off)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			assert.Equal(t, tc.wantName, name)
+			assert.Equal(t, tc.wantNext, next)
+		})
+	}
+}
+
+func TestDecodeDomainNames(t *testing.T) {
+	data := []byte{
+		7, 'e', 'x', 'a', 'm', 'p', 'l', 'e',
+		3, 'o', 'r', 'g',
+		0,
+		3, 'l', 'a', 'n',
+		0xC0, 0,
+	}
+
+	names, err := decodeDomainNames(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.org", "lan.example.org"}, names)
+
+	_, err = decodeDomainNames(append(data, 0xC0, 20))
+	testutil.AssertErrorMsg(t, "name at index 2: offset 19: pointer to 20 must point before 19", err)
+}
+
+func TestRequestedHostname4(t *testing.T) {
+	wireFQDN := []byte{fqdnFlagE, 0, 0, 5, 'p', 'h', 'o', 'n', 'e', 3, 'l', 'a', 'n', 0}
+
+	testCases := []struct {
+		name string
+		want string
+		opts layers.DHCPOptions
+	}{{
+		name: "none",
+		want: "",
+		opts: nil,
+	}, {
+		name: "hostname",
+		want: "phone",
+		opts: layers.DHCPOptions{
+			layers.NewDHCPOption(layers.DHCPOptHostname, []byte("phone")),
+			layers.NewDHCPOption(dhcpOptClientFQDN, wireFQDN),
+		},
+	}, {
+		name: "fqdn_wire",
+		want: "phone.lan",
+		opts: layers.DHCPOptions{layers.NewDHCPOption(dhcpOptClientFQDN, wireFQDN)},
+	}, {
+		name: "fqdn_ascii",
+		want: "phone.lan",
+		opts: layers.DHCPOptions{
+			layers.NewDHCPOption(dhcpOptClientFQDN, append([]byte{0, 0, 0}, "phone.lan."...)),
+		},
+	}, {
+		name: "fqdn_bad",
+		want: "",
+		opts: layers.DHCPOptions{
+			layers.NewDHCPOption(dhcpOptClientFQDN, []byte{fqdnFlagE, 0, 0, 0xC0, 0}),
+		},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := &layers.DHCPv4{Options: tc.opts}
+			assert.Equal(t, tc.want, requestedHostname4(msg))
+		})
+	}
+}
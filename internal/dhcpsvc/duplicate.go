@@ -0,0 +1,85 @@
+package dhcpsvc
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
+// DuplicateReason is the reason for a lease to be considered a duplicate of
+// another one.
+type DuplicateReason uint8
+
+// DuplicateReason values.
+const (
+	// DuplicateReasonIP means that the lease has the same IP address as
+	// another one.
+	DuplicateReasonIP DuplicateReason = iota + 1
+
+	// DuplicateReasonMAC means that the lease has the same hardware address as
+	// another one on the same network interface.
+	DuplicateReasonMAC
+)
+
+// String implements the [fmt.Stringer] interface for DuplicateReason.
+func (r DuplicateReason) String() (s string) {
+	switch r {
+	case DuplicateReasonIP:
+		return "ip"
+	case DuplicateReasonMAC:
+		return "mac"
+	default:
+		return fmt.Sprintf("!bad_duplicate_reason_%d", r)
+	}
+}
+
+// DuplicateLease is an error describing a lease, which hasn't been added since
+// it duplicates a previously added one.
+type DuplicateLease struct {
+	// Skipped is the lease, which hasn't been added.
+	Skipped *Lease
+
+	// Kept is the previously added lease.
+	Kept *Lease
+
+	// Reason is the reason for Skipped to be considered a duplicate of Kept.
+	Reason DuplicateReason
+}
+
+// type check
+var _ error = (*DuplicateLease)(nil)
+
+// Error implements the error interface for *DuplicateLease.
+func (d *DuplicateLease) Error() (msg string) {
+	switch d.Reason {
+	case DuplicateReasonIP:
+		return fmt.Sprintf("lease for ip %s already exists", d.Skipped.IP)
+	case DuplicateReasonMAC:
+		return fmt.Sprintf("lease for mac %s already exists", d.Skipped.HWAddr)
+	default:
+		return fmt.Sprintf("duplicate lease: %s", d.Reason)
+	}
+}
+
+// duplicateOf returns the error describing l as a duplicate of a lease already
+// held by iface, if any.  l must have a valid hardware address.  srv.leasesMu
+// is expected to be locked.
+func (srv *DHCPServer) duplicateOf(l *Lease, iface *netInterface) (dup *DuplicateLease) {
+	if kept, ok := srv.leases.leaseByAddr(l.IP); ok {
+		return &DuplicateLease{Skipped: l.Clone(), Kept: kept.Clone(), Reason: DuplicateReasonIP}
+	} else if kept, ok = iface.leases[newLeaseKey(l)]; ok {
+		return &DuplicateLease{Skipped: l.Clone(), Kept: kept.Clone(), Reason: DuplicateReasonMAC}
+	}
+
+	return nil
+}
+
+// DuplicateLeases returns the leases skipped while loading the database, since
+// they duplicate the ones loaded before.  The returned leases must not be
+// modified.
+func (srv *DHCPServer) DuplicateLeases() (dups []*DuplicateLease) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	return slices.Clone(srv.duplicates)
+}
@@ -0,0 +1,140 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/google/gopacket/layers"
+)
+
+// ClientHint describes a client, either a sample one to preview the options
+// sent to, see [DHCPServer.EffectiveOptions], or the one to classify, see
+// [Config.Classifier].  All its fields are optional.
+type ClientHint struct {
+	// MAC is the hardware address of the client.  If empty, the client is
+	// considered unknown, i.e. having no lease and matching no option profile
+	// by the hardware address.
+	MAC net.HardwareAddr
+
+	// VendorClass is the Vendor Class Identifier option sent by the client.
+	VendorClass string
+
+	// UserClass is the User Class option sent by the client.
+	UserClass []byte
+
+	// Hostname is the Host Name option sent by the client.
+	Hostname string
+}
+
+// unknownClientMAC is the hardware address used for previewing the options
+// sent to an unknown client, see [ClientHint.MAC].
+var unknownClientMAC = net.HardwareAddr{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// newClientHint4 returns the description of the client sent req.
+func newClientHint4(req *layers.DHCPv4) (h *ClientHint) {
+	return &ClientHint{
+		MAC:         req.ClientHWAddr,
+		VendorClass: string(optData4(req, layers.DHCPOptClassID)),
+		UserClass:   optData4(req, dhcpOptUserClass),
+		Hostname:    requestedHostname4(req),
+	}
+}
+
+// options returns the options sent by the client described by h.
+func (h *ClientHint) options() (opts layers.DHCPOptions) {
+	if h.VendorClass != "" {
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOptClassID, []byte(h.VendorClass)))
+	}
+
+	if len(h.UserClass) > 0 {
+		opts = append(opts, layers.NewDHCPOption(dhcpOptUserClass, h.UserClass))
+	}
+
+	if h.Hostname != "" {
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOptHostname, []byte(h.Hostname)))
+	}
+
+	return opts
+}
+
+// EffectiveOptions returns the options in the order those would be sent
+// within DHCPACK to the client described by hint on the network interface with
+// the given name, i.e. with the defaults, the options of the matching profile,
+// and the expanded templates applied.  Nothing is sent and no leases are
+// changed.  If the client has no lease, the options are computed as if it's
+// granted a new one, except for the Host Name option, since the hostname may
+// depend on the address, which isn't known yet.
+//
+// TODO(e.burkov):  Expose as the preview endpoint once the HTTP API uses the
+// service.
+func (srv *DHCPServer) EffectiveOptions(ifaceName string, hint *ClientHint) (opts []Option, err error) {
+	defer func() { err = errors.Annotate(err, "effective options for %q: %w", ifaceName) }()
+
+	iface := srv.iface4ByName(ifaceName)
+	if iface == nil {
+		return nil, errors.Error("no such ipv4 interface")
+	}
+
+	mac := hint.MAC
+	if len(mac) == 0 {
+		mac = unknownClientMAC
+	}
+
+	err = netutil.ValidateMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("client hardware address: %w", err)
+	}
+
+	msg := &layers.DHCPv4{
+		Operation:    layers.DHCPOpRequest,
+		HardwareType: layers.LinkTypeEthernet,
+		HardwareLen:  uint8(len(mac)),
+		ClientHWAddr: mac,
+		Options:      append(layers.DHCPOptions{newMsgTypeOpt4(layers.DHCPMsgTypeRequest)}, hint.options()...),
+	}
+
+	// Call the configured functions with srv.leasesMu unlocked, since those may
+	// be slow.
+	class := srv.classify4(iface, msg)
+	l, ttl := srv.previewLease4(iface, msg, class)
+	resp := srv.newAck4(iface, msg, l, ttl, class)
+	orderOpts4(resp, msg)
+	fitReply4(resp, maxMsgSize4(msg))
+
+	for _, opt := range resp.Options {
+		opts = append(opts, newOptionFromDHCP(opt))
+	}
+
+	return opts, nil
+}
+
+// previewLease4 returns the lease which would be granted on iface for ttl to
+// the client of the given class, which has sent msg.  l has no address if the
+// client has no lease on iface.
+func (srv *DHCPServer) previewLease4(
+	iface *iface4,
+	msg *layers.DHCPv4,
+	class string,
+) (l *Lease, ttl time.Duration) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	ttl = srv.leaseTTL4(iface, msg, class)
+
+	mac := msg.ClientHWAddr
+	prev, ok := iface.leases[leaseKey{mac: macToKey(mac)}]
+	if !ok {
+		return &Lease{HWAddr: mac}, ttl
+	}
+
+	l = prev.Clone()
+	if !prev.IsStatic {
+		requested := requestedHostname4(msg)
+		l.Hostname = srv.clientHostname(requested, prev.IP, prev).hostname
+	}
+
+	return l, ttl
+}
@@ -0,0 +1,121 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_EffectiveOptions(t *testing.T) {
+	const ifaceName = "eth0"
+
+	conf := newTestIPv4Config()
+	conf.EchoHostname = true
+	conf.LeaseClasses = []*LeaseClass{{
+		Name:          "guest",
+		VendorClass:   "guest-device",
+		LeaseDuration: 10 * time.Minute,
+	}}
+	conf.Options = []Option{
+		OptionIP(uint8(layers.DHCPOptNTPServers), netip.MustParseAddr("192.168.0.123")),
+	}
+
+	iotDNS := netip.MustParseAddr("192.168.0.53")
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		OptionProfiles: []*OptionProfile{{
+			Name:        "iot",
+			VendorClass: "iot-device",
+			Options:     []Option{OptionIP(uint8(layers.DHCPOptDNS), iotDNS)},
+		}},
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// ack returns the DHCPACK reply to the client described by hint, which
+	// requests ip.
+	ack := func(t *testing.T, hint *ClientHint, ip netip.Addr) (resp *layers.DHCPv4) {
+		t.Helper()
+
+		reqOpts := append(hint.options(), layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice()))
+		resp, handleErr := srv.handle4(ifaceName, newTestRequest4(hint.MAC, layers.DHCPMsgTypeRequest, reqOpts...))
+		require.NoError(t, handleErr)
+		require.NotNil(t, resp)
+		require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+		return resp
+	}
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	t.Run("same_as_ack", func(t *testing.T) {
+		hint := &ClientHint{
+			MAC:         mac,
+			VendorClass: "guest-device",
+			Hostname:    "Phone",
+		}
+		resp := ack(t, hint, netip.MustParseAddr("192.168.0.2"))
+
+		opts, effErr := srv.EffectiveOptions(ifaceName, hint)
+		require.NoError(t, effErr)
+
+		assert.Equal(t, resp.Options, dhcpOptions(opts))
+		assert.Equal(t, []byte("phone"), optData4(resp, layers.DHCPOptHostname))
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		hint := &ClientHint{
+			MAC:         net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x03},
+			VendorClass: "iot-device",
+			UserClass:   []byte("sensors"),
+		}
+		resp := ack(t, hint, netip.MustParseAddr("192.168.0.3"))
+
+		opts, effErr := srv.EffectiveOptions(ifaceName, hint)
+		require.NoError(t, effErr)
+
+		assert.Equal(t, resp.Options, dhcpOptions(opts))
+		assert.Equal(t, iotDNS, optIP4(resp, layers.DHCPOptDNS))
+	})
+
+	t.Run("no_changes", func(t *testing.T) {
+		before := srv.Leases()
+
+		_, effErr := srv.EffectiveOptions(ifaceName, &ClientHint{
+			MAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+		})
+		require.NoError(t, effErr)
+
+		_, effErr = srv.EffectiveOptions(ifaceName, &ClientHint{})
+		require.NoError(t, effErr)
+
+		assert.ElementsMatch(t, before, srv.Leases())
+	})
+
+	t.Run("unknown_interface", func(t *testing.T) {
+		_, effErr := srv.EffectiveOptions("eth1", &ClientHint{MAC: mac})
+		testutil.AssertErrorMsg(t, `effective options for "eth1": no such ipv4 interface`, effErr)
+	})
+
+	t.Run("bad_mac", func(t *testing.T) {
+		_, effErr := srv.EffectiveOptions(ifaceName, &ClientHint{MAC: mac[:2]})
+		testutil.AssertErrorMsg(
+			t,
+			`effective options for "eth0": client hardware address: `+
+				`bad mac address "02:00": bad mac address length 2, allowed: [6 8 20]`,
+			effErr,
+		)
+	})
+}
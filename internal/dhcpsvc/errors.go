@@ -0,0 +1,149 @@
+package dhcpsvc
+
+import (
+	"fmt"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+const (
+	// errNilConfig is returned when a nil config met.
+	errNilConfig errors.Error = "config is nil"
+
+	// errNoInterfaces is returned when no interfaces found in configuration.
+	errNoInterfaces errors.Error = "no interfaces specified"
+
+	// errNoRange4 is returned when DHCPv4 is enabled on an interface, but its
+	// address range isn't configured.
+	errNoRange4 errors.Error = "ipv4 enabled but range not configured"
+
+	// errNoRange6 is returned when DHCPv6 is enabled on an interface, but its
+	// address range isn't configured.
+	errNoRange6 errors.Error = "ipv6 enabled but range not configured"
+)
+
+const (
+	// ErrRangeInverted is returned when the start of an IP address range isn't
+	// less than its end.
+	ErrRangeInverted errors.Error = "start is greater than or equal to end"
+
+	// ErrRangeCrossFamily is returned when the start and the end of an IP
+	// address range belong to different address families.
+	ErrRangeCrossFamily errors.Error = "start and end must be within the same address family"
+
+	// ErrRangeTooLarge is returned when an IP address range contains too many
+	// addresses.
+	ErrRangeTooLarge errors.Error = "range is too large"
+)
+
+// newMustErr returns an error that indicates that valName must be as must
+// describes.
+func newMustErr(valName, must string, val fmt.Stringer) (err error) {
+	return fmt.Errorf("%s %s must %s", valName, val, must)
+}
+
+// Kinds of the errors returned by the static lease methods of [DHCPServer].
+// Use [errors.Is] to check the kind of an error.
+//
+// TODO(e.burkov):  Map the kinds to the HTTP status codes once the HTTP API
+// uses the service.
+const (
+	// ErrStaticLeaseInvalid is the kind of the errors caused by an invalid
+	// lease, e.g. a malformed hardware address or hostname.
+	ErrStaticLeaseInvalid errors.Error = "invalid static lease"
+
+	// ErrStaticLeaseConflict is the kind of the errors caused by a lease
+	// duplicating the IP address, hardware address, or hostname of another
+	// lease.
+	ErrStaticLeaseConflict errors.Error = "static lease conflict"
+
+	// ErrStaticLeaseNoInterface is the kind of the errors caused by a lease
+	// with an IP address not within any of the served networks.
+	ErrStaticLeaseNoInterface errors.Error = "no interface for static lease"
+
+	// ErrStaticLeaseNotFound is the kind of the errors caused by a missing
+	// static lease to update or remove.
+	ErrStaticLeaseNotFound errors.Error = "static lease not found"
+
+	// ErrStaticLeaseStore is the kind of the errors caused by a failure to
+	// persist the leases.  Unlike the other kinds, the change is applied
+	// nevertheless and the subscribers are notified about it, but it's lost on
+	// restart unless the leases are stored later.
+	ErrStaticLeaseStore errors.Error = "storing static leases"
+)
+
+// StaticLeaseError is the error returned by the static lease methods of
+// [DHCPServer].  Its message is the one of the underlying error.
+type StaticLeaseError struct {
+	// Err is the underlying error.  It must not be nil.
+	Err error
+
+	// Kind is the kind of the error.  It's one of ErrStaticLeaseInvalid,
+	// ErrStaticLeaseConflict, ErrStaticLeaseNoInterface,
+	// ErrStaticLeaseNotFound, and ErrStaticLeaseStore.
+	Kind errors.Error
+}
+
+// type check
+var _ error = (*StaticLeaseError)(nil)
+
+// Error implements the [error] interface for *StaticLeaseError.
+func (err *StaticLeaseError) Error() (msg string) {
+	return err.Err.Error()
+}
+
+// Unwrap returns both the kind and the underlying error of err, so that
+// [errors.Is] matches either of them.
+func (err *StaticLeaseError) Unwrap() (errs []error) {
+	return []error{err.Kind, err.Err}
+}
+
+// newStaticLeaseErr returns err classified as kind.  It returns nil if err is
+// nil.
+func newStaticLeaseErr(kind errors.Error, err error) (wrapped error) {
+	if err == nil {
+		return nil
+	}
+
+	return &StaticLeaseError{
+		Err:  err,
+		Kind: kind,
+	}
+}
+
+// ErrInterfaceNotFound is returned when there is no network interface served
+// by the DHCP server with the requested name and address family.  Use
+// [errors.Is] to check for it.
+const ErrInterfaceNotFound errors.Error = "interface not found"
+
+// ErrPoolExhausted is the kind of the errors caused by having no free addresses
+// left within the range of a network interface.  Use [errors.Is] to check for
+// it and [errors.As] with [*PoolExhaustedError] to get the network interface.
+const ErrPoolExhausted errors.Error = "pool exhausted"
+
+// PoolExhaustedError is the error returned when an address can't be allocated
+// on a network interface since its pool is exhausted.
+type PoolExhaustedError struct {
+	// InterfaceName is the name of the network interface, which pool is
+	// exhausted.
+	InterfaceName string
+}
+
+// type check
+var _ error = (*PoolExhaustedError)(nil)
+
+// Error implements the [error] interface for *PoolExhaustedError.
+func (err *PoolExhaustedError) Error() (msg string) {
+	return fmt.Sprintf("interface %q: %s", err.InterfaceName, ErrPoolExhausted)
+}
+
+// Unwrap returns [ErrPoolExhausted], so that [errors.Is] matches it.
+func (err *PoolExhaustedError) Unwrap() (unwrapped error) {
+	return ErrPoolExhausted
+}
+
+// newPoolExhaustedErr returns the error about the exhausted pool of the network
+// interface with ifaceName.
+func newPoolExhaustedErr(ifaceName string) (err error) {
+	return &PoolExhaustedError{InterfaceName: ifaceName}
+}
@@ -0,0 +1,164 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"golang.org/x/exp/slices"
+)
+
+// EventType is the type of a change of a DHCP lease.
+type EventType uint8
+
+// EventType values.
+const (
+	// EventTypeAdded means that the lease has been added.
+	EventTypeAdded EventType = iota + 1
+
+	// EventTypeUpdated means that the lease has been changed.
+	EventTypeUpdated
+
+	// EventTypeRemoved means that the lease has been removed.
+	EventTypeRemoved
+
+	// EventTypeExhausted means that the lease hasn't been granted, since the
+	// maximum number of leases is reached.
+	EventTypeExhausted
+
+	// EventTypeRenamed means that the hostname of the dynamic lease has been
+	// changed, since a static lease has taken it.
+	EventTypeRenamed
+
+	// EventTypeDeprecated means that the address of the dynamic lease is no
+	// longer within the range of its network interface, so the lease won't be
+	// renewed and the client will have to acquire another address.
+	EventTypeDeprecated
+
+	// EventTypeExpired means that the dynamic DHCPv4 lease has expired.  The
+	// lease is kept, so that the client may acquire the same address again,
+	// but its address is no longer bound, see [DHCPServer.Bindings].  The
+	// expired DHCPv6 leases are removed instead.
+	EventTypeExpired
+)
+
+// String implements the [fmt.Stringer] interface for EventType.
+func (t EventType) String() (s string) {
+	switch t {
+	case EventTypeAdded:
+		return "added"
+	case EventTypeUpdated:
+		return "updated"
+	case EventTypeRemoved:
+		return "removed"
+	case EventTypeExhausted:
+		return "exhausted"
+	case EventTypeRenamed:
+		return "renamed"
+	case EventTypeDeprecated:
+		return "deprecated"
+	case EventTypeExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("!bad_event_type_%d", t)
+	}
+}
+
+// Event is a notification about a change of a DHCP lease.
+type Event struct {
+	// Lease is a copy of the changed lease.  For [EventTypeRemoved] it's the
+	// removed lease.  For [EventTypeExhausted] it's the lease refused to the
+	// client, which has no address.
+	Lease *Lease
+
+	// Prev is a copy of the lease before the change.  It's only set for
+	// [EventTypeUpdated].
+	Prev *Lease
+
+	// Type is the type of the change.
+	Type EventType
+}
+
+// maxDrops is the number of events dropped in a row, after which the
+// subscriber is considered stuck.
+const maxDrops = 16
+
+// subscribers is the set of channels receiving [Event]s.  It is safe for
+// concurrent use.
+type subscribers struct {
+	// mu protects chans and drops.
+	mu *sync.Mutex
+
+	// drops are the numbers of events dropped in a row for each channel.
+	drops map[chan<- *Event]uint
+
+	// chans are the channels to send events to.
+	chans []chan<- *Event
+}
+
+// newSubscribers returns a new empty set of subscribers.
+func newSubscribers() (s *subscribers) {
+	return &subscribers{
+		mu:    &sync.Mutex{},
+		drops: map[chan<- *Event]uint{},
+	}
+}
+
+// add adds ch to s, if it's not already there.
+func (s *subscribers) add(ch chan<- *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !slices.Contains(s.chans, ch) {
+		s.chans = append(s.chans, ch)
+	}
+}
+
+// remove removes ch from s, if it's there.
+func (s *subscribers) remove(ch chan<- *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if i := slices.Index(s.chans, ch); i >= 0 {
+		s.chans = slices.Delete(s.chans, i, i+1)
+	}
+
+	delete(s.drops, ch)
+}
+
+// notify sends evs to every subscriber.  It never blocks, so the events are
+// dropped for the subscribers not ready to receive them.  It must not be called
+// with any lease locks held.
+func (s *subscribers) notify(evs ...*Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.chans {
+		for _, ev := range evs {
+			select {
+			case ch <- ev:
+				s.drops[ch] = 0
+			default:
+				s.drops[ch]++
+				log.Debug("dhcpsvc: dropped %s event for %s", ev.Type, ev.Lease.IP)
+			}
+		}
+	}
+}
+
+// check returns an error if any of the subscribers is stuck, i.e. its channel
+// is full and at least [maxDrops] events have been dropped for it in a row.
+func (s *subscribers) check() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for i, ch := range s.chans {
+		if n := s.drops[ch]; n >= maxDrops && len(ch) == cap(ch) {
+			errs = append(errs, fmt.Errorf("subscriber %d: %d events dropped in a row", i, n))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,117 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// newEpoch returns a new random identifier of the server instance.
+func newEpoch() (epoch string) {
+	b := make([]byte, 8)
+
+	// crypto/rand.Read never fails on the supported platforms.
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// markExit makes the database file written afterwards mark the leases as
+// granted by this instance of the server, if [Config.ReleaseOnExit] is set.
+// Otherwise, or if exiting is false, the mark is removed.
+func (srv *DHCPServer) markExit(exiting bool) {
+	srv.leasesMu.Lock()
+	defer srv.leasesMu.Unlock()
+
+	if exiting && srv.conf.ReleaseOnExit {
+		srv.exitEpoch = srv.epoch
+	} else {
+		srv.exitEpoch = ""
+	}
+}
+
+// startReconfirm starts the goroutine reconfirming the unconfirmed leases, if
+// there are any and the probing is enabled.
+func (srv *DHCPServer) startReconfirm() {
+	if srv.conf.ConflictProber == nil || srv.conf.ICMPTimeout <= 0 {
+		return
+	}
+
+	unconfirmed := srv.unconfirmedLeases()
+	if len(unconfirmed) == 0 {
+		return
+	}
+
+	srv.wg.Add(1)
+	go srv.reconfirm(unconfirmed)
+}
+
+// unconfirmedLeases returns the copies of the unconfirmed leases.
+func (srv *DHCPServer) unconfirmedLeases() (ls []*Lease) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		if l.unconfirmed {
+			ls = append(ls, l.Clone())
+		}
+
+		return true
+	})
+
+	return ls
+}
+
+// reconfirm probes the addresses of unconfirmed, confirms the leases, which
+// addresses are in use, and releases the others.  The leases changed since
+// unconfirmed have been collected are kept as is.  It's intended to be used as
+// a goroutine.
+func (srv *DHCPServer) reconfirm(unconfirmed []*Lease) {
+	defer srv.wg.Done()
+	defer log.OnPanic("dhcpsvc: reconfirming leases")
+
+	inUse := make([]bool, len(unconfirmed))
+	for i, l := range unconfirmed {
+		inUse[i] = srv.probeInUse(l)
+	}
+
+	var evs []*Event
+	err := srv.withLeasesStored(func() (st *dbState, err error) {
+		for i, u := range unconfirmed {
+			l, ok := srv.leases.leaseByAddr(u.IP)
+			if !ok || !l.unconfirmed || !bytes.Equal(l.HWAddr, u.HWAddr) {
+				continue
+			}
+
+			if inUse[i] {
+				l.unconfirmed = false
+
+				continue
+			}
+
+			iface := srv.ifaceByName(l.InterfaceName, l.IP.Is4())
+			err = srv.leases.remove(l, iface)
+			if err != nil {
+				return nil, fmt.Errorf("removing lease for %s: %w", l.IP, err)
+			}
+
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeRemoved})
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		log.Error("dhcpsvc: reconfirming leases: %s", err)
+
+		return
+	}
+
+	log.Info("dhcpsvc: released %d of %d unconfirmed leases", len(evs), len(unconfirmed))
+
+	if len(evs) > 0 {
+		srv.subscribers.notify(evs...)
+	}
+}
@@ -0,0 +1,118 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_Shutdown_releaseOnExit(t *testing.T) {
+	ipA := netip.MustParseAddr("192.168.0.2")
+	ipB := netip.MustParseAddr("192.168.0.3")
+
+	macA := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x0A}
+	macB := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x0B}
+
+	testCases := []struct {
+		name          string
+		wantProbed    []netip.Addr
+		wantHosts     []string
+		releaseOnExit bool
+	}{{
+		name:          "released",
+		wantProbed:    []netip.Addr{ipA, ipB},
+		wantHosts:     []string{"hosta", ""},
+		releaseOnExit: true,
+	}, {
+		name:          "default",
+		wantProbed:    nil,
+		wantHosts:     []string{"hosta", "hostb"},
+		releaseOnExit: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+
+			// Only the address of the first client is in use once the server
+			// is restarted.
+			probedMu := &sync.Mutex{}
+			var probed []netip.Addr
+			restarted := false
+
+			newServer := func() (srv *DHCPServer) {
+				srv, err := New(&Config{
+					Enabled:         true,
+					LocalDomainName: "local",
+					DBFilePath:      dbFilePath,
+					Listener:        newTestListener(nil, nil),
+					ConflictProber: func(_ context.Context, _ string, ip netip.Addr) (ok bool, err error) {
+						probedMu.Lock()
+						defer probedMu.Unlock()
+
+						if !restarted {
+							return false, nil
+						}
+
+						probed = append(probed, ip)
+
+						return ip == ipA, nil
+					},
+					ICMPTimeout:   time.Second,
+					ReleaseOnExit: tc.releaseOnExit,
+					Interfaces: map[string]*InterfaceConfig{
+						"eth0": {
+							IPv4: newTestIPv4Config(),
+							IPv6: &IPv6Config{Enabled: false},
+						},
+					},
+				})
+				require.NoError(t, err)
+
+				return srv
+			}
+
+			srv := newServer()
+			require.NoError(t, srv.Start())
+
+			requestLease4(t, srv, macA, ipA, "hosta")
+			requestLease4(t, srv, macB, ipB, "hostb")
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			require.NoError(t, srv.Shutdown(ctx))
+
+			probedMu.Lock()
+			restarted = true
+			probedMu.Unlock()
+
+			srv = newServer()
+
+			// The leases of the previous instance are resolved before those
+			// are reconfirmed.
+			assert.Equal(t, "hosta", srv.HostByIP(ipA))
+			assert.Equal(t, "hostb", srv.HostByIP(ipB))
+
+			startTestServer(t, srv)
+			if tc.releaseOnExit {
+				require.Eventually(t, func() (ok bool) {
+					return len(srv.unconfirmedLeases()) == 0
+				}, time.Second, time.Millisecond)
+			}
+
+			probedMu.Lock()
+			defer probedMu.Unlock()
+
+			assert.ElementsMatch(t, tc.wantProbed, probed)
+			assert.Equal(t, tc.wantHosts, []string{srv.HostByIP(ipA), srv.HostByIP(ipB)})
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+
+	"golang.org/x/exp/maps"
+)
+
+// expireLeases6 removes the dynamic leases of iface, which valid lifetimes are
+// over, and notifies the subscribers about those.  It's called when the
+// messages are received on iface and by [DHCPServer.sweep].
+func (srv *DHCPServer) expireLeases6(iface *iface6) (err error) {
+	now := srv.now()
+	if !srv.hasExpired6(iface) {
+		return nil
+	}
+
+	var evs []*Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		for _, l := range maps.Values(iface.leases) {
+			if !l.isExpired6(now) {
+				continue
+			}
+
+			err = srv.leases.remove(l, &iface.netInterface)
+			if err != nil {
+				return nil, fmt.Errorf("removing expired lease for %s: %w", l.IP, err)
+			}
+
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeRemoved})
+		}
+
+		if len(evs) == 0 {
+			return nil, nil
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("expiring leases: %w", err)
+	}
+
+	if len(evs) > 0 {
+		srv.subscribers.notify(evs...)
+	}
+
+	return nil
+}
+
+// reclaimExpired4 removes the expired dynamic DHCPv4 lease for ip, if any, so
+// that the address may be leased to another client.  ev is the event about the
+// removed lease, it's nil if there is no such lease.  srv.leasesMu is expected
+// to be locked.
+func (srv *DHCPServer) reclaimExpired4(ip netip.Addr) (ev *Event, err error) {
+	l, ok := srv.leases.leaseByAddr(ip)
+	if !ok || !l.isExpired4(srv.now()) {
+		return nil, nil
+	}
+
+	owner := srv.iface4ByName(l.InterfaceName)
+	if owner == nil {
+		return nil, fmt.Errorf("reclaiming expired lease for %s: no interface %q", ip, l.InterfaceName)
+	}
+
+	err = srv.leases.remove(l, &owner.netInterface)
+	if err != nil {
+		return nil, fmt.Errorf("reclaiming expired lease for %s: %w", ip, err)
+	}
+
+	log.Debug("dhcpsvc: interface %q: reclaimed expired lease for %s", owner.name, ip)
+
+	return &Event{Lease: l.Clone(), Type: EventTypeRemoved}, nil
+}
+
+// hasExpired6 returns true if iface has at least a single dynamic lease, which
+// valid lifetime is over.
+func (srv *DHCPServer) hasExpired6(iface *iface6) (ok bool) {
+	now := srv.now()
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	for _, l := range iface.leases {
+		if l.isExpired6(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportExpired4 notifies the subscribers about the dynamic leases of iface
+// expired since the previous call.  Unlike the DHCPv6 ones, those are kept
+// until their addresses are leased to other clients, so that the clients may
+// acquire the same addresses again.  It's called when the messages are received
+// on iface and by [DHCPServer.sweep], so the expired leases may be reported up
+// to [sweepInterval] late.
+func (srv *DHCPServer) reportExpired4(iface *iface4) {
+	now := srv.now()
+	if !srv.hasExpired4(iface, now) {
+		return
+	}
+
+	var evs []*Event
+	func() {
+		srv.leasesMu.Lock()
+		defer srv.leasesMu.Unlock()
+
+		for _, l := range iface.leases {
+			if iface.expiredSinceSweep(l, now) {
+				evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeExpired})
+			}
+		}
+
+		iface.expirySwept = now
+	}()
+
+	srv.subscribers.notify(evs...)
+}
+
+// hasExpired4 returns true if iface has at least a single dynamic lease expired
+// since the previous call of [DHCPServer.reportExpired4].
+func (srv *DHCPServer) hasExpired4(iface *iface4, now time.Time) (ok bool) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	for _, l := range iface.leases {
+		if iface.expiredSinceSweep(l, now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expiredSinceSweep returns true if l is a dynamic lease expired after
+// iface.expirySwept and not later than now.  [DHCPServer.leasesMu] is expected
+// to be locked.
+func (iface *iface4) expiredSinceSweep(l *Lease, now time.Time) (ok bool) {
+	return !l.IsStatic && iface.expirySwept.Before(l.Expiry) && !now.Before(l.Expiry)
+}
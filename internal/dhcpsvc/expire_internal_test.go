@@ -0,0 +1,205 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// iaAddrLifetimes6 returns the preferred and valid lifetimes of the address
+// within the first IA_NA option of msg.
+func iaAddrLifetimes6(t *testing.T, msg *layers.DHCPv6) (preferred, valid uint32) {
+	t.Helper()
+
+	iaNA := optData6(msg, layers.DHCPv6OptIANA)
+	require.GreaterOrEqual(t, len(iaNA), iaNAHdrLen6)
+
+	data := nestedOptData6(iaNA[iaNAHdrLen6:], layers.DHCPv6OptIAAddr)
+	require.GreaterOrEqual(t, len(data), iaAddrHdrLen6)
+
+	return binary.BigEndian.Uint32(data[net.IPv6len:]), binary.BigEndian.Uint32(data[net.IPv6len+4:])
+}
+
+func TestDHCPServer_handle6_lifetimes(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+		host      = "host"
+	)
+
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+	conf := &Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		DBFilePath:      dbFilePath,
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: &IPv4Config{Enabled: false},
+				IPv6: &IPv6Config{
+					Enabled:           true,
+					RangeStart:        netip.MustParseAddr("2001:db8::1"),
+					LeaseDuration:     time.Hour,
+					PreferredDuration: 30 * time.Minute,
+				},
+			},
+		},
+	}
+
+	srv, err := New(conf)
+	require.NoError(t, err)
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	evs := make(chan *Event, 1)
+	srv.Subscribe(evs)
+	t.Cleanup(func() { srv.Unsubscribe(evs) })
+
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	req := newTestRequest6(layers.DHCPv6MsgTypeRequest, duid, 1)
+	req.Options = append(req.Options, srv.iface6ByName(ifaceName).srvIDOpt)
+
+	resp, err := srv.handle6(ifaceName, req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	preferred, valid := iaAddrLifetimes6(t, reencode6(t, resp))
+	assert.Equal(t, uint32(30*60), preferred)
+	assert.Equal(t, uint32(60*60), valid)
+
+	leases := srv.Leases()
+	require.Len(t, leases, 1)
+
+	ip := leases[0].IP
+	assert.Equal(t, now.Add(30*time.Minute), leases[0].PreferredUntil)
+	assert.Equal(t, now.Add(time.Hour), leases[0].Expiry)
+
+	// The server doesn't assign hostnames to DHCPv6 clients yet, so set it
+	// directly.
+	srv.leases.rename(srv.leases.byAddr[ip], host)
+	require.Equal(t, ip, srv.IPByHost(host))
+
+	assert.Equal(t, EventTypeAdded, (<-evs).Type)
+
+	t.Run("persisted", func(t *testing.T) {
+		loaded, loadErr := New(conf)
+		require.NoError(t, loadErr)
+
+		loadedLeases := loaded.Leases()
+		require.Len(t, loadedLeases, 1)
+
+		assert.True(t, leases[0].PreferredUntil.Equal(loadedLeases[0].PreferredUntil))
+		assert.True(t, leases[0].Expiry.Equal(loadedLeases[0].Expiry))
+	})
+
+	t.Run("deprecated", func(t *testing.T) {
+		now = now.Add(45 * time.Minute)
+
+		assert.False(t, srv.IPByHost(host).IsValid())
+		assert.Len(t, srv.Leases(), 1)
+	})
+
+	t.Run("renewed", func(t *testing.T) {
+		req.MsgType = layers.DHCPv6MsgTypeRenew
+		resp, err = srv.handle6(ifaceName, req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		preferred, valid = iaAddrLifetimes6(t, reencode6(t, resp))
+		assert.Equal(t, uint32(30*60), preferred)
+		assert.Equal(t, uint32(60*60), valid)
+
+		assert.Equal(t, ip, srv.IPByHost(host))
+		assert.Equal(t, EventTypeUpdated, (<-evs).Type)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(time.Hour)
+
+		other := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07})
+		resp, err = srv.handle6(ifaceName, newTestRequest6(layers.DHCPv6MsgTypeSolicit, other, 1))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		require.Len(t, evs, 1)
+
+		ev := <-evs
+		assert.Equal(t, EventTypeRemoved, ev.Type)
+		assert.Equal(t, ip, ev.Lease.IP)
+
+		assert.Empty(t, srv.Leases())
+		assert.False(t, srv.IPByHost(host).IsValid())
+	})
+}
+
+func TestDHCPServer_handle4_reuseExpired(t *testing.T) {
+	conf := newTestIPv4Config()
+	conf.RangeEnd = conf.RangeStart.Next()
+
+	srv := newTestServer4(t, conf)
+	require.NoError(t, srv.AddStaticLease(&Lease{
+		IP:     conf.RangeEnd,
+		HWAddr: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x01, 0x01},
+	}))
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	evs := make(chan *Event, 4)
+	srv.Subscribe(evs)
+	t.Cleanup(func() { srv.Unsubscribe(evs) })
+
+	ip := conf.RangeStart
+	prevMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	requestLease4(t, srv, prevMAC, ip, "prev")
+	require.Equal(t, EventTypeAdded, (<-evs).Type)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+
+	t.Run("active", func(t *testing.T) {
+		_, err := srv.handle4("eth0", newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+		assert.ErrorIs(t, err, ErrPoolExhausted)
+
+		assert.Zero(t, srv.Status().Interfaces[0].IPv4.FreeAddrs)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(conf.LeaseDuration)
+
+		assert.Equal(t, uint64(1), srv.Status().Interfaces[0].IPv4.FreeAddrs)
+
+		offer, err := srv.handle4("eth0", newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+		require.NoError(t, err)
+		require.NotNil(t, offer)
+
+		offered, _ := netip.AddrFromSlice(offer.YourClientIP.To4())
+		require.Equal(t, ip, offered)
+
+		requestLease4(t, srv, mac, ip, "next")
+
+		l, ok := srv.leases.leaseByAddr(ip)
+		require.True(t, ok)
+
+		assert.Equal(t, mac, l.HWAddr)
+		assert.Equal(t, "next", l.Hostname)
+		assert.Len(t, srv.Leases(), 2)
+		assert.False(t, srv.IPByHost("prev").IsValid())
+
+		var types []EventType
+		for len(evs) > 0 {
+			ev := <-evs
+			types = append(types, ev.Type)
+			if ev.Type == EventTypeRemoved {
+				assert.Equal(t, prevMAC, ev.Lease.HWAddr)
+			}
+		}
+
+		assert.Equal(t, []EventType{EventTypeExpired, EventTypeAdded, EventTypeRemoved}, types)
+	})
+}
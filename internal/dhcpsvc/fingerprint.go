@@ -0,0 +1,86 @@
+package dhcpsvc
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+)
+
+// maxFingerprintLen4 is the maximum length of the DHCPv4 fingerprint, i.e. the
+// maximum of 255 comma-separated option codes of 3 digits, the separator, and
+// the vendor class identifier of the maximum option length.
+const maxFingerprintLen4 = 255*4 - 1 + 1 + maxOptLen4
+
+// appendFingerprint4 appends the fingerprint of the client sent req to dst and
+// returns the result.  The fingerprint is the comma-separated decimal codes
+// from the Parameter Request List option in the order of the request, followed
+// by the semicolon and the Vendor Class Identifier option's data, if any, e.g.
+// "1,3,6,15,31,33,43,44,46,47,119,121,249,252;MSFT 5.0".  Nothing is appended
+// if req contains neither option.  It doesn't allocate if dst has enough
+// capacity, see [maxFingerprintLen4].
+func appendFingerprint4(dst []byte, req *layers.DHCPv4) (res []byte) {
+	var prl, vendorClass []byte
+	var hasVendorClass bool
+	for _, opt := range req.Options {
+		switch opt.Type {
+		case layers.DHCPOptParamsRequest:
+			prl = opt.Data
+		case layers.DHCPOptClassID:
+			vendorClass, hasVendorClass = opt.Data, true
+		}
+	}
+
+	for i, code := range prl {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+
+		dst = strconv.AppendUint(dst, uint64(code), 10)
+	}
+
+	if hasVendorClass {
+		dst = append(dst, ';')
+		dst = append(dst, vendorClass...)
+	}
+
+	return dst
+}
+
+// fingerprint4 returns the fingerprint of the client sent req, see
+// [appendFingerprint4].
+func fingerprint4(req *layers.DHCPv4) (fp string) {
+	var buf [maxFingerprintLen4]byte
+
+	return string(appendFingerprint4(buf[:0], req))
+}
+
+// fingerprintChanged4 returns true if the client with mac on iface holds a
+// dynamic lease with the fingerprint other than fp.
+func (srv *DHCPServer) fingerprintChanged4(iface *iface4, mac net.HardwareAddr, fp []byte) (ok bool) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	l, ok := iface.leases[leaseKey{mac: macToKey(mac)}]
+
+	return ok && !l.IsStatic && l.Fingerprint != string(fp)
+}
+
+// updateFingerprint4 sets the fingerprint of the dynamic lease of the client
+// with mac on iface to fp, if the client holds one.
+func (srv *DHCPServer) updateFingerprint4(iface *iface4, mac net.HardwareAddr, fp []byte) {
+	err := srv.withLeasesStored(func() (st *dbState, err error) {
+		l, ok := iface.leases[leaseKey{mac: macToKey(mac)}]
+		if !ok || l.IsStatic || l.Fingerprint == string(fp) {
+			return nil, nil
+		}
+
+		l.Fingerprint = string(fp)
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		log.Error("dhcpsvc: interface %q: updating fingerprint of %s: %s", iface.name, mac, err)
+	}
+}
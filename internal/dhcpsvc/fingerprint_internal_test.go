@@ -0,0 +1,101 @@
+package dhcpsvc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPRLWindows is the Parameter Request List option sent by Windows 10
+// clients.
+var testPRLWindows = layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{
+	1, 3, 6, 15, 31, 33, 43, 44, 46, 47, 119, 121, 249, 252,
+})
+
+// testFingerprintWindows is the fingerprint of a client sending
+// [testPRLWindows] and the "MSFT 5.0" vendor class identifier.
+const testFingerprintWindows = "1,3,6,15,31,33,43,44,46,47,119,121,249,252;MSFT 5.0"
+
+func TestAppendFingerprint4(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	vendorClass := layers.NewDHCPOption(layers.DHCPOptClassID, []byte("MSFT 5.0"))
+
+	testCases := []struct {
+		name string
+		want string
+		opts []layers.DHCPOption
+	}{{
+		name: "none",
+		want: "",
+		opts: nil,
+	}, {
+		name: "windows",
+		want: testFingerprintWindows,
+		opts: []layers.DHCPOption{vendorClass, testPRLWindows},
+	}, {
+		name: "prl_only",
+		want: "1,3,6,15,31,33,43,44,46,47,119,121,249,252",
+		opts: []layers.DHCPOption{testPRLWindows},
+	}, {
+		name: "vendor_class_only",
+		want: ";MSFT 5.0",
+		opts: []layers.DHCPOption{vendorClass},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newTestRequest4(mac, layers.DHCPMsgTypeDiscover, tc.opts...)
+
+			var buf [maxFingerprintLen4]byte
+			assert.Equal(t, tc.want, string(appendFingerprint4(buf[:0], req)))
+
+			allocs := testing.AllocsPerRun(10, func() {
+				_ = appendFingerprint4(buf[:0], req)
+			})
+			assert.Zero(t, allocs)
+		})
+	}
+}
+
+func TestDHCPServer_handle4_fingerprint(t *testing.T) {
+	const ifaceName = "eth0"
+
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	vendorClass := layers.NewDHCPOption(layers.DHCPOptClassID, []byte("MSFT 5.0"))
+
+	reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 0, 2})
+	resp, err := srv.handle4(ifaceName, newTestRequest4(
+		mac,
+		layers.DHCPMsgTypeRequest,
+		reqIPOpt,
+		testPRLWindows,
+		vendorClass,
+	))
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+	leases := srv.Leases()
+	require.Len(t, leases, 1)
+
+	assert.Equal(t, testFingerprintWindows, leases[0].Fingerprint)
+
+	// The most recent fingerprint is kept.
+	resp, err = srv.handle4(ifaceName, newTestRequest4(
+		mac,
+		layers.DHCPMsgTypeDiscover,
+		layers.NewDHCPOption(layers.DHCPOptParamsRequest, []byte{1, 3, 6}),
+	))
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	leases = srv.Leases()
+	require.Len(t, leases, 1)
+
+	assert.Equal(t, "1,3,6", leases[0].Fingerprint)
+}
@@ -0,0 +1,153 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// dhcpMsgTypeForceRenew is the type of the message sent by the server to force
+// the client to renew its lease.  It's not defined in [layers].
+//
+// See https://datatracker.ietf.org/doc/html/rfc3203#section-4.
+const dhcpMsgTypeForceRenew layers.DHCPMsgType = 9
+
+// renewTarget is the client to send DHCPFORCERENEW to.
+type renewTarget struct {
+	// mac is the hardware address of the client.
+	mac net.HardwareAddr
+
+	// ip is the leased address of the client.
+	ip netip.Addr
+}
+
+// ForceRenew unicasts DHCPFORCERENEW to the clients of every active DHCPv4
+// lease on the network interface with the given name, prompting them to renew
+// their leases and thus to pick up the changed options.  The clients not
+// supporting the message ignore it.  The messages are sent at most once per
+// [Config.ForceRenewInterval].  srv must be started.
+//
+// TODO(e.burkov):  Add the authentication required by RFC 6704.
+func (srv *DHCPServer) ForceRenew(ctx context.Context, ifaceName string) (err error) {
+	defer func() { err = errors.Annotate(err, "forcing renewal on %q: %w", ifaceName) }()
+
+	iface := srv.iface4ByName(ifaceName)
+	if iface == nil {
+		return errors.Error("no such ipv4 interface")
+	}
+
+	targets := srv.renewTargets(iface)
+
+	srv.connsMu.Lock()
+	conn := iface.conn
+	srv.connsMu.Unlock()
+
+	if conn == nil {
+		return errors.Error("interface is not served")
+	}
+
+	_, clientPort := srv.conf.ports4()
+	buf := gopacket.NewSerializeBuffer()
+	var errs []error
+	for n, t := range targets {
+		if n > 0 && srv.conf.ForceRenewInterval > 0 {
+			err = sleepContext(ctx, srv.conf.ForceRenewInterval)
+			if err != nil {
+				errs = append(errs, err)
+
+				break
+			}
+		}
+
+		err = sendForceRenew4(conn, buf, iface, t, clientPort)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sending to %s: %w", t.ip, err))
+		}
+	}
+
+	log.Debug("dhcpsvc: interface %q: sent forcerenew to %d clients", ifaceName, len(targets))
+
+	return errors.Join(errs...)
+}
+
+// renewTargets returns the clients of the active leases on iface.
+func (srv *DHCPServer) renewTargets(iface *iface4) (targets []renewTarget) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	now := srv.now()
+	targets = make([]renewTarget, 0, len(iface.leases))
+	for _, l := range iface.leases {
+		if l.IsStatic || l.Expiry.After(now) {
+			targets = append(targets, renewTarget{
+				mac: slices.Clone(l.HWAddr),
+				ip:  l.IP,
+			})
+		}
+	}
+
+	return targets
+}
+
+// sendForceRenew4 serializes DHCPFORCERENEW for t into buf and sends it via
+// conn of iface to the client port.
+//
+// See https://datatracker.ietf.org/doc/html/rfc3203#section-4.
+func sendForceRenew4(
+	conn net.PacketConn,
+	buf gopacket.SerializeBuffer,
+	iface *iface4,
+	t renewTarget,
+	port uint16,
+) (err error) {
+	msg := &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: layers.LinkTypeEthernet,
+		HardwareLen:  uint8(len(t.mac)),
+		Xid:          rand.Uint32(),
+		ClientIP:     t.ip.AsSlice(),
+		ClientHWAddr: t.mac,
+		Options: layers.DHCPOptions{
+			newMsgTypeOpt4(dhcpMsgTypeForceRenew),
+			iface.srvIDOpt,
+		},
+	}
+
+	err = buf.Clear()
+	if err != nil {
+		// Shouldn't happen, since the buffer is in memory.
+		panic(err)
+	}
+
+	err = gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg)
+	if err != nil {
+		return fmt.Errorf("encoding: %w", err)
+	}
+
+	_, err = conn.WriteTo(buf.Bytes(), &net.UDPAddr{IP: t.ip.AsSlice(), Port: int(port)})
+
+	return err
+}
+
+// sleepContext pauses for d or until ctx is done, whichever happens first.  err
+// is the error of ctx, if it's done.
+func sleepContext(ctx context.Context, d time.Duration) (err error) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
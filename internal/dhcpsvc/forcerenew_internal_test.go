@@ -0,0 +1,134 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWrite is the message written to the connection opened by the listener
+// from newWritesListener.
+type testWrite struct {
+	// to is the address data is written to.
+	to net.Addr
+
+	// data is the message written.
+	data []byte
+}
+
+// newWritesListener returns a new testListener opening connections, which send
+// the written messages to writes and block reading until closed.
+func newWritesListener(writes chan<- *testWrite) (l testListener) {
+	return func(_ context.Context, _ string, laddr netip.AddrPort) (conn net.PacketConn, err error) {
+		closed := make(chan struct{})
+
+		return &fakenet.PacketConn{
+			OnClose: func() (err error) {
+				close(closed)
+
+				return nil
+			},
+			OnLocalAddr: func() (addr net.Addr) { return net.UDPAddrFromAddrPort(laddr) },
+			OnReadFrom: func(_ []byte) (n int, addr net.Addr, err error) {
+				<-closed
+
+				return 0, nil, net.ErrClosed
+			},
+			OnWriteTo: func(b []byte, to net.Addr) (n int, err error) {
+				writes <- &testWrite{to: to, data: append([]byte(nil), b...)}
+
+				return len(b), nil
+			},
+		}, nil
+	}
+}
+
+func TestDHCPServer_ForceRenew(t *testing.T) {
+	const ifaceName = "eth0"
+
+	writes := make(chan *testWrite, 10)
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Listener:        newWritesListener(writes),
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	active := []*Lease{{
+		IP:       netip.MustParseAddr("192.168.0.2"),
+		Expiry:   now.Add(time.Hour),
+		Hostname: "dynamic",
+		HWAddr:   net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+	}, {
+		IP:       netip.MustParseAddr("192.168.0.200"),
+		Hostname: "static",
+		HWAddr:   net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+		IsStatic: true,
+	}}
+	expired := &Lease{
+		IP:       netip.MustParseAddr("192.168.0.3"),
+		Expiry:   now.Add(-time.Hour),
+		Hostname: "expired",
+		HWAddr:   net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x03},
+	}
+
+	require.NoError(t, srv.ReplaceLeases(append([]*Lease{expired}, active...)))
+
+	t.Run("not_served", func(t *testing.T) {
+		err = srv.ForceRenew(context.Background(), ifaceName)
+		testutil.AssertErrorMsg(t, `forcing renewal on "eth0": interface is not served`, err)
+	})
+
+	startTestServer(t, srv)
+
+	t.Run("unknown_interface", func(t *testing.T) {
+		err = srv.ForceRenew(context.Background(), "eth1")
+		testutil.AssertErrorMsg(t, `forcing renewal on "eth1": no such ipv4 interface`, err)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		require.NoError(t, srv.ForceRenew(context.Background(), ifaceName))
+		require.Len(t, writes, len(active))
+
+		got := map[netip.Addr]net.HardwareAddr{}
+		for range active {
+			w := <-writes
+
+			to, ok := w.to.(*net.UDPAddr)
+			require.True(t, ok)
+			assert.Equal(t, clientPort4, to.Port)
+
+			msg := &layers.DHCPv4{}
+			require.NoError(t, msg.DecodeFromBytes(w.data, gopacket.NilDecodeFeedback))
+
+			assert.Equal(t, dhcpMsgTypeForceRenew, msgType4(msg))
+			assert.Equal(t, newTestIPv4Config().GatewayIP, optIP4(msg, layers.DHCPOptServerID))
+
+			ip, _ := netip.AddrFromSlice(to.IP.To4())
+			got[ip] = msg.ClientHWAddr
+		}
+
+		want := map[netip.Addr]net.HardwareAddr{}
+		for _, l := range active {
+			want[l.IP] = l.HWAddr
+		}
+
+		assert.Equal(t, want, got)
+	})
+}
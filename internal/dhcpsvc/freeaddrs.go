@@ -0,0 +1,54 @@
+package dhcpsvc
+
+import "net/netip"
+
+// freeAddrs returns the number of addresses within the address space of iface
+// available for allocation.  reserved is the address of iface, which is never
+// allocated, it's ignored if invalid or outside of the address space.  offered
+// are the addresses offered to the clients, which are unavailable until the
+// reservations expire, it may be nil.  Each unavailable address is subtracted
+// once, even if it's reserved, leased, offered, or quarantined at the same
+// time, since those are collapsed into a single set.
+// The addresses of the expired dynamic DHCPv4 leases are free.  It's the only
+// source of truth for the number of free addresses.  srv.leasesMu is expected
+// to be locked.
+func (srv *DHCPServer) freeAddrs(
+	iface *netInterface,
+	reserved netip.Addr,
+	offered *offers,
+) (n uint64) {
+	used := newBitSet()
+	if off, ok := iface.addrSpace.offset(reserved); ok {
+		used.set(off)
+	}
+
+	now := srv.now()
+
+	// Use the index, since the static leases within the address space may be
+	// attributed to other network interfaces.
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		if off, ok := iface.addrSpace.offset(l.IP); ok && !l.isExpired4(now) {
+			used.set(off)
+		}
+
+		return true
+	})
+
+	for ip := range iface.quarantined {
+		if off, ok := iface.addrSpace.offset(ip); ok && iface.isQuarantined(ip, now) {
+			used.set(off)
+		}
+	}
+
+	if offered != nil {
+		offered.rangeReserved(now, func(ip netip.Addr) (cont bool) {
+			if off, ok := iface.addrSpace.offset(ip); ok {
+				used.set(off)
+			}
+
+			return true
+		})
+	}
+
+	return iface.addrSpace.len() - used.count()
+}
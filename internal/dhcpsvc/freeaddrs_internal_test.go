@@ -0,0 +1,138 @@
+package dhcpsvc
+
+import (
+	"math/rand"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_freeAddrs(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+
+		// rangeLen is the number of addresses in the range, kept small so
+		// that it's exhausted by some of the combinations.
+		rangeLen = 16
+
+		iterations = 200
+	)
+
+	conf := newTestIPv4Config()
+	conf.RangeStart = netip.MustParseAddr("192.168.0.10")
+	conf.RangeEnd = netip.MustParseAddr("192.168.0.25")
+
+	// Brute-force the number of free addresses by checking each address of
+	// the range.
+	recount := func(srv *DHCPServer, iface *iface4, reserved netip.Addr) (n uint64) {
+		now := srv.now()
+		for ip := conf.RangeStart; !conf.RangeEnd.Less(ip); ip = ip.Next() {
+			if l, ok := srv.leases.byAddr[ip]; ok && !l.isExpired4(now) {
+				continue
+			} else if r, ok := iface.offers.reserved[ip]; ok && now.Before(r.until) {
+				continue
+			} else if ip != reserved && !iface.isQuarantined(ip, now) {
+				n++
+			}
+		}
+
+		return n
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	randIP := func() (ip netip.Addr) {
+		// Pick from a wider network than the range sometimes to also get
+		// static leases outside of it.
+		if rng.Intn(4) == 0 {
+			return netip.AddrFrom4([4]byte{192, 168, 0, byte(2 + rng.Intn(40))})
+		}
+
+		return netip.AddrFrom4([4]byte{192, 168, 0, byte(10 + rng.Intn(rangeLen))})
+	}
+
+	for i := 0; i < iterations; i++ {
+		srv := newTestServer4(t, conf)
+		iface := srv.interfaces4[0]
+
+		used := map[netip.Addr]bool{conf.GatewayIP: true}
+		var leases []*Lease
+		for n := rng.Intn(4 * rangeLen); n > 0; n-- {
+			ip := randIP()
+			if used[ip] {
+				continue
+			}
+
+			used[ip] = true
+			l := &Lease{
+				IP:     ip,
+				HWAddr: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, byte(i), byte(len(leases))},
+			}
+
+			if !iface.addrSpace.contains(ip) || rng.Intn(2) == 0 {
+				l.IsStatic = true
+			} else if rng.Intn(4) == 0 {
+				// Expired leases don't make their addresses unavailable.
+				l.Expiry = time.Now().Add(-time.Hour)
+			} else {
+				l.Expiry = time.Now().Add(time.Hour)
+			}
+
+			leases = append(leases, l)
+		}
+
+		require.NoError(t, srv.ReplaceLeases(leases))
+
+		// Quarantine and offer some addresses, which may also be leased, with
+		// some of the quarantines and the offers elapsed.
+		srv.leasesMu.Lock()
+		for n := rng.Intn(rangeLen / 2); n > 0; n-- {
+			iface.quarantined[randIP()] = time.Now().Add(time.Duration(rng.Intn(3)-1) * time.Hour)
+		}
+
+		for n := rng.Intn(rangeLen / 2); n > 0; n-- {
+			iface.offers.reserved[randIP()] = offerReservation{
+				mac:   macToKey(net.HardwareAddr{0x06, 0x00, 0x00, 0x00, byte(i), byte(n)}),
+				until: time.Now().Add(time.Duration(rng.Intn(2)*2-1) * time.Minute),
+			}
+		}
+		srv.leasesMu.Unlock()
+
+		// Reserve an address, which may also be leased, to check that it isn't
+		// subtracted twice.
+		reserved := randIP()
+
+		srv.leasesMu.RLock()
+		got := srv.freeAddrs(&iface.netInterface, reserved, iface.offers)
+		want := recount(srv, iface, reserved)
+		srv.leasesMu.RUnlock()
+
+		require.Equalf(t, want, got, "iteration %d", i)
+
+		// An address is offered as long as there are free addresses, and it's
+		// reserved for the client afterwards.
+		srv.leasesMu.RLock()
+		free := srv.freeAddrs(&iface.netInterface, iface.gateway, iface.offers)
+		srv.leasesMu.RUnlock()
+
+		mac := net.HardwareAddr{0x04, 0x00, 0x00, 0x00, 0x00, byte(i)}
+		offer, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+		if free > 0 {
+			require.NoErrorf(t, err, "iteration %d", i)
+		} else {
+			require.ErrorIsf(t, err, ErrPoolExhausted, "iteration %d", i)
+		}
+
+		wantFree := free
+		if offer != nil {
+			wantFree--
+		}
+
+		assert.Equalf(t, free > 0, offer != nil, "iteration %d", i)
+		assert.Equalf(t, wantFree, srv.Status().Interfaces[0].IPv4.FreeAddrs, "iteration %d", i)
+	}
+}
@@ -0,0 +1,199 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// HealthStatus is the state of the DHCP server, see [DHCPServer.Health].
+type HealthStatus struct {
+	// LastStored is the time the leases have been last successfully written
+	// into the database file.  It's zero if they haven't been written since
+	// the server has been created or the database is disabled.
+	LastStored time.Time
+
+	// Sockets are the states of the connections of the served network
+	// interfaces sorted by interface name, the IPv4 ones first.
+	Sockets []*SocketHealth
+
+	// SweeperAlive is true if the goroutine sweeping the expired leases is
+	// running.
+	SweeperAlive bool
+
+	// Healthy is true if all the sockets, except the paused ones, are bound
+	// and the sweeper is alive.
+	Healthy bool
+}
+
+// SocketHealth is the state of the connection of a served network interface.
+type SocketHealth struct {
+	// Name is the name of the network interface.
+	Name string
+
+	// IPv4 is true if the connection serves DHCPv4, and false if it serves
+	// DHCPv6.
+	IPv4 bool
+
+	// Bound is true if the connection is open and being read from.
+	Bound bool
+
+	// Paused is true if the network interface isn't served on purpose, see
+	// [DHCPServer.DisableInterface], so that the connection isn't open.
+	Paused bool
+}
+
+// Health returns the current state of srv.  Unlike [DHCPServer.HealthCheck],
+// it performs no I/O and doesn't lock the leases, so it may be called as often
+// as needed, e.g. by a liveness probe.
+func (srv *DHCPServer) Health() (st *HealthStatus) {
+	st = &HealthStatus{
+		SweeperAlive: srv.sweeperAlive.Load(),
+	}
+
+	if storedAt := srv.lastStored.Load(); storedAt != nil {
+		st.LastStored = *storedAt
+	}
+
+	func() {
+		srv.connsMu.Lock()
+		defer srv.connsMu.Unlock()
+
+		st.Sockets = make([]*SocketHealth, 0, len(srv.interfaces4)+len(srv.interfaces6))
+		for _, iface := range srv.interfaces4 {
+			st.Sockets = append(st.Sockets, newSocketHealth(&iface.netInterface, true))
+		}
+
+		for _, iface := range srv.interfaces6 {
+			st.Sockets = append(st.Sockets, newSocketHealth(&iface.netInterface, false))
+		}
+	}()
+
+	st.Healthy = st.SweeperAlive
+	for _, s := range st.Sockets {
+		st.Healthy = st.Healthy && (s.Bound || s.Paused)
+	}
+
+	return st
+}
+
+// newSocketHealth returns the state of the connection of iface.
+// [DHCPServer.connsMu] is expected to be locked.
+func newSocketHealth(iface *netInterface, is4 bool) (s *SocketHealth) {
+	return &SocketHealth{
+		Name:   iface.name,
+		IPv4:   is4,
+		Bound:  iface.conn != nil,
+		Paused: iface.paused,
+	}
+}
+
+// HealthCheck returns an error describing each failing aspect of srv:
+//
+//   - a served network interface, which isn't paused, has no bound connection;
+//   - the directory of the lease database isn't writable;
+//   - the leases of a network interface don't match the lease index;
+//   - a subscriber doesn't receive events.
+//
+// It's cheap enough to be called periodically, e.g. every 30 seconds.
+func (srv *DHCPServer) HealthCheck(_ context.Context) (err error) {
+	errs := []error{
+		srv.checkConns(),
+		srv.checkDB(),
+		srv.checkLeases(),
+	}
+
+	if subErr := srv.subscribers.check(); subErr != nil {
+		errs = append(errs, fmt.Errorf("events: %w", subErr))
+	}
+
+	err = errors.Join(errs...)
+	if err != nil {
+		return fmt.Errorf("dhcp server health: %w", err)
+	}
+
+	return nil
+}
+
+// checkConns returns an error if any of the served network interfaces, which
+// isn't paused, has no bound connection.
+func (srv *DHCPServer) checkConns() (err error) {
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+
+	var errs []error
+	for _, iface := range srv.interfaces4 {
+		if iface.conn == nil && !iface.paused {
+			errs = append(errs, fmt.Errorf("interface %q: ipv4: no bound listener", iface.name))
+		}
+	}
+
+	for _, iface := range srv.interfaces6 {
+		if iface.conn == nil && !iface.paused {
+			errs = append(errs, fmt.Errorf("interface %q: ipv6: no bound listener", iface.name))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkDB returns an error if the database file can't be written, which is
+// checked by creating a temporary file next to it.
+func (srv *DHCPServer) checkDB() (err error) {
+	if srv.dbFilePath == "" {
+		return nil
+	}
+
+	defer func() { err = errors.Annotate(err, "db: %w") }()
+
+	dir, base := filepath.Split(srv.dbFilePath)
+
+	return checkDirWritable(dir, base+".health-*")
+}
+
+// checkLeases returns an error if the leases held by the network interfaces
+// don't match the lease index.
+func (srv *DHCPServer) checkLeases() (err error) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	var errs []error
+	total := 0
+	checkIface := func(iface *netInterface, family string) {
+		total += len(iface.leases)
+
+		missing := 0
+		for _, l := range iface.leases {
+			if indexed, ok := srv.leases.leaseByAddr(l.IP); !ok || indexed != l {
+				missing++
+			}
+		}
+
+		if missing > 0 {
+			errs = append(errs, fmt.Errorf(
+				"interface %q: %s: %d of %d leases not indexed",
+				iface.name,
+				family,
+				missing,
+				len(iface.leases),
+			))
+		}
+	}
+
+	for _, iface := range srv.interfaces4 {
+		checkIface(&iface.netInterface, "ipv4")
+	}
+
+	for _, iface := range srv.interfaces6 {
+		checkIface(&iface.netInterface, "ipv6")
+	}
+
+	if n := srv.leases.len(); n != total {
+		errs = append(errs, fmt.Errorf("%d leases indexed, %d held by interfaces", n, total))
+	}
+
+	return errors.Join(errs...)
+}
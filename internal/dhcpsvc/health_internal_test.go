@@ -0,0 +1,336 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testListener is a [Listener] for tests.
+type testListener func(
+	ctx context.Context,
+	ifaceName string,
+	laddr netip.AddrPort,
+) (conn net.PacketConn, err error)
+
+// type check
+var _ Listener = testListener(nil)
+
+// ListenPacket implements the [Listener] interface for testListener.
+func (l testListener) ListenPacket(
+	ctx context.Context,
+	ifaceName string,
+	laddr netip.AddrPort,
+) (conn net.PacketConn, err error) {
+	return l(ctx, ifaceName, laddr)
+}
+
+// testRead is the result of reading from the connection opened by
+// testListener.
+type testRead struct {
+	// err is the error to return.  If not nil, data is ignored.
+	err error
+
+	// from is the address data is read from.
+	from net.Addr
+
+	// data is the message read.
+	data []byte
+}
+
+// newTestListener returns a new testListener opening connections, which read
+// the results received from reads4 or reads6, depending on the address family
+// of the connection, and block until closed otherwise.
+func newTestListener(reads4, reads6 <-chan *testRead) (l testListener) {
+	return func(_ context.Context, _ string, laddr netip.AddrPort) (conn net.PacketConn, err error) {
+		closed := make(chan struct{})
+		reads := reads6
+		if laddr.Addr().Is4() {
+			reads = reads4
+		}
+
+		return &fakenet.PacketConn{
+			OnClose: func() (err error) {
+				close(closed)
+
+				return nil
+			},
+			OnLocalAddr: func() (addr net.Addr) { return net.UDPAddrFromAddrPort(laddr) },
+			OnReadFrom: func(b []byte) (n int, addr net.Addr, err error) {
+				var r *testRead
+				select {
+				case r = <-reads:
+				case <-closed:
+					return 0, nil, net.ErrClosed
+				}
+
+				// Drop the message received after closing, since select picks
+				// randomly between the ready cases.
+				select {
+				case <-closed:
+					return 0, nil, net.ErrClosed
+				default:
+				}
+
+				if r.err != nil {
+					return 0, nil, r.err
+				}
+
+				return copy(b, r.data), r.from, nil
+			},
+			OnWriteTo: func(b []byte, _ net.Addr) (n int, err error) { return len(b), nil },
+		}, nil
+	}
+}
+
+// newListeningTestServer returns a new DHCPv4 server storing leases in
+// dbFilePath and reading DHCPv4 messages from reads.
+func newListeningTestServer(
+	t *testing.T,
+	dbFilePath string,
+	reads <-chan *testRead,
+) (srv *DHCPServer) {
+	t.Helper()
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		DBFilePath:      dbFilePath,
+		Listener:        newTestListener(reads, nil),
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return srv
+}
+
+// startTestServer starts srv and shuts it down on cleanup.
+func startTestServer(t *testing.T, srv *DHCPServer) {
+	t.Helper()
+
+	require.NoError(t, srv.Start())
+	testutil.CleanupAndRequireSuccess(t, func() (err error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		return srv.Shutdown(ctx)
+	})
+}
+
+// newHealthTestLease returns a new static lease for the i-th client of the
+// test network.
+func newHealthTestLease(i byte) (l *Lease) {
+	return &Lease{
+		Hostname: "host" + string('a'+rune(i)),
+		HWAddr:   net.HardwareAddr{0x2, 0x0, 0x0, 0x0, 0x0, i},
+		IP:       netip.AddrFrom4([4]byte{192, 168, 0, 100 + i}),
+	}
+}
+
+func TestDHCPServer_HealthCheck(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("healthy", func(t *testing.T) {
+		srv := newListeningTestServer(t, filepath.Join(t.TempDir(), "leases.json"), nil)
+		startTestServer(t, srv)
+
+		require.NoError(t, srv.AddStaticLease(newHealthTestLease(0)))
+
+		assert.NoError(t, srv.HealthCheck(ctx))
+	})
+
+	t.Run("not_started", func(t *testing.T) {
+		srv := newListeningTestServer(t, "", nil)
+
+		err := srv.HealthCheck(ctx)
+		testutil.AssertErrorMsg(
+			t,
+			`dhcp server health: interface "eth0": ipv4: no bound listener`,
+			err,
+		)
+	})
+
+	t.Run("listener_failed", func(t *testing.T) {
+		reads := make(chan *testRead, 1)
+		srv := newListeningTestServer(t, "", reads)
+		startTestServer(t, srv)
+
+		require.NoError(t, srv.HealthCheck(ctx))
+
+		reads <- &testRead{err: assert.AnError}
+
+		var err error
+		require.Eventually(t, func() (ok bool) {
+			err = srv.HealthCheck(ctx)
+
+			return err != nil
+		}, time.Second, time.Millisecond)
+
+		testutil.AssertErrorMsg(
+			t,
+			`dhcp server health: interface "eth0": ipv4: no bound listener`,
+			err,
+		)
+	})
+
+	t.Run("db_not_writable", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "missing")
+		srv := newListeningTestServer(t, filepath.Join(dir, "leases.json"), nil)
+		startTestServer(t, srv)
+
+		// The directory is created by New, so remove it afterwards.
+		require.NoError(t, os.Remove(dir))
+
+		err := srv.HealthCheck(ctx)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		assert.ErrorContains(t, err, "dhcp server health: db: open "+dir)
+
+		// Let the leases be flushed on shutdown.
+		require.NoError(t, os.Mkdir(dir, 0o700))
+	})
+
+	t.Run("leases_mismatch", func(t *testing.T) {
+		srv := newListeningTestServer(t, "", nil)
+		startTestServer(t, srv)
+
+		l := newHealthTestLease(0)
+		require.NoError(t, srv.AddStaticLease(l))
+
+		delete(srv.leases.byAddr, l.IP)
+
+		err := srv.HealthCheck(ctx)
+		testutil.AssertErrorMsg(
+			t,
+			"dhcp server health: "+
+				`interface "eth0": ipv4: 1 of 1 leases not indexed`+"\n"+
+				"0 leases indexed, 1 held by interfaces",
+			err,
+		)
+	})
+
+	t.Run("subscriber_stuck", func(t *testing.T) {
+		srv := newListeningTestServer(t, "", nil)
+		startTestServer(t, srv)
+
+		srv.Subscribe(make(chan *Event))
+
+		for i := byte(0); i < maxDrops; i++ {
+			require.NoError(t, srv.AddStaticLease(newHealthTestLease(i)))
+		}
+
+		err := srv.HealthCheck(ctx)
+		testutil.AssertErrorMsg(
+			t,
+			"dhcp server health: events: subscriber 0: 16 events dropped in a row",
+			err,
+		)
+	})
+
+	t.Run("subscriber_recovered", func(t *testing.T) {
+		srv := newListeningTestServer(t, "", nil)
+		startTestServer(t, srv)
+
+		ch := make(chan *Event, 1)
+		srv.Subscribe(ch)
+
+		for i := byte(0); i < maxDrops+1; i++ {
+			require.NoError(t, srv.AddStaticLease(newHealthTestLease(i)))
+		}
+
+		require.Error(t, srv.HealthCheck(ctx))
+
+		<-ch
+		require.NoError(t, srv.AddStaticLease(newHealthTestLease(maxDrops+1)))
+
+		assert.NoError(t, srv.HealthCheck(ctx))
+	})
+}
+
+func TestDHCPServer_Health(t *testing.T) {
+	t.Run("healthy", func(t *testing.T) {
+		srv := newListeningTestServer(t, filepath.Join(t.TempDir(), "leases.json"), nil)
+		startTestServer(t, srv)
+
+		require.NoError(t, srv.AddStaticLease(newHealthTestLease(0)))
+
+		st := srv.Health()
+		assert.True(t, st.Healthy)
+		assert.True(t, st.SweeperAlive)
+		assert.False(t, st.LastStored.IsZero())
+		assert.Equal(t, []*SocketHealth{{Name: "eth0", IPv4: true, Bound: true}}, st.Sockets)
+	})
+
+	t.Run("not_started", func(t *testing.T) {
+		srv := newListeningTestServer(t, "", nil)
+
+		st := srv.Health()
+		assert.False(t, st.Healthy)
+		assert.False(t, st.SweeperAlive)
+		assert.True(t, st.LastStored.IsZero())
+		assert.Equal(t, []*SocketHealth{{Name: "eth0", IPv4: true, Bound: false}}, st.Sockets)
+	})
+
+	t.Run("unbound", func(t *testing.T) {
+		reads := make(chan *testRead, 1)
+		srv := newListeningTestServer(t, "", reads)
+		startTestServer(t, srv)
+
+		require.True(t, srv.Health().Healthy)
+
+		reads <- &testRead{err: assert.AnError}
+
+		require.Eventually(t, func() (ok bool) {
+			return !srv.Health().Healthy
+		}, time.Second, time.Millisecond)
+
+		st := srv.Health()
+		assert.True(t, st.SweeperAlive)
+		assert.Equal(t, []*SocketHealth{{Name: "eth0", IPv4: true, Bound: false}}, st.Sockets)
+	})
+
+	t.Run("sweeper_dead", func(t *testing.T) {
+		srv := newListeningTestServer(t, "", nil)
+		startTestServer(t, srv)
+
+		require.True(t, srv.Health().Healthy)
+
+		// Replace the running sweeper with the one panicking on the first
+		// sweep.
+		srv.connsMu.Lock()
+		srv.stopSweeper()
+		srv.connsMu.Unlock()
+
+		require.Eventually(t, func() (ok bool) {
+			return !srv.sweeperAlive.Load()
+		}, time.Second, time.Millisecond)
+
+		srv.sweeperAlive.Store(true)
+		srv.wg.Add(1)
+		go srv.runSweeper(make(chan struct{}), time.Millisecond, func() {
+			panic("test sweep failure")
+		})
+
+		require.Eventually(t, func() (ok bool) {
+			return !srv.Health().Healthy
+		}, time.Second, time.Millisecond)
+
+		st := srv.Health()
+		assert.False(t, st.SweeperAlive)
+		assert.Equal(t, []*SocketHealth{{Name: "eth0", IPv4: true, Bound: true}}, st.Sockets)
+	})
+}
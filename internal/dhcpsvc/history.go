@@ -0,0 +1,285 @@
+package dhcpsvc
+
+import (
+	"container/list"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// Limits of the transaction history.  Those are conservative enough to keep
+// the history always enabled.
+const (
+	// maxHistoryClients is the maximum number of clients the transaction
+	// history is kept for.  The least recently seen clients are evicted first.
+	maxHistoryClients = 256
+
+	// maxHistoryRecords is the maximum number of the most recent transactions
+	// kept for a single client.
+	maxHistoryRecords = 16
+)
+
+// TransactionOutcome is the result of handling a message from a client.
+type TransactionOutcome uint8
+
+// TransactionOutcome values.
+const (
+	// TransactionOutcomeIgnored means that no reply has been sent.
+	TransactionOutcomeIgnored TransactionOutcome = iota + 1
+
+	// TransactionOutcomeOffered means that an address has been offered with a
+	// DHCPOFFER or an Advertise message.
+	TransactionOutcomeOffered
+
+	// TransactionOutcomeAcked means that the message has been replied with a
+	// DHCPACK or a Reply message.
+	TransactionOutcomeAcked
+
+	// TransactionOutcomeNaked means that the message has been replied with a
+	// DHCPNAK message.
+	TransactionOutcomeNaked
+
+	// TransactionOutcomeFailed means that handling the message has failed.
+	TransactionOutcomeFailed
+)
+
+// String implements the [fmt.Stringer] interface for TransactionOutcome.
+func (o TransactionOutcome) String() (s string) {
+	switch o {
+	case TransactionOutcomeIgnored:
+		return "ignored"
+	case TransactionOutcomeOffered:
+		return "offered"
+	case TransactionOutcomeAcked:
+		return "acked"
+	case TransactionOutcomeNaked:
+		return "naked"
+	case TransactionOutcomeFailed:
+		return "failed"
+	default:
+		return fmt.Sprintf("!bad_transaction_outcome_%d", o)
+	}
+}
+
+// TransactionRecord is a single message from a client and its outcome.
+type TransactionRecord struct {
+	// Time is the time the message has been handled.
+	Time time.Time
+
+	// IP is the address offered or granted to the client.  For DHCPv6 it's
+	// the address of the first identity association.  It's invalid if there
+	// is no such address.
+	IP netip.Addr
+
+	// MessageType is the type of the message received from the client, e.g.
+	// "Discover" or "Solicit".
+	MessageType string
+
+	// InterfaceName is the name of the network interface the message has been
+	// received on.
+	InterfaceName string
+
+	// Reason is the error occurred while handling the message, if any.
+	Reason string
+
+	// RelayInfo is the data of the Relay Agent Information option the message
+	// has been forwarded with, without the sub-options dropped for being too
+	// long.  It's only kept for diagnostics and isn't stored within the leases.
+	RelayInfo []byte
+
+	// Outcome is the result of handling the message.
+	Outcome TransactionOutcome
+}
+
+// ClientHistory returns the most recent transactions of the client with mac,
+// from the oldest to the newest.  For DHCPv6 clients mac is the one derived
+// from the DUID.  records are nil if there is no history for the client.  The
+// history isn't persisted, so it's empty after restarting.
+func (srv *DHCPServer) ClientHistory(mac net.HardwareAddr) (records []*TransactionRecord) {
+	if netutil.ValidateMAC(mac) != nil {
+		return nil
+	}
+
+	return srv.history.get(macToKey(mac))
+}
+
+// clientHistory is the transaction history of a single client.
+type clientHistory struct {
+	// key is the key of the client within [history.clients].
+	key macKey
+
+	// records is the ring of the most recent transactions.
+	records [maxHistoryRecords]TransactionRecord
+
+	// next is the index within records to store the next transaction at.
+	next int
+
+	// count is the number of transactions stored in records.
+	count int
+}
+
+// history is the transaction history of the clients.  It's safe for concurrent
+// use.
+type history struct {
+	// mu protects the fields below.
+	mu *sync.Mutex
+
+	// clients are the histories of clients with the most recently seen first.
+	clients *list.List
+
+	// byKey is the index of clients.
+	byKey map[macKey]*list.Element
+}
+
+// newHistory returns a new properly initialized *history.
+func newHistory() (h *history) {
+	return &history{
+		mu:      &sync.Mutex{},
+		clients: list.New(),
+		byKey:   map[macKey]*list.Element{},
+	}
+}
+
+// add stores rec for the client with key, evicting the least recently seen
+// client if there are too many of those.
+func (h *history) add(key macKey, rec *TransactionRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var ch *clientHistory
+	if e, ok := h.byKey[key]; ok {
+		h.clients.MoveToFront(e)
+		ch = e.Value.(*clientHistory)
+	} else {
+		if h.clients.Len() >= maxHistoryClients {
+			oldest := h.clients.Remove(h.clients.Back()).(*clientHistory)
+			delete(h.byKey, oldest.key)
+		}
+
+		ch = &clientHistory{key: key}
+		h.byKey[key] = h.clients.PushFront(ch)
+	}
+
+	ch.records[ch.next] = *rec
+	ch.next = (ch.next + 1) % maxHistoryRecords
+	if ch.count < maxHistoryRecords {
+		ch.count++
+	}
+}
+
+// get returns the copies of the records of the client with key from the
+// oldest to the newest.
+func (h *history) get(key macKey) (records []*TransactionRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e, ok := h.byKey[key]
+	if !ok {
+		return nil
+	}
+
+	ch := e.Value.(*clientHistory)
+	records = make([]*TransactionRecord, 0, ch.count)
+	for i := 0; i < ch.count; i++ {
+		rec := ch.records[(ch.next-ch.count+i+maxHistoryRecords)%maxHistoryRecords]
+		rec.RelayInfo = slices.Clone(rec.RelayInfo)
+		records = append(records, &rec)
+	}
+
+	return records
+}
+
+// newTransactionRecord returns a new record of the message of the given type
+// handled at now on the network interface with the given name.
+func newTransactionRecord(
+	now time.Time,
+	ifaceName string,
+	msgType fmt.Stringer,
+	ip netip.Addr,
+	outcome TransactionOutcome,
+	err error,
+) (rec *TransactionRecord) {
+	rec = &TransactionRecord{
+		Time:          now,
+		IP:            ip,
+		MessageType:   msgType.String(),
+		InterfaceName: ifaceName,
+		Outcome:       outcome,
+	}
+
+	if err != nil {
+		rec.Outcome = TransactionOutcomeFailed
+		rec.Reason = err.Error()
+	}
+
+	return rec
+}
+
+// record4 stores the transaction of the client sent req on the network
+// interface with the given name.  relayInfo is the data of the Relay Agent
+// Information option of req, see [relayInfo4].  resp and err are the results of
+// handling req.
+func (srv *DHCPServer) record4(
+	ifaceName string,
+	req *layers.DHCPv4,
+	resp *layers.DHCPv4,
+	relayInfo []byte,
+	err error,
+) {
+	if netutil.ValidateMAC(req.ClientHWAddr) != nil {
+		return
+	}
+
+	outcome := TransactionOutcomeIgnored
+	var ip netip.Addr
+	if resp != nil {
+		switch msgType4(resp) {
+		case layers.DHCPMsgTypeOffer:
+			outcome = TransactionOutcomeOffered
+		case layers.DHCPMsgTypeAck:
+			outcome = TransactionOutcomeAcked
+		case layers.DHCPMsgTypeNak:
+			outcome = TransactionOutcomeNaked
+		}
+
+		ip, _ = netip.AddrFromSlice(resp.YourClientIP.To4())
+	}
+
+	rec := newTransactionRecord(srv.now(), ifaceName, msgType4(req), ip, outcome, err)
+	rec.RelayInfo = relayInfo
+	srv.history.add(macToKey(req.ClientHWAddr), rec)
+}
+
+// record6 stores the transaction of the client sent req on the network
+// interface with the given name.  resp and err are the results of handling
+// req.
+func (srv *DHCPServer) record6(ifaceName string, req, resp *layers.DHCPv6, err error) {
+	mac := macFromClientID(optData6(req, layers.DHCPv6OptClientID), false)
+	if netutil.ValidateMAC(mac) != nil {
+		return
+	}
+
+	outcome := TransactionOutcomeIgnored
+	var ip netip.Addr
+	if resp != nil {
+		switch resp.MsgType {
+		case layers.DHCPv6MsgTypeAdverstise:
+			outcome = TransactionOutcomeOffered
+		case layers.DHCPv6MsgTypeReply:
+			outcome = TransactionOutcomeAcked
+		}
+
+		if ias := iaNAs6(resp); len(ias) > 0 {
+			ip = ias[0].addr
+		}
+	}
+
+	rec := newTransactionRecord(srv.now(), ifaceName, req.MsgType, ip, outcome, err)
+	srv.history.add(macToKey(mac), rec)
+}
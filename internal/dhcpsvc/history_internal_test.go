@@ -0,0 +1,108 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_ClientHistory(t *testing.T) {
+	const ifaceName = "eth0"
+
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	ip := netip.MustParseAddr("192.168.0.2")
+	badIP := netip.MustParseAddr("192.168.0.255")
+
+	msgs := []*layers.DHCPv4{
+		newTestRequest4(mac, layers.DHCPMsgTypeDiscover),
+		newTestRequest4(
+			mac,
+			layers.DHCPMsgTypeRequest,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice()),
+		),
+		newTestRequest4(mac, layers.DHCPMsgTypeDiscover),
+		newTestRequest4(
+			mac,
+			layers.DHCPMsgTypeRequest,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, badIP.AsSlice()),
+		),
+	}
+
+	for _, msg := range msgs {
+		_, err := srv.handle4(ifaceName, msg)
+		require.NoError(t, err)
+
+		now = now.Add(time.Second)
+	}
+
+	start := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, []*TransactionRecord{{
+		Time:          start,
+		IP:            ip,
+		MessageType:   "Discover",
+		InterfaceName: ifaceName,
+		Outcome:       TransactionOutcomeOffered,
+	}, {
+		Time:          start.Add(time.Second),
+		IP:            ip,
+		MessageType:   "Request",
+		InterfaceName: ifaceName,
+		Outcome:       TransactionOutcomeAcked,
+	}, {
+		Time:          start.Add(2 * time.Second),
+		IP:            ip,
+		MessageType:   "Discover",
+		InterfaceName: ifaceName,
+		Outcome:       TransactionOutcomeOffered,
+	}, {
+		Time:          start.Add(3 * time.Second),
+		IP:            netip.Addr{},
+		MessageType:   "Request",
+		InterfaceName: ifaceName,
+		Outcome:       TransactionOutcomeNaked,
+	}}, srv.ClientHistory(mac))
+
+	assert.Nil(t, srv.ClientHistory(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}))
+}
+
+func TestHistory_limits(t *testing.T) {
+	h := newHistory()
+
+	newKey := func(i int) (key macKey) {
+		return macToKey(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, byte(i >> 8), byte(i)})
+	}
+
+	t.Run("records", func(t *testing.T) {
+		key := newKey(0)
+		for i := 0; i < maxHistoryRecords+2; i++ {
+			h.add(key, &TransactionRecord{Time: time.Unix(int64(i), 0)})
+		}
+
+		records := h.get(key)
+		require.Len(t, records, maxHistoryRecords)
+
+		assert.Equal(t, time.Unix(2, 0), records[0].Time)
+		assert.Equal(t, time.Unix(maxHistoryRecords+1, 0), records[maxHistoryRecords-1].Time)
+	})
+
+	t.Run("clients", func(t *testing.T) {
+		for i := 1; i <= maxHistoryClients; i++ {
+			h.add(newKey(i), &TransactionRecord{})
+		}
+
+		// The first client is the least recently seen one.
+		assert.Nil(t, h.get(newKey(0)))
+		assert.Len(t, h.get(newKey(1)), 1)
+		assert.Len(t, h.byKey, maxHistoryClients)
+	})
+}
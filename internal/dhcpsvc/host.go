@@ -0,0 +1,170 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/mapsutil"
+	"golang.org/x/exp/slices"
+)
+
+// SystemProvider provides the information about the machine the DHCP server
+// is going to run on, see [Config.ValidateOnHost].
+type SystemProvider interface {
+	// InterfaceAddrs returns the IP addresses of the network interface with
+	// the given name.  It returns an error if there is no such interface.
+	InterfaceAddrs(ctx context.Context, ifaceName string) (addrs []netip.Addr, err error)
+
+	// CheckPort returns an error if the UDP port of the address family defined
+	// by is4 can't be listened on.
+	CheckPort(ctx context.Context, port uint16, is4 bool) (err error)
+}
+
+// OSSystemProvider is the [SystemProvider] using the operating system.
+type OSSystemProvider struct{}
+
+// type check
+var _ SystemProvider = OSSystemProvider{}
+
+// InterfaceAddrs implements the [SystemProvider] interface for
+// OSSystemProvider.
+func (OSSystemProvider) InterfaceAddrs(
+	_ context.Context,
+	ifaceName string,
+) (addrs []netip.Addr, err error) {
+	iface, err := net.InterfaceByName(ifaceName)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	ifaceAddrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("getting addresses: %w", err)
+	}
+
+	for _, a := range ifaceAddrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		if addr, ok := netip.AddrFromSlice(ipNet.IP); ok {
+			addrs = append(addrs, addr.Unmap())
+		}
+	}
+
+	return addrs, nil
+}
+
+// CheckPort implements the [SystemProvider] interface for OSSystemProvider.
+// It checks the port by listening on it on the unspecified address.
+func (OSSystemProvider) CheckPort(ctx context.Context, port uint16, is4 bool) (err error) {
+	network, laddr := "udp6", netip.AddrPortFrom(netip.IPv6Unspecified(), port)
+	if is4 {
+		network, laddr = "udp4", netip.AddrPortFrom(netip.IPv4Unspecified(), port)
+	}
+
+	conn, err := (&net.ListenConfig{}).ListenPacket(ctx, network, laddr.String())
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	return conn.Close()
+}
+
+// ValidateOnHost returns an error if conf can't be used on the machine
+// described by sys, i.e. the network interfaces don't exist or lack the
+// configured addresses, the ports can't be listened on, or the directory of the
+// database file can't be written.  Unlike [Config.Validate], it reports all
+// the problems found at once.  conf is expected to be valid.  [New] doesn't
+// call it, so it should be called before enabling the server.
+func (conf *Config) ValidateOnHost(ctx context.Context, sys SystemProvider) (err error) {
+	if conf == nil {
+		return errNilConfig
+	} else if !conf.Enabled {
+		return nil
+	}
+
+	var errs []error
+	var has4, has6 bool
+	mapsutil.OrderedRange(conf.Interfaces, func(name string, ic *InterfaceConfig) (cont bool) {
+		v4 := ic.IPv4 != nil && ic.IPv4.Enabled
+		v6 := ic.IPv6 != nil && ic.IPv6.Enabled
+		has4, has6 = has4 || v4, has6 || v6
+
+		if ifaceErr := checkHostIface(ctx, sys, name, ic, v4, v6); ifaceErr != nil {
+			errs = append(errs, fmt.Errorf("interface %q: %w", name, ifaceErr))
+		}
+
+		return true
+	})
+
+	if has4 {
+		port, _ := conf.ports4()
+		if portErr := sys.CheckPort(ctx, port, true); portErr != nil {
+			errs = append(errs, fmt.Errorf("ipv4: port %d: %w", port, portErr))
+		}
+	}
+
+	if has6 {
+		if portErr := sys.CheckPort(ctx, serverPort6, false); portErr != nil {
+			errs = append(errs, fmt.Errorf("ipv6: port %d: %w", serverPort6, portErr))
+		}
+	}
+
+	if dbErr := conf.validateDBDir(); dbErr != nil {
+		errs = append(errs, dbErr)
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkHostIface returns an error if the network interface with the given name
+// configured with ic doesn't exist within sys or lacks the addresses required
+// by the enabled address families.
+func checkHostIface(
+	ctx context.Context,
+	sys SystemProvider,
+	name string,
+	ic *InterfaceConfig,
+	v4 bool,
+	v6 bool,
+) (err error) {
+	addrs, err := sys.InterfaceAddrs(ctx, name)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	var errs []error
+	if v4 && !slices.Contains(addrs, ic.IPv4.GatewayIP) {
+		errs = append(errs, fmt.Errorf("ipv4: gateway ip %s is not assigned", ic.IPv4.GatewayIP))
+	}
+
+	if v6 && !slices.ContainsFunc(addrs, netip.Addr.Is6) {
+		errs = append(errs, errors.Error("ipv6: no ipv6 addresses assigned"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateAll returns the errors of both [Config.Validate] and
+// [Config.ValidateOnHost].  The host isn't checked if conf itself is invalid.
+func (conf *Config) ValidateAll(ctx context.Context, sys SystemProvider) (err error) {
+	err = conf.Validate()
+	if err != nil {
+		return fmt.Errorf("validating config: %w", err)
+	}
+
+	err = conf.ValidateOnHost(ctx, sys)
+	if err != nil {
+		return fmt.Errorf("validating config on host: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package dhcpsvc_test
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSystemProvider is a [dhcpsvc.SystemProvider] for tests.
+type fakeSystemProvider struct {
+	// addrs are the addresses of the existing network interfaces by their
+	// names.
+	addrs map[string][]netip.Addr
+
+	// busyPorts are the ports, which can't be listened on.
+	busyPorts map[uint16]bool
+}
+
+// type check
+var _ dhcpsvc.SystemProvider = (*fakeSystemProvider)(nil)
+
+// InterfaceAddrs implements the [dhcpsvc.SystemProvider] interface for
+// *fakeSystemProvider.
+func (p *fakeSystemProvider) InterfaceAddrs(
+	_ context.Context,
+	ifaceName string,
+) (addrs []netip.Addr, err error) {
+	addrs, ok := p.addrs[ifaceName]
+	if !ok {
+		return nil, errors.Error("no such network interface")
+	}
+
+	return addrs, nil
+}
+
+// CheckPort implements the [dhcpsvc.SystemProvider] interface for
+// *fakeSystemProvider.
+func (p *fakeSystemProvider) CheckPort(_ context.Context, port uint16, _ bool) (err error) {
+	if p.busyPorts[port] {
+		return errors.Error("address already in use")
+	}
+
+	return nil
+}
+
+// newTestHostConfig returns a new valid configuration with the given database
+// file path.
+func newTestHostConfig(dbFilePath string) (conf *dhcpsvc.Config) {
+	return &dhcpsvc.Config{
+		Enabled:         true,
+		LocalDomainName: testLocalTLD,
+		DBFilePath:      dbFilePath,
+		Interfaces:      testInterfaceConf,
+	}
+}
+
+func TestConfig_ValidateOnHost(t *testing.T) {
+	ctx := context.Background()
+
+	allAddrs := map[string][]netip.Addr{
+		"eth0": {netip.MustParseAddr("192.168.0.1"), netip.MustParseAddr("fe80::1")},
+		"eth1": {netip.MustParseAddr("172.16.0.1"), netip.MustParseAddr("fe80::2")},
+	}
+
+	notDir := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notDir, nil, 0o600))
+
+	badDBPath := filepath.Join(notDir, "leases.json")
+
+	testCases := []struct {
+		sys        *fakeSystemProvider
+		name       string
+		dbFilePath string
+		wantErrMsg string
+	}{{
+		sys:        &fakeSystemProvider{addrs: allAddrs},
+		name:       "valid",
+		dbFilePath: filepath.Join(t.TempDir(), "leases.json"),
+		wantErrMsg: "",
+	}, {
+		sys: &fakeSystemProvider{addrs: map[string][]netip.Addr{
+			"eth0": allAddrs["eth0"],
+		}},
+		name:       "no_interface",
+		dbFilePath: "",
+		wantErrMsg: `interface "eth1": no such network interface`,
+	}, {
+		sys: &fakeSystemProvider{addrs: map[string][]netip.Addr{
+			"eth0": {netip.MustParseAddr("192.168.0.2")},
+			"eth1": allAddrs["eth1"],
+		}},
+		name:       "no_addresses",
+		dbFilePath: "",
+		wantErrMsg: `interface "eth0": ipv4: gateway ip 192.168.0.1 is not assigned` + "\n" +
+			"ipv6: no ipv6 addresses assigned",
+	}, {
+		sys: &fakeSystemProvider{
+			addrs:     allAddrs,
+			busyPorts: map[uint16]bool{67: true, 547: true},
+		},
+		name:       "busy_ports",
+		dbFilePath: "",
+		wantErrMsg: "ipv4: port 67: address already in use\n" +
+			"ipv6: port 547: address already in use",
+	}, {
+		sys:        &fakeSystemProvider{addrs: allAddrs},
+		name:       "db_not_dir",
+		dbFilePath: badDBPath,
+		wantErrMsg: fmt.Sprintf("db file path %q: %q is not a directory", badDBPath, notDir),
+	}, {
+		sys:        &fakeSystemProvider{},
+		name:       "combined",
+		dbFilePath: badDBPath,
+		wantErrMsg: `interface "eth0": no such network interface` + "\n" +
+			`interface "eth1": no such network interface` + "\n" +
+			fmt.Sprintf("db file path %q: %q is not a directory", badDBPath, notDir),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := newTestHostConfig(tc.dbFilePath)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, conf.ValidateOnHost(ctx, tc.sys))
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		conf := &dhcpsvc.Config{Enabled: false}
+		testutil.AssertErrorMsg(t, "", conf.ValidateOnHost(ctx, &fakeSystemProvider{}))
+	})
+}
+
+func TestConfig_ValidateAll(t *testing.T) {
+	ctx := context.Background()
+	sys := &fakeSystemProvider{}
+
+	t.Run("invalid", func(t *testing.T) {
+		conf := &dhcpsvc.Config{Enabled: true, LocalDomainName: testLocalTLD}
+		testutil.AssertErrorMsg(t, "validating config: no interfaces specified", conf.ValidateAll(ctx, sys))
+	})
+
+	t.Run("host", func(t *testing.T) {
+		conf := newTestHostConfig("")
+		testutil.AssertErrorMsg(
+			t,
+			"validating config on host: "+
+				`interface "eth0": no such network interface`+"\n"+
+				`interface "eth1": no such network interface`,
+			conf.ValidateAll(ctx, sys),
+		)
+	})
+}
@@ -0,0 +1,246 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/aghnet"
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/stringutil"
+)
+
+// normalizeDomainName returns the canonical form of the domain name, which is
+// lowercased and has no trailing dot.
+func normalizeDomainName(name string) (norm string) {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// trimLocalDomain returns host in the canonical form, see
+// [normalizeDomainName], with the local domain name removed, if host is within
+// it.
+func (srv *DHCPServer) trimLocalDomain(host string) (name string) {
+	name = normalizeDomainName(host)
+	if netutil.IsSubdomain(name, srv.localTLD) {
+		name = name[:len(name)-len(srv.localTLD)-1]
+	}
+
+	return name
+}
+
+// normalizeHostname normalizes a hostname sent by the client.  If err is not
+// nil, norm is an empty string.
+func normalizeHostname(hostname string) (norm string, err error) {
+	defer func() { err = errors.Annotate(err, "normalizing %q: %w", hostname) }()
+
+	if hostname == "" {
+		return "", nil
+	}
+
+	norm = strings.ToLower(hostname)
+	parts := strings.FieldsFunc(norm, func(c rune) (ok bool) {
+		return c != '.' && !netutil.IsValidHostOuterRune(c)
+	})
+
+	if len(parts) == 0 {
+		return "", fmt.Errorf("no valid parts")
+	}
+
+	norm = strings.Join(parts, "-")
+	norm = strings.TrimSuffix(norm, "-")
+
+	return norm, nil
+}
+
+// newReservedHostnames returns the set of reserved hostnames normalized, see
+// [Config.ReservedHostnames].
+func newReservedHostnames(names []string) (set *stringutil.Set) {
+	if names == nil {
+		names = []string{"gateway", "router"}
+
+		host, err := os.Hostname()
+		if err != nil {
+			log.Debug("dhcpsvc: getting hostname: %s", err)
+		} else {
+			host, _, _ = strings.Cut(host, ".")
+			names = append(names, host)
+		}
+	}
+
+	set = stringutil.NewSet()
+	for _, name := range names {
+		set.Add(normalizeDomainName(name))
+	}
+
+	return set
+}
+
+// maxHostnameSuffix is the greatest numeric suffix appended to the hostname
+// requested by a client, when it's already bound to another client, see
+// [DHCPServer.dedupHostname].
+const maxHostnameSuffix = 16
+
+// hostnameClaim is the result of resolving the hostname requested by a client,
+// see [DHCPServer.clientHostname].
+type hostnameClaim struct {
+	// expired is the dynamic lease, which has already expired, but still
+	// holds the hostname.  It should yield the hostname to the client, see
+	// [DHCPServer.yieldHostname].  It's nil if there is no such lease.
+	expired *Lease
+
+	// hostname is the hostname to assign to the client.  It's empty if no
+	// unique hostname could be assigned.
+	hostname string
+
+	// spoofed is true if the client has requested a reserved hostname or the
+	// one bound to another client.
+	spoofed bool
+}
+
+// clientHostname returns the hostname to assign to the client, which is about
+// to hold a lease for ip.  requested is the hostname sent by the client, and
+// prev is the lease the client currently holds, if any.  The hostname of prev
+// is kept if the client hasn't requested any.
+//
+// The requested hostname is normalized and replaced with the one generated from
+// ip if it's invalid or reserved, see [Config.ReservedHostnames].  All the
+// interfaces share the local domain name, so a hostname bound to another client
+// on any interface can't be taken over until that client's lease expires, and
+// it's suffixed with the smallest free number instead, see
+// [DHCPServer.dedupHostname].  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) clientHostname(requested string, ip netip.Addr, prev *Lease) (c hostnameClaim) {
+	if requested == "" && prev != nil && prev.Hostname != "" {
+		return hostnameClaim{hostname: prev.Hostname}
+	}
+
+	hostname, err := normalizeHostname(srv.trimLocalDomain(requested))
+	if err != nil {
+		log.Info("dhcpsvc: %s", err)
+	} else if hostname != "" {
+		err = netutil.ValidateHostname(hostname)
+		if err != nil {
+			log.Info("dhcpsvc: %s", err)
+			hostname = ""
+		}
+	}
+
+	if srv.reservedHostnames.Has(hostname) {
+		log.Info("dhcpsvc: warning: client at %s requested reserved hostname %q", ip, hostname)
+
+		c.spoofed, hostname = true, ""
+	}
+
+	if hostname != "" {
+		existing, ok := srv.leases.leaseByName(hostname)
+		switch {
+		case !ok, existing == prev:
+			return hostnameClaim{hostname: hostname}
+		case !existing.IsStatic && !srv.now().Before(existing.Expiry):
+			return hostnameClaim{expired: existing, hostname: hostname}
+		}
+
+		c.spoofed = true
+		if c.hostname = srv.dedupHostname(hostname, prev); c.hostname != "" {
+			log.Info(
+				"dhcpsvc: warning: client at %s requested hostname %q bound to %s, using %q",
+				ip,
+				hostname,
+				existing.IP,
+				c.hostname,
+			)
+
+			return c
+		}
+
+		log.Info("dhcpsvc: hostname %q already exists", hostname)
+	}
+
+	c.hostname = aghnet.GenerateHostname(ip)
+	if srv.hostnameTaken(c.hostname, prev) {
+		log.Info("dhcpsvc: generated hostname %q already exists", c.hostname)
+
+		c.hostname = ""
+	}
+
+	return c
+}
+
+// assignHostname sets the hostname for the client requested it to l, which is
+// about to replace prev, if any, see [DHCPServer.clientHostname].  The attempts
+// to spoof hostnames are counted.  renamed is the expired lease, which has
+// yielded the hostname to l, if any.  undo restores the hostname of renamed,
+// it's never nil.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) assignHostname(l *Lease, requested string, prev *Lease) (renamed *Lease, undo func()) {
+	c := srv.clientHostname(requested, l.IP, prev)
+	if c.spoofed {
+		srv.hostnameSpoofs.Add(1)
+	}
+
+	l.Hostname = c.hostname
+	if c.expired == nil {
+		return nil, func() {}
+	}
+
+	return srv.yieldHostname(l)
+}
+
+// dedupHostname returns hostname suffixed with the smallest number from 2 to
+// [maxHostnameSuffix], which makes it unused by any lease other than l, e.g.
+// "printer-2".  deduped is empty if there is no such number or the suffixed
+// hostname is invalid.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) dedupHostname(hostname string, l *Lease) (deduped string) {
+	for i := 2; i <= maxHostnameSuffix; i++ {
+		deduped = hostname + "-" + strconv.Itoa(i)
+		if netutil.ValidateHostname(deduped) != nil {
+			return ""
+		} else if !srv.hostnameTaken(deduped, l) {
+			return deduped
+		}
+	}
+
+	return ""
+}
+
+// hostnameTaken returns true if hostname is used by a lease other than l.
+// srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) hostnameTaken(hostname string, l *Lease) (ok bool) {
+	existing, ok := srv.leases.leaseByName(hostname)
+
+	return ok && existing != l
+}
+
+// yieldHostname renames the dynamic lease holding the hostname of l, if any, so
+// that static leases take precedence over dynamic ones, and the expired leases
+// don't hold their hostnames.
+// The renamed lease gets the hostname generated from its address, or none if
+// that one is also taken.  renamed is nil if no lease has been renamed.  undo
+// restores the previous hostname of renamed, it's never nil.  srv.leasesMu is
+// expected to be locked.
+func (srv *DHCPServer) yieldHostname(l *Lease) (renamed *Lease, undo func()) {
+	existing, ok := srv.leases.leaseByName(l.Hostname)
+	if l.Hostname == "" || !ok || existing.IsStatic {
+		return nil, func() {}
+	}
+
+	prevName := existing.Hostname
+	hostname := aghnet.GenerateHostname(existing.IP)
+	if srv.hostnameTaken(hostname, existing) {
+		hostname = ""
+	}
+
+	log.Info(
+		"dhcpsvc: hostname %q taken by lease for %s, renaming lease for %s to %q",
+		prevName,
+		l.IP,
+		existing.IP,
+		hostname,
+	)
+
+	srv.leases.rename(existing, hostname)
+
+	return existing, func() { srv.leases.rename(existing, prevName) }
+}
@@ -0,0 +1,281 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestLease4 makes the client with mac obtain a dynamic lease for ip with
+// hostname requested on the "eth0" interface of srv.
+func requestLease4(t *testing.T, srv *DHCPServer, mac net.HardwareAddr, ip netip.Addr, hostname string) {
+	t.Helper()
+
+	hostnameOpt := layers.NewDHCPOption(layers.DHCPOptHostname, []byte(hostname))
+	reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice())
+
+	for _, typ := range []layers.DHCPMsgType{layers.DHCPMsgTypeDiscover, layers.DHCPMsgTypeRequest} {
+		resp, err := srv.handle4("eth0", newTestRequest4(mac, typ, hostnameOpt, reqIPOpt))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+	}
+}
+
+func TestDHCPServer_yieldHostname(t *testing.T) {
+	const hostname = "nas"
+
+	dynIP := netip.MustParseAddr("192.168.0.2")
+	dynMAC := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	static := &Lease{
+		IP:       netip.MustParseAddr("192.168.0.100"),
+		Hostname: hostname,
+		HWAddr:   net.HardwareAddr{0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+	}
+
+	t.Run("static_first", func(t *testing.T) {
+		srv := newTestServer4(t, newTestIPv4Config())
+		require.NoError(t, srv.AddStaticLease(static))
+
+		requestLease4(t, srv, dynMAC, dynIP, hostname)
+
+		assert.Equal(t, static.IP, srv.IPByHost(hostname))
+		assert.Equal(t, hostname+"-2", srv.HostByIP(dynIP))
+	})
+
+	t.Run("dynamic_first", func(t *testing.T) {
+		srv := newTestServer4(t, newTestIPv4Config())
+		requestLease4(t, srv, dynMAC, dynIP, hostname)
+
+		require.Equal(t, dynIP, srv.IPByHost(hostname))
+
+		// Checking doesn't rename the dynamic lease.
+		require.NoError(t, srv.CheckStaticLease(static))
+		require.Equal(t, dynIP, srv.IPByHost(hostname))
+
+		ch := make(chan *Event, 2)
+		srv.Subscribe(ch)
+
+		require.NoError(t, srv.AddStaticLease(static))
+
+		require.Len(t, ch, 2)
+
+		ev := <-ch
+		assert.Equal(t, EventTypeAdded, ev.Type)
+
+		ev = <-ch
+		assert.Equal(t, EventTypeRenamed, ev.Type)
+		assert.Equal(t, dynIP, ev.Lease.IP)
+		assert.Equal(t, "192-168-0-2", ev.Lease.Hostname)
+
+		assert.Equal(t, static.IP, srv.IPByHost(hostname))
+		assert.Equal(t, "192-168-0-2", srv.HostByIP(dynIP))
+		assert.Equal(t, dynIP, srv.IPByHost("192-168-0-2"))
+	})
+
+	t.Run("conflict_undone", func(t *testing.T) {
+		srv := newTestServer4(t, newTestIPv4Config())
+		requestLease4(t, srv, dynMAC, dynIP, hostname)
+
+		err := srv.AddStaticLease(&Lease{
+			IP:       dynIP,
+			Hostname: hostname,
+			HWAddr:   static.HWAddr,
+		})
+		assert.ErrorIs(t, err, ErrStaticLeaseConflict)
+
+		assert.Equal(t, dynIP, srv.IPByHost(hostname))
+		assert.Equal(t, hostname, srv.HostByIP(dynIP))
+	})
+}
+
+func TestDHCPServer_clientHostname_interfaces(t *testing.T) {
+	const hostname = "printer"
+
+	newIPv4Config := func(octet byte) (conf *IPv4Config) {
+		return &IPv4Config{
+			Enabled:       true,
+			GatewayIP:     netip.AddrFrom4([4]byte{192, 168, octet, 1}),
+			SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+			RangeStart:    netip.AddrFrom4([4]byte{192, 168, octet, 2}),
+			RangeEnd:      netip.AddrFrom4([4]byte{192, 168, octet, 254}),
+			LeaseDuration: time.Hour,
+		}
+	}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newIPv4Config(0),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+			"eth1": {
+				IPv4: newIPv4Config(1),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	ch := make(chan *Event, 1)
+	srv.Subscribe(ch)
+	t.Cleanup(func() { srv.Unsubscribe(ch) })
+
+	// request makes the client with mac obtain a lease for ip on the network
+	// interface named ifaceName and returns the resulting event.
+	request := func(t *testing.T, ifaceName string, mac net.HardwareAddr, ip netip.Addr) (ev *Event) {
+		t.Helper()
+
+		hostnameOpt := layers.NewDHCPOption(layers.DHCPOptHostname, []byte(hostname))
+		reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice())
+
+		req := newTestRequest4(mac, layers.DHCPMsgTypeRequest, hostnameOpt, reqIPOpt)
+		resp, reqErr := srv.handle4(ifaceName, req)
+		require.NoError(t, reqErr)
+		require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+		require.Len(t, ch, 1)
+
+		return <-ch
+	}
+
+	ip0 := netip.MustParseAddr("192.168.0.2")
+	mac0 := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	ev := request(t, "eth0", mac0, ip0)
+	require.Equal(t, hostname, ev.Lease.Hostname)
+
+	ip1 := netip.MustParseAddr("192.168.1.2")
+	mac1 := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}
+
+	t.Run("shared_domain", func(t *testing.T) {
+		ev = request(t, "eth1", mac1, ip1)
+		assert.Equal(t, EventTypeAdded, ev.Type)
+		assert.Equal(t, hostname+"-2", ev.Lease.Hostname)
+
+		assert.Equal(t, ip0, srv.IPByHost(hostname))
+		assert.Equal(t, ip1, srv.IPByHost(hostname+"-2"))
+
+		// Renewing keeps the deduplicated hostname.
+		ev = request(t, "eth1", mac1, ip1)
+		assert.Equal(t, EventTypeUpdated, ev.Type)
+		assert.Equal(t, hostname+"-2", ev.Lease.Hostname)
+	})
+
+	t.Run("same_interface", func(t *testing.T) {
+		ip := netip.MustParseAddr("192.168.0.3")
+		ev = request(t, "eth0", net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x08}, ip)
+		assert.Equal(t, hostname+"-3", ev.Lease.Hostname)
+		assert.Equal(t, ip, srv.IPByHost(hostname+"-3"))
+	})
+
+	t.Run("renamed", func(t *testing.T) {
+		evs := make(chan *Event, 2)
+		srv.Subscribe(evs)
+		t.Cleanup(func() { srv.Unsubscribe(evs) })
+
+		static := &Lease{
+			IP:       netip.MustParseAddr("192.168.1.100"),
+			Hostname: hostname,
+			HWAddr:   net.HardwareAddr{0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+		}
+		require.NoError(t, srv.AddStaticLease(static))
+
+		// Drain the event sent to the common subscriber.
+		<-ch
+
+		require.Len(t, evs, 2)
+		assert.Equal(t, EventTypeAdded, (<-evs).Type)
+
+		ev = <-evs
+		assert.Equal(t, EventTypeRenamed, ev.Type)
+		assert.Equal(t, ip0, ev.Lease.IP)
+		assert.Equal(t, "eth0", ev.Lease.InterfaceName)
+		assert.Equal(t, "192-168-0-2", ev.Lease.Hostname)
+
+		assert.Equal(t, static.IP, srv.IPByHost(hostname))
+	})
+}
+
+func TestDHCPServer_clientHostname_spoofing(t *testing.T) {
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	ch := make(chan *Event, 8)
+	srv.Subscribe(ch)
+	t.Cleanup(func() { srv.Unsubscribe(ch) })
+
+	ownerIP := netip.MustParseAddr("192.168.0.2")
+	ownerMAC := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	requestLease4(t, srv, ownerMAC, ownerIP, "nas")
+	require.Equal(t, "nas", srv.HostByIP(ownerIP))
+	require.Zero(t, srv.Stats().HostnameSpoofs)
+
+	<-ch
+
+	t.Run("reserved", func(t *testing.T) {
+		ip := netip.MustParseAddr("192.168.0.3")
+		requestLease4(t, srv, net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}, ip, "Router.local")
+
+		assert.Equal(t, "192-168-0-3", srv.HostByIP(ip))
+		assert.False(t, srv.IPByHost("router").IsValid())
+		assert.Equal(t, uint64(1), srv.Stats().HostnameSpoofs)
+
+		<-ch
+	})
+
+	t.Run("takeover_blocked", func(t *testing.T) {
+		ip := netip.MustParseAddr("192.168.0.4")
+		requestLease4(t, srv, net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x08}, ip, "nas")
+
+		assert.Equal(t, "nas-2", srv.HostByIP(ip))
+		assert.Equal(t, ownerIP, srv.IPByHost("nas"))
+		assert.Equal(t, uint64(2), srv.Stats().HostnameSpoofs)
+
+		<-ch
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+
+		ip := netip.MustParseAddr("192.168.0.5")
+		requestLease4(t, srv, net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x09}, ip, "nas")
+
+		assert.Equal(t, ip, srv.IPByHost("nas"))
+		assert.Equal(t, "192-168-0-2", srv.HostByIP(ownerIP))
+		assert.Equal(t, uint64(2), srv.Stats().HostnameSpoofs)
+
+		// All the previous leases have expired.
+		require.Len(t, ch, 5)
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, EventTypeExpired, (<-ch).Type)
+		}
+
+		assert.Equal(t, EventTypeAdded, (<-ch).Type)
+
+		ev := <-ch
+		assert.Equal(t, EventTypeRenamed, ev.Type)
+		assert.Equal(t, ownerIP, ev.Lease.IP)
+	})
+}
+
+func TestNewReservedHostnames(t *testing.T) {
+	set := newReservedHostnames([]string{"NAS.", "printer"})
+	assert.True(t, set.Has("nas"))
+	assert.True(t, set.Has("printer"))
+	assert.False(t, set.Has("router"))
+
+	set = newReservedHostnames(nil)
+	assert.True(t, set.Has("gateway"))
+	assert.True(t, set.Has("router"))
+
+	assert.Empty(t, newReservedHostnames([]string{}).Values())
+}
@@ -0,0 +1,67 @@
+package dhcpsvc
+
+import (
+	"encoding/json"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_specialIfaceName(t *testing.T) {
+	const ifaceName = `wlan0 "guest"`
+
+	conf := &Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	}
+
+	t.Run("config", func(t *testing.T) {
+		require.NoError(t, conf.Validate())
+
+		b, err := json.Marshal(conf)
+		require.NoError(t, err)
+
+		got := &Config{}
+		require.NoError(t, json.Unmarshal(b, got))
+
+		assert.Contains(t, got.Interfaces, ifaceName)
+	})
+
+	srv, err := New(conf)
+	require.NoError(t, err)
+
+	t.Run("static_lease", func(t *testing.T) {
+		l := &Lease{
+			IP:     netip.MustParseAddr("192.168.0.100"),
+			HWAddr: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		}
+		require.NoError(t, srv.AddStaticLease(l))
+
+		leases := srv.Leases()
+		require.Len(t, leases, 1)
+
+		assert.Equal(t, ifaceName, leases[0].InterfaceName)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		s := srv.Status()
+		require.Len(t, s.Interfaces, 1)
+
+		assert.Equal(t, ifaceName, s.Interfaces[0].Name)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err = srv.EffectiveOptions(ifaceName+"2", &ClientHint{})
+		testutil.AssertErrorMsg(t, `effective options for "wlan0 \"guest\"2": no such ipv4 interface`, err)
+	})
+}
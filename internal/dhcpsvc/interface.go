@@ -0,0 +1,187 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// macKey contains hardware address as byte array of 6, 8, or 20 bytes.
+//
+// TODO(e.burkov):  Move to aghnet or even to netutil.
+type macKey any
+
+// macToKey converts mac into macKey, which is used as the key for the lease
+// maps.  mac must be a valid hardware address of length 6, 8, or 20 bytes, see
+// [netutil.ValidateMAC].
+func macToKey(mac net.HardwareAddr) (key macKey) {
+	switch len(mac) {
+	case 6:
+		return [6]byte(mac)
+	case 8:
+		return [8]byte(mac)
+	case 20:
+		return [20]byte(mac)
+	default:
+		panic(fmt.Errorf("invalid mac address %#v", mac))
+	}
+}
+
+// leaseKey is the key of a lease within a network interface.
+type leaseKey struct {
+	// mac is the hardware address of the client.
+	mac macKey
+
+	// iaid is the identity association identifier of a DHCPv6 lease.  It's
+	// always zero for DHCPv4 leases.
+	iaid uint32
+}
+
+// newLeaseKey returns the key of l within a network interface.  l must have a
+// valid hardware address.
+func newLeaseKey(l *Lease) (k leaseKey) {
+	return leaseKey{mac: macToKey(l.HWAddr), iaid: l.IAID}
+}
+
+// netInterface is a common part of any network interface within the DHCP
+// server.
+//
+// TODO(e.burkov):  Add other methods as [DHCPServer] evolves.
+type netInterface struct {
+	// subnet is the network subnet.
+	subnet netip.Prefix
+
+	// addrSpace is the address space allocated for leasing.  It's protected by
+	// [DHCPServer.leasesMu], since it's changed by [DHCPServer.UpdateConfig].
+	addrSpace ipRange
+
+	// conn is the connection serving the network interface.  It's nil if the
+	// interface isn't served.  It's protected by [DHCPServer.connsMu].
+	conn net.PacketConn
+
+	// boundAt is the time conn has been opened.  It's zero if conn is nil.
+	// It's protected by [DHCPServer.connsMu].
+	boundAt time.Time
+
+	// name is the name of the network interface.
+	name string
+
+	// leases is a set of leases indexed by hardware address and, for DHCPv6,
+	// identity association.
+	leases map[leaseKey]*Lease
+
+	// leaseTTL is the default Time-To-Live value for leases.  It's protected by
+	// [DHCPServer.leasesMu].
+	leaseTTL time.Duration
+
+	// searchList is the encoded list of domain names for the clients to
+	// search, see [searchListData].  It's nil if there are no such names.
+	searchList []byte
+
+	// quarantined are the addresses within addrSpace unavailable for
+	// allocation mapped to the time those become available again, which is
+	// zero for the ones quarantined indefinitely.  It's protected by
+	// [DHCPServer.leasesMu].
+	quarantined map[netip.Addr]time.Time
+
+	// unexpectedPorts is the number of messages received from unexpected
+	// source ports, see [SourcePortMode].
+	unexpectedPorts *atomic.Uint64
+
+	// rebinds is the number of times conn has been reopened, see
+	// [DHCPServer.Rebind].  It's protected by [DHCPServer.connsMu].
+	rebinds uint
+
+	// paused is true if the interface isn't served, so that conn is nil, but
+	// its leases are kept, see [DHCPServer.DisableInterface].  It's protected
+	// by [DHCPServer.connsMu].
+	paused bool
+}
+
+// newNetInterface creates a new netInterface with the given name, network,
+// address space, leaseTTL value, and search domains.  The search list is
+// encoded once here to be sent within the options of any address family.
+func newNetInterface(
+	name string,
+	subnet netip.Prefix,
+	addrSpace ipRange,
+	leaseTTL time.Duration,
+	domains []string,
+) (iface netInterface) {
+	return netInterface{
+		subnet:          subnet,
+		addrSpace:       addrSpace,
+		name:            name,
+		leases:          map[leaseKey]*Lease{},
+		leaseTTL:        leaseTTL,
+		searchList:      searchListData(domains),
+		quarantined:     map[netip.Addr]time.Time{},
+		unexpectedPorts: &atomic.Uint64{},
+	}
+}
+
+// isDeprecated returns true if l is a dynamic lease outside the address space
+// of iface, which happens when the range is shrunk by
+// [DHCPServer.UpdateConfig].  Such a lease is kept until it expires, but isn't
+// renewed.  [DHCPServer.leasesMu] is expected to be locked.
+func (iface *netInterface) isDeprecated(l *Lease) (ok bool) {
+	return !l.IsStatic && !iface.addrSpace.contains(l.IP)
+}
+
+// validateLease returns an error if l can't be held by iface.  Static leases
+// must be within the subnet of iface and dynamic ones within its address space.
+func (iface *netInterface) validateLease(l *Lease) (err error) {
+	if l.IsStatic {
+		if !iface.subnet.Contains(l.IP) {
+			return fmt.Errorf("ip %s is not within %s", l.IP, iface.subnet)
+		}
+	} else if !iface.addrSpace.contains(l.IP) {
+		return fmt.Errorf("ip %s is not within the range %s", l.IP, iface.addrSpace)
+	}
+
+	return nil
+}
+
+// addLease inserts the given lease into iface.  It returns an error if the
+// lease can't be inserted.
+func (iface *netInterface) addLease(l *Lease) (err error) {
+	mk := newLeaseKey(l)
+	if _, found := iface.leases[mk]; found {
+		return fmt.Errorf("lease for mac %s already exists", l.HWAddr)
+	}
+
+	iface.leases[mk] = l
+
+	return nil
+}
+
+// updateLease replaces an existing lease within iface with the given one.  It
+// returns an error if there is no lease with such hardware address.
+func (iface *netInterface) updateLease(old, l *Lease) (err error) {
+	oldMK, mk := newLeaseKey(old), newLeaseKey(l)
+	if _, found := iface.leases[oldMK]; !found {
+		return fmt.Errorf("no lease for mac %s found", old.HWAddr)
+	} else if _, found = iface.leases[mk]; found && mk != oldMK {
+		return fmt.Errorf("lease for mac %s already exists", l.HWAddr)
+	}
+
+	delete(iface.leases, oldMK)
+	iface.leases[mk] = l
+
+	return nil
+}
+
+// removeLease removes an existing lease from iface.  It returns an error if
+// there is no lease equal to l.
+func (iface *netInterface) removeLease(l *Lease) (err error) {
+	mk := newLeaseKey(l)
+	if _, found := iface.leases[mk]; !found {
+		return fmt.Errorf("no lease for mac %s found", l.HWAddr)
+	}
+
+	delete(iface.leases, mk)
+
+	return nil
+}
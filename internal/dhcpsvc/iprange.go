@@ -0,0 +1,165 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// ipRange is an inclusive range of IP addresses.  A zero range doesn't contain
+// any IP addresses.
+//
+// It is safe for concurrent use.
+type ipRange struct {
+	start netip.Addr
+	end   netip.Addr
+}
+
+// maxRangeLen is the maximum IP range length.  The bitsets used in servers only
+// accept uints, which can have the size of 32 bit.
+//
+// TODO(a.garipov, e.burkov):  Reconsider the value for IPv6.
+const maxRangeLen = math.MaxUint32
+
+// newIPRange creates a new IP address range.  start must be less than end.  The
+// resulting range must not be greater than maxRangeLen.  Any error returned
+// wraps either [ErrRangeCrossFamily], [ErrRangeInverted], or
+// [ErrRangeTooLarge].
+func newIPRange(start, end netip.Addr) (r ipRange, err error) {
+	defer func() { err = errors.Annotate(err, "invalid ip range %s-%s: %w", start, end) }()
+
+	switch false {
+	case start.Is4() == end.Is4():
+		return ipRange{}, ErrRangeCrossFamily
+	case start.Less(end):
+		return ipRange{}, ErrRangeInverted
+	default:
+		diff := (&big.Int{}).Sub(
+			(&big.Int{}).SetBytes(end.AsSlice()),
+			(&big.Int{}).SetBytes(start.AsSlice()),
+		)
+
+		if !diff.IsUint64() || diff.Uint64() > maxRangeLen {
+			return ipRange{}, fmt.Errorf("%w: length must be within %d", ErrRangeTooLarge, uint32(maxRangeLen))
+		}
+	}
+
+	return ipRange{
+		start: start,
+		end:   end,
+	}, nil
+}
+
+// contains returns true if r contains ip.
+func (r ipRange) contains(ip netip.Addr) (ok bool) {
+	// Assume that the end was checked to be within the same address family as
+	// the start during construction.
+	return r.start.Is4() == ip.Is4() && !ip.Less(r.start) && !r.end.Less(ip)
+}
+
+// ipPredicate is a function that is called on every IP address in
+// [ipRange.find].
+type ipPredicate func(ip netip.Addr) (ok bool)
+
+// find finds the first IP address in r for which p returns true.  It returns an
+// empty [netip.Addr] if there are no addresses that satisfy p.
+func (r ipRange) find(p ipPredicate) (ip netip.Addr) {
+	for ip = r.start; ip.IsValid() && !r.end.Less(ip); ip = ip.Next() {
+		if p(ip) {
+			return ip
+		}
+	}
+
+	return netip.Addr{}
+}
+
+// findFrom is like [ipRange.find], but starts searching from the address from
+// and wraps around to the start of r.  from is ignored if it's not within r.
+func (r ipRange) findFrom(from netip.Addr, p ipPredicate) (ip netip.Addr) {
+	if !r.contains(from) {
+		return r.find(p)
+	}
+
+	for ip = from; ip.IsValid() && !r.end.Less(ip); ip = ip.Next() {
+		if p(ip) {
+			return ip
+		}
+	}
+
+	for ip = r.start; ip.Less(from); ip = ip.Next() {
+		if p(ip) {
+			return ip
+		}
+	}
+
+	return netip.Addr{}
+}
+
+// findDesc is like [ipRange.find], but searches from the end of r downward.
+func (r ipRange) findDesc(p ipPredicate) (ip netip.Addr) {
+	for ip = r.end; ip.IsValid() && !ip.Less(r.start); ip = ip.Prev() {
+		if p(ip) {
+			return ip
+		}
+	}
+
+	return netip.Addr{}
+}
+
+// findFromDesc is like [ipRange.findFrom], but searches downward from the
+// address from and wraps around to the end of r.  from is ignored if it's not
+// within r.
+func (r ipRange) findFromDesc(from netip.Addr, p ipPredicate) (ip netip.Addr) {
+	if !r.contains(from) {
+		return r.findDesc(p)
+	}
+
+	for ip = from; ip.IsValid() && !ip.Less(r.start); ip = ip.Prev() {
+		if p(ip) {
+			return ip
+		}
+	}
+
+	for ip = r.end; from.Less(ip); ip = ip.Prev() {
+		if p(ip) {
+			return ip
+		}
+	}
+
+	return netip.Addr{}
+}
+
+// offset returns the offset of ip from the beginning of r.  It returns 0 and
+// false if ip is not in r.
+func (r ipRange) offset(ip netip.Addr) (offset uint64, ok bool) {
+	if !r.contains(ip) {
+		return 0, false
+	}
+
+	startData, ipData := r.start.As16(), ip.As16()
+	be := binary.BigEndian
+
+	// Assume that the range length was checked against maxRangeLen during
+	// construction.
+	return be.Uint64(ipData[8:]) - be.Uint64(startData[8:]), true
+}
+
+// len returns the number of addresses in r.
+func (r ipRange) len() (n uint64) {
+	if !r.start.IsValid() {
+		return 0
+	}
+
+	last, _ := r.offset(r.end)
+
+	return last + 1
+}
+
+// String implements the fmt.Stringer interface for ipRange.
+func (r ipRange) String() (s string) {
+	return fmt.Sprintf("%s-%s", r.start, r.end)
+}
@@ -0,0 +1,333 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"strconv"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIPRange(t *testing.T) {
+	start4 := netip.MustParseAddr("0.0.0.1")
+	end4 := netip.MustParseAddr("0.0.0.3")
+	start6 := netip.MustParseAddr("1::1")
+	end6 := netip.MustParseAddr("1::3")
+	end6Large := netip.MustParseAddr("2::3")
+
+	testCases := []struct {
+		start      netip.Addr
+		end        netip.Addr
+		wantErr    error
+		name       string
+		wantErrMsg string
+	}{{
+		start:      start4,
+		end:        end4,
+		wantErr:    nil,
+		name:       "success_ipv4",
+		wantErrMsg: "",
+	}, {
+		start:      start6,
+		end:        end6,
+		wantErr:    nil,
+		name:       "success_ipv6",
+		wantErrMsg: "",
+	}, {
+		start:   end4,
+		end:     start4,
+		wantErr: ErrRangeInverted,
+		name:    "start_gt_end",
+		wantErrMsg: "invalid ip range 0.0.0.3-0.0.0.1: start is greater than or " +
+			"equal to end",
+	}, {
+		start:   start4,
+		end:     start4,
+		wantErr: ErrRangeInverted,
+		name:    "start_eq_end",
+		wantErrMsg: "invalid ip range 0.0.0.1-0.0.0.1: start is greater than or " +
+			"equal to end",
+	}, {
+		start:   start6,
+		end:     end6Large,
+		wantErr: ErrRangeTooLarge,
+		name:    "too_large",
+		wantErrMsg: "invalid ip range 1::1-2::3: range is too large: length must " +
+			"be within " + strconv.FormatUint(maxRangeLen, 10),
+	}, {
+		start:   start4,
+		end:     end6,
+		wantErr: ErrRangeCrossFamily,
+		name:    "different_family",
+		wantErrMsg: "invalid ip range 0.0.0.1-1::3: start and end must be within " +
+			"the same address family",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newIPRange(tc.start, tc.end)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestIPRange_Contains(t *testing.T) {
+	start, end := netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.3")
+	r, err := newIPRange(start, end)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		in   netip.Addr
+		want assert.BoolAssertionFunc
+		name string
+	}{{
+		in:   start,
+		want: assert.True,
+		name: "start",
+	}, {
+		in:   end,
+		want: assert.True,
+		name: "end",
+	}, {
+		in:   start.Next(),
+		want: assert.True,
+		name: "within",
+	}, {
+		in:   netip.MustParseAddr("0.0.0.0"),
+		want: assert.False,
+		name: "before",
+	}, {
+		in:   netip.MustParseAddr("0.0.0.4"),
+		want: assert.False,
+		name: "after",
+	}, {
+		in:   netip.MustParseAddr("::"),
+		want: assert.False,
+		name: "another_family",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.want(t, r.contains(tc.in))
+		})
+	}
+}
+
+func TestIPRange_Find(t *testing.T) {
+	start, end := netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.5")
+	r, err := newIPRange(start, end)
+	require.NoError(t, err)
+
+	num, ok := r.offset(end)
+	require.True(t, ok)
+
+	testCases := []struct {
+		predicate ipPredicate
+		want      netip.Addr
+		name      string
+	}{{
+		predicate: func(ip netip.Addr) (ok bool) {
+			ipData := ip.AsSlice()
+
+			return ipData[len(ipData)-1]%2 == 0
+		},
+		want: netip.MustParseAddr("0.0.0.2"),
+		name: "even",
+	}, {
+		predicate: func(ip netip.Addr) (ok bool) {
+			ipData := ip.AsSlice()
+
+			return ipData[len(ipData)-1]%10 == 0
+		},
+		want: netip.Addr{},
+		name: "none",
+	}, {
+		predicate: func(ip netip.Addr) (ok bool) {
+			return true
+		},
+		want: start,
+		name: "first",
+	}, {
+		predicate: func(ip netip.Addr) (ok bool) {
+			off, _ := r.offset(ip)
+
+			return off == num
+		},
+		want: end,
+		name: "last",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.find(tc.predicate))
+		})
+	}
+}
+
+func TestIPRange_Offset(t *testing.T) {
+	start, end := netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.5")
+	r, err := newIPRange(start, end)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		in         netip.Addr
+		name       string
+		wantOffset uint64
+		wantOK     bool
+	}{{
+		in:         netip.MustParseAddr("0.0.0.2"),
+		name:       "in",
+		wantOffset: 1,
+		wantOK:     true,
+	}, {
+		in:         start,
+		name:       "in_start",
+		wantOffset: 0,
+		wantOK:     true,
+	}, {
+		in:         end,
+		name:       "in_end",
+		wantOffset: 4,
+		wantOK:     true,
+	}, {
+		in:         netip.MustParseAddr("0.0.0.6"),
+		name:       "out_after",
+		wantOffset: 0,
+		wantOK:     false,
+	}, {
+		in:         netip.MustParseAddr("0.0.0.0"),
+		name:       "out_before",
+		wantOffset: 0,
+		wantOK:     false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			offset, ok := r.offset(tc.in)
+			assert.Equal(t, tc.wantOffset, offset)
+			assert.Equal(t, tc.wantOK, ok)
+		})
+	}
+}
+
+func TestIPRange_FindFrom(t *testing.T) {
+	start, end := netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.5")
+	r, err := newIPRange(start, end)
+	require.NoError(t, err)
+
+	odd := func(ip netip.Addr) (ok bool) {
+		return ip.As4()[3]%2 == 1
+	}
+
+	testCases := []struct {
+		from      netip.Addr
+		predicate ipPredicate
+		want      netip.Addr
+		name      string
+	}{{
+		from:      netip.MustParseAddr("0.0.0.2"),
+		predicate: odd,
+		want:      netip.MustParseAddr("0.0.0.3"),
+		name:      "forward",
+	}, {
+		from:      netip.MustParseAddr("0.0.0.4"),
+		predicate: odd,
+		want:      end,
+		name:      "forward_end",
+	}, {
+		from: netip.MustParseAddr("0.0.0.4"),
+		predicate: func(ip netip.Addr) (ok bool) {
+			return ip == netip.MustParseAddr("0.0.0.2")
+		},
+		want: netip.MustParseAddr("0.0.0.2"),
+		name: "wrap",
+	}, {
+		from: netip.MustParseAddr("0.0.0.3"),
+		predicate: func(ip netip.Addr) (ok bool) {
+			return false
+		},
+		want: netip.Addr{},
+		name: "none",
+	}, {
+		from:      netip.Addr{},
+		predicate: odd,
+		want:      start,
+		name:      "invalid_from",
+	}, {
+		from:      netip.MustParseAddr("0.0.0.6"),
+		predicate: odd,
+		want:      start,
+		name:      "out_of_range_from",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.findFrom(tc.from, tc.predicate))
+		})
+	}
+}
+
+func TestIPRange_FindFromDesc(t *testing.T) {
+	start, end := netip.MustParseAddr("0.0.0.1"), netip.MustParseAddr("0.0.0.5")
+	r, err := newIPRange(start, end)
+	require.NoError(t, err)
+
+	even := func(ip netip.Addr) (ok bool) {
+		return ip.As4()[3]%2 == 0
+	}
+
+	testCases := []struct {
+		from      netip.Addr
+		predicate ipPredicate
+		want      netip.Addr
+		name      string
+	}{{
+		from:      netip.MustParseAddr("0.0.0.3"),
+		predicate: even,
+		want:      netip.MustParseAddr("0.0.0.2"),
+		name:      "backward",
+	}, {
+		from:      netip.MustParseAddr("0.0.0.4"),
+		predicate: even,
+		want:      netip.MustParseAddr("0.0.0.4"),
+		name:      "from_itself",
+	}, {
+		from: netip.MustParseAddr("0.0.0.2"),
+		predicate: func(ip netip.Addr) (ok bool) {
+			return ip == netip.MustParseAddr("0.0.0.4")
+		},
+		want: netip.MustParseAddr("0.0.0.4"),
+		name: "wrap",
+	}, {
+		from: netip.MustParseAddr("0.0.0.3"),
+		predicate: func(ip netip.Addr) (ok bool) {
+			return false
+		},
+		want: netip.Addr{},
+		name: "none",
+	}, {
+		from:      netip.Addr{},
+		predicate: even,
+		want:      netip.MustParseAddr("0.0.0.4"),
+		name:      "invalid_from",
+	}, {
+		from: netip.MustParseAddr("0.0.0.6"),
+		predicate: func(ip netip.Addr) (ok bool) {
+			return true
+		},
+		want: end,
+		name: "out_of_range_from",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.findFromDesc(tc.from, tc.predicate))
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package dhcpsvc
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// maxLeaseJitter is the upper bound of [Config.LeaseJitter], exclusive.
+const maxLeaseJitter = 100
+
+// maxLeaseTime is the maximum lease time representable in both DHCPv4 and
+// DHCPv6 messages, which is less than the infinite one.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-3.3 and
+// https://datatracker.ietf.org/doc/html/rfc8415#section-7.7.
+const maxLeaseTime = (math.MaxUint32 - 1) * time.Second
+
+// jitterTTL returns ttl randomized within srv.conf.LeaseJitter percents of it
+// in both directions.  The result is never shorter than a second and never
+// longer than [maxLeaseTime].  ttl is returned as is if the jitter is disabled.
+func (srv *DHCPServer) jitterTTL(ttl time.Duration) (jittered time.Duration) {
+	if srv.conf.LeaseJitter == 0 {
+		return ttl
+	}
+
+	// Don't use the nanosecond precision, since the lease times are sent in
+	// seconds anyway.
+	secs := int64(ttl / time.Second)
+	delta := secs * int64(srv.conf.LeaseJitter) / 100
+	if delta == 0 {
+		return ttl
+	}
+
+	jittered = time.Duration(secs-delta+rand.Int63n(2*delta+1)) * time.Second
+	if jittered < time.Second {
+		return time.Second
+	} else if jittered > maxLeaseTime {
+		return maxLeaseTime
+	}
+
+	return jittered
+}
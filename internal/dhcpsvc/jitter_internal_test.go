@@ -0,0 +1,84 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handle4_leaseJitter(t *testing.T) {
+	const (
+		clientsNum = 32
+		ttl        = time.Hour
+	)
+
+	testCases := []struct {
+		name    string
+		jitter  uint
+		wantMin time.Duration
+		wantMax time.Duration
+	}{{
+		name:    "disabled",
+		jitter:  0,
+		wantMin: ttl,
+		wantMax: ttl,
+	}, {
+		name:    "enabled",
+		jitter:  20,
+		wantMin: 48 * time.Minute,
+		wantMax: 72 * time.Minute,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer4(t, newTestIPv4Config())
+			srv.conf.LeaseJitter = tc.jitter
+
+			granted := map[time.Duration]struct{}{}
+			for i := 0; i < clientsNum; i++ {
+				mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, byte(i)}
+
+				resp, err := srv.handle4("eth0", newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+				require.NoError(t, err)
+				require.NotNil(t, resp)
+
+				reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, resp.YourClientIP.To4())
+				resp, err = srv.handle4("eth0", newTestRequest4(mac, layers.DHCPMsgTypeRequest, reqIPOpt))
+				require.NoError(t, err)
+				require.NotNil(t, resp)
+				require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+				data := optData4(resp, layers.DHCPOptLeaseTime)
+				require.Len(t, data, 4)
+
+				got := time.Duration(binary.BigEndian.Uint32(data)) * time.Second
+				assert.GreaterOrEqual(t, got, tc.wantMin)
+				assert.LessOrEqual(t, got, tc.wantMax)
+
+				granted[got] = struct{}{}
+			}
+
+			if tc.jitter == 0 {
+				assert.Len(t, granted, 1)
+			} else {
+				assert.Greater(t, len(granted), 1)
+			}
+		})
+	}
+}
+
+func TestDHCPServer_jitterTTL(t *testing.T) {
+	srv := &DHCPServer{conf: &Config{LeaseJitter: 99}}
+
+	assert.Equal(t, time.Second, srv.jitterTTL(time.Second))
+	assert.LessOrEqual(t, srv.jitterTTL(maxLeaseTime), maxLeaseTime)
+
+	for i := 0; i < 100; i++ {
+		assert.GreaterOrEqual(t, srv.jitterTTL(10*time.Second), time.Second)
+	}
+}
@@ -0,0 +1,638 @@
+package dhcpsvc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/timeutil"
+	"github.com/google/uuid"
+)
+
+// leaseJSON is the JSON form of [Lease].  The fields are encoded in the order
+// of declaration.
+type leaseJSON struct {
+	// Expiry is the expiration time in RFC 3339 format.  It's empty for static
+	// leases.
+	Expiry      string     `json:"expires,omitempty"`
+	Preferred   string     `json:"preferred_until,omitempty"`
+	IP          netip.Addr `json:"ip"`
+	Hostname    string     `json:"hostname"`
+	HWAddr      string     `json:"mac"`
+	Comment     string     `json:"comment,omitempty"`
+	Vendor      string     `json:"vendor,omitempty"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	ClientID    string     `json:"client_id,omitempty"`
+	Interface   string     `json:"interface,omitempty"`
+	IAID        uint32     `json:"iaid,omitempty"`
+	IsStatic    bool       `json:"static"`
+}
+
+// type check
+var _ json.Marshaler = (*Lease)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *Lease.  The
+// hardware address is encoded in the lowercase colon-separated form, and the
+// client identifier is hex-encoded.
+func (l *Lease) MarshalJSON() (b []byte, err error) {
+	lj := &leaseJSON{
+		IP:          l.IP,
+		Hostname:    l.Hostname,
+		HWAddr:      l.HWAddr.String(),
+		Comment:     l.Comment,
+		Vendor:      l.Vendor,
+		Fingerprint: l.Fingerprint,
+		ClientID:    hex.EncodeToString(l.ClientID),
+		Interface:   l.InterfaceName,
+		IAID:        l.IAID,
+		IsStatic:    l.IsStatic,
+	}
+
+	if !l.IsStatic {
+		lj.Expiry = l.Expiry.Format(time.RFC3339)
+	}
+
+	if !l.PreferredUntil.IsZero() {
+		lj.Preferred = l.PreferredUntil.Format(time.RFC3339)
+	}
+
+	return json.Marshal(lj)
+}
+
+// type check
+var _ json.Unmarshaler = (*Lease)(nil)
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for *Lease.  All
+// the fields except the IP and hardware addresses are optional.
+func (l *Lease) UnmarshalJSON(b []byte) (err error) {
+	lj := &leaseJSON{}
+	err = json.Unmarshal(b, lj)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	mac, err := net.ParseMAC(lj.HWAddr)
+	if err != nil {
+		return fmt.Errorf("parsing hardware address: %w", err)
+	}
+
+	clientID, err := hex.DecodeString(lj.ClientID)
+	if err != nil {
+		return fmt.Errorf("parsing client id: %w", err)
+	} else if len(clientID) == 0 {
+		clientID = nil
+	}
+
+	var expiry time.Time
+	if !lj.IsStatic && lj.Expiry != "" {
+		expiry, err = time.Parse(time.RFC3339, lj.Expiry)
+		if err != nil {
+			return fmt.Errorf("parsing expiry time: %w", err)
+		}
+	}
+
+	var preferred time.Time
+	if lj.Preferred != "" {
+		preferred, err = time.Parse(time.RFC3339, lj.Preferred)
+		if err != nil {
+			return fmt.Errorf("parsing preferred lifetime end: %w", err)
+		}
+	}
+
+	*l = Lease{
+		IP:             lj.IP,
+		Expiry:         expiry,
+		PreferredUntil: preferred,
+		Hostname:       lj.Hostname,
+		HWAddr:         mac,
+		Comment:        lj.Comment,
+		Vendor:         lj.Vendor,
+		Fingerprint:    lj.Fingerprint,
+		ClientID:       clientID,
+		InterfaceName:  lj.Interface,
+		IAID:           lj.IAID,
+		IsStatic:       lj.IsStatic,
+	}
+
+	return nil
+}
+
+// configJSON is the JSON form of [Config].  The extension points, e.g.
+// [Config.Listener], and [Config.WorkDir] aren't encoded.
+type configJSON struct {
+	Interfaces            map[string]*InterfaceConfig `json:"interfaces"`
+	LocalDomainName       string                      `json:"local_domain_name"`
+	ExtraSearchDomains    []string                    `json:"extra_search_domains,omitempty"`
+	ReservedHostnames     []string                    `json:"reserved_hostnames"`
+	IgnoredClasses        []string                    `json:"ignored_classes,omitempty"`
+	OptionProfiles        []*optionProfileJSON        `json:"option_profiles,omitempty"`
+	DBFilePath            string                      `json:"db_file_path,omitempty"`
+	LeaseQueryRequestors  []netip.Addr                `json:"lease_query_requestors,omitempty"`
+	ICMPTimeout           timeutil.Duration           `json:"icmp_timeout"`
+	ForceRenewInterval    timeutil.Duration           `json:"force_renew_interval"`
+	RenewalUpdateInterval timeutil.Duration           `json:"renewal_update_interval"`
+	ProbeBudget           timeutil.Duration           `json:"probe_budget"`
+	OfferTimeout          timeutil.Duration           `json:"offer_timeout"`
+	MaxLeases             uint                        `json:"max_leases"`
+	LeaseJitter           uint                        `json:"lease_jitter"`
+	MaxProbes             uint                        `json:"max_probes"`
+	ServerPort            int                         `json:"server_port,omitempty"`
+	ClientPort            int                         `json:"client_port,omitempty"`
+	WrongFamilyMode       WrongFamilyMode             `json:"wrong_family_mode"`
+	SourcePortMode        SourcePortMode              `json:"source_port_mode"`
+	UnknownClients        UnknownClientsPolicy        `json:"unknown_clients"`
+	ExpiryPolicy          ExpiryPolicy                `json:"expiry_policy"`
+	Authoritative         bool                        `json:"authoritative"`
+	ReleaseOnExit         bool                        `json:"release_on_exit"`
+	Enabled               bool                        `json:"enabled"`
+}
+
+// type check
+var _ json.Marshaler = (*Config)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *Config.  The
+// durations are encoded as strings, e.g. "24h".  The interfaces are encoded in
+// the order of their names.
+func (conf *Config) MarshalJSON() (b []byte, err error) {
+	return json.Marshal(&configJSON{
+		Interfaces:            conf.Interfaces,
+		LocalDomainName:       conf.LocalDomainName,
+		ExtraSearchDomains:    conf.ExtraSearchDomains,
+		ReservedHostnames:     conf.ReservedHostnames,
+		IgnoredClasses:        conf.IgnoredClasses,
+		OptionProfiles:        newOptionProfilesJSON(conf.OptionProfiles),
+		DBFilePath:            conf.DBFilePath,
+		LeaseQueryRequestors:  conf.LeaseQueryRequestors,
+		ICMPTimeout:           timeutil.Duration{Duration: conf.ICMPTimeout},
+		ForceRenewInterval:    timeutil.Duration{Duration: conf.ForceRenewInterval},
+		RenewalUpdateInterval: timeutil.Duration{Duration: conf.RenewalUpdateInterval},
+		ProbeBudget:           timeutil.Duration{Duration: conf.ProbeBudget},
+		OfferTimeout:          timeutil.Duration{Duration: conf.OfferTimeout},
+		MaxLeases:             conf.MaxLeases,
+		LeaseJitter:           conf.LeaseJitter,
+		MaxProbes:             conf.MaxProbes,
+		ServerPort:            conf.ServerPort,
+		ClientPort:            conf.ClientPort,
+		WrongFamilyMode:       conf.WrongFamilyMode,
+		SourcePortMode:        conf.SourcePortMode,
+		UnknownClients:        conf.UnknownClients,
+		ExpiryPolicy:          conf.ExpiryPolicy,
+		Authoritative:         conf.Authoritative,
+		ReleaseOnExit:         conf.ReleaseOnExit,
+		Enabled:               conf.Enabled,
+	})
+}
+
+// type check
+var _ json.Unmarshaler = (*Config)(nil)
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for *Config.  The
+// extension points and the working directory of conf are kept.
+func (conf *Config) UnmarshalJSON(b []byte) (err error) {
+	cj := &configJSON{}
+	err = json.Unmarshal(b, cj)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	profiles, err := optionProfilesToInternal(cj.OptionProfiles)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	conf.Interfaces = cj.Interfaces
+	conf.LocalDomainName = cj.LocalDomainName
+	conf.ExtraSearchDomains = cj.ExtraSearchDomains
+	conf.ReservedHostnames = cj.ReservedHostnames
+	conf.IgnoredClasses = cj.IgnoredClasses
+	conf.OptionProfiles = profiles
+	conf.DBFilePath = cj.DBFilePath
+	conf.LeaseQueryRequestors = cj.LeaseQueryRequestors
+	conf.ICMPTimeout = cj.ICMPTimeout.Duration
+	conf.ForceRenewInterval = cj.ForceRenewInterval.Duration
+	conf.RenewalUpdateInterval = cj.RenewalUpdateInterval.Duration
+	conf.ProbeBudget = cj.ProbeBudget.Duration
+	conf.OfferTimeout = cj.OfferTimeout.Duration
+	conf.MaxLeases = cj.MaxLeases
+	conf.LeaseJitter = cj.LeaseJitter
+	conf.MaxProbes = cj.MaxProbes
+	conf.ServerPort = cj.ServerPort
+	conf.ClientPort = cj.ClientPort
+	conf.WrongFamilyMode = cj.WrongFamilyMode
+	conf.SourcePortMode = cj.SourcePortMode
+	conf.UnknownClients = cj.UnknownClients
+	conf.ExpiryPolicy = cj.ExpiryPolicy
+	conf.Authoritative = cj.Authoritative
+	conf.ReleaseOnExit = cj.ReleaseOnExit
+	conf.Enabled = cj.Enabled
+
+	return nil
+}
+
+// interfaceConfigJSON is the JSON form of [InterfaceConfig].
+type interfaceConfigJSON struct {
+	IPv4          *IPv4Config `json:"ipv4,omitempty"`
+	IPv6          *IPv6Config `json:"ipv6,omitempty"`
+	Authoritative *bool       `json:"authoritative,omitempty"`
+}
+
+// type check
+var _ json.Marshaler = (*InterfaceConfig)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *InterfaceConfig.
+func (ic *InterfaceConfig) MarshalJSON() (b []byte, err error) {
+	return json.Marshal(&interfaceConfigJSON{
+		IPv4:          ic.IPv4,
+		IPv6:          ic.IPv6,
+		Authoritative: ic.Authoritative,
+	})
+}
+
+// type check
+var _ json.Unmarshaler = (*InterfaceConfig)(nil)
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for
+// *InterfaceConfig.
+func (ic *InterfaceConfig) UnmarshalJSON(b []byte) (err error) {
+	icj := &interfaceConfigJSON{}
+	err = json.Unmarshal(b, icj)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	ic.IPv4, ic.IPv6 = icj.IPv4, icj.IPv6
+	ic.Authoritative = icj.Authoritative
+
+	return nil
+}
+
+// ipv4ConfigJSON is the JSON form of [IPv4Config].
+type ipv4ConfigJSON struct {
+	GatewayIP        netip.Addr        `json:"gateway_ip"`
+	SubnetMask       netip.Addr        `json:"subnet_mask"`
+	Subnet           netip.Prefix      `json:"subnet"`
+	RangeStart       netip.Addr        `json:"range_start"`
+	RangeEnd         netip.Addr        `json:"range_end"`
+	RangeStartOffset uint32            `json:"range_start_offset,omitempty"`
+	RangeEndOffset   uint32            `json:"range_end_offset,omitempty"`
+	Options          []string          `json:"options,omitempty"`
+	TimezonePOSIX    string            `json:"timezone_posix,omitempty"`
+	TimezoneTZDB     string            `json:"timezone_tzdb,omitempty"`
+	CiscoTFTPServers []netip.Addr      `json:"cisco_tftp_servers,omitempty"`
+	LeaseClasses     []*leaseClassJSON `json:"lease_classes,omitempty"`
+	Netboot          *netbootJSON      `json:"netboot,omitempty"`
+	LeaseDuration    timeutil.Duration `json:"lease_duration"`
+	CheckConflicts   *bool             `json:"check_conflicts,omitempty"`
+	MaxHops          uint8             `json:"max_hops,omitempty"`
+	AllocDirection   AllocDirection    `json:"alloc_direction"`
+	EchoHostname     bool              `json:"echo_hostname"`
+	Paused           bool              `json:"paused"`
+	Enabled          bool              `json:"enabled"`
+}
+
+// type check
+var _ json.Marshaler = (*IPv4Config)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *IPv4Config.  The
+// options are encoded in the string form, see [formatOptStr].
+func (conf *IPv4Config) MarshalJSON() (b []byte, err error) {
+	cj := &ipv4ConfigJSON{
+		GatewayIP:        conf.GatewayIP,
+		SubnetMask:       conf.SubnetMask,
+		Subnet:           conf.Subnet,
+		RangeStart:       conf.RangeStart,
+		RangeEnd:         conf.RangeEnd,
+		RangeStartOffset: conf.RangeStartOffset,
+		RangeEndOffset:   conf.RangeEndOffset,
+		Options:          formatOptions4(conf.options()),
+		TimezonePOSIX:    conf.TimezonePOSIX,
+		TimezoneTZDB:     conf.TimezoneTZDB,
+		CiscoTFTPServers: conf.CiscoTFTPServers,
+		Netboot:          newNetbootJSON(conf.Netboot),
+		LeaseDuration:    timeutil.Duration{Duration: conf.LeaseDuration},
+		CheckConflicts:   conf.CheckConflicts,
+		MaxHops:          conf.MaxHops,
+		AllocDirection:   conf.AllocDirection,
+		EchoHostname:     conf.EchoHostname,
+		Paused:           conf.Paused,
+		Enabled:          conf.Enabled,
+	}
+
+	for _, c := range conf.LeaseClasses {
+		cj.LeaseClasses = append(cj.LeaseClasses, &leaseClassJSON{
+			Name:          c.Name,
+			VendorClass:   c.VendorClass,
+			MACPrefix:     hex.EncodeToString(c.MACPrefix),
+			LeaseDuration: timeutil.Duration{Duration: c.LeaseDuration},
+		})
+	}
+
+	return json.Marshal(cj)
+}
+
+// type check
+var _ json.Unmarshaler = (*IPv4Config)(nil)
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for *IPv4Config.
+// The options are parsed from the string form, see [parseOptStr].
+func (conf *IPv4Config) UnmarshalJSON(b []byte) (err error) {
+	cj := &ipv4ConfigJSON{}
+	err = json.Unmarshal(b, cj)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	opts, err := parseOptions4(cj.Options)
+	if err != nil {
+		return fmt.Errorf("options: %w", err)
+	}
+
+	netboot, err := cj.Netboot.toInternal()
+	if err != nil {
+		return fmt.Errorf("netboot: %w", err)
+	}
+
+	var classes []*LeaseClass
+	for i, c := range cj.LeaseClasses {
+		var prefix []byte
+		prefix, err = hex.DecodeString(c.MACPrefix)
+		if err != nil {
+			return fmt.Errorf("lease class at index %d: mac prefix: %w", i, err)
+		} else if len(prefix) == 0 {
+			prefix = nil
+		}
+
+		classes = append(classes, &LeaseClass{
+			Name:          c.Name,
+			VendorClass:   c.VendorClass,
+			MACPrefix:     prefix,
+			LeaseDuration: c.LeaseDuration.Duration,
+		})
+	}
+
+	*conf = IPv4Config{
+		GatewayIP:        cj.GatewayIP,
+		SubnetMask:       cj.SubnetMask,
+		Subnet:           cj.Subnet,
+		RangeStart:       cj.RangeStart,
+		RangeEnd:         cj.RangeEnd,
+		RangeStartOffset: cj.RangeStartOffset,
+		RangeEndOffset:   cj.RangeEndOffset,
+		Options:          opts,
+		TimezonePOSIX:    cj.TimezonePOSIX,
+		TimezoneTZDB:     cj.TimezoneTZDB,
+		CiscoTFTPServers: cj.CiscoTFTPServers,
+		LeaseClasses:     classes,
+		Netboot:          netboot,
+		LeaseDuration:    cj.LeaseDuration.Duration,
+		CheckConflicts:   cj.CheckConflicts,
+		MaxHops:          cj.MaxHops,
+		AllocDirection:   cj.AllocDirection,
+		EchoHostname:     cj.EchoHostname,
+		Paused:           cj.Paused,
+		Enabled:          cj.Enabled,
+	}
+
+	return nil
+}
+
+// leaseClassJSON is the JSON form of [LeaseClass].
+type leaseClassJSON struct {
+	Name          string            `json:"name,omitempty"`
+	VendorClass   string            `json:"vendor_class,omitempty"`
+	MACPrefix     string            `json:"mac_prefix,omitempty"`
+	LeaseDuration timeutil.Duration `json:"lease_duration"`
+}
+
+// optionProfileJSON is the JSON form of [OptionProfile].
+type optionProfileJSON struct {
+	Name        string   `json:"name"`
+	VendorClass string   `json:"vendor_class,omitempty"`
+	MACs        []string `json:"macs,omitempty"`
+	Options     []string `json:"options,omitempty"`
+}
+
+// newOptionProfilesJSON returns the JSON form of profiles.
+func newOptionProfilesJSON(profiles []*OptionProfile) (pjs []*optionProfileJSON) {
+	for _, p := range profiles {
+		pj := &optionProfileJSON{
+			Name:        p.Name,
+			VendorClass: p.VendorClass,
+			Options:     formatOptions4(p.Options),
+		}
+
+		for _, mac := range p.MACs {
+			pj.MACs = append(pj.MACs, mac.String())
+		}
+
+		pjs = append(pjs, pj)
+	}
+
+	return pjs
+}
+
+// optionProfilesToInternal converts pjs to []*OptionProfile.
+func optionProfilesToInternal(pjs []*optionProfileJSON) (profiles []*OptionProfile, err error) {
+	for i, pj := range pjs {
+		p := &OptionProfile{
+			Name:        pj.Name,
+			VendorClass: pj.VendorClass,
+		}
+
+		for _, s := range pj.MACs {
+			var mac net.HardwareAddr
+			mac, err = net.ParseMAC(s)
+			if err != nil {
+				return nil, fmt.Errorf("option profile at index %d: %w", i, err)
+			}
+
+			p.MACs = append(p.MACs, mac)
+		}
+
+		p.Options, err = parseOptions4(pj.Options)
+		if err != nil {
+			return nil, fmt.Errorf("option profile at index %d: options: %w", i, err)
+		}
+
+		profiles = append(profiles, p)
+	}
+
+	return profiles, nil
+}
+
+// netbootJSON is the JSON form of [NetbootConfig].
+type netbootJSON struct {
+	Rules   []*netbootRuleJSON `json:"rules"`
+	Default *netbootRuleJSON   `json:"default,omitempty"`
+	Enabled bool               `json:"enabled"`
+}
+
+// newNetbootJSON returns the JSON form of conf.  nj is nil if conf is nil.
+func newNetbootJSON(conf *NetbootConfig) (nj *netbootJSON) {
+	if conf == nil {
+		return nil
+	}
+
+	nj = &netbootJSON{
+		Rules:   make([]*netbootRuleJSON, 0, len(conf.Rules)),
+		Default: newNetbootRuleJSON(conf.Default),
+		Enabled: conf.Enabled,
+	}
+
+	for _, r := range conf.Rules {
+		nj.Rules = append(nj.Rules, newNetbootRuleJSON(r))
+	}
+
+	return nj
+}
+
+// toInternal converts nj to *NetbootConfig.  conf is nil if nj is nil.
+func (nj *netbootJSON) toInternal() (conf *NetbootConfig, err error) {
+	if nj == nil {
+		return nil, nil
+	}
+
+	conf = &NetbootConfig{
+		Enabled: nj.Enabled,
+	}
+
+	for i, rj := range nj.Rules {
+		var r *NetbootRule
+		r, err = rj.toInternal()
+		if err != nil {
+			return nil, fmt.Errorf("rule at index %d: %w", i, err)
+		}
+
+		conf.Rules = append(conf.Rules, r)
+	}
+
+	conf.Default, err = nj.Default.toInternal()
+	if err != nil {
+		return nil, fmt.Errorf("default rule: %w", err)
+	}
+
+	return conf, nil
+}
+
+// netbootRuleJSON is the JSON form of [NetbootRule].
+type netbootRuleJSON struct {
+	NextServer    netip.Addr   `json:"next_server"`
+	Archs         []ClientArch `json:"archs,omitempty"`
+	MachineIDs    []uuid.UUID  `json:"machine_ids,omitempty"`
+	UserClass     string       `json:"user_class,omitempty"`
+	BootFile      string       `json:"boot_file"`
+	VendorOptions []string     `json:"vendor_options,omitempty"`
+}
+
+// newNetbootRuleJSON returns the JSON form of r.  rj is nil if r is nil.
+func newNetbootRuleJSON(r *NetbootRule) (rj *netbootRuleJSON) {
+	if r == nil {
+		return nil
+	}
+
+	return &netbootRuleJSON{
+		NextServer:    r.NextServer,
+		Archs:         r.Archs,
+		MachineIDs:    r.MachineIDs,
+		UserClass:     r.UserClass,
+		BootFile:      r.BootFile,
+		VendorOptions: formatOpts4(r.VendorOptions),
+	}
+}
+
+// toInternal converts rj to *NetbootRule.  r is nil if rj is nil.
+func (rj *netbootRuleJSON) toInternal() (r *NetbootRule, err error) {
+	if rj == nil {
+		return nil, nil
+	}
+
+	opts, err := parseOpts4(rj.VendorOptions)
+	if err != nil {
+		return nil, fmt.Errorf("vendor options: %w", err)
+	}
+
+	return &NetbootRule{
+		NextServer:    rj.NextServer,
+		Archs:         rj.Archs,
+		MachineIDs:    rj.MachineIDs,
+		UserClass:     rj.UserClass,
+		BootFile:      rj.BootFile,
+		VendorOptions: opts,
+	}, nil
+}
+
+// ipv6ConfigJSON is the JSON form of [IPv6Config].
+type ipv6ConfigJSON struct {
+	RangeStart        netip.Addr        `json:"range_start"`
+	Options           []string          `json:"options,omitempty"`
+	LeaseDuration     timeutil.Duration `json:"lease_duration"`
+	PreferredDuration timeutil.Duration `json:"preferred_duration"`
+	RASLAACOnly       bool              `json:"ra_slaac_only"`
+	RAAllowSLAAC      bool              `json:"ra_allow_slaac"`
+	RapidCommit       bool              `json:"rapid_commit"`
+	BindLinkLocal     bool              `json:"bind_link_local"`
+	Paused            bool              `json:"paused"`
+	Enabled           bool              `json:"enabled"`
+}
+
+// type check
+var _ json.Marshaler = (*IPv6Config)(nil)
+
+// MarshalJSON implements the [json.Marshaler] interface for *IPv6Config.  The
+// options are encoded in the string form, see [formatOptStr].
+func (conf *IPv6Config) MarshalJSON() (b []byte, err error) {
+	return json.Marshal(&ipv6ConfigJSON{
+		RangeStart:        conf.RangeStart,
+		Options:           formatOpts6(conf.Options),
+		LeaseDuration:     timeutil.Duration{Duration: conf.LeaseDuration},
+		PreferredDuration: timeutil.Duration{Duration: conf.PreferredDuration},
+		RASLAACOnly:       conf.RASLAACOnly,
+		RAAllowSLAAC:      conf.RAAllowSLAAC,
+		RapidCommit:       conf.RapidCommit,
+		BindLinkLocal:     conf.BindLinkLocal,
+		Paused:            conf.Paused,
+		Enabled:           conf.Enabled,
+	})
+}
+
+// type check
+var _ json.Unmarshaler = (*IPv6Config)(nil)
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface for *IPv6Config.
+// The options are parsed from the string form, see [parseOptStr].
+func (conf *IPv6Config) UnmarshalJSON(b []byte) (err error) {
+	cj := &ipv6ConfigJSON{}
+	err = json.Unmarshal(b, cj)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	opts, err := parseOpts6(cj.Options)
+	if err != nil {
+		return fmt.Errorf("options: %w", err)
+	}
+
+	*conf = IPv6Config{
+		RangeStart:        cj.RangeStart,
+		Options:           opts,
+		LeaseDuration:     cj.LeaseDuration.Duration,
+		PreferredDuration: cj.PreferredDuration.Duration,
+		RASLAACOnly:       cj.RASLAACOnly,
+		RAAllowSLAAC:      cj.RAAllowSLAAC,
+		RapidCommit:       cj.RapidCommit,
+		BindLinkLocal:     cj.BindLinkLocal,
+		Paused:            cj.Paused,
+		Enabled:           cj.Enabled,
+	}
+
+	return nil
+}
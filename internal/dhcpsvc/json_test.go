@@ -0,0 +1,273 @@
+package dhcpsvc_test
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testdata is a virtual filesystem containing test data.
+var testdata = os.DirFS("testdata")
+
+// requireGolden requires v to be encoded as the JSON in the file named
+// goldenName within the test data directory of t, and returns the file's
+// contents.
+func requireGolden(t *testing.T, v any, goldenName string) (golden []byte) {
+	t.Helper()
+
+	golden, err := fs.ReadFile(testdata, path.Join(t.Name(), goldenName))
+	require.NoError(t, err)
+
+	got, err := json.MarshalIndent(v, "", "  ")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(golden), string(got)+"\n")
+
+	return golden
+}
+
+// newTestJSONLeases returns the representative set of leases for JSON tests.
+func newTestJSONLeases() (leases []*dhcpsvc.Lease) {
+	return []*dhcpsvc.Lease{{
+		IP:            netip.MustParseAddr("192.168.0.2"),
+		Expiry:        time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC),
+		Hostname:      "phone",
+		HWAddr:        mustParseMAC("AA:BB:CC:DD:EE:FF"),
+		Vendor:        "Vendor Inc.",
+		Fingerprint:   "1,3,6,15;MSFT 5.0",
+		ClientID:      []byte{0x01, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF},
+		InterfaceName: "eth0",
+	}, {
+		IP:            netip.MustParseAddr("192.168.0.100"),
+		Hostname:      "tv",
+		HWAddr:        mustParseMAC("01:02:03:04:05:06"),
+		Comment:       "Living room TV",
+		InterfaceName: "eth0",
+		IsStatic:      true,
+	}, {
+		IP:             netip.MustParseAddr("2001:db8::2"),
+		Expiry:         time.Date(2023, time.October, 2, 0, 30, 0, 0, time.UTC),
+		PreferredUntil: time.Date(2023, time.October, 1, 18, 30, 0, 0, time.UTC),
+		HWAddr:         mustParseMAC("02:03:04:05:06:07"),
+		InterfaceName:  "eth1",
+		IAID:           1,
+	}}
+}
+
+func TestLease_MarshalJSON(t *testing.T) {
+	leases := newTestJSONLeases()
+	golden := requireGolden(t, leases, "leases.json")
+
+	var decoded []*dhcpsvc.Lease
+	require.NoError(t, json.Unmarshal(golden, &decoded))
+
+	assert.Equal(t, leases, decoded)
+}
+
+func TestLease_UnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		want       *dhcpsvc.Lease
+		name       string
+		in         string
+		wantErrMsg string
+	}{{
+		want: &dhcpsvc.Lease{
+			IP:     netip.MustParseAddr("192.168.0.3"),
+			HWAddr: mustParseMAC("aa:bb:cc:dd:ee:ff"),
+		},
+		name:       "minimal",
+		in:         `{"ip":"192.168.0.3","mac":"AA-BB-CC-DD-EE-FF"}`,
+		wantErrMsg: "",
+	}, {
+		want: &dhcpsvc.Lease{
+			IP:       netip.MustParseAddr("192.168.0.3"),
+			HWAddr:   mustParseMAC("aa:bb:cc:dd:ee:ff"),
+			Hostname: "nas",
+			IsStatic: true,
+		},
+		name:       "static",
+		in:         `{"ip":"192.168.0.3","mac":"aa:bb:cc:dd:ee:ff","hostname":"nas","static":true}`,
+		wantErrMsg: "",
+	}, {
+		want:       nil,
+		name:       "bad_mac",
+		in:         `{"ip":"192.168.0.3","mac":"aa:bb"}`,
+		wantErrMsg: "parsing hardware address: address aa:bb: invalid MAC address",
+	}, {
+		want: nil,
+		name: "bad_expiry",
+		in:   `{"ip":"192.168.0.3","mac":"aa:bb:cc:dd:ee:ff","expires":"tomorrow"}`,
+		wantErrMsg: `parsing expiry time: parsing time "tomorrow" as ` +
+			`"2006-01-02T15:04:05Z07:00": cannot parse "tomorrow" as "2006"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := &dhcpsvc.Lease{}
+			err := json.Unmarshal([]byte(tc.in), l)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+
+			if tc.want != nil {
+				assert.Equal(t, tc.want, l)
+			}
+		})
+	}
+}
+
+// newTestJSONConfig returns the representative configuration for JSON tests.
+func newTestJSONConfig() (conf *dhcpsvc.Config) {
+	checkConflicts := true
+	authoritative := false
+
+	return &dhcpsvc.Config{
+		Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+			"eth1": {
+				IPv4: &dhcpsvc.IPv4Config{Enabled: false},
+				IPv6: &dhcpsvc.IPv6Config{
+					RangeStart: netip.MustParseAddr("2001:db8::1"),
+					Options: layers.DHCPv6Options{
+						layers.NewDHCPv6Option(layers.DHCPv6OptDomainList, []byte{3, 'l', 'a', 'n', 0}),
+					},
+					LeaseDuration:     12 * time.Hour,
+					PreferredDuration: 6 * time.Hour,
+					RapidCommit:       true,
+					Paused:            true,
+					Enabled:           true,
+				},
+			},
+			"eth0": {
+				IPv4: &dhcpsvc.IPv4Config{
+					GatewayIP:  netip.MustParseAddr("192.168.0.1"),
+					SubnetMask: netip.MustParseAddr("255.255.255.0"),
+					RangeStart: netip.MustParseAddr("192.168.0.2"),
+					RangeEnd:   netip.MustParseAddr("192.168.0.254"),
+					Options: []dhcpsvc.Option{
+						dhcpsvc.OptionIP(uint8(layers.DHCPOptDNS), netip.MustParseAddr("1.1.1.1")),
+						dhcpsvc.OptionText(uint8(layers.DHCPOptDomainName), "lan"),
+						dhcpsvc.OptionBytes(uint8(layers.DHCPOptIPForwarding), []byte{0}),
+					},
+					LeaseClasses: []*dhcpsvc.LeaseClass{{
+						Name:          "guest",
+						MACPrefix:     net.HardwareAddr{0x02, 0x00, 0x00},
+						LeaseDuration: 30 * time.Minute,
+					}},
+					Netboot: &dhcpsvc.NetbootConfig{
+						Rules: []*dhcpsvc.NetbootRule{{
+							NextServer: netip.MustParseAddr("192.168.0.10"),
+							Archs:      []dhcpsvc.ClientArch{dhcpsvc.ClientArchX64UEFI},
+							MachineIDs: []uuid.UUID{uuid.MustParse("4c4c4544-0042-3510-8052-b4c04f4d4e32")},
+							BootFile:   "ipxe.efi",
+						}},
+						Enabled: true,
+					},
+					LeaseDuration:  24 * time.Hour,
+					CheckConflicts: &checkConflicts,
+					MaxHops:        8,
+					AllocDirection: dhcpsvc.AllocDirectionDescending,
+					Enabled:        true,
+				},
+				IPv6:          &dhcpsvc.IPv6Config{Enabled: false},
+				Authoritative: &authoritative,
+			},
+		},
+		LocalDomainName:    "lan",
+		ExtraSearchDomains: []string{"home.arpa"},
+		ReservedHostnames:  []string{"router", "nas"},
+		IgnoredClasses:     []string{"blocked"},
+		OptionProfiles: []*dhcpsvc.OptionProfile{{
+			Name:        "iot",
+			VendorClass: "iot-device",
+			MACs:        []net.HardwareAddr{mustParseMAC("02:00:00:00:00:01")},
+			Options: []dhcpsvc.Option{
+				dhcpsvc.OptionIP(uint8(layers.DHCPOptDNS), netip.MustParseAddr("8.8.8.8")),
+			},
+		}},
+		ICMPTimeout:           time.Second,
+		ForceRenewInterval:    100 * time.Millisecond,
+		RenewalUpdateInterval: 30 * time.Minute,
+		ProbeBudget:           time.Second,
+		OfferTimeout:          5 * time.Second,
+		MaxProbes:             2,
+		ServerPort:            1067,
+		ClientPort:            1068,
+		WrongFamilyMode:       dhcpsvc.WrongFamilyModeLog,
+		SourcePortMode:        dhcpsvc.SourcePortModeStrict,
+		UnknownClients:        dhcpsvc.UnknownClientsPolicyDeny,
+		ExpiryPolicy:          dhcpsvc.ExpiryPolicyKeep,
+		Authoritative:         true,
+		ReleaseOnExit:         true,
+		Enabled:               true,
+	}
+}
+
+func TestConfig_MarshalJSON(t *testing.T) {
+	conf := newTestJSONConfig()
+	golden := requireGolden(t, conf, "config.json")
+
+	decoded := &dhcpsvc.Config{}
+	require.NoError(t, json.Unmarshal(golden, decoded))
+
+	assert.Equal(t, conf, decoded)
+}
+
+func TestConfig_UnmarshalJSON(t *testing.T) {
+	const in = `{
+		"enabled": true,
+		"local_domain_name": "lan",
+		"interfaces": {
+			"eth0": {
+				"ipv4": {
+					"enabled": true,
+					"gateway_ip": "192.168.0.1",
+					"subnet_mask": "255.255.255.0",
+					"range_start": "192.168.0.2",
+					"range_end": "192.168.0.254",
+					"lease_duration": "24h",
+					"options": [
+						"6 ips 1.1.1.1, 8.8.8.8",
+						"15 text lan",
+						"51 dur 1h"
+					]
+				}
+			}
+		}
+	}`
+
+	conf := &dhcpsvc.Config{}
+	require.NoError(t, json.Unmarshal([]byte(in), conf))
+
+	require.Contains(t, conf.Interfaces, "eth0")
+
+	ic := conf.Interfaces["eth0"]
+	require.NotNil(t, ic.IPv4)
+
+	assert.Nil(t, ic.IPv6)
+	assert.Equal(t, 24*time.Hour, ic.IPv4.LeaseDuration)
+	assert.Equal(t, []dhcpsvc.Option{
+		dhcpsvc.OptionIP(
+			uint8(layers.DHCPOptDNS),
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("8.8.8.8"),
+		),
+		dhcpsvc.OptionText(uint8(layers.DHCPOptDomainName), "lan"),
+		dhcpsvc.OptionBytes(uint8(layers.DHCPOptLeaseTime), []byte{0, 0, 0x0E, 0x10}),
+	}, ic.IPv4.Options)
+	assert.Zero(t, conf.ICMPTimeout)
+	assert.Equal(t, dhcpsvc.WrongFamilyModeCount, conf.WrongFamilyMode)
+	assert.Equal(t, dhcpsvc.SourcePortModePermissive, conf.SourcePortMode)
+
+	err := json.Unmarshal([]byte(`{"interfaces":{"eth0":{"ipv4":{"options":["6 bad 1"]}}}}`), conf)
+	testutil.AssertErrorMsg(t, `options: option "6 bad 1": unknown value type "bad"`, err)
+}
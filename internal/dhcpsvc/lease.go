@@ -0,0 +1,244 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// MaxLeaseCommentLen is the maximum length of [Lease.Comment] in runes.
+const MaxLeaseCommentLen = 256
+
+// InfiniteTTL is the remaining time of a lease that never expires, i.e. of a
+// static one.  See [Lease.RemainingTTL].
+const InfiniteTTL time.Duration = math.MaxInt64
+
+// Lease is a DHCP lease.
+//
+// TODO(e.burkov):  Consider moving it to [agh], since it also may be needed in
+// [websvc].
+type Lease struct {
+	// IP is the IP address leased to the client.
+	IP netip.Addr
+
+	// Expiry is the expiration time of the lease.  For DHCPv6 leases it's the
+	// end of the valid lifetime of the address, after which the binding is
+	// removed.
+	Expiry time.Time
+
+	// PreferredUntil is the end of the preferred lifetime of the address of a
+	// dynamic DHCPv6 lease, after which the address is deprecated.  It's never
+	// after Expiry.  It's zero for DHCPv4 and static leases.
+	//
+	// See https://datatracker.ietf.org/doc/html/rfc8415#section-21.6.
+	PreferredUntil time.Time
+
+	// Hostname of the client.
+	Hostname string
+
+	// HWAddr is the physical hardware address (MAC address).
+	HWAddr net.HardwareAddr
+
+	// Comment is the arbitrary annotation of a static lease set by the user,
+	// e.g. the name of the device.  It's at most [MaxLeaseCommentLen] runes
+	// long.
+	Comment string
+
+	// Vendor is the name of the vendor of the client's network interface
+	// resolved from the hardware address, if any.  It's [VendorLocal] for
+	// locally administered addresses.
+	Vendor string
+
+	// Fingerprint is the DHCPv4 fingerprint of the client from its most recent
+	// DHCPDISCOVER or DHCPREQUEST message, which can be used to identify the
+	// device, e.g. "1,3,6,15;MSFT 5.0".  It consists of the Parameter Request
+	// List option's codes in the order of the request and the Vendor Class
+	// Identifier option's data.  It's empty for static and DHCPv6 leases.
+	Fingerprint string
+
+	// ClientID is the client identifier sent by the client, if any.  For
+	// DHCPv4 it's the value of the Client-identifier option, and for DHCPv6
+	// it's the DUID.
+	ClientID []byte
+
+	// InterfaceName is the name of the network interface the lease has been
+	// granted on.
+	InterfaceName string
+
+	// IAID is the identity association identifier of a DHCPv6 lease, which
+	// tells apart the addresses leased to the same client.  It's always zero
+	// for DHCPv4 leases.
+	IAID uint32
+
+	// IsStatic defines if the lease is static.
+	IsStatic bool
+
+	// unconfirmed is true for the dynamic DHCPv4 lease granted by the previous
+	// instance of the server, which address hasn't been reconfirmed yet, see
+	// [Config.ReleaseOnExit].  It's reset once the lease is changed.
+	unconfirmed bool
+
+	// reportedAt is the time the last change of the dynamic DHCPv4 lease has
+	// been reported, see [Config.RenewalUpdateInterval].
+	reportedAt time.Time
+}
+
+// Clone returns a deep copy of l.
+func (l *Lease) Clone() (clone *Lease) {
+	if l == nil {
+		return nil
+	}
+
+	return &Lease{
+		Expiry:         l.Expiry,
+		PreferredUntil: l.PreferredUntil,
+		Hostname:       l.Hostname,
+		HWAddr:         slices.Clone(l.HWAddr),
+		Comment:        l.Comment,
+		Vendor:         l.Vendor,
+		Fingerprint:    l.Fingerprint,
+		ClientID:       slices.Clone(l.ClientID),
+		IP:             l.IP,
+		InterfaceName:  l.InterfaceName,
+		IAID:           l.IAID,
+		IsStatic:       l.IsStatic,
+	}
+}
+
+// sameExceptExpiry returns true if l and other only differ in their expiration
+// times, if at all.
+func (l *Lease) sameExceptExpiry(other *Lease) (ok bool) {
+	return l.IP == other.IP &&
+		l.PreferredUntil.Equal(other.PreferredUntil) &&
+		l.Hostname == other.Hostname &&
+		bytes.Equal(l.HWAddr, other.HWAddr) &&
+		l.Comment == other.Comment &&
+		l.Vendor == other.Vendor &&
+		l.Fingerprint == other.Fingerprint &&
+		bytes.Equal(l.ClientID, other.ClientID) &&
+		l.InterfaceName == other.InterfaceName &&
+		l.IAID == other.IAID &&
+		l.IsStatic == other.IsStatic &&
+		l.unconfirmed == other.unconfirmed
+}
+
+// RemainingTTL returns the time left until l expires at now.  It's
+// [InfiniteTTL] for static leases and zero for the leases that have already
+// expired.
+func (l *Lease) RemainingTTL(now time.Time) (ttl time.Duration) {
+	if l.IsStatic {
+		return InfiniteTTL
+	}
+
+	ttl = l.Expiry.Sub(now)
+	if ttl < 0 {
+		return 0
+	}
+
+	return ttl
+}
+
+// isDeprecated returns true if l is a dynamic DHCPv6 lease, which preferred
+// lifetime is over at now.  Deprecated addresses aren't resolved for new
+// queries, but still belong to the client until l expires.
+func (l *Lease) isDeprecated(now time.Time) (ok bool) {
+	return !l.PreferredUntil.IsZero() && !now.Before(l.PreferredUntil)
+}
+
+// isExpired4 returns true if l is a dynamic DHCPv4 lease, which is over at now.
+// The address of such a lease may be leased to another client.
+func (l *Lease) isExpired4(now time.Time) (ok bool) {
+	return l.IP.Is4() && !l.IsStatic && !now.Before(l.Expiry)
+}
+
+// isExpired6 returns true if l is a dynamic DHCPv6 lease, which valid lifetime
+// is over at now.
+func (l *Lease) isExpired6(now time.Time) (ok bool) {
+	return l.IP.Is6() && !l.IsStatic && !now.Before(l.Expiry)
+}
+
+// mac returns the hardware address of the client holding l.  If l has no
+// hardware address, it's derived from the client identifier.  mac is nil if
+// neither is available.  The returned slice must not be modified.
+func (l *Lease) mac() (mac net.HardwareAddr) {
+	if len(l.HWAddr) > 0 {
+		return l.HWAddr
+	}
+
+	return macFromClientID(l.ClientID, l.IP.Is4())
+}
+
+// Hardware and DUID types relevant for deriving the hardware address from
+// client identifiers.
+//
+// See https://www.iana.org/assignments/arp-parameters and
+// https://datatracker.ietf.org/doc/html/rfc8415#section-11.1.
+const (
+	// hwTypeEthernet is the hardware type of Ethernet (10Mb).
+	hwTypeEthernet = 1
+
+	// clientIDTypeDUID is the type of DHCPv4 client identifier containing an
+	// IAID and a DUID.  See RFC 4361.
+	clientIDTypeDUID = 255
+
+	// duidTypeLLT is the type of DUID based on link-layer address plus time.
+	duidTypeLLT = 1
+
+	// duidTypeLL is the type of DUID based on link-layer address.
+	duidTypeLL = 3
+)
+
+// macFromClientID returns the hardware address encoded within the client
+// identifier id.  is4 tells if id is a DHCPv4 client identifier as opposed to
+// DHCPv6 DUID.  mac is nil if id doesn't contain a valid hardware address.
+func macFromClientID(id []byte, is4 bool) (mac net.HardwareAddr) {
+	if is4 {
+		switch {
+		case len(id) == 0:
+			return nil
+		case id[0] == hwTypeEthernet:
+			return validMAC(id[1:])
+		case id[0] == clientIDTypeDUID && len(id) > 5:
+			// Skip the type and the 4-byte IAID.
+			id = id[5:]
+		default:
+			return nil
+		}
+	}
+
+	if len(id) < 4 {
+		return nil
+	}
+
+	// Skip the DUID type and the hardware type.
+	typ, data := binary.BigEndian.Uint16(id), id[4:]
+	switch typ {
+	case duidTypeLLT:
+		if len(data) < 4 {
+			return nil
+		}
+
+		// Skip the time.
+		return validMAC(data[4:])
+	case duidTypeLL:
+		return validMAC(data)
+	default:
+		return nil
+	}
+}
+
+// validMAC returns data as a hardware address if it has an appropriate length.
+// Otherwise, it returns nil.
+func validMAC(data []byte) (mac net.HardwareAddr) {
+	switch len(data) {
+	case 6, 8, 20:
+		return data
+	default:
+		return nil
+	}
+}
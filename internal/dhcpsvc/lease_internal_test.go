@@ -0,0 +1,136 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLease_mac(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	otherMAC := net.HardwareAddr{0x02, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	ip4 := netip.MustParseAddr("192.168.0.2")
+	ip6 := netip.MustParseAddr("2001:db8::2")
+
+	testCases := []struct {
+		lease *Lease
+		want  net.HardwareAddr
+		name  string
+	}{{
+		lease: &Lease{IP: ip4, HWAddr: mac},
+		want:  mac,
+		name:  "hwaddr",
+	}, {
+		lease: &Lease{IP: ip4, HWAddr: mac, ClientID: append([]byte{1}, otherMAC...)},
+		want:  mac,
+		name:  "hwaddr_precedence",
+	}, {
+		lease: &Lease{IP: ip4, ClientID: append([]byte{1}, mac...)},
+		want:  mac,
+		name:  "v4_ethernet",
+	}, {
+		lease: &Lease{IP: ip4, ClientID: append([]byte{1}, mac[:5]...)},
+		want:  nil,
+		name:  "v4_short",
+	}, {
+		lease: &Lease{IP: ip4, ClientID: []byte("hostname")},
+		want:  nil,
+		name:  "v4_opaque",
+	}, {
+		lease: &Lease{
+			IP: ip4,
+			ClientID: append([]byte{
+				// Type.
+				0xFF,
+				// IAID.
+				0x00, 0x00, 0x00, 0x01,
+				// DUID-LL and Ethernet.
+				0x00, 0x03, 0x00, 0x01,
+			}, mac...),
+		},
+		want: mac,
+		name: "v4_duid",
+	}, {
+		lease: &Lease{
+			IP: ip6,
+			ClientID: append([]byte{
+				// DUID-LLT and Ethernet.
+				0x00, 0x01, 0x00, 0x01,
+				// Time.
+				0x00, 0x00, 0x00, 0x01,
+			}, mac...),
+		},
+		want: mac,
+		name: "v6_duid_llt",
+	}, {
+		lease: &Lease{
+			IP: ip6,
+			ClientID: append([]byte{
+				// DUID-LL and Ethernet.
+				0x00, 0x03, 0x00, 0x01,
+			}, mac...),
+		},
+		want: mac,
+		name: "v6_duid_ll",
+	}, {
+		lease: &Lease{
+			IP: ip6,
+			ClientID: []byte{
+				// DUID-EN.
+				0x00, 0x02,
+				// Enterprise number.
+				0x00, 0x00, 0x00, 0x09,
+				// Identifier.
+				0x01, 0x02, 0x03, 0x04, 0x05, 0x06,
+			},
+		},
+		want: nil,
+		name: "v6_duid_en",
+	}, {
+		lease: &Lease{IP: ip6},
+		want:  nil,
+		name:  "none",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.lease.mac())
+		})
+	}
+}
+
+func TestLease_RemainingTTL(t *testing.T) {
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		lease *Lease
+		name  string
+		want  time.Duration
+	}{{
+		lease: &Lease{Expiry: now.Add(42 * time.Minute)},
+		name:  "active",
+		want:  42 * time.Minute,
+	}, {
+		lease: &Lease{Expiry: now},
+		name:  "expiring",
+		want:  0,
+	}, {
+		lease: &Lease{Expiry: now.Add(-time.Hour)},
+		name:  "expired",
+		want:  0,
+	}, {
+		lease: &Lease{IsStatic: true},
+		name:  "static",
+		want:  InfiniteTTL,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.lease.RemainingTTL(now))
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package dhcpsvc
+
+import (
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"golang.org/x/exp/slices"
+)
+
+// leaseBinVersion is the current version of the binary encoding of leases, see
+// [Lease.MarshalBinary] and [MarshalLeases].
+//
+// The encoding is forward compatible: the fields are only ever appended to the
+// records in the newer versions, so that the decoders of the older ones read
+// the fields they know and skip the rest of each record.
+const leaseBinVersion byte = 1
+
+// Flags of a lease record within the binary encoding.
+const (
+	// leaseBinFlagStatic is set for static leases.
+	leaseBinFlagStatic byte = 1 << iota
+
+	// leaseBinFlagExpiry is set if the expiration time is encoded.
+	leaseBinFlagExpiry
+
+	// leaseBinFlagPreferred is set if the end of the preferred lifetime is
+	// encoded.
+	leaseBinFlagPreferred
+)
+
+const (
+	// errBinTruncated is returned when the binary encoding of leases ends
+	// unexpectedly.
+	errBinTruncated errors.Error = "unexpected end of data"
+
+	// errBinNoVersion is returned when the binary encoding of leases has an
+	// invalid version.
+	errBinNoVersion errors.Error = "version must be positive"
+)
+
+// type check
+var _ encoding.BinaryMarshaler = (*Lease)(nil)
+
+// MarshalBinary implements the [encoding.BinaryMarshaler] interface for *Lease.
+// The encoding is more compact than the JSON one, see [MarshalLeases] for
+// encoding many leases at once.
+func (l *Lease) MarshalBinary() (data []byte, err error) {
+	return l.appendBinary([]byte{leaseBinVersion}), nil
+}
+
+// type check
+var _ encoding.BinaryUnmarshaler = (*Lease)(nil)
+
+// UnmarshalBinary implements the [encoding.BinaryUnmarshaler] interface for
+// *Lease.  data may be encoded by any version of [Lease.MarshalBinary].
+func (l *Lease) UnmarshalBinary(data []byte) (err error) {
+	r := &binReader{data: data}
+	if r.byte() == 0 && r.err == nil {
+		return errBinNoVersion
+	}
+
+	l.readBinary(r)
+
+	return r.err
+}
+
+// MarshalLeases returns the binary encoding of leases, which is a version
+// followed by the number of leases and the length-prefixed records of each of
+// them.  It's intended for passing large sets of leases between processes.
+func MarshalLeases(leases []*Lease) (data []byte, err error) {
+	data = []byte{leaseBinVersion}
+	data = binary.AppendUvarint(data, uint64(len(leases)))
+
+	var rec []byte
+	for _, l := range leases {
+		rec = l.appendBinary(rec[:0])
+		data = binary.AppendUvarint(data, uint64(len(rec)))
+		data = append(data, rec...)
+	}
+
+	return data, nil
+}
+
+// UnmarshalLeases decodes the leases encoded by any version of [MarshalLeases].
+func UnmarshalLeases(data []byte) (leases []*Lease, err error) {
+	r := &binReader{data: data}
+	if r.byte() == 0 && r.err == nil {
+		return nil, errBinNoVersion
+	}
+
+	n := r.uvarint()
+	if r.err != nil {
+		return nil, fmt.Errorf("reading number of leases: %w", r.err)
+	} else if n > uint64(len(r.data)) {
+		// Each record takes at least a byte, so don't allocate for the bogus
+		// number of leases.
+		return nil, fmt.Errorf("number of leases %d: %w", n, errBinTruncated)
+	}
+
+	leases = make([]*Lease, 0, n)
+	for i := uint64(0); i < n; i++ {
+		rec := &binReader{data: r.bytes()}
+		if r.err != nil {
+			return nil, fmt.Errorf("lease at index %d: %w", i, r.err)
+		}
+
+		l := &Lease{}
+		l.readBinary(rec)
+		if rec.err != nil {
+			return nil, fmt.Errorf("lease at index %d: %w", i, rec.err)
+		}
+
+		leases = append(leases, l)
+	}
+
+	return leases, nil
+}
+
+// appendBinary appends the binary record of l to data and returns the result.
+func (l *Lease) appendBinary(data []byte) (res []byte) {
+	var flags byte
+	if l.IsStatic {
+		flags |= leaseBinFlagStatic
+	}
+
+	if !l.Expiry.IsZero() {
+		flags |= leaseBinFlagExpiry
+	}
+
+	if !l.PreferredUntil.IsZero() {
+		flags |= leaseBinFlagPreferred
+	}
+
+	data = append(data, flags)
+	data = appendBinBytes(data, l.IP.AsSlice())
+	data = appendBinBytes(data, l.HWAddr)
+	data = appendBinBytes(data, []byte(l.Hostname))
+	data = appendBinBytes(data, l.ClientID)
+	data = appendBinBytes(data, []byte(l.InterfaceName))
+	data = binary.AppendUvarint(data, uint64(l.IAID))
+
+	if flags&leaseBinFlagExpiry != 0 {
+		data = appendBinTime(data, l.Expiry)
+	}
+
+	if flags&leaseBinFlagPreferred != 0 {
+		data = appendBinTime(data, l.PreferredUntil)
+	}
+
+	data = appendBinBytes(data, []byte(l.Comment))
+	data = appendBinBytes(data, []byte(l.Vendor))
+
+	return appendBinBytes(data, []byte(l.Fingerprint))
+}
+
+// readBinary sets the fields of l from the binary record read from r.  The
+// error, if any, is left within r.
+func (l *Lease) readBinary(r *binReader) {
+	flags := r.byte()
+
+	if ipData := r.bytes(); len(ipData) > 0 {
+		var ok bool
+		l.IP, ok = netip.AddrFromSlice(ipData)
+		if !ok && r.err == nil {
+			r.err = fmt.Errorf("bad ip address length %d", len(ipData))
+		}
+	}
+
+	l.HWAddr = r.clonedBytes()
+	l.Hostname = string(r.bytes())
+	l.ClientID = r.clonedBytes()
+	l.InterfaceName = string(r.bytes())
+
+	iaid := r.uvarint()
+	if iaid > uint64(^uint32(0)) && r.err == nil {
+		r.err = fmt.Errorf("iaid %d is too large", iaid)
+	}
+
+	l.IAID = uint32(iaid)
+	l.IsStatic = flags&leaseBinFlagStatic != 0
+
+	if flags&leaseBinFlagExpiry != 0 {
+		l.Expiry = r.time()
+	}
+
+	if flags&leaseBinFlagPreferred != 0 {
+		l.PreferredUntil = r.time()
+	}
+
+	l.Comment = string(r.bytes())
+	l.Vendor = string(r.bytes())
+	l.Fingerprint = string(r.bytes())
+}
+
+// appendBinBytes appends the length-prefixed b to data and returns the result.
+func appendBinBytes(data, b []byte) (res []byte) {
+	data = binary.AppendUvarint(data, uint64(len(b)))
+
+	return append(data, b...)
+}
+
+// appendBinTime appends t as the seconds and nanoseconds since the Unix epoch
+// to data and returns the result.
+func appendBinTime(data []byte, t time.Time) (res []byte) {
+	data = binary.AppendVarint(data, t.Unix())
+
+	return binary.AppendUvarint(data, uint64(t.Nanosecond()))
+}
+
+// binReader reads the binary encoding of leases.  Once an error occurs, it's
+// kept in err and the subsequent reads return zero values.
+type binReader struct {
+	// err is the first error occurred while reading.
+	err error
+
+	// data is the unread data.
+	data []byte
+}
+
+// byte reads a single byte.
+func (r *binReader) byte() (b byte) {
+	if r.err != nil {
+		return 0
+	} else if len(r.data) == 0 {
+		r.err = errBinTruncated
+
+		return 0
+	}
+
+	b, r.data = r.data[0], r.data[1:]
+
+	return b
+}
+
+// uvarint reads an unsigned varint.
+func (r *binReader) uvarint() (v uint64) {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = errBinTruncated
+
+		return 0
+	}
+
+	r.data = r.data[n:]
+
+	return v
+}
+
+// varint reads a signed varint.
+func (r *binReader) varint() (v int64) {
+	if r.err != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errBinTruncated
+
+		return 0
+	}
+
+	r.data = r.data[n:]
+
+	return v
+}
+
+// bytes reads length-prefixed bytes.  b refers to the underlying data.
+func (r *binReader) bytes() (b []byte) {
+	l := r.uvarint()
+	if r.err != nil {
+		return nil
+	} else if l > uint64(len(r.data)) {
+		r.err = errBinTruncated
+
+		return nil
+	}
+
+	b, r.data = r.data[:l], r.data[l:]
+
+	return b
+}
+
+// clonedBytes is like [binReader.bytes], but returns a copy, which is nil if
+// empty.
+func (r *binReader) clonedBytes() (b []byte) {
+	b = r.bytes()
+	if len(b) == 0 {
+		return nil
+	}
+
+	return slices.Clone(b)
+}
+
+// time reads the time encoded by [appendBinTime] in UTC.
+func (r *binReader) time() (t time.Time) {
+	sec := r.varint()
+	nsec := r.uvarint()
+	if r.err != nil {
+		return time.Time{}
+	} else if nsec >= uint64(time.Second) {
+		r.err = fmt.Errorf("nanoseconds %d out of range", nsec)
+
+		return time.Time{}
+	}
+
+	return time.Unix(sec, int64(nsec)).UTC()
+}
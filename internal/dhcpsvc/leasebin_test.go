@@ -0,0 +1,152 @@
+package dhcpsvc_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLease_MarshalBinary(t *testing.T) {
+	for _, l := range newTestJSONLeases() {
+		data, err := l.MarshalBinary()
+		require.NoError(t, err)
+
+		decoded := &dhcpsvc.Lease{}
+		err = decoded.UnmarshalBinary(data)
+		require.NoError(t, err)
+
+		assert.Equal(t, l, decoded)
+	}
+}
+
+func TestLease_UnmarshalBinary(t *testing.T) {
+	l := newTestJSONLeases()[0]
+	data, err := l.MarshalBinary()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		want       *dhcpsvc.Lease
+		name       string
+		in         []byte
+		wantErrMsg string
+	}{{
+		want:       l,
+		name:       "newer_version",
+		in:         append(append([]byte{2}, data[1:]...), 0xFF, 0xFF),
+		wantErrMsg: "",
+	}, {
+		want:       nil,
+		name:       "empty",
+		in:         nil,
+		wantErrMsg: "unexpected end of data",
+	}, {
+		want:       nil,
+		name:       "zero_version",
+		in:         append([]byte{0}, data[1:]...),
+		wantErrMsg: "version must be positive",
+	}, {
+		want:       nil,
+		name:       "truncated",
+		in:         data[:len(data)-1],
+		wantErrMsg: "unexpected end of data",
+	}, {
+		want:       nil,
+		name:       "bad_ip",
+		in:         []byte{1, 0, 3, 1, 2, 3},
+		wantErrMsg: "bad ip address length 3",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded := &dhcpsvc.Lease{}
+			decodeErr := decoded.UnmarshalBinary(tc.in)
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, decodeErr)
+			if tc.want != nil {
+				assert.Equal(t, tc.want, decoded)
+			}
+		})
+	}
+}
+
+// newTestBinLeases returns n distinct leases of both address families.
+func newTestBinLeases(n int) (leases []*dhcpsvc.Lease) {
+	expiry := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	leases = make([]*dhcpsvc.Lease, 0, n)
+	for i := 0; i < n; i++ {
+		mac := net.HardwareAddr{0x02, 0x00, 0x00, byte(i >> 16), byte(i >> 8), byte(i)}
+		l := &dhcpsvc.Lease{
+			Expiry:        expiry.Add(time.Duration(i) * time.Second),
+			Hostname:      fmt.Sprintf("host-%d", i),
+			HWAddr:        mac,
+			InterfaceName: "eth0",
+		}
+
+		switch i % 3 {
+		case 0:
+			l.IP = netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)})
+			l.ClientID = append([]byte{1}, mac...)
+			l.Fingerprint = "1,3,6,15;MSFT 5.0"
+		case 1:
+			l.IP = netip.AddrFrom16([16]byte{0x20, 0x01, 0x0d, 0xb8, 13: byte(i >> 16), byte(i >> 8), byte(i)})
+			l.PreferredUntil = l.Expiry.Add(-time.Hour)
+			l.IAID = uint32(i)
+		default:
+			l.IP = netip.AddrFrom4([4]byte{172, byte(i >> 16), byte(i >> 8), byte(i)})
+			l.Expiry = time.Time{}
+			l.Comment = "static"
+			l.IsStatic = true
+		}
+
+		leases = append(leases, l)
+	}
+
+	return leases
+}
+
+func TestMarshalLeases(t *testing.T) {
+	leases := newTestBinLeases(10_000)
+
+	data, err := dhcpsvc.MarshalLeases(leases)
+	require.NoError(t, err)
+
+	jsonData, err := json.Marshal(leases)
+	require.NoError(t, err)
+
+	assert.Less(t, len(data), len(jsonData))
+
+	decoded, err := dhcpsvc.UnmarshalLeases(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, leases, decoded)
+
+	t.Run("empty", func(t *testing.T) {
+		emptyData, marshalErr := dhcpsvc.MarshalLeases(nil)
+		require.NoError(t, marshalErr)
+
+		got, unmarshalErr := dhcpsvc.UnmarshalLeases(emptyData)
+		require.NoError(t, unmarshalErr)
+
+		assert.Empty(t, got)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		_, unmarshalErr := dhcpsvc.UnmarshalLeases(data[:len(data)-1])
+		testutil.AssertErrorMsg(t, "lease at index 9999: unexpected end of data", unmarshalErr)
+	})
+
+	t.Run("bad_count", func(t *testing.T) {
+		bogus := binary.AppendUvarint([]byte{1}, 1<<40)
+		_, unmarshalErr := dhcpsvc.UnmarshalLeases(bogus)
+		testutil.AssertErrorMsg(t, "number of leases 1099511627776: unexpected end of data", unmarshalErr)
+	})
+}
@@ -0,0 +1,200 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// leaseIndex is the set of leases indexed by their identifiers for quick
+// lookup.
+type leaseIndex struct {
+	// byAddr is a lookup shortcut for leases by their IP addresses.
+	byAddr map[netip.Addr]*Lease
+
+	// byName is a lookup shortcut for leases by their hostnames.  The keys
+	// are lowercased.
+	byName map[string]*Lease
+
+	// byClientID is a lookup shortcut for leases by their client identifiers.
+	// The keys are the identifiers converted to strings.  Since the same client
+	// may hold leases on several interfaces, the most recently indexed lease
+	// wins.
+	byClientID map[string]*Lease
+}
+
+// newLeaseIndex returns a new index for [Lease]s.
+func newLeaseIndex() (idx *leaseIndex) {
+	return &leaseIndex{
+		byAddr:     map[netip.Addr]*Lease{},
+		byName:     map[string]*Lease{},
+		byClientID: map[string]*Lease{},
+	}
+}
+
+// leaseByAddr returns a lease by its IP address.
+func (idx *leaseIndex) leaseByAddr(addr netip.Addr) (l *Lease, ok bool) {
+	l, ok = idx.byAddr[addr]
+
+	return l, ok
+}
+
+// leaseByName returns a lease by its hostname.  name is matched
+// case-insensitively.
+func (idx *leaseIndex) leaseByName(name string) (l *Lease, ok bool) {
+	l, ok = idx.byName[strings.ToLower(name)]
+
+	return l, ok
+}
+
+// leaseByClientID returns a lease by its client identifier.
+func (idx *leaseIndex) leaseByClientID(id []byte) (l *Lease, ok bool) {
+	l, ok = idx.byClientID[string(id)]
+
+	return l, ok
+}
+
+// len returns the number of leases in idx.
+func (idx *leaseIndex) len() (n int) {
+	return len(idx.byAddr)
+}
+
+// clear removes all leases from idx.
+func (idx *leaseIndex) clear() {
+	idx.byAddr = map[netip.Addr]*Lease{}
+	idx.byName = map[string]*Lease{}
+	idx.byClientID = map[string]*Lease{}
+}
+
+// add adds l into idx and into iface.  l must be valid, iface should be
+// responsible for l's IP.  It returns an error if l duplicates at least a
+// single value of another lease.
+func (idx *leaseIndex) add(l *Lease, iface *netInterface) (err error) {
+	err = idx.checkAdd(l, iface)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	err = iface.addLease(l)
+	if err != nil {
+		return err
+	}
+
+	idx.byAddr[l.IP] = l
+	if loweredName := strings.ToLower(l.Hostname); loweredName != "" {
+		idx.byName[loweredName] = l
+	}
+
+	idx.addClientID(l)
+
+	return nil
+}
+
+// checkAdd returns an error if l can't be added into idx and into iface, see
+// [leaseIndex.add].  It doesn't modify idx nor iface.
+func (idx *leaseIndex) checkAdd(l *Lease, iface *netInterface) (err error) {
+	loweredName := strings.ToLower(l.Hostname)
+
+	if _, ok := idx.byAddr[l.IP]; ok {
+		return fmt.Errorf("lease for ip %s already exists", l.IP)
+	} else if _, ok = idx.byName[loweredName]; ok && loweredName != "" {
+		return fmt.Errorf("lease for hostname %q already exists", l.Hostname)
+	} else if _, ok = iface.leases[newLeaseKey(l)]; ok {
+		return fmt.Errorf("lease for mac %s already exists", l.HWAddr)
+	}
+
+	return nil
+}
+
+// update replaces old with l in idx and in iface.  l must be valid, iface
+// should be responsible for l's IP.  It returns an error if l duplicates at
+// least a single value of another lease, except old.
+func (idx *leaseIndex) update(old, l *Lease, iface *netInterface) (err error) {
+	loweredName := strings.ToLower(l.Hostname)
+
+	if existing, ok := idx.byAddr[l.IP]; ok && existing != old {
+		return fmt.Errorf("lease for ip %s already exists", l.IP)
+	} else if existing, ok = idx.byName[loweredName]; ok && existing != old && loweredName != "" {
+		return fmt.Errorf("lease for hostname %q already exists", l.Hostname)
+	}
+
+	err = iface.updateLease(old, l)
+	if err != nil {
+		return err
+	}
+
+	delete(idx.byAddr, old.IP)
+	delete(idx.byName, strings.ToLower(old.Hostname))
+	idx.removeClientID(old)
+
+	idx.byAddr[l.IP] = l
+	if loweredName != "" {
+		idx.byName[loweredName] = l
+	}
+
+	idx.addClientID(l)
+
+	return nil
+}
+
+// remove removes l from idx and from iface.  l must be valid, iface should
+// contain the same lease or the lease itself.  It returns an error if the lease
+// is not found.
+func (idx *leaseIndex) remove(l *Lease, iface *netInterface) (err error) {
+	existing, ok := idx.byAddr[l.IP]
+	if !ok {
+		return fmt.Errorf("no lease for ip %s", l.IP)
+	}
+
+	err = iface.removeLease(existing)
+	if err != nil {
+		return err
+	}
+
+	delete(idx.byAddr, existing.IP)
+	if loweredName := strings.ToLower(existing.Hostname); idx.byName[loweredName] == existing {
+		delete(idx.byName, loweredName)
+	}
+
+	idx.removeClientID(existing)
+
+	return nil
+}
+
+// rename changes the hostname of l, which must be in idx, to hostname.
+// hostname must not be used by another lease.
+func (idx *leaseIndex) rename(l *Lease, hostname string) {
+	if loweredName := strings.ToLower(l.Hostname); idx.byName[loweredName] == l {
+		delete(idx.byName, loweredName)
+	}
+
+	l.Hostname = hostname
+	if hostname != "" {
+		idx.byName[strings.ToLower(hostname)] = l
+	}
+}
+
+// addClientID indexes l by its client identifier, if any.
+func (idx *leaseIndex) addClientID(l *Lease) {
+	if len(l.ClientID) > 0 {
+		idx.byClientID[string(l.ClientID)] = l
+	}
+}
+
+// removeClientID removes l from the client identifier index, if it's indexed.
+func (idx *leaseIndex) removeClientID(l *Lease) {
+	if id := string(l.ClientID); idx.byClientID[id] == l {
+		delete(idx.byClientID, id)
+	}
+}
+
+// rangeLeases calls f for each lease in idx in an unspecified order until f
+// returns false.
+func (idx *leaseIndex) rangeLeases(f func(l *Lease) (cont bool)) {
+	for _, l := range idx.byAddr {
+		if !f(l) {
+			break
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// Message types of DHCPv4 leasequery not defined in [layers].
+//
+// See https://datatracker.ietf.org/doc/html/rfc4388#section-6.1.
+const (
+	// dhcpMsgTypeLeaseQuery is the type of the query sent by the requestor.
+	dhcpMsgTypeLeaseQuery layers.DHCPMsgType = 10
+
+	// dhcpMsgTypeLeaseUnassigned is the type of the reply telling that the
+	// queried address is served but not leased.
+	dhcpMsgTypeLeaseUnassigned layers.DHCPMsgType = 11
+
+	// dhcpMsgTypeLeaseUnknown is the type of the reply telling that there is
+	// no information about the query.
+	dhcpMsgTypeLeaseUnknown layers.DHCPMsgType = 12
+
+	// dhcpMsgTypeLeaseActive is the type of the reply containing the data of
+	// the active lease.
+	dhcpMsgTypeLeaseActive layers.DHCPMsgType = 13
+)
+
+// handleLeaseQuery4 handles the DHCPLEASEQUERY message and returns the reply
+// describing the queried lease.  The query is made by the IP address, by the
+// hardware address, or by the client identifier, in that order of precedence.
+// resp is nil if the requestor isn't allowed to query the leases.
+//
+// See https://datatracker.ietf.org/doc/html/rfc4388#section-6.4.
+func (srv *DHCPServer) handleLeaseQuery4(iface *iface4, req *layers.DHCPv4) (resp *layers.DHCPv4) {
+	requestor, _ := netip.AddrFromSlice(req.RelayAgentIP.To4())
+	if !isSet4(requestor) || !slices.Contains(srv.conf.LeaseQueryRequestors, requestor) {
+		log.Debug("dhcpsvc: leasequery from unknown requestor %s, dropping message", requestor)
+
+		return nil
+	}
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	now := srv.now()
+	isActive := func(l *Lease) (ok bool) {
+		return l.IP.Is4() && (l.IsStatic || l.Expiry.After(now))
+	}
+
+	var l *Lease
+	typ := dhcpMsgTypeLeaseUnknown
+	if ip, _ := netip.AddrFromSlice(req.ClientIP.To4()); isSet4(ip) {
+		if found, ok := srv.leases.leaseByAddr(ip); ok && isActive(found) {
+			l = found
+		} else if srv.servesAddr4(ip) {
+			typ = dhcpMsgTypeLeaseUnassigned
+		}
+	} else if len(req.ClientHWAddr) > 0 {
+		l = srv.leaseByMAC4(req.ClientHWAddr)
+	} else if id := optData4(req, layers.DHCPOptClientID); len(id) > 0 {
+		l, _ = srv.leases.leaseByClientID(id)
+	}
+
+	if l != nil && isActive(l) {
+		return iface.newLeaseActiveReply4(req, l, now)
+	}
+
+	return &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: req.HardwareType,
+		HardwareLen:  req.HardwareLen,
+		Xid:          req.Xid,
+		ClientIP:     req.ClientIP,
+		RelayAgentIP: req.RelayAgentIP,
+		ClientHWAddr: req.ClientHWAddr,
+		Options:      layers.DHCPOptions{newMsgTypeOpt4(typ), iface.srvIDOpt},
+	}
+}
+
+// newLeaseActiveReply4 returns the DHCPLEASEACTIVE reply to req describing l.
+// now is used to calculate the remaining lease time.
+func (iface *iface4) newLeaseActiveReply4(
+	req *layers.DHCPv4,
+	l *Lease,
+	now time.Time,
+) (resp *layers.DHCPv4) {
+	var leaseTime uint32 = math.MaxUint32
+	if ttl := l.RemainingTTL(now); ttl != InfiniteTTL {
+		leaseTime = uint32(ttl / time.Second)
+	}
+
+	resp = &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: layers.LinkTypeEthernet,
+		HardwareLen:  uint8(len(l.HWAddr)),
+		Xid:          req.Xid,
+		ClientIP:     l.IP.AsSlice(),
+		RelayAgentIP: req.RelayAgentIP,
+		ClientHWAddr: slices.Clone(l.HWAddr),
+		Options: layers.DHCPOptions{
+			newMsgTypeOpt4(dhcpMsgTypeLeaseActive),
+			iface.srvIDOpt,
+			layers.NewDHCPOption(
+				layers.DHCPOptLeaseTime,
+				binary.BigEndian.AppendUint32(nil, leaseTime),
+			),
+		},
+	}
+
+	if len(l.ClientID) > 0 {
+		resp.Options = append(
+			resp.Options,
+			layers.NewDHCPOption(layers.DHCPOptClientID, slices.Clone(l.ClientID)),
+		)
+	}
+
+	return resp
+}
+
+// leaseByMAC4 returns the DHCPv4 lease of the client with mac on any of the
+// served network interfaces.  l is nil if there is no such lease or mac is
+// invalid.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) leaseByMAC4(mac net.HardwareAddr) (l *Lease) {
+	if netutil.ValidateMAC(mac) != nil {
+		return nil
+	}
+
+	key := leaseKey{mac: macToKey(mac)}
+	for _, iface := range srv.interfaces4 {
+		if l = iface.leases[key]; l != nil {
+			return l
+		}
+	}
+
+	return nil
+}
+
+// servesAddr4 returns true if ip is within the address space of any of the
+// served DHCPv4 network interfaces.
+func (srv *DHCPServer) servesAddr4(ip netip.Addr) (ok bool) {
+	for _, iface := range srv.interfaces4 {
+		if iface.addrSpace.contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
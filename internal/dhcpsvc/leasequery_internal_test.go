@@ -0,0 +1,178 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handleLeaseQuery4(t *testing.T) {
+	const ifaceName = "eth0"
+
+	requestor := netip.MustParseAddr("192.168.0.10")
+
+	srv, err := New(&Config{
+		Enabled:              true,
+		LocalDomainName:      "local",
+		LeaseQueryRequestors: []netip.Addr{requestor},
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	dynMAC := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	dynIP := netip.MustParseAddr("192.168.0.2")
+	clientID := []byte{hwTypeEthernet, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	resp, err := srv.handle4(ifaceName, newTestRequest4(
+		dynMAC,
+		layers.DHCPMsgTypeRequest,
+		layers.NewDHCPOption(layers.DHCPOptRequestIP, dynIP.AsSlice()),
+		layers.NewDHCPOption(layers.DHCPOptClientID, clientID),
+	))
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+	staticMAC := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x07}
+	staticIP := netip.MustParseAddr("192.168.0.100")
+	require.NoError(t, srv.AddStaticLease(&Lease{
+		Hostname: "static",
+		HWAddr:   staticMAC,
+		IP:       staticIP,
+	}))
+
+	newQuery := func(
+		relay netip.Addr,
+		ip netip.Addr,
+		mac net.HardwareAddr,
+		opts ...layers.DHCPOption,
+	) (req *layers.DHCPv4) {
+		req = newTestRequest4(mac, dhcpMsgTypeLeaseQuery, opts...)
+		req.RelayAgentIP = relay.AsSlice()
+		if ip.IsValid() {
+			req.ClientIP = ip.AsSlice()
+		}
+
+		return req
+	}
+
+	testCases := []struct {
+		req      *layers.DHCPv4
+		wantIP   netip.Addr
+		name     string
+		wantMAC  net.HardwareAddr
+		wantType layers.DHCPMsgType
+	}{{
+		req:      newQuery(requestor, dynIP, nil),
+		wantIP:   dynIP,
+		name:     "by_ip",
+		wantMAC:  dynMAC,
+		wantType: dhcpMsgTypeLeaseActive,
+	}, {
+		req:      newQuery(requestor, netip.MustParseAddr("192.168.0.50"), nil),
+		wantIP:   netip.MustParseAddr("192.168.0.50"),
+		name:     "by_ip_unassigned",
+		wantMAC:  nil,
+		wantType: dhcpMsgTypeLeaseUnassigned,
+	}, {
+		req:      newQuery(requestor, netip.MustParseAddr("10.0.0.1"), nil),
+		wantIP:   netip.MustParseAddr("10.0.0.1"),
+		name:     "by_ip_unknown",
+		wantMAC:  nil,
+		wantType: dhcpMsgTypeLeaseUnknown,
+	}, {
+		req:      newQuery(requestor, netip.Addr{}, staticMAC),
+		wantIP:   staticIP,
+		name:     "by_mac",
+		wantMAC:  staticMAC,
+		wantType: dhcpMsgTypeLeaseActive,
+	}, {
+		req:      newQuery(requestor, netip.Addr{}, net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x08}),
+		wantIP:   netip.IPv4Unspecified(),
+		name:     "by_mac_unknown",
+		wantMAC:  net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x08},
+		wantType: dhcpMsgTypeLeaseUnknown,
+	}, {
+		req: newQuery(
+			requestor,
+			netip.Addr{},
+			nil,
+			layers.NewDHCPOption(layers.DHCPOptClientID, clientID),
+		),
+		wantIP:   dynIP,
+		name:     "by_client_id",
+		wantMAC:  dynMAC,
+		wantType: dhcpMsgTypeLeaseActive,
+	}, {
+		req: newQuery(
+			requestor,
+			netip.Addr{},
+			nil,
+			layers.NewDHCPOption(layers.DHCPOptClientID, []byte{0x00, 0x01}),
+		),
+		wantIP:   netip.IPv4Unspecified(),
+		name:     "by_client_id_unknown",
+		wantMAC:  nil,
+		wantType: dhcpMsgTypeLeaseUnknown,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err = srv.handle4(ifaceName, tc.req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantType, msgType4(resp))
+			assert.Equal(t, tc.req.Xid, resp.Xid)
+			assert.Equal(t, requestor.AsSlice(), []byte(resp.RelayAgentIP.To4()))
+			assert.Equal(t, tc.wantMAC, resp.ClientHWAddr)
+
+			ip, _ := netip.AddrFromSlice(resp.ClientIP.To4())
+			if !ip.IsValid() {
+				ip = netip.IPv4Unspecified()
+			}
+
+			assert.Equal(t, tc.wantIP, ip)
+		})
+	}
+
+	t.Run("lease_data", func(t *testing.T) {
+		resp, err = srv.handle4(ifaceName, newQuery(requestor, staticIP, nil))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		leaseTime := optData4(resp, layers.DHCPOptLeaseTime)
+		require.Len(t, leaseTime, 4)
+
+		assert.Equal(t, uint32(math.MaxUint32), binary.BigEndian.Uint32(leaseTime))
+
+		resp, err = srv.handle4(ifaceName, newQuery(requestor, dynIP, nil))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, clientID, optData4(resp, layers.DHCPOptClientID))
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		for _, relay := range []netip.Addr{
+			netip.MustParseAddr("192.168.0.11"),
+			netip.IPv4Unspecified(),
+		} {
+			resp, err = srv.handle4(ifaceName, newQuery(relay, dynIP, nil))
+			require.NoError(t, err)
+
+			assert.Nil(t, resp)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// maskToPrefixLen returns the length of the prefix of the network mask.  ok is
+// false if mask isn't a valid canonical mask, i.e. its ones aren't followed by
+// zeros only.
+func maskToPrefixLen(mask netip.Addr) (bits int, ok bool) {
+	if !mask.IsValid() {
+		return 0, false
+	}
+
+	bits, total := net.IPMask(mask.AsSlice()).Size()
+
+	return bits, total != 0
+}
+
+// prefixLenToMask returns the network mask having the prefix of the given
+// length.  is4 tells if mask should be an IPv4 one.  mask is invalid if bits is
+// out of range for the address family.
+func prefixLenToMask(bits int, is4 bool) (mask netip.Addr) {
+	total := netutil.IPv6BitLen
+	if is4 {
+		total = netutil.IPv4BitLen
+	}
+
+	// net.CIDRMask returns nil for invalid bits, so that mask is invalid.
+	mask, _ = netip.AddrFromSlice(net.CIDRMask(bits, total))
+
+	return mask
+}
+
+// subnetMaskOpt4 returns the Subnet Mask option for subnet.  It's the only
+// source of the option sent to the clients, so that it always reflects the
+// prefix length of the network interface, however the network is configured.
+func subnetMaskOpt4(subnet netip.Prefix) (opt layers.DHCPOption) {
+	return layers.NewDHCPOption(layers.DHCPOptSubnetMask, prefixLenToMask(subnet.Bits(), true).AsSlice())
+}
+
+// dropSubnetMask4 returns opts without the Subnet Mask options, which are
+// logged as ignored, see [subnetMaskOpt4].  owner describes the source of
+// opts within the log message.
+func dropSubnetMask4(opts layers.DHCPOptions, owner string) (res layers.DHCPOptions) {
+	return slices.DeleteFunc(opts, func(o layers.DHCPOption) (ok bool) {
+		if o.Type != layers.DHCPOptSubnetMask {
+			return false
+		}
+
+		log.Info("dhcpsvc: warning: %s: subnet mask option is derived from subnet, ignoring", owner)
+
+		return true
+	})
+}
@@ -0,0 +1,196 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskToPrefixLen(t *testing.T) {
+	testCases := []struct {
+		mask   netip.Addr
+		name   string
+		bits   int
+		wantOK bool
+	}{{
+		mask:   netip.MustParseAddr("0.0.0.0"),
+		name:   "zero_v4",
+		bits:   0,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("255.0.0.0"),
+		name:   "8",
+		bits:   8,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("255.255.240.0"),
+		name:   "20",
+		bits:   20,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("255.255.255.0"),
+		name:   "24",
+		bits:   24,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("255.255.255.254"),
+		name:   "31",
+		bits:   31,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("255.255.255.255"),
+		name:   "32",
+		bits:   32,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("ffff:ffff:ffff:ffff::"),
+		name:   "64_v6",
+		bits:   64,
+		wantOK: true,
+	}, {
+		mask:   netip.MustParseAddr("255.0.255.0"),
+		name:   "non_canonical",
+		bits:   0,
+		wantOK: false,
+	}, {
+		mask:   netip.MustParseAddr("0.255.255.255"),
+		name:   "inverted",
+		bits:   0,
+		wantOK: false,
+	}, {
+		mask:   netip.MustParseAddr("ffff::ffff"),
+		name:   "non_canonical_v6",
+		bits:   0,
+		wantOK: false,
+	}, {
+		mask:   netip.Addr{},
+		name:   "invalid",
+		bits:   0,
+		wantOK: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bits, ok := maskToPrefixLen(tc.mask)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.bits, bits)
+
+			if ok {
+				assert.Equal(t, tc.mask, prefixLenToMask(bits, tc.mask.Is4()))
+			}
+		})
+	}
+}
+
+func TestPrefixLenToMask(t *testing.T) {
+	testCases := []struct {
+		want netip.Addr
+		name string
+		bits int
+		is4  bool
+	}{{
+		want: netip.MustParseAddr("255.255.255.0"),
+		name: "24",
+		bits: 24,
+		is4:  true,
+	}, {
+		want: netip.MustParseAddr("ffff:ffff:ffff:ffff::"),
+		name: "64_v6",
+		bits: 64,
+		is4:  false,
+	}, {
+		want: netip.MustParseAddr("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00"),
+		name: "120_v6",
+		bits: 120,
+		is4:  false,
+	}, {
+		want: netip.Addr{},
+		name: "too_long",
+		bits: 33,
+		is4:  true,
+	}, {
+		want: netip.Addr{},
+		name: "negative",
+		bits: -1,
+		is4:  false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mask := prefixLenToMask(tc.bits, tc.is4)
+			assert.Equal(t, tc.want, mask)
+
+			if mask.IsValid() {
+				bits, ok := maskToPrefixLen(mask)
+				assert.True(t, ok)
+				assert.Equal(t, tc.bits, bits)
+			}
+		})
+	}
+}
+
+func TestDHCPServer_handle4_subnetMask(t *testing.T) {
+	wantMask := []byte{255, 255, 240, 0}
+
+	testCases := []struct {
+		conf *IPv4Config
+		name string
+	}{{
+		conf: &IPv4Config{
+			GatewayIP:  netip.MustParseAddr("10.0.0.1"),
+			SubnetMask: netip.MustParseAddr("255.255.240.0"),
+			RangeStart: netip.MustParseAddr("10.0.0.2"),
+			RangeEnd:   netip.MustParseAddr("10.0.15.254"),
+		},
+		name: "mask",
+	}, {
+		conf: &IPv4Config{
+			GatewayIP:  netip.MustParseAddr("10.0.0.1"),
+			Subnet:     netip.MustParsePrefix("10.0.0.0/20"),
+			RangeStart: netip.MustParseAddr("10.0.0.2"),
+			RangeEnd:   netip.MustParseAddr("10.0.15.254"),
+		},
+		name: "prefix",
+	}, {
+		conf: &IPv4Config{
+			GatewayIP:        netip.MustParseAddr("10.0.0.1"),
+			Subnet:           netip.MustParsePrefix("10.0.3.7/20"),
+			RangeStartOffset: 2,
+			RangeEndOffset:   4094,
+		},
+		name: "prefix_offsets",
+	}, {
+		conf: &IPv4Config{
+			GatewayIP:  netip.MustParseAddr("10.0.0.1"),
+			SubnetMask: netip.MustParseAddr("255.255.240.0"),
+			RangeStart: netip.MustParseAddr("10.0.0.2"),
+			RangeEnd:   netip.MustParseAddr("10.0.15.254"),
+			Options: []Option{
+				OptionIP(uint8(layers.DHCPOptSubnetMask), netip.MustParseAddr("255.255.255.0")),
+			},
+		},
+		name: "configured_option",
+	}}
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.conf.Enabled = true
+			tc.conf.LeaseDuration = 1 * time.Hour
+
+			srv := newTestServer4(t, tc.conf)
+
+			resp, err := srv.handle4("eth0", newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, wantMask, optData4(resp, layers.DHCPOptSubnetMask))
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/google/gopacket/layers"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// ClientArch is the client system architecture type sent by network booting
+// clients within the Client System Architecture Type option.
+//
+// See https://www.iana.org/assignments/dhcpv6-parameters/processor-architecture.csv.
+type ClientArch uint16
+
+// Common client system architecture types.
+const (
+	// ClientArchX86BIOS is the architecture of x86 clients booting from the
+	// legacy BIOS PXE.
+	ClientArchX86BIOS ClientArch = 0
+
+	// ClientArchX86UEFI is the architecture of x86 clients booting from UEFI.
+	ClientArchX86UEFI ClientArch = 6
+
+	// ClientArchX64UEFI is the architecture of x64 clients booting from UEFI.
+	ClientArchX64UEFI ClientArch = 7
+
+	// ClientArchARM64UEFI is the architecture of ARM64 clients booting from
+	// UEFI.
+	ClientArchARM64UEFI ClientArch = 11
+
+	// ClientArchX64UEFIHTTP is the architecture of x64 clients booting from
+	// UEFI over HTTP.
+	ClientArchX64UEFIHTTP ClientArch = 16
+)
+
+// NetbootConfig is the configuration of network booting of DHCPv4 clients.
+// It only applies to clients sending the Client System Architecture Type, the
+// Client Network Interface Identifier, the Client Machine Identifier, or the
+// User Class option.
+type NetbootConfig struct {
+	// Rules are the rules selecting the boot parameters for the clients.  The
+	// first matching rule applies.  It must not be empty if Enabled is true.
+	Rules []*NetbootRule
+
+	// Default is the rule applied to the clients matching none of Rules.  If
+	// nil, such clients are sent no boot parameters.
+	Default *NetbootRule
+
+	// Enabled defines if the boot parameters should be sent to clients.
+	Enabled bool
+}
+
+// NetbootRule selects the boot parameters for the network booting clients.
+// A client matches the rule if it matches Archs, MachineIDs, and UserClass.
+type NetbootRule struct {
+	// NextServer is the address of the server to load the boot file from, sent
+	// within the siaddr field.  It's not sent if unset.
+	NextServer netip.Addr
+
+	// Archs are the architectures of the matching clients.  Empty Archs match
+	// any client.
+	Archs []ClientArch
+
+	// MachineIDs are the machine identifiers of the matching clients, i.e. the
+	// UUIDs sent within the Client Machine Identifier option.  The identifiers
+	// are compared byte by byte as sent by the clients.  Empty MachineIDs match
+	// any client.
+	MachineIDs []uuid.UUID
+
+	// UserClass is the user class of the matching clients, e.g. "iPXE".  Empty
+	// UserClass matches any client.
+	UserClass string
+
+	// BootFile is the name of the boot file, sent within the Bootfile Name
+	// option.  It must not be empty.
+	BootFile string
+
+	// VendorOptions are the additional options sent to the matching clients,
+	// e.g. the Vendor Class Identifier "HTTPClient" required by UEFI HTTP
+	// boot.
+	VendorOptions layers.DHCPOptions
+}
+
+// validate returns an error if conf can't be used.
+func (conf *NetbootConfig) validate() (err error) {
+	switch {
+	case conf == nil:
+		return nil
+	case !conf.Enabled:
+		return nil
+	case len(conf.Rules) == 0:
+		return errors.Error("no netboot rules")
+	}
+
+	for i, r := range conf.Rules {
+		err = r.validate()
+		if err != nil {
+			return fmt.Errorf("netboot rule at index %d: %w", i, err)
+		}
+	}
+
+	if conf.Default != nil {
+		err = conf.Default.validate()
+		if err != nil {
+			return fmt.Errorf("default netboot rule: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// validate returns an error if r can't be used.
+func (r *NetbootRule) validate() (err error) {
+	switch {
+	case r == nil:
+		return errNilConfig
+	case r.BootFile == "":
+		return errors.Error("boot file must not be empty")
+	case len(r.BootFile) > maxOptLen4:
+		return fmt.Errorf("boot file %q must not be longer than %d bytes", r.BootFile, maxOptLen4)
+	case r.NextServer.IsValid() && !r.NextServer.Is4():
+		return newMustErr("next server", "be a valid ipv4", r.NextServer)
+	default:
+		return nil
+	}
+}
+
+// Options of DHCPv4 related to network booting not defined in [layers].
+const (
+	// dhcpOptBootFileName is the option containing the name of the boot file.
+	// See RFC 2132.
+	dhcpOptBootFileName layers.DHCPOpt = 67
+
+	// dhcpOptUserClass is the option containing the user classes of the
+	// client.  See RFC 3004.
+	dhcpOptUserClass layers.DHCPOpt = 77
+
+	// dhcpOptClientArch is the option containing the system architecture types
+	// of the client.  See RFC 4578.
+	dhcpOptClientArch layers.DHCPOpt = 93
+
+	// dhcpOptClientNDI is the option containing the version of the network
+	// interface of the client.  See RFC 4578.
+	dhcpOptClientNDI layers.DHCPOpt = 94
+
+	// dhcpOptClientMachineID is the option containing the machine identifier
+	// of the client.  See RFC 4578.
+	dhcpOptClientMachineID layers.DHCPOpt = 97
+)
+
+// Constants of the Client Network Interface Identifier and the Client Machine
+// Identifier options.
+//
+// See https://datatracker.ietf.org/doc/html/rfc4578#section-2.2.
+const (
+	// clientNDILen is the length of the Client Network Interface Identifier
+	// option's data.
+	clientNDILen = 3
+
+	// clientNDITypeUNDI is the type of the Universal Network Device Interface
+	// within the Client Network Interface Identifier option.
+	clientNDITypeUNDI = 1
+
+	// clientMachineIDTypeUUID is the type of the UUID within the Client
+	// Machine Identifier option.
+	clientMachineIDTypeUUID = 0
+)
+
+// netbootClient is the information sent by a network booting client.
+type netbootClient struct {
+	// userClass is the data of the User Class option.
+	userClass []byte
+
+	// archs are the system architecture types of the client.
+	archs []ClientArch
+
+	// machineID is the machine identifier of the client.  It's valid only if
+	// hasMachineID is true.
+	machineID uuid.UUID
+
+	// hasMachineID is true if the client has sent a valid machine identifier.
+	hasMachineID bool
+
+	// hasUNDI is true if the client has sent a valid network interface
+	// identifier.
+	hasUNDI bool
+}
+
+// newNetbootClient returns the information about the network booting client
+// sent req.  c is nil if the client isn't a network booting one.
+func newNetbootClient(req *layers.DHCPv4) (c *netbootClient) {
+	c = &netbootClient{
+		userClass: optData4(req, dhcpOptUserClass),
+		archs:     clientArchs(req),
+	}
+
+	ndi := optData4(req, dhcpOptClientNDI)
+	c.hasUNDI = len(ndi) == clientNDILen && ndi[0] == clientNDITypeUNDI
+
+	id := optData4(req, dhcpOptClientMachineID)
+	if len(id) == 1+len(c.machineID) && id[0] == clientMachineIDTypeUUID {
+		copy(c.machineID[:], id[1:])
+		c.hasMachineID = true
+	}
+
+	if len(c.archs) == 0 && len(c.userClass) == 0 && !c.hasUNDI && !c.hasMachineID {
+		return nil
+	}
+
+	return c
+}
+
+// matches returns true if the client c matches r.
+func (r *NetbootRule) matches(c *netbootClient) (ok bool) {
+	if len(r.Archs) > 0 && !slices.ContainsFunc(c.archs, func(a ClientArch) (found bool) {
+		return slices.Contains(r.Archs, a)
+	}) {
+		return false
+	}
+
+	if len(r.MachineIDs) > 0 && !(c.hasMachineID && slices.Contains(r.MachineIDs, c.machineID)) {
+		return false
+	}
+
+	return r.UserClass == "" || hasUserClass(c.userClass, r.UserClass)
+}
+
+// hasUserClass returns true if data of the User Class option contains class.
+// Since some clients, e.g. iPXE, send the single user class as is, data is
+// checked to be equal to class before being parsed as a list of classes.
+//
+// See https://datatracker.ietf.org/doc/html/rfc3004#section-4.
+func hasUserClass(data []byte, class string) (ok bool) {
+	if string(data) == class {
+		return true
+	}
+
+	for len(data) > 0 {
+		l := int(data[0])
+		if l == 0 || l >= len(data) {
+			return false
+		} else if string(data[1:l+1]) == class {
+			return true
+		}
+
+		data = data[l+1:]
+	}
+
+	return false
+}
+
+// clientArchs returns the system architecture types sent by the client within
+// req.
+//
+// See https://datatracker.ietf.org/doc/html/rfc4578#section-2.1.
+func clientArchs(req *layers.DHCPv4) (archs []ClientArch) {
+	data := optData4(req, dhcpOptClientArch)
+	for ; len(data) >= 2; data = data[2:] {
+		archs = append(archs, ClientArch(binary.BigEndian.Uint16(data)))
+	}
+
+	return archs
+}
+
+// netbootRule returns the rule selecting the boot parameters for the client c.
+// r is nil if c is nil or no rule applies to it.
+func (conf *NetbootConfig) netbootRule(c *netbootClient) (r *NetbootRule) {
+	if c == nil {
+		return nil
+	}
+
+	for _, r = range conf.Rules {
+		if r.matches(c) {
+			return r
+		}
+	}
+
+	return conf.Default
+}
+
+// setNetboot4 sets the boot parameters selected for the client sent req to
+// resp.  The Client Machine Identifier option is sent back to the client, if
+// it has sent one, as required by PXE.
+func (iface *iface4) setNetboot4(resp *layers.DHCPv4, req *layers.DHCPv4) {
+	if iface.netboot == nil {
+		return
+	}
+
+	c := newNetbootClient(req)
+	r := iface.netboot.netbootRule(c)
+	if r == nil {
+		return
+	}
+
+	if c.hasMachineID {
+		setOpt4(resp, layers.NewDHCPOption(
+			dhcpOptClientMachineID,
+			append([]byte{clientMachineIDTypeUUID}, c.machineID[:]...),
+		))
+	}
+
+	if r.NextServer.IsValid() {
+		resp.NextServerIP = r.NextServer.AsSlice()
+	}
+
+	setOpt4(resp, layers.NewDHCPOption(dhcpOptBootFileName, []byte(r.BootFile)))
+	for _, opt := range r.VendorOptions {
+		setOpt4(resp, opt)
+	}
+}
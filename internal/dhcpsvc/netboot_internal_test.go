@@ -0,0 +1,246 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handle4_netboot(t *testing.T) {
+	const ifaceName = "eth0"
+
+	tftpIP := netip.MustParseAddr("192.168.0.253")
+	httpIP := netip.MustParseAddr("192.168.0.252")
+
+	conf := newTestIPv4Config()
+	conf.Netboot = &NetbootConfig{
+		Rules: []*NetbootRule{{
+			NextServer: httpIP,
+			UserClass:  "iPXE",
+			BootFile:   "http://192.168.0.252/boot.ipxe",
+		}, {
+			NextServer: tftpIP,
+			Archs:      []ClientArch{ClientArchX86BIOS},
+			BootFile:   "undionly.kpxe",
+		}, {
+			NextServer: tftpIP,
+			Archs:      []ClientArch{ClientArchX64UEFI, ClientArchX86UEFI},
+			BootFile:   "ipxe.efi",
+		}, {
+			Archs:    []ClientArch{ClientArchX64UEFIHTTP},
+			BootFile: "http://192.168.0.252/ipxe.efi",
+			VendorOptions: layers.DHCPOptions{
+				layers.NewDHCPOption(layers.DHCPOptClassID, []byte("HTTPClient")),
+			},
+		}},
+		Enabled: true,
+	}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	newArchOpt := func(arch ClientArch) (opt layers.DHCPOption) {
+		return layers.NewDHCPOption(dhcpOptClientArch, []byte{byte(arch >> 8), byte(arch)})
+	}
+
+	testCases := []struct {
+		name           string
+		opts           []layers.DHCPOption
+		wantNextServer netip.Addr
+		wantBootFile   []byte
+		wantClassID    []byte
+	}{{
+		name:           "bios",
+		opts:           []layers.DHCPOption{newArchOpt(ClientArchX86BIOS)},
+		wantNextServer: tftpIP,
+		wantBootFile:   []byte("undionly.kpxe"),
+		wantClassID:    nil,
+	}, {
+		name:           "uefi_x64",
+		opts:           []layers.DHCPOption{newArchOpt(ClientArchX64UEFI)},
+		wantNextServer: tftpIP,
+		wantBootFile:   []byte("ipxe.efi"),
+		wantClassID:    nil,
+	}, {
+		name:           "uefi_x64_http",
+		opts:           []layers.DHCPOption{newArchOpt(ClientArchX64UEFIHTTP)},
+		wantNextServer: netip.Addr{},
+		wantBootFile:   []byte("http://192.168.0.252/ipxe.efi"),
+		wantClassID:    []byte("HTTPClient"),
+	}, {
+		name: "ipxe_bios",
+		opts: []layers.DHCPOption{
+			newArchOpt(ClientArchX86BIOS),
+			layers.NewDHCPOption(dhcpOptUserClass, []byte("iPXE")),
+		},
+		wantNextServer: httpIP,
+		wantBootFile:   []byte("http://192.168.0.252/boot.ipxe"),
+		wantClassID:    nil,
+	}, {
+		name: "ipxe_uefi_rfc3004",
+		opts: []layers.DHCPOption{
+			newArchOpt(ClientArchX64UEFI),
+			layers.NewDHCPOption(dhcpOptUserClass, []byte("\x03foo\x04iPXE")),
+		},
+		wantNextServer: httpIP,
+		wantBootFile:   []byte("http://192.168.0.252/boot.ipxe"),
+		wantClassID:    nil,
+	}, {
+		name:           "unknown_arch",
+		opts:           []layers.DHCPOption{newArchOpt(ClientArchARM64UEFI)},
+		wantNextServer: netip.Addr{},
+		wantBootFile:   nil,
+		wantClassID:    nil,
+	}, {
+		name:           "not_netboot",
+		opts:           nil,
+		wantNextServer: netip.Addr{},
+		wantBootFile:   nil,
+		wantClassID:    nil,
+	}}
+
+	for i, tc := range testCases {
+		mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, byte(i + 1)}
+
+		t.Run(tc.name, func(t *testing.T) {
+			offer, hErr := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover, tc.opts...))
+			require.NoError(t, hErr)
+			require.NotNil(t, offer)
+
+			nextServer, _ := netip.AddrFromSlice(offer.NextServerIP.To4())
+			assert.Equal(t, tc.wantNextServer, nextServer)
+			assert.Equal(t, tc.wantBootFile, optData4(offer, dhcpOptBootFileName))
+			assert.Equal(t, tc.wantClassID, optData4(offer, layers.DHCPOptClassID))
+		})
+	}
+}
+
+func TestNetbootConfig_default(t *testing.T) {
+	conf := &NetbootConfig{
+		Rules: []*NetbootRule{{
+			Archs:    []ClientArch{ClientArchX86BIOS},
+			BootFile: "undionly.kpxe",
+		}},
+		Default: &NetbootRule{
+			BootFile: "default.efi",
+		},
+		Enabled: true,
+	}
+	require.NoError(t, conf.validate())
+
+	req := newTestRequest4(
+		net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		layers.DHCPMsgTypeDiscover,
+		layers.NewDHCPOption(dhcpOptClientArch, []byte{0x00, byte(ClientArchARM64UEFI)}),
+	)
+
+	assert.Same(t, conf.Default, conf.netbootRule(newNetbootClient(req)))
+}
+
+func TestDHCPServer_handle4_netbootPXE(t *testing.T) {
+	const ifaceName = "eth0"
+
+	tftpIP := netip.MustParseAddr("192.168.0.253")
+	machineID := uuid.MustParse("4c4c4544-0042-3510-8052-b4c04f4d4e32")
+
+	conf := newTestIPv4Config()
+	conf.Netboot = &NetbootConfig{
+		Rules: []*NetbootRule{{
+			NextServer: tftpIP,
+			MachineIDs: []uuid.UUID{machineID},
+			BootFile:   "lab.efi",
+		}, {
+			NextServer: tftpIP,
+			Archs:      []ClientArch{ClientArchX86BIOS},
+			BootFile:   "undionly.kpxe",
+		}, {
+			NextServer: tftpIP,
+			Archs:      []ClientArch{ClientArchX64UEFI},
+			BootFile:   "snponly.efi",
+		}},
+		Default: &NetbootRule{
+			NextServer: tftpIP,
+			BootFile:   "default.kpxe",
+		},
+		Enabled: true,
+	}
+
+	srv := newTestServer4(t, conf)
+
+	ndiOpt := layers.NewDHCPOption(dhcpOptClientNDI, []byte{clientNDITypeUNDI, 2, 1})
+	newArchOpt := func(arch ClientArch) (opt layers.DHCPOption) {
+		return layers.NewDHCPOption(dhcpOptClientArch, []byte{byte(arch >> 8), byte(arch)})
+	}
+	newMachineIDOpt := func(id uuid.UUID) (opt layers.DHCPOption) {
+		return layers.NewDHCPOption(
+			dhcpOptClientMachineID,
+			append([]byte{clientMachineIDTypeUUID}, id[:]...),
+		)
+	}
+
+	otherID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	testCases := []struct {
+		name          string
+		opts          []layers.DHCPOption
+		wantBootFile  []byte
+		wantMachineID []byte
+	}{{
+		name:          "uefi_x64",
+		opts:          []layers.DHCPOption{newArchOpt(ClientArchX64UEFI), ndiOpt, newMachineIDOpt(otherID)},
+		wantBootFile:  []byte("snponly.efi"),
+		wantMachineID: newMachineIDOpt(otherID).Data,
+	}, {
+		name:          "bios",
+		opts:          []layers.DHCPOption{newArchOpt(ClientArchX86BIOS), ndiOpt, newMachineIDOpt(otherID)},
+		wantBootFile:  []byte("undionly.kpxe"),
+		wantMachineID: newMachineIDOpt(otherID).Data,
+	}, {
+		name:          "machine_id",
+		opts:          []layers.DHCPOption{newArchOpt(ClientArchX64UEFI), ndiOpt, newMachineIDOpt(machineID)},
+		wantBootFile:  []byte("lab.efi"),
+		wantMachineID: newMachineIDOpt(machineID).Data,
+	}, {
+		name:          "no_arch",
+		opts:          []layers.DHCPOption{ndiOpt},
+		wantBootFile:  []byte("default.kpxe"),
+		wantMachineID: nil,
+	}, {
+		name: "bad_machine_id",
+		opts: []layers.DHCPOption{
+			newArchOpt(ClientArchX86BIOS),
+			layers.NewDHCPOption(dhcpOptClientMachineID, []byte{0x01, 0x02}),
+		},
+		wantBootFile:  []byte("undionly.kpxe"),
+		wantMachineID: nil,
+	}}
+
+	for i, tc := range testCases {
+		mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, byte(i + 1)}
+
+		t.Run(tc.name, func(t *testing.T) {
+			offer, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover, tc.opts...))
+			require.NoError(t, err)
+			require.NotNil(t, offer)
+
+			nextServer, _ := netip.AddrFromSlice(offer.NextServerIP.To4())
+			assert.Equal(t, tftpIP, nextServer)
+			assert.Equal(t, tc.wantBootFile, optData4(offer, dhcpOptBootFileName))
+			assert.Equal(t, tc.wantMachineID, optData4(offer, dhcpOptClientMachineID))
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// defaultOfferTimeout is the default time an offered address stays reserved
+// for the client, see [Config.OfferTimeout].
+const defaultOfferTimeout = 10 * time.Second
+
+// offerReservation is an address offered to a client, which hasn't requested
+// it yet.
+type offerReservation struct {
+	// mac is the hardware address of the client.
+	mac macKey
+
+	// until is the time the reservation expires at.
+	until time.Time
+}
+
+// offers are the addresses offered within DHCPOFFER and reserved for the
+// clients until those request them or the reservations expire.  It's safe for
+// concurrent use.
+type offers struct {
+	// mu protects reserved.
+	mu *sync.Mutex
+
+	// reserved maps the offered addresses to their reservations.
+	reserved map[netip.Addr]offerReservation
+}
+
+// newOffers returns a new properly initialized *offers.
+func newOffers() (o *offers) {
+	return &offers{
+		mu:       &sync.Mutex{},
+		reserved: map[netip.Addr]offerReservation{},
+	}
+}
+
+// reserve reserves ip for the client with mac until the given time, replacing
+// the previous reservation of the client, if any.  The expired reservations
+// are removed.
+func (o *offers) reserve(ip netip.Addr, mac net.HardwareAddr, now, until time.Time) {
+	key := macToKey(mac)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for rip, r := range o.reserved {
+		if r.mac == key || !now.Before(r.until) {
+			delete(o.reserved, rip)
+		}
+	}
+
+	o.reserved[ip] = offerReservation{
+		mac:   key,
+		until: until,
+	}
+}
+
+// isReservedForOther returns true if ip is reserved at now for a client other
+// than the one with mac.
+func (o *offers) isReservedForOther(ip netip.Addr, mac net.HardwareAddr, now time.Time) (ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	r, ok := o.reserved[ip]
+
+	return ok && now.Before(r.until) && r.mac != macToKey(mac)
+}
+
+// reservedFor returns the address reserved at now for the client with mac.
+// ip is invalid if there is no such reservation.
+func (o *offers) reservedFor(mac net.HardwareAddr, now time.Time) (ip netip.Addr) {
+	key := macToKey(mac)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for rip, r := range o.reserved {
+		if r.mac == key && now.Before(r.until) {
+			return rip
+		}
+	}
+
+	return netip.Addr{}
+}
+
+// rangeReserved calls f for each address reserved at now until f returns
+// false.  f must not call the methods of o.
+func (o *offers) rangeReserved(now time.Time, f func(ip netip.Addr) (cont bool)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for ip, r := range o.reserved {
+		if now.Before(r.until) && !f(ip) {
+			return
+		}
+	}
+}
+
+// release removes the reservation of ip, if any.
+func (o *offers) release(ip netip.Addr) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	delete(o.reserved, ip)
+}
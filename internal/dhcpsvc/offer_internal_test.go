@@ -0,0 +1,78 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handle4_offerTimeout(t *testing.T) {
+	const (
+		ifaceName    = "eth0"
+		offerTimeout = 5 * time.Second
+	)
+
+	firstIP := netip.MustParseAddr("192.168.0.2")
+	secondIP := netip.MustParseAddr("192.168.0.3")
+
+	macA := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x0A}
+	macB := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x0B}
+
+	srv := newTestServer4(t, newTestIPv4Config())
+	srv.conf.OfferTimeout = offerTimeout
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	discover := func(t *testing.T, mac net.HardwareAddr, reqIP netip.Addr) (ip netip.Addr) {
+		t.Helper()
+
+		var opts []layers.DHCPOption
+		if reqIP.IsValid() {
+			opts = append(opts, layers.NewDHCPOption(layers.DHCPOptRequestIP, reqIP.AsSlice()))
+		}
+
+		resp, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover, opts...))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		ip, ok := netip.AddrFromSlice(resp.YourClientIP.To4())
+		require.True(t, ok)
+
+		return ip
+	}
+
+	request := func(t *testing.T, mac net.HardwareAddr, ip netip.Addr) (typ layers.DHCPMsgType) {
+		t.Helper()
+
+		resp, err := srv.handle4(ifaceName, newTestRequest4(
+			mac,
+			layers.DHCPMsgTypeRequest,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice()),
+		))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		return msgType4(resp)
+	}
+
+	require.Equal(t, firstIP, discover(t, macA, netip.Addr{}))
+
+	// The address offered to the first client is reserved for it.
+	assert.Equal(t, firstIP, discover(t, macA, netip.Addr{}))
+	assert.Equal(t, secondIP, discover(t, macB, firstIP))
+	assert.Equal(t, layers.DHCPMsgTypeNak, request(t, macB, firstIP))
+
+	// The first client never requests the address, so it's returned to the
+	// pool after the timeout.
+	now = now.Add(offerTimeout)
+
+	assert.Equal(t, firstIP, discover(t, macB, firstIP))
+	assert.Equal(t, layers.DHCPMsgTypeAck, request(t, macB, firstIP))
+	assert.Equal(t, secondIP, discover(t, macA, firstIP))
+}
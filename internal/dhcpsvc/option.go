@@ -0,0 +1,306 @@
+package dhcpsvc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// OptionKind is the kind of the value of a DHCPv4 [Option], which defines how
+// the value is represented within the configuration.
+type OptionKind uint8
+
+// OptionKind values.
+const (
+	// OptionKindBytes means that the value is arbitrary data.
+	OptionKindBytes OptionKind = iota
+
+	// OptionKindIP means that the value is a list of IPv4 addresses.
+	OptionKindIP
+
+	// OptionKindText means that the value is a string.
+	OptionKindText
+)
+
+// String implements the [fmt.Stringer] interface for OptionKind.
+func (k OptionKind) String() (s string) {
+	switch k {
+	case OptionKindBytes:
+		return "bytes"
+	case OptionKindIP:
+		return "ip"
+	case OptionKindText:
+		return "text"
+	default:
+		return fmt.Sprintf("!bad_option_kind_%d", k)
+	}
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for OptionKind.
+func (k OptionKind) MarshalText() (text []byte, err error) {
+	if k > OptionKindText {
+		return nil, fmt.Errorf("bad option kind %d", k)
+	}
+
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *OptionKind.
+func (k *OptionKind) UnmarshalText(text []byte) (err error) {
+	switch s := string(text); s {
+	case "bytes":
+		*k = OptionKindBytes
+	case "ip":
+		*k = OptionKindIP
+	case "text":
+		*k = OptionKindText
+	default:
+		return fmt.Errorf("option kind %q must be one of bytes, ip, or text", s)
+	}
+
+	return nil
+}
+
+// Option is a DHCPv4 option sent to the clients.  Use [OptionIP],
+// [OptionText], and [OptionBytes] to create it.
+type Option struct {
+	// Data is the value of the option as sent on the wire.
+	Data []byte
+
+	// Code is the code of the option.  Pad (0) and End (255) aren't allowed.
+	Code uint8
+
+	// Kind is the kind of Data.
+	Kind OptionKind
+}
+
+// OptionIP returns a new option with the given code containing ips, e.g. the
+// Domain Name Server one.  The addresses other than IPv4 ones are skipped.
+func OptionIP(code uint8, ips ...netip.Addr) (o Option) {
+	data := make([]byte, 0, len(ips)*net.IPv4len)
+	for _, ip := range ips {
+		if ip = ip.Unmap(); ip.Is4() {
+			data = append(data, ip.AsSlice()...)
+		}
+	}
+
+	return Option{
+		Data: data,
+		Code: code,
+		Kind: OptionKindIP,
+	}
+}
+
+// OptionText returns a new option with the given code containing s, e.g. the
+// Domain Name one.  s may contain templates, see [IPv4Config.Options].
+func OptionText(code uint8, s string) (o Option) {
+	return Option{
+		Data: []byte(s),
+		Code: code,
+		Kind: OptionKindText,
+	}
+}
+
+// OptionBytes returns a new option with the given code containing a copy of
+// data.
+func OptionBytes(code uint8, data []byte) (o Option) {
+	return Option{
+		Data: slices.Clone(data),
+		Code: code,
+		Kind: OptionKindBytes,
+	}
+}
+
+// IPs returns the addresses within o.  ips is nil if o isn't of
+// [OptionKindIP].
+func (o Option) IPs() (ips []netip.Addr) {
+	if o.Kind != OptionKindIP {
+		return nil
+	}
+
+	for data := o.Data; len(data) >= net.IPv4len; data = data[net.IPv4len:] {
+		ips = append(ips, netip.AddrFrom4([net.IPv4len]byte(data)))
+	}
+
+	return ips
+}
+
+// String implements the [fmt.Stringer] interface for Option.  s is the string
+// form of o, which is "CODE TYPE VALUE", see [parseOptStr].
+func (o Option) String() (s string) {
+	switch o.Kind {
+	case OptionKindIP:
+		ips := o.IPs()
+		strs := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			strs = append(strs, ip.String())
+		}
+
+		return fmt.Sprintf("%d %s %s", o.Code, optTypeIPs, strings.Join(strs, ","))
+	case OptionKindText:
+		return fmt.Sprintf("%d %s %s", o.Code, optTypeText, o.Data)
+	default:
+		return formatOptStr(uint16(o.Code), o.Data)
+	}
+}
+
+// validate returns an error if o can't be sent.
+func (o Option) validate() (err error) {
+	switch {
+	case o.Code == uint8(layers.DHCPOptPad), o.Code == uint8(layers.DHCPOptEnd):
+		return fmt.Errorf("code %d is reserved", o.Code)
+	case o.Kind > OptionKindText:
+		return fmt.Errorf("bad option kind %d", o.Kind)
+	case o.Kind == OptionKindIP && (len(o.Data) == 0 || len(o.Data)%net.IPv4len != 0):
+		return fmt.Errorf("ip data length %d must be a positive multiple of %d", len(o.Data), net.IPv4len)
+	case len(o.Data) > maxOptLen4:
+		return fmt.Errorf("data length %d must not exceed %d", len(o.Data), maxOptLen4)
+	default:
+		return nil
+	}
+}
+
+// dhcpOption returns o converted to the option of [layers].
+func (o Option) dhcpOption() (opt layers.DHCPOption) {
+	return layers.NewDHCPOption(layers.DHCPOpt(o.Code), o.Data)
+}
+
+// newOptionFromDHCP returns opt converted to [Option] of [OptionKindBytes].
+func newOptionFromDHCP(opt layers.DHCPOption) (o Option) {
+	return OptionBytes(uint8(opt.Type), opt.Data)
+}
+
+// validateOpts4 returns an error if any of opts can't be sent.
+func validateOpts4(opts []Option) (err error) {
+	for i, o := range opts {
+		err = o.validate()
+		if err != nil {
+			return fmt.Errorf("option at index %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// dhcpOptions returns opts converted to the options of [layers].
+func dhcpOptions(opts []Option) (dhcpOpts layers.DHCPOptions) {
+	dhcpOpts = make(layers.DHCPOptions, 0, len(opts))
+	for _, o := range opts {
+		dhcpOpts = append(dhcpOpts, o.dhcpOption())
+	}
+
+	return dhcpOpts
+}
+
+// formatOptions4 returns the string forms of opts, see [Option.String].
+func formatOptions4(opts []Option) (strs []string) {
+	for _, o := range opts {
+		strs = append(strs, o.String())
+	}
+
+	return strs
+}
+
+// parseOptions4 parses the string forms of the DHCPv4 options, see
+// [parseOptStr].  The kinds of the options are derived from the value types.
+func parseOptions4(strs []string) (opts []Option, err error) {
+	for _, s := range strs {
+		var code uint16
+		var data []byte
+		code, data, err = parseOptStr(s, math.MaxUint8-1)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return nil, err
+		}
+
+		_, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+		typ, _, _ := strings.Cut(rest, " ")
+
+		o := Option{Data: data, Code: uint8(code)}
+		switch typ {
+		case optTypeIP, optTypeIPs:
+			o.Kind = OptionKindIP
+		case optTypeText:
+			o.Kind = OptionKindText
+		default:
+			o.Kind = OptionKindBytes
+		}
+
+		opts = append(opts, o)
+	}
+
+	return opts, nil
+}
+
+// optionYAML is the YAML representation of [Option].  Exactly one of the value
+// fields is set.  The code goes first for readability.
+type optionYAML struct {
+	Code uint8        `yaml:"code"`
+	IP   []netip.Addr `yaml:"ip,omitempty"`
+	Text *string      `yaml:"text,omitempty"`
+	Hex  *string      `yaml:"hex,omitempty"`
+}
+
+// type check
+var _ yaml.Marshaler = Option{}
+
+// MarshalYAML implements the [yaml.Marshaler] interface for Option.  The value
+// is encoded according to the kind of o.
+func (o Option) MarshalYAML() (v any, err error) {
+	oy := &optionYAML{Code: o.Code}
+	switch o.Kind {
+	case OptionKindIP:
+		oy.IP = o.IPs()
+	case OptionKindText:
+		text := string(o.Data)
+		oy.Text = &text
+	default:
+		h := hex.EncodeToString(o.Data)
+		oy.Hex = &h
+	}
+
+	return oy, nil
+}
+
+// type check
+var _ yaml.Unmarshaler = (*Option)(nil)
+
+// UnmarshalYAML implements the [yaml.Unmarshaler] interface for *Option.
+func (o *Option) UnmarshalYAML(value *yaml.Node) (err error) {
+	oy := &optionYAML{}
+	err = value.Decode(oy)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	var decoded Option
+	switch {
+	case oy.IP != nil && oy.Text == nil && oy.Hex == nil:
+		decoded = OptionIP(oy.Code, oy.IP...)
+	case oy.Text != nil && oy.IP == nil && oy.Hex == nil:
+		decoded = OptionText(oy.Code, *oy.Text)
+	case oy.Hex != nil && oy.IP == nil && oy.Text == nil:
+		var data []byte
+		data, err = hex.DecodeString(*oy.Hex)
+		if err != nil {
+			return fmt.Errorf("option %d: decoding hex: %w", oy.Code, err)
+		}
+
+		decoded = OptionBytes(oy.Code, data)
+	default:
+		return fmt.Errorf("option %d: exactly one of ip, text, or hex must be set", oy.Code)
+	}
+
+	*o = decoded
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestOption_constructors(t *testing.T) {
+	ip1, ip2 := netip.MustParseAddr("192.168.0.1"), netip.MustParseAddr("192.168.0.2")
+
+	testCases := []struct {
+		want    layers.DHCPOption
+		name    string
+		wantStr string
+		opt     Option
+		wantIPs []netip.Addr
+	}{{
+		want:    layers.NewDHCPOption(layers.DHCPOptDNS, []byte{192, 168, 0, 1, 192, 168, 0, 2}),
+		name:    "ip",
+		wantStr: "6 ips 192.168.0.1,192.168.0.2",
+		opt:     OptionIP(uint8(layers.DHCPOptDNS), ip1, ip2),
+		wantIPs: []netip.Addr{ip1, ip2},
+	}, {
+		want:    layers.NewDHCPOption(layers.DHCPOptDNS, []byte{192, 168, 0, 1}),
+		name:    "ip_mapped",
+		wantStr: "6 ips 192.168.0.1",
+		opt:     OptionIP(uint8(layers.DHCPOptDNS), netip.AddrFrom16(ip1.As16())),
+		wantIPs: []netip.Addr{ip1},
+	}, {
+		want:    layers.NewDHCPOption(layers.DHCPOptDomainName, []byte("lan")),
+		name:    "text",
+		wantStr: "15 text lan",
+		opt:     OptionText(uint8(layers.DHCPOptDomainName), "lan"),
+		wantIPs: nil,
+	}, {
+		want:    layers.NewDHCPOption(layers.DHCPOptIPForwarding, []byte{1}),
+		name:    "bytes",
+		wantStr: "19 hex 01",
+		opt:     OptionBytes(uint8(layers.DHCPOptIPForwarding), []byte{1}),
+		wantIPs: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, tc.opt.validate())
+
+			assert.Equal(t, tc.want, tc.opt.dhcpOption())
+			assert.Equal(t, tc.wantStr, tc.opt.String())
+			assert.Equal(t, tc.wantIPs, tc.opt.IPs())
+
+			parsed, err := parseOptions4([]string{tc.wantStr})
+			require.NoError(t, err)
+
+			assert.Equal(t, []Option{tc.opt}, parsed)
+		})
+	}
+
+	t.Run("from_dhcp", func(t *testing.T) {
+		opt := layers.NewDHCPOption(layers.DHCPOptDNS, []byte{192, 168, 0, 1})
+		o := newOptionFromDHCP(opt)
+
+		assert.Equal(t, OptionKindBytes, o.Kind)
+		assert.Equal(t, opt, o.dhcpOption())
+	})
+}
+
+func TestOption_validate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		wantErrMsg string
+		opt        Option
+	}{{
+		name:       "pad",
+		wantErrMsg: "code 0 is reserved",
+		opt:        OptionBytes(uint8(layers.DHCPOptPad), []byte{1}),
+	}, {
+		name:       "end",
+		wantErrMsg: "code 255 is reserved",
+		opt:        OptionBytes(uint8(layers.DHCPOptEnd), []byte{1}),
+	}, {
+		name:       "no_ips",
+		wantErrMsg: "ip data length 0 must be a positive multiple of 4",
+		opt:        OptionIP(uint8(layers.DHCPOptDNS)),
+	}, {
+		name:       "ipv6",
+		wantErrMsg: "ip data length 0 must be a positive multiple of 4",
+		opt:        OptionIP(uint8(layers.DHCPOptDNS), netip.MustParseAddr("2001:db8::1")),
+	}, {
+		name:       "bad_kind",
+		wantErrMsg: "bad option kind 3",
+		opt:        Option{Code: 1, Kind: OptionKindText + 1},
+	}, {
+		name:       "too_long",
+		wantErrMsg: "data length 256 must not exceed 255",
+		opt:        OptionBytes(uint8(layers.DHCPOptIPForwarding), make([]byte, 256)),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, tc.opt.validate())
+		})
+	}
+}
+
+func TestOption_YAML(t *testing.T) {
+	testCases := []struct {
+		name string
+		want string
+		opt  Option
+	}{{
+		name: "ip",
+		want: "code: 6\nip:\n    - 1.1.1.1\n    - 8.8.8.8\n",
+		opt: OptionIP(
+			uint8(layers.DHCPOptDNS),
+			netip.MustParseAddr("1.1.1.1"),
+			netip.MustParseAddr("8.8.8.8"),
+		),
+	}, {
+		name: "text",
+		want: "code: 15\ntext: lan\n",
+		opt:  OptionText(uint8(layers.DHCPOptDomainName), "lan"),
+	}, {
+		name: "text_empty",
+		want: "code: 15\ntext: \"\"\n",
+		opt:  OptionText(uint8(layers.DHCPOptDomainName), ""),
+	}, {
+		name: "bytes",
+		want: "code: 43\nhex: 0102ff\n",
+		opt:  OptionBytes(uint8(layers.DHCPOptVendorOption), []byte{1, 2, 0xFF}),
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := yaml.Marshal(tc.opt)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, string(data))
+
+			var decoded Option
+			err = yaml.Unmarshal(data, &decoded)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.opt.Code, decoded.Code)
+			assert.Equal(t, tc.opt.Kind, decoded.Kind)
+			assert.Equal(t, string(tc.opt.Data), string(decoded.Data))
+		})
+	}
+
+	t.Run("ambiguous", func(t *testing.T) {
+		var decoded Option
+		err := yaml.Unmarshal([]byte("code: 15\ntext: lan\nhex: 00\n"), &decoded)
+		testutil.AssertErrorMsg(t, "option 15: exactly one of ip, text, or hex must be set", err)
+	})
+}
+
+func TestIPv4Config_options(t *testing.T) {
+	conf := newTestIPv4Config()
+	conf.Options = []Option{OptionText(uint8(layers.DHCPOptDomainName), "lan")}
+	conf.DHCPOptions = layers.DHCPOptions{
+		layers.NewDHCPOption(layers.DHCPOptDNS, []byte{1, 1, 1, 1}),
+	}
+
+	assert.Equal(t, layers.DHCPOptions{
+		layers.NewDHCPOption(layers.DHCPOptDomainName, []byte("lan")),
+		layers.NewDHCPOption(layers.DHCPOptDNS, []byte{1, 1, 1, 1}),
+	}, conf.dhcpOptions())
+
+	assert.Len(t, conf.Options, 1)
+}
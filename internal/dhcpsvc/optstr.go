@@ -0,0 +1,179 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/google/gopacket/layers"
+)
+
+// Types of the values within the string form of the DHCP options, the same as
+// the ones of the legacy configuration.
+const (
+	optTypeHex  = "hex"
+	optTypeIP   = "ip"
+	optTypeIPs  = "ips"
+	optTypeText = "text"
+	optTypeDur  = "dur"
+	optTypeU8   = "u8"
+	optTypeU16  = "u16"
+	optTypeBool = "bool"
+)
+
+// formatOptStr returns the string form of the option with the given code and
+// data, which is "CODE hex DATA".
+func formatOptStr(code uint16, data []byte) (s string) {
+	return fmt.Sprintf("%d %s %s", code, optTypeHex, hex.EncodeToString(data))
+}
+
+// parseOptStr parses the string form of the option, which is "CODE TYPE VALUE".
+// maxCode is the maximum allowed code.
+func parseOptStr(s string, maxCode uint16) (code uint16, data []byte, err error) {
+	defer func() { err = errors.Annotate(err, "option %q: %w", s) }()
+
+	codeStr, rest, _ := strings.Cut(strings.TrimSpace(s), " ")
+	typ, val, _ := strings.Cut(rest, " ")
+
+	c, err := strconv.ParseUint(codeStr, 10, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("parsing code: %w", err)
+	} else if c == 0 || c > uint64(maxCode) {
+		return 0, nil, fmt.Errorf("code %d must be within [1, %d]", c, maxCode)
+	}
+
+	data, err = parseOptVal(typ, val)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return 0, nil, err
+	}
+
+	return uint16(c), data, nil
+}
+
+// parseOptVal parses val of the option value type typ into the option data.
+func parseOptVal(typ, val string) (data []byte, err error) {
+	switch typ {
+	case optTypeHex:
+		return hex.DecodeString(val)
+	case optTypeIP:
+		var ip netip.Addr
+		ip, err = netip.ParseAddr(val)
+		if err != nil {
+			return nil, err
+		}
+
+		return ip.AsSlice(), nil
+	case optTypeIPs:
+		for _, s := range strings.Split(val, ",") {
+			var ip netip.Addr
+			ip, err = netip.ParseAddr(strings.TrimSpace(s))
+			if err != nil {
+				return nil, err
+			}
+
+			data = append(data, ip.AsSlice()...)
+		}
+
+		return data, nil
+	case optTypeText:
+		return []byte(val), nil
+	case optTypeDur:
+		var d time.Duration
+		d, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, err
+		} else if d < 0 || d/time.Second > math.MaxUint32 {
+			return nil, fmt.Errorf("duration %s is out of range", d)
+		}
+
+		return binary.BigEndian.AppendUint32(nil, uint32(d/time.Second)), nil
+	case optTypeU8, optTypeU16:
+		bitSize := 8
+		if typ == optTypeU16 {
+			bitSize = 16
+		}
+
+		var n uint64
+		n, err = strconv.ParseUint(val, 0, bitSize)
+		if err != nil {
+			return nil, err
+		} else if bitSize == 8 {
+			return []byte{byte(n)}, nil
+		}
+
+		return binary.BigEndian.AppendUint16(nil, uint16(n)), nil
+	case optTypeBool:
+		var b bool
+		b, err = strconv.ParseBool(val)
+		if err != nil {
+			return nil, err
+		} else if b {
+			return []byte{1}, nil
+		}
+
+		return []byte{0}, nil
+	default:
+		return nil, fmt.Errorf("unknown value type %q", typ)
+	}
+}
+
+// formatOpts4 returns the string forms of opts, see [formatOptStr].
+func formatOpts4(opts layers.DHCPOptions) (strs []string) {
+	for _, o := range opts {
+		strs = append(strs, formatOptStr(uint16(o.Type), o.Data))
+	}
+
+	return strs
+}
+
+// parseOpts4 parses the string forms of the DHCPv4 options, see
+// [parseOptStr].
+func parseOpts4(strs []string) (opts layers.DHCPOptions, err error) {
+	for _, s := range strs {
+		var code uint16
+		var data []byte
+		code, data, err = parseOptStr(s, math.MaxUint8-1)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return nil, err
+		}
+
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOpt(code), data))
+	}
+
+	return opts, nil
+}
+
+// formatOpts6 returns the string forms of opts, see [formatOptStr].
+func formatOpts6(opts layers.DHCPv6Options) (strs []string) {
+	for _, o := range opts {
+		strs = append(strs, formatOptStr(uint16(o.Code), o.Data))
+	}
+
+	return strs
+}
+
+// parseOpts6 parses the string forms of the DHCPv6 options, see
+// [parseOptStr].
+func parseOpts6(strs []string) (opts layers.DHCPv6Options, err error) {
+	for _, s := range strs {
+		var code uint16
+		var data []byte
+		code, data, err = parseOptStr(s, math.MaxUint16)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return nil, err
+		}
+
+		opts = append(opts, layers.NewDHCPv6Option(layers.DHCPv6Opt(code), data))
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,102 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/google/gopacket/layers"
+)
+
+// Variables of the templates within the string values of the configured
+// DHCPv4 options.
+const (
+	// optVarServerIP is the address the server identifies itself with on the
+	// network interface, see [layers.DHCPOptServerID].
+	optVarServerIP = "ServerIP"
+
+	// optVarGatewayIP is the address of the gateway of the network interface.
+	optVarGatewayIP = "GatewayIP"
+)
+
+// optTmplRe matches a single variable within the template, e.g.
+// "{{ .ServerIP }}".
+var optTmplRe = regexp.MustCompile(`{{\s*\.(\w+)\s*}}`)
+
+// optTmplVars are the values of the template variables for a network interface.
+type optTmplVars struct {
+	// serverIP is the value of [optVarServerIP].
+	serverIP netip.Addr
+
+	// gatewayIP is the value of [optVarGatewayIP].
+	gatewayIP netip.Addr
+}
+
+// isOptTmpl returns true if data is a string value containing a template.  The
+// values without braces are never considered templates, so that they are sent
+// as is.
+func isOptTmpl(data []byte) (ok bool) {
+	return bytes.Contains(data, []byte("{{")) && utf8.Valid(data)
+}
+
+// expandOptTmpl returns data with the template variables replaced with their
+// values from vars.  It returns an error if data contains unknown variables or
+// malformed templates.
+func expandOptTmpl(data []byte, vars *optTmplVars) (expanded []byte, err error) {
+	var errs []error
+	expanded = optTmplRe.ReplaceAllFunc(data, func(m []byte) (val []byte) {
+		switch name := string(optTmplRe.FindSubmatch(m)[1]); name {
+		case optVarServerIP:
+			return []byte(vars.serverIP.String())
+		case optVarGatewayIP:
+			return []byte(vars.gatewayIP.String())
+		default:
+			errs = append(errs, fmt.Errorf("unknown variable %q", name))
+
+			return m
+		}
+	})
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	} else if bytes.Contains(expanded, []byte("{{")) {
+		return nil, errors.Error("malformed template")
+	}
+
+	return expanded, nil
+}
+
+// validateOptTmpls4 returns an error if any of the string values of opts
+// contains an invalid template or is too long to be sent after expanding it.
+func validateOptTmpls4(opts layers.DHCPOptions) (err error) {
+	// Use the longest possible values to check the length.
+	longest := netip.AddrFrom4([4]byte{255, 255, 255, 255})
+	vars := &optTmplVars{
+		serverIP:  longest,
+		gatewayIP: longest,
+	}
+
+	for _, opt := range opts {
+		if !isOptTmpl(opt.Data) {
+			continue
+		}
+
+		var data []byte
+		data, err = expandOptTmpl(opt.Data, vars)
+		if err != nil {
+			return fmt.Errorf("option %d: %w", opt.Type, err)
+		} else if len(data) > maxOptLen4 {
+			return fmt.Errorf(
+				"option %d: expanded length %d must not exceed %d",
+				opt.Type,
+				len(data),
+				maxOptLen4,
+			)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dhcpOptCaptivePortal is the DHCPv4 option containing the URI of the captive
+// portal.  See RFC 8910.
+const dhcpOptCaptivePortal layers.DHCPOpt = 114
+
+func TestDHCPServer_handle4_optTemplates(t *testing.T) {
+	newConf := func(subnet byte) (conf *IPv4Config) {
+		return &IPv4Config{
+			Enabled:       true,
+			GatewayIP:     netip.AddrFrom4([4]byte{192, 168, subnet, 1}),
+			SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+			RangeStart:    netip.AddrFrom4([4]byte{192, 168, subnet, 2}),
+			RangeEnd:      netip.AddrFrom4([4]byte{192, 168, subnet, 254}),
+			LeaseDuration: time.Hour,
+			Options: []Option{
+				OptionText(
+					uint8(dhcpOptCaptivePortal),
+					"http://{{ .ServerIP }}:8080/portal?gw={{.GatewayIP}}",
+				),
+				OptionText(uint8(layers.DHCPOptDomainName), "raw}}"),
+			},
+		}
+	}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newConf(0),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+			"eth1": {
+				IPv4: newConf(1),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		ifaceName string
+		wantURL   string
+	}{{
+		name:      "eth0",
+		ifaceName: "eth0",
+		wantURL:   "http://192.168.0.1:8080/portal?gw=192.168.0.1",
+	}, {
+		name:      "eth1",
+		ifaceName: "eth1",
+		wantURL:   "http://192.168.1.1:8080/portal?gw=192.168.1.1",
+	}}
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, respErr := srv.handle4(tc.ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+			require.NoError(t, respErr)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantURL, string(optData4(resp, dhcpOptCaptivePortal)))
+			assert.Equal(t, "raw}}", string(optData4(resp, layers.DHCPOptDomainName)))
+		})
+	}
+}
+
+func TestValidateOptTmpls4(t *testing.T) {
+	testCases := []struct {
+		name       string
+		data       []byte
+		wantErrMsg string
+	}{{
+		name:       "raw",
+		data:       []byte("http://192.168.0.1/wpad.dat"),
+		wantErrMsg: "",
+	}, {
+		name:       "binary",
+		data:       []byte{'{', '{', 0xFF},
+		wantErrMsg: "",
+	}, {
+		name:       "valid",
+		data:       []byte("http://{{ .ServerIP }}/wpad.dat"),
+		wantErrMsg: "",
+	}, {
+		name:       "unknown_variable",
+		data:       []byte("http://{{ .ServerAddr }}/wpad.dat"),
+		wantErrMsg: `option 252: unknown variable "ServerAddr"`,
+	}, {
+		name:       "malformed",
+		data:       []byte("http://{{ ServerIP }}/wpad.dat"),
+		wantErrMsg: "option 252: malformed template",
+	}, {
+		name:       "too_long",
+		data:       []byte(strings.Repeat("{{.GatewayIP}}", 18)),
+		wantErrMsg: "option 252: expanded length 270 must not exceed 255",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateOptTmpls4(layers.DHCPOptions{
+				layers.NewDHCPOption(layers.DHCPOpt(252), tc.data),
+			})
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, err)
+		})
+	}
+}
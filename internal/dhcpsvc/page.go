@@ -0,0 +1,107 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Sort keys for [DHCPServer.LeasesPaged].
+const (
+	// SortByIP sorts leases by their IP addresses.
+	SortByIP = "ip"
+
+	// SortByMAC sorts leases by their hardware addresses.
+	SortByMAC = "mac"
+
+	// SortByHostname sorts leases by their hostnames case-insensitively.
+	SortByHostname = "hostname"
+
+	// SortByExpiry sorts leases by their expiration times.  Static leases go
+	// first, since they never expire.
+	SortByExpiry = "expiry"
+)
+
+// leaseCmp compares leases a and b, returning a negative number if a goes
+// before b, a positive number if a goes after b, and zero otherwise.
+type leaseCmp func(a, b *Lease) (res int)
+
+// leaseCmps are the comparators of the leases for each supported sort key.
+// Leases with equal keys are compared by their IP addresses.
+var leaseCmps = map[string]leaseCmp{
+	SortByIP: func(a, b *Lease) (res int) {
+		return a.IP.Compare(b.IP)
+	},
+	SortByMAC: func(a, b *Lease) (res int) {
+		if res = bytes.Compare(a.HWAddr, b.HWAddr); res != 0 {
+			return res
+		}
+
+		return a.IP.Compare(b.IP)
+	},
+	SortByHostname: func(a, b *Lease) (res int) {
+		if res = strings.Compare(strings.ToLower(a.Hostname), strings.ToLower(b.Hostname)); res != 0 {
+			return res
+		}
+
+		return a.IP.Compare(b.IP)
+	},
+	SortByExpiry: func(a, b *Lease) (res int) {
+		if res = a.Expiry.Compare(b.Expiry); res != 0 {
+			return res
+		}
+
+		return a.IP.Compare(b.IP)
+	},
+}
+
+// LeasesPaged returns at most limit leases sorted by sortBy, skipping the first
+// offset ones, and the total number of leases.  sortBy must be one of SortByIP,
+// SortByMAC, SortByHostname, and SortByExpiry.  leases is empty if offset is
+// greater than or equal to total.
+func (srv *DHCPServer) LeasesPaged(
+	offset int,
+	limit int,
+	sortBy string,
+) (leases []*Lease, total int, err error) {
+	cmp, ok := leaseCmps[sortBy]
+	switch {
+	case !ok:
+		return nil, 0, fmt.Errorf("sort key %q is not supported", sortBy)
+	case offset < 0:
+		return nil, 0, fmt.Errorf("offset %d must not be negative", offset)
+	case limit <= 0:
+		return nil, 0, fmt.Errorf("limit %d must be positive", limit)
+	}
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	total = srv.leases.len()
+	if offset >= total {
+		return []*Lease{}, total, nil
+	}
+
+	all := make([]*Lease, 0, total)
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		all = append(all, l)
+
+		return true
+	})
+
+	slices.SortFunc(all, cmp)
+
+	all = all[offset:]
+	if len(all) > limit {
+		all = all[:limit]
+	}
+
+	leases = make([]*Lease, 0, len(all))
+	for _, l := range all {
+		leases = append(leases, l.Clone())
+	}
+
+	return leases, total, nil
+}
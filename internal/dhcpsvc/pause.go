@@ -0,0 +1,122 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+)
+
+// EnableInterface resumes serving the address family fam on the network
+// interface with the given name paused by [DHCPServer.DisableInterface].  If
+// srv is started, the connection of the interface is reopened.  The state is
+// reflected in the configuration returned by [DHCPServer.Config], see
+// [IPv4Config.Paused] and [IPv6Config.Paused], so that it survives the restart
+// once the configuration is saved.  It returns [ErrInterfaceNotFound] if srv
+// doesn't serve fam on the interface.
+func (srv *DHCPServer) EnableInterface(
+	ctx context.Context,
+	ifaceName string,
+	fam netutil.AddrFamily,
+) (err error) {
+	defer func() { err = errors.Annotate(err, "enabling %q: %w", ifaceName) }()
+
+	return srv.setPaused(ctx, ifaceName, fam, false)
+}
+
+// DisableInterface pauses serving the address family fam on the network
+// interface with the given name, leaving the other interfaces and families
+// served.  If srv is started, the connection of the interface is closed.  The
+// leases of the interface are kept and remain readable.  The state is reflected
+// in the configuration returned by [DHCPServer.Config], see
+// [IPv4Config.Paused] and [IPv6Config.Paused], so that it survives the restart
+// once the configuration is saved.  It returns [ErrInterfaceNotFound] if srv
+// doesn't serve fam on the interface.
+func (srv *DHCPServer) DisableInterface(
+	ctx context.Context,
+	ifaceName string,
+	fam netutil.AddrFamily,
+) (err error) {
+	defer func() { err = errors.Annotate(err, "disabling %q: %w", ifaceName) }()
+
+	return srv.setPaused(ctx, ifaceName, fam, true)
+}
+
+// setPaused pauses or resumes serving fam on the network interface with the
+// given name.  The configuration srv is created with isn't modified, since it's
+// owned by the caller, see [DHCPServer.pausedConfig].
+func (srv *DHCPServer) setPaused(
+	ctx context.Context,
+	ifaceName string,
+	fam netutil.AddrFamily,
+	paused bool,
+) (err error) {
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+
+	var iface *netInterface
+	var listen func() (err error)
+	switch fam {
+	case netutil.AddrFamilyIPv4:
+		if i := srv.iface4ByName(ifaceName); i != nil {
+			iface = &i.netInterface
+			listen = func() (err error) { return srv.listen4(ctx, i) }
+		}
+	case netutil.AddrFamilyIPv6:
+		if i := srv.iface6ByName(ifaceName); i != nil {
+			iface = &i.netInterface
+			listen = func() (err error) { return srv.listen6(ctx, i) }
+		}
+	default:
+		return fmt.Errorf("bad address family %s", fam)
+	}
+
+	if iface == nil {
+		return fmt.Errorf("%s: %w", fam, ErrInterfaceNotFound)
+	} else if iface.paused == paused {
+		return nil
+	}
+
+	iface.paused = paused
+
+	log.Info("dhcpsvc: interface %q: %s paused: %t", ifaceName, fam, paused)
+
+	if srv.startTime.IsZero() {
+		return nil
+	} else if paused {
+		return iface.closeConn()
+	}
+
+	return listen()
+}
+
+// pausedConfig returns a copy of srv.conf with the paused state of each served
+// address family set as it's currently applied.  The copy is shallow, except
+// for the parts holding the paused state.  srv.connsMu is expected to be
+// locked.
+func (srv *DHCPServer) pausedConfig() (conf *Config) {
+	c := *srv.conf
+	conf = &c
+	conf.Interfaces = make(map[string]*InterfaceConfig, len(srv.conf.Interfaces))
+
+	for name, ic := range srv.conf.Interfaces {
+		ifaceConf := *ic
+		if i := srv.iface4ByName(name); i != nil && ic.IPv4 != nil {
+			v4Conf := *ic.IPv4
+			v4Conf.Paused = i.paused
+			ifaceConf.IPv4 = &v4Conf
+		}
+
+		if i := srv.iface6ByName(name); i != nil && ic.IPv6 != nil {
+			v6Conf := *ic.IPv6
+			v6Conf.Paused = i.paused
+			ifaceConf.IPv6 = &v6Conf
+		}
+
+		conf.Interfaces[name] = &ifaceConf
+	}
+
+	return conf
+}
@@ -0,0 +1,248 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pauseTestTimeout is the time to wait for the fake network to receive or send
+// a message in the pausing test.
+const pauseTestTimeout = 200 * time.Millisecond
+
+// pauseTestIface is the fake network of a single interface in the pausing
+// test.
+type pauseTestIface struct {
+	// reads are the messages received on the interface.
+	reads chan []byte
+
+	// replies are the messages sent on the interface.
+	replies chan []byte
+}
+
+// newPauseTestListener returns a new testListener opening DHCPv4 connections,
+// which read from and write to the fake networks of ifaces, and the ones
+// blocking until closed otherwise.
+func newPauseTestListener(ifaces map[string]*pauseTestIface) (l testListener) {
+	idle := newTestListener(nil, nil)
+
+	return func(ctx context.Context, ifaceName string, laddr netip.AddrPort) (conn net.PacketConn, err error) {
+		iface := ifaces[ifaceName]
+		if !laddr.Addr().Is4() || iface == nil {
+			return idle(ctx, ifaceName, laddr)
+		}
+
+		closed := make(chan struct{})
+		from := &net.UDPAddr{IP: net.IPv4bcast, Port: clientPort4}
+
+		return &fakenet.PacketConn{
+			OnClose: func() (err error) {
+				close(closed)
+
+				return nil
+			},
+			OnLocalAddr: func() (addr net.Addr) { return net.UDPAddrFromAddrPort(laddr) },
+			OnReadFrom: func(b []byte) (n int, addr net.Addr, err error) {
+				var data []byte
+				select {
+				case data = <-iface.reads:
+				case <-closed:
+					return 0, nil, net.ErrClosed
+				}
+
+				// Drop the message received after closing, since select picks
+				// randomly between the ready cases.
+				select {
+				case <-closed:
+					return 0, nil, net.ErrClosed
+				default:
+					return copy(b, data), from, nil
+				}
+			},
+			OnWriteTo: func(b []byte, _ net.Addr) (n int, err error) {
+				iface.replies <- append([]byte(nil), b...)
+
+				return len(b), nil
+			},
+		}, nil
+	}
+}
+
+// newPauseTestIPv4Config returns a new DHCPv4 configuration for the network
+// 192.168.x.0/24.
+func newPauseTestIPv4Config(x byte) (conf *IPv4Config) {
+	return &IPv4Config{
+		GatewayIP:     netip.AddrFrom4([4]byte{192, 168, x, 1}),
+		SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+		RangeStart:    netip.AddrFrom4([4]byte{192, 168, x, 2}),
+		RangeEnd:      netip.AddrFrom4([4]byte{192, 168, x, 254}),
+		LeaseDuration: time.Hour,
+		Enabled:       true,
+	}
+}
+
+// discover sends DHCPDISCOVER from mac to iface and returns the reply, if any.
+func (iface *pauseTestIface) discover(t *testing.T, mac net.HardwareAddr) (resp *layers.DHCPv4) {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, newTestRequest4(
+		mac,
+		layers.DHCPMsgTypeDiscover,
+	))
+	require.NoError(t, err)
+
+	select {
+	case iface.reads <- buf.Bytes():
+	case <-time.After(pauseTestTimeout):
+		return nil
+	}
+
+	select {
+	case data := <-iface.replies:
+		resp = &layers.DHCPv4{}
+		require.NoError(t, resp.DecodeFromBytes(data, gopacket.NilDecodeFeedback))
+
+		return resp
+	case <-time.After(pauseTestTimeout):
+		return nil
+	}
+}
+
+func TestDHCPServer_DisableInterface(t *testing.T) {
+	lan := &pauseTestIface{reads: make(chan []byte), replies: make(chan []byte, 1)}
+	guest := &pauseTestIface{reads: make(chan []byte), replies: make(chan []byte, 1)}
+
+	conf := &Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Listener:        newPauseTestListener(map[string]*pauseTestIface{"eth0": lan, "eth1": guest}),
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newPauseTestIPv4Config(0),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+			"eth1": {
+				IPv4: newPauseTestIPv4Config(1),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	}
+
+	srv, err := New(conf)
+	require.NoError(t, err)
+
+	startTestServer(t, srv)
+
+	ctx := context.Background()
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	guestLease := &Lease{
+		Hostname: "guest",
+		HWAddr:   net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+		IP:       netip.MustParseAddr("192.168.1.100"),
+	}
+	require.NoError(t, srv.AddStaticLease(guestLease))
+
+	require.NoError(t, srv.DisableInterface(ctx, "eth1", netutil.AddrFamilyIPv4))
+
+	t.Run("paused", func(t *testing.T) {
+		resp := lan.discover(t, mac)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, layers.DHCPMsgTypeOffer, msgType4(resp))
+		assert.Nil(t, guest.discover(t, mac))
+
+		st := srv.Status()
+		require.Len(t, st.Interfaces, 2)
+
+		assert.False(t, st.Interfaces[0].IPv4.Paused)
+		assert.False(t, st.Interfaces[0].IPv4.BoundAt.IsZero())
+		assert.True(t, st.Interfaces[1].IPv4.Paused)
+		assert.True(t, st.Interfaces[1].IPv4.BoundAt.IsZero())
+		assert.Equal(t, 1, st.Interfaces[1].IPv4.StaticLeases)
+
+		assert.Equal(t, guestLease.Hostname, srv.HostByIP(guestLease.IP))
+		assert.True(t, srv.Config().Interfaces["eth1"].IPv4.Paused)
+		assert.False(t, conf.Interfaces["eth1"].IPv4.Paused)
+		assert.True(t, srv.Health().Healthy)
+	})
+
+	require.NoError(t, srv.EnableInterface(ctx, "eth1", netutil.AddrFamilyIPv4))
+
+	t.Run("resumed", func(t *testing.T) {
+		resp := guest.discover(t, mac)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, layers.DHCPMsgTypeOffer, msgType4(resp))
+
+		st := srv.Status()
+		require.Len(t, st.Interfaces, 2)
+
+		assert.False(t, st.Interfaces[1].IPv4.Paused)
+		assert.False(t, st.Interfaces[1].IPv4.BoundAt.IsZero())
+		assert.False(t, srv.Config().Interfaces["eth1"].IPv4.Paused)
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		err = srv.DisableInterface(ctx, "eth2", netutil.AddrFamilyIPv4)
+		assert.ErrorIs(t, err, ErrInterfaceNotFound)
+		testutil.AssertErrorMsg(t, `disabling "eth2": ipv4: interface not found`, err)
+
+		err = srv.EnableInterface(ctx, "eth0", netutil.AddrFamilyIPv6)
+		assert.ErrorIs(t, err, ErrInterfaceNotFound)
+		testutil.AssertErrorMsg(t, `enabling "eth0": ipv6: interface not found`, err)
+	})
+}
+
+func TestDHCPServer_Start_paused(t *testing.T) {
+	lan := &pauseTestIface{reads: make(chan []byte), replies: make(chan []byte, 1)}
+
+	conf := newPauseTestIPv4Config(0)
+	conf.Paused = true
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Listener:        newPauseTestListener(map[string]*pauseTestIface{"eth0": lan}),
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	startTestServer(t, srv)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	assert.Nil(t, lan.discover(t, mac))
+
+	st := srv.Status()
+	require.Len(t, st.Interfaces, 1)
+
+	assert.True(t, st.Interfaces[0].IPv4.Paused)
+	assert.NoError(t, srv.HealthCheck(context.Background()))
+
+	err = srv.DisableInterface(context.Background(), "eth0", netutil.AddrFamilyIPv4)
+	require.NoError(t, err)
+
+	err = srv.EnableInterface(context.Background(), "eth0", netutil.AddrFamilyIPv4)
+	require.NoError(t, err)
+
+	resp := lan.discover(t, mac)
+	require.NotNil(t, resp)
+
+	assert.Equal(t, layers.DHCPMsgTypeOffer, msgType4(resp))
+	assert.NoError(t, srv.HealthCheck(context.Background()))
+}
@@ -0,0 +1,247 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// UnknownClientsPolicy defines how the server treats the DHCPv4 clients
+// unknown to it, i.e. the ones having no static lease.
+type UnknownClientsPolicy uint8
+
+// UnknownClientsPolicy values.
+const (
+	// UnknownClientsPolicyAllow means that the unknown clients are served as
+	// usual.
+	UnknownClientsPolicyAllow UnknownClientsPolicy = iota
+
+	// UnknownClientsPolicyDeny means that the messages of the unknown clients
+	// are dropped, and the clients are put into the pending queue, see
+	// [DHCPServer.PendingClients].  The clients are approved by adding static
+	// leases for them.
+	UnknownClientsPolicyDeny
+)
+
+// String implements the [fmt.Stringer] interface for UnknownClientsPolicy.
+func (p UnknownClientsPolicy) String() (s string) {
+	switch p {
+	case UnknownClientsPolicyAllow:
+		return "allow"
+	case UnknownClientsPolicyDeny:
+		return "deny"
+	default:
+		return fmt.Sprintf("!bad_unknown_clients_policy_%d", p)
+	}
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for
+// UnknownClientsPolicy.
+func (p UnknownClientsPolicy) MarshalText() (text []byte, err error) {
+	if p > UnknownClientsPolicyDeny {
+		return nil, fmt.Errorf("bad unknown clients policy %d", p)
+	}
+
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *UnknownClientsPolicy.
+func (p *UnknownClientsPolicy) UnmarshalText(text []byte) (err error) {
+	switch s := string(text); s {
+	case "allow":
+		*p = UnknownClientsPolicyAllow
+	case "deny":
+		*p = UnknownClientsPolicyDeny
+	default:
+		return fmt.Errorf("unknown clients policy %q must be either allow or deny", s)
+	}
+
+	return nil
+}
+
+// Limits of the pending queue.  Those keep the queue small under a flood of
+// messages with random hardware addresses.
+const (
+	// maxPendingClients is the maximum number of clients in the pending
+	// queue.  The least recently seen clients are evicted first.
+	maxPendingClients = 256
+
+	// pendingClientTTL is the time a client stays in the pending queue since
+	// its last message.
+	pendingClientTTL = 1 * time.Hour
+)
+
+// PendingClient is a client denied according to [UnknownClientsPolicyDeny],
+// which awaits approval.
+type PendingClient struct {
+	// FirstSeen is the time of the first message of the client since it was
+	// put into the pending queue.
+	FirstSeen time.Time
+
+	// LastSeen is the time of the most recent message of the client.
+	LastSeen time.Time
+
+	// HWAddr is the hardware address of the client.
+	HWAddr net.HardwareAddr
+
+	// InterfaceName is the name of the network interface the most recent
+	// message of the client has been received on.
+	InterfaceName string
+
+	// Hostname is the hostname requested by the client, if any.
+	Hostname string
+}
+
+// clone returns a deep copy of c.
+func (c *PendingClient) clone() (clone *PendingClient) {
+	clone = &PendingClient{}
+	*clone = *c
+	clone.HWAddr = slices.Clone(c.HWAddr)
+
+	return clone
+}
+
+// pendingClients is the bounded queue of the clients awaiting approval, which
+// entries expire after [pendingClientTTL].
+type pendingClients struct {
+	// mu protects clients.
+	mu *sync.Mutex
+
+	// clients are the pending clients by their hardware addresses.
+	clients map[macKey]*PendingClient
+}
+
+// newPendingClients returns a new empty pending queue.
+func newPendingClients() (p *pendingClients) {
+	return &pendingClients{
+		mu:      &sync.Mutex{},
+		clients: map[macKey]*PendingClient{},
+	}
+}
+
+// add puts the client with mac, which has sent a message on the network
+// interface named ifaceName at now, into p or updates it.  If p is full, the
+// least recently seen client is evicted.
+func (p *pendingClients) add(mac net.HardwareAddr, ifaceName, hostname string, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.evictExpired(now)
+
+	key := macToKey(mac)
+	c, ok := p.clients[key]
+	if !ok {
+		if len(p.clients) >= maxPendingClients {
+			p.evictOldest()
+		}
+
+		c = &PendingClient{
+			FirstSeen: now,
+			HWAddr:    slices.Clone(mac),
+		}
+		p.clients[key] = c
+	}
+
+	c.LastSeen = now
+	c.InterfaceName = ifaceName
+	c.Hostname = hostname
+}
+
+// remove removes the client with mac from p, if any.
+func (p *pendingClients) remove(mac net.HardwareAddr) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.clients, macToKey(mac))
+}
+
+// list returns the copies of the clients pending at now sorted by the time they
+// were first seen.
+func (p *pendingClients) list(now time.Time) (clients []*PendingClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.evictExpired(now)
+
+	clients = make([]*PendingClient, 0, len(p.clients))
+	for _, c := range p.clients {
+		clients = append(clients, c.clone())
+	}
+
+	slices.SortFunc(clients, func(a, b *PendingClient) (res int) {
+		return a.FirstSeen.Compare(b.FirstSeen)
+	})
+
+	return clients
+}
+
+// evictExpired removes the clients not seen for [pendingClientTTL] at now.
+// p.mu is expected to be locked.
+func (p *pendingClients) evictExpired(now time.Time) {
+	for key, c := range p.clients {
+		if !now.Before(c.LastSeen.Add(pendingClientTTL)) {
+			delete(p.clients, key)
+		}
+	}
+}
+
+// evictOldest removes the least recently seen client.  p.mu is expected to be
+// locked.
+func (p *pendingClients) evictOldest() {
+	var oldestKey macKey
+	var oldest *PendingClient
+	for key, c := range p.clients {
+		if oldest == nil || c.LastSeen.Before(oldest.LastSeen) {
+			oldestKey, oldest = key, c
+		}
+	}
+
+	delete(p.clients, oldestKey)
+}
+
+// PendingClients returns the clients denied according to
+// [UnknownClientsPolicyDeny], which await approval.  The clients expire from
+// the queue after an hour without messages.  It's safe for concurrent use.
+func (srv *DHCPServer) PendingClients() (clients []*PendingClient) {
+	return srv.pending.list(srv.now())
+}
+
+// admitClient4 returns true if the client of the given class sent req on iface
+// should be served.  Otherwise, the client is either ignored, see
+// [Config.IgnoredClasses], or put into the pending queue.
+func (srv *DHCPServer) admitClient4(iface *iface4, req *layers.DHCPv4, class string) (ok bool) {
+	if class != "" && slices.Contains(srv.conf.IgnoredClasses, class) {
+		log.Debug("dhcpsvc: interface %q: client %s is of ignored class %q", iface.name, req.ClientHWAddr, class)
+
+		return false
+	}
+
+	if srv.conf.UnknownClients != UnknownClientsPolicyDeny || srv.knownClient4(iface, req.ClientHWAddr) {
+		return true
+	}
+
+	log.Debug("dhcpsvc: interface %q: client %s is unknown, dropping message", iface.name, req.ClientHWAddr)
+
+	srv.pending.add(req.ClientHWAddr, iface.name, requestedHostname4(req), srv.now())
+
+	return false
+}
+
+// knownClient4 returns true if the client with mac has a static lease on iface
+// or is resolved by [Config.StaticResolver].
+func (srv *DHCPServer) knownClient4(iface *iface4, mac net.HardwareAddr) (ok bool) {
+	srv.leasesMu.RLock()
+	l, ok := iface.leases[leaseKey{mac: macToKey(mac)}]
+	ok = ok && l.IsStatic
+	srv.leasesMu.RUnlock()
+
+	// Resolve the address with the leases unlocked, since the resolver may be
+	// slow.
+	return ok || srv.resolveStatic4(mac).IsValid()
+}
@@ -0,0 +1,93 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_PendingClients(t *testing.T) {
+	const ifaceName = "eth0"
+
+	srv := newTestServer4(t, newTestIPv4Config())
+	srv.conf.UnknownClients = UnknownClientsPolicyDeny
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	hostnameOpt := layers.NewDHCPOption(layers.DHCPOptHostname, []byte("laptop"))
+
+	discover := func(t *testing.T) (resp *layers.DHCPv4) {
+		t.Helper()
+
+		resp, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover, hostnameOpt))
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("denied", func(t *testing.T) {
+		assert.Nil(t, discover(t))
+
+		now = now.Add(time.Minute)
+		assert.Nil(t, discover(t))
+
+		assert.Equal(t, []*PendingClient{{
+			FirstSeen:     now.Add(-time.Minute),
+			LastSeen:      now,
+			HWAddr:        mac,
+			InterfaceName: ifaceName,
+			Hostname:      "laptop",
+		}}, srv.PendingClients())
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now = now.Add(pendingClientTTL - time.Second)
+		require.Len(t, srv.PendingClients(), 1)
+
+		now = now.Add(time.Second)
+		assert.Empty(t, srv.PendingClients())
+	})
+
+	t.Run("approved", func(t *testing.T) {
+		assert.Nil(t, discover(t))
+		require.Len(t, srv.PendingClients(), 1)
+
+		ip := netip.MustParseAddr("192.168.0.100")
+		require.NoError(t, srv.AddStaticLease(&Lease{IP: ip, Hostname: "laptop", HWAddr: mac}))
+		assert.Empty(t, srv.PendingClients())
+
+		resp := discover(t)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, net.IP(ip.AsSlice()), resp.YourClientIP)
+		assert.Empty(t, srv.PendingClients())
+	})
+}
+
+func TestPendingClients_flood(t *testing.T) {
+	p := newPendingClients()
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+
+	newMAC := func(i int) (mac net.HardwareAddr) {
+		return net.HardwareAddr{0x02, 0x00, 0x00, byte(i >> 16), byte(i >> 8), byte(i)}
+	}
+
+	const flood = 10 * maxPendingClients
+	for i := 0; i < flood; i++ {
+		p.add(newMAC(i), "eth0", "", now.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	clients := p.list(now.Add(flood * time.Millisecond))
+	require.Len(t, clients, maxPendingClients)
+
+	// The least recently seen clients are evicted first.
+	assert.Equal(t, newMAC(flood-maxPendingClients), clients[0].HWAddr)
+	assert.Equal(t, newMAC(flood-1), clients[maxPendingClients-1].HWAddr)
+}
@@ -0,0 +1,66 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// allOf returns the predicate, which is true for the addresses, for which all
+// of ps are true.  ps are called in order, so the cheaper ones should go
+// first.
+func allOf(ps ...ipPredicate) (p ipPredicate) {
+	return func(ip netip.Addr) (ok bool) {
+		for _, pred := range ps {
+			if !pred(ip) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+// exceptAddrs returns the predicate, which is false for ips only.
+func exceptAddrs(ips ...netip.Addr) (p ipPredicate) {
+	return func(ip netip.Addr) (ok bool) {
+		return !slices.Contains(ips, ip)
+	}
+}
+
+// notQuarantined returns the predicate, which is false for the addresses
+// quarantined on iface at now.  [DHCPServer.leasesMu] is expected to be locked
+// while it's called.
+func notQuarantined(iface *netInterface, now time.Time) (p ipPredicate) {
+	return func(ip netip.Addr) (ok bool) {
+		return !iface.isQuarantined(ip, now)
+	}
+}
+
+// notLeased returns the predicate, which is false for the addresses of the
+// static and dynamic leases within idx, except the dynamic DHCPv4 leases
+// expired at now, see [Lease.isExpired4].  [DHCPServer.leasesMu] is expected to
+// be locked while it's called.
+func notLeased(idx *leaseIndex, now time.Time) (p ipPredicate) {
+	return func(ip netip.Addr) (ok bool) {
+		l, ok := idx.leaseByAddr(ip)
+
+		return !ok || l.isExpired4(now)
+	}
+}
+
+// freePredicate4 returns the predicate, which is true for the addresses that
+// may be leased on iface at now, i.e. those that aren't the network, broadcast,
+// or gateway address, aren't leased, and aren't quarantined.  The addresses of
+// the expired dynamic leases are free, see [DHCPServer.reclaimExpired4].  The
+// predicate only refers to the state of srv, so it's cheap to build for every
+// allocation and it sees the changes made to the leases after it's built.
+// [DHCPServer.leasesMu] is expected to be locked while it's called.
+func (srv *DHCPServer) freePredicate4(iface *iface4, now time.Time) (p ipPredicate) {
+	return allOf(
+		exceptAddrs(iface.subnet.Masked().Addr(), broadcast4(iface.subnet), iface.gateway),
+		notQuarantined(&iface.netInterface, now),
+		notLeased(srv.leases, now),
+	)
+}
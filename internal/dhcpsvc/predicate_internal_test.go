@@ -0,0 +1,92 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_freePredicate4(t *testing.T) {
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	srv.now = func() (t time.Time) { return now }
+
+	staticIP := netip.MustParseAddr("192.168.0.100")
+	require.NoError(t, srv.AddStaticLease(&Lease{
+		IP:     staticIP,
+		HWAddr: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x01, 0x01},
+	}))
+
+	dynamicIP := netip.MustParseAddr("192.168.0.2")
+	requestLease4(t, srv, net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}, dynamicIP, "")
+
+	quarantinedIP := netip.MustParseAddr("192.168.0.50")
+	require.NoError(t, srv.Quarantine(quarantinedIP, 0))
+
+	iface := srv.iface4ByName("eth0")
+	require.NotNil(t, iface)
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	isFree := srv.freePredicate4(iface, now)
+
+	testCases := []struct {
+		ip   netip.Addr
+		name string
+		want bool
+	}{{
+		ip:   netip.MustParseAddr("192.168.0.0"),
+		name: "network",
+		want: false,
+	}, {
+		ip:   netip.MustParseAddr("192.168.0.255"),
+		name: "broadcast",
+		want: false,
+	}, {
+		ip:   iface.gateway,
+		name: "gateway",
+		want: false,
+	}, {
+		ip:   staticIP,
+		name: "static",
+		want: false,
+	}, {
+		ip:   dynamicIP,
+		name: "dynamic",
+		want: false,
+	}, {
+		ip:   quarantinedIP,
+		name: "quarantined",
+		want: false,
+	}, {
+		ip:   netip.MustParseAddr("192.168.0.77"),
+		name: "free",
+		want: true,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isFree(tc.ip))
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		isFreeLater := srv.freePredicate4(iface, now.Add(time.Hour))
+
+		assert.True(t, isFreeLater(dynamicIP))
+		assert.False(t, isFreeLater(staticIP))
+	})
+
+	t.Run("find", func(t *testing.T) {
+		r, err := newIPRange(dynamicIP, netip.MustParseAddr("192.168.0.3"))
+		require.NoError(t, err)
+
+		assert.Equal(t, netip.MustParseAddr("192.168.0.3"), r.find(isFree))
+	})
+}
@@ -0,0 +1,177 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/stringutil"
+	"github.com/google/gopacket/layers"
+)
+
+// OptionProfile is a named set of DHCPv4 options sent to a group of clients,
+// e.g. "iot" or "guests", in addition to the options of the network interface.
+// A client belongs to the profile if it's classified so by [Config.Classifier],
+// or if it matches any of VendorClass and MACs, which are set.
+type OptionProfile struct {
+	// Name is the name of the profile.  It's matched against the result of
+	// [Config.Classifier].  It must be unique.
+	Name string
+
+	// VendorClass is the Vendor Class Identifier option sent by the clients of
+	// the profile.  It's ignored if empty.
+	VendorClass string
+
+	// MACs are the hardware addresses of the clients of the profile.
+	MACs []net.HardwareAddr
+
+	// Options are sent to the clients of the profile, replacing the options of
+	// the network interface of the same types.  The templates within their
+	// string values are expanded, see [IPv4Config.Options].
+	Options []Option
+}
+
+// validate returns an error if p can't be used.
+func (p *OptionProfile) validate() (err error) {
+	if p == nil {
+		return errNilConfig
+	} else if p.Name == "" {
+		return errors.Error("no name")
+	}
+
+	for i, mac := range p.MACs {
+		if len(mac) == 0 || len(mac) > 20 {
+			return fmt.Errorf("mac at index %d: length %d must be from 1 to 20", i, len(mac))
+		}
+	}
+
+	err = validateOpts4(p.Options)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	return validateOptTmpls4(dhcpOptions(p.Options))
+}
+
+// validateProfiles4 returns an error if any of profiles can't be used or their
+// names aren't unique.
+func validateProfiles4(profiles []*OptionProfile) (err error) {
+	names := stringutil.NewSet()
+	for i, p := range profiles {
+		err = p.validate()
+		if err != nil {
+			return fmt.Errorf("option profile at index %d: %w", i, err)
+		} else if names.Has(p.Name) {
+			return fmt.Errorf("option profile at index %d: duplicate name %q", i, p.Name)
+		}
+
+		names.Add(p.Name)
+	}
+
+	return nil
+}
+
+// matches returns true if the client with mac sent vendorClass matches p.
+func (p *OptionProfile) matches(vendorClass []byte, mac net.HardwareAddr) (ok bool) {
+	if p.VendorClass != "" && string(vendorClass) == p.VendorClass {
+		return true
+	}
+
+	for _, m := range p.MACs {
+		if bytes.Equal(m, mac) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifaceProfile4 is an [OptionProfile] prepared for a network interface.
+type ifaceProfile4 struct {
+	// OptionProfile is embedded here to match the clients.
+	*OptionProfile
+
+	// opts are the options of the profile with the templates expanded for the
+	// network interface.  The data of these must not be modified.
+	opts layers.DHCPOptions
+}
+
+// newIfaceProfiles4 returns profiles prepared for the network interface with
+// the given gateway, which the server also identifies itself with.
+func newIfaceProfiles4(
+	profiles []*OptionProfile,
+	gateway netip.Addr,
+) (ps []*ifaceProfile4, err error) {
+	vars := &optTmplVars{
+		serverIP:  gateway,
+		gatewayIP: gateway,
+	}
+
+	for _, p := range profiles {
+		opts := dropSubnetMask4(dhcpOptions(p.Options), fmt.Sprintf("option profile %q", p.Name))
+		for i, opt := range opts {
+			if !isOptTmpl(opt.Data) {
+				continue
+			}
+
+			var data []byte
+			data, err = expandOptTmpl(opt.Data, vars)
+			if err != nil {
+				return nil, fmt.Errorf("option profile %q: option %d: %w", p.Name, opt.Type, err)
+			}
+
+			opts[i] = layers.NewDHCPOption(opt.Type, data)
+		}
+
+		ps = append(ps, &ifaceProfile4{
+			OptionProfile: p,
+			opts:          opts,
+		})
+	}
+
+	return ps, nil
+}
+
+// profile4 returns the option profile of the client of the given class sent
+// req on iface, see [DHCPServer.classify4].  The profile named by the class
+// takes precedence over the ones matched by the properties of req.  The first
+// matching profile applies.  p is nil if the client belongs to no profile.
+func profile4(iface *iface4, req *layers.DHCPv4, class string) (p *ifaceProfile4) {
+	if len(iface.profiles) == 0 {
+		return nil
+	}
+
+	if class != "" {
+		for _, p = range iface.profiles {
+			if p.Name == class {
+				return p
+			}
+		}
+	}
+
+	vendorClass := optData4(req, layers.DHCPOptClassID)
+	for _, p = range iface.profiles {
+		if p.matches(vendorClass, req.ClientHWAddr) {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// setProfile4 sets the options of the profile of the client of the given class
+// sent req on iface within resp, replacing the ones of the same types.  resp is
+// left as is if the client belongs to no profile.
+func setProfile4(iface *iface4, resp, req *layers.DHCPv4, class string) {
+	p := profile4(iface, req, class)
+	if p == nil {
+		return
+	}
+
+	for _, opt := range p.opts {
+		setOpt4(resp, opt)
+	}
+}
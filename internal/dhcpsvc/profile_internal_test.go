@@ -0,0 +1,160 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handle4_optionProfiles(t *testing.T) {
+	const ifaceName = "eth0"
+
+	iotDNS := []byte{10, 0, 0, 53}
+	iotNTP := []byte{192, 168, 0, 1}
+	workDNS := []byte{1, 1, 1, 1}
+
+	iotMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	classifiedMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+	workMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x03}
+	otherMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x04}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Classifier: func(_ string, req *ClientHint) (class string) {
+			if req.MAC.String() == classifiedMAC.String() {
+				return "IoT"
+			}
+
+			return ""
+		},
+		OptionProfiles: []*OptionProfile{{
+			Name: "IoT",
+			MACs: []net.HardwareAddr{iotMAC},
+			Options: []Option{
+				OptionBytes(uint8(layers.DHCPOptDNS), iotDNS),
+				OptionText(uint8(layers.DHCPOptNTPServers), "{{ .GatewayIP }}"),
+			},
+		}, {
+			Name:        "workstations",
+			VendorClass: "MSFT 5.0",
+			Options: []Option{
+				OptionBytes(uint8(layers.DHCPOptDNS), workDNS),
+			},
+		}},
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// The NTP servers template is expanded into the textual form of the
+	// address.
+	iotNTP = []byte(netip.AddrFrom4([4]byte(iotNTP)).String())
+	vendorClassOpt := layers.NewDHCPOption(layers.DHCPOptClassID, []byte("MSFT 5.0"))
+
+	testCases := []struct {
+		name    string
+		mac     net.HardwareAddr
+		opts    []layers.DHCPOption
+		wantDNS []byte
+		wantNTP []byte
+	}{{
+		name:    "mac_list",
+		mac:     iotMAC,
+		opts:    nil,
+		wantDNS: iotDNS,
+		wantNTP: iotNTP,
+	}, {
+		name:    "classifier",
+		mac:     classifiedMAC,
+		opts:    []layers.DHCPOption{vendorClassOpt},
+		wantDNS: iotDNS,
+		wantNTP: iotNTP,
+	}, {
+		name:    "vendor_class",
+		mac:     workMAC,
+		opts:    []layers.DHCPOption{vendorClassOpt},
+		wantDNS: workDNS,
+		wantNTP: nil,
+	}, {
+		name:    "default",
+		mac:     otherMAC,
+		opts:    nil,
+		wantDNS: []byte{192, 168, 0, 1},
+		wantNTP: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			offer, respErr := srv.handle4(ifaceName, newTestRequest4(tc.mac, layers.DHCPMsgTypeDiscover, tc.opts...))
+			require.NoError(t, respErr)
+			require.NotNil(t, offer)
+
+			assert.Equal(t, tc.wantDNS, optData4(offer, layers.DHCPOptDNS))
+			assert.Equal(t, tc.wantNTP, optData4(offer, layers.DHCPOptNTPServers))
+
+			reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, offer.YourClientIP.To4())
+			opts := append([]layers.DHCPOption{reqIPOpt}, tc.opts...)
+
+			ack, respErr := srv.handle4(ifaceName, newTestRequest4(tc.mac, layers.DHCPMsgTypeRequest, opts...))
+			require.NoError(t, respErr)
+			require.NotNil(t, ack)
+
+			require.Equal(t, layers.DHCPMsgTypeAck, msgType4(ack))
+			assert.Equal(t, tc.wantDNS, optData4(ack, layers.DHCPOptDNS))
+			assert.Equal(t, tc.wantNTP, optData4(ack, layers.DHCPOptNTPServers))
+		})
+	}
+}
+
+func TestConfig_Validate_optionProfiles(t *testing.T) {
+	testCases := []struct {
+		name       string
+		profiles   []*OptionProfile
+		wantErrMsg string
+	}{{
+		name:       "valid",
+		profiles:   []*OptionProfile{{Name: "iot"}, {Name: "guests"}},
+		wantErrMsg: "",
+	}, {
+		name:       "nil",
+		profiles:   []*OptionProfile{nil},
+		wantErrMsg: "option profile at index 0: config is nil",
+	}, {
+		name:       "no_name",
+		profiles:   []*OptionProfile{{VendorClass: "iot"}},
+		wantErrMsg: "option profile at index 0: no name",
+	}, {
+		name:       "duplicate",
+		profiles:   []*OptionProfile{{Name: "iot"}, {Name: "iot"}},
+		wantErrMsg: `option profile at index 1: duplicate name "iot"`,
+	}, {
+		name:       "empty_mac",
+		profiles:   []*OptionProfile{{Name: "iot", MACs: []net.HardwareAddr{{}}}},
+		wantErrMsg: "option profile at index 0: mac at index 0: length 0 must be from 1 to 20",
+	}, {
+		name: "bad_template",
+		profiles: []*OptionProfile{{
+			Name: "iot",
+			Options: []Option{
+				OptionText(uint8(layers.DHCPOptNTPServers), "{{ .Unknown }}"),
+			},
+		}},
+		wantErrMsg: `option profile at index 0: option 42: unknown variable "Unknown"`,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertErrorMsg(t, tc.wantErrMsg, validateProfiles4(tc.profiles))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// Quarantine marks ip as unavailable for allocation for d, e.g. when it's known
+// to be used by a device not configured via DHCP.  Zero d means indefinitely.
+// ip must be within the address range of a served network interface.  The
+// existing leases for ip aren't affected.  Quarantining ip again replaces the
+// previous duration.  The quarantine is stored in the database, so that it
+// survives the restart, unless it elapses while the server is down.
+func (srv *DHCPServer) Quarantine(ip netip.Addr, d time.Duration) (err error) {
+	defer func() { err = errors.Annotate(err, "quarantining %s: %w", ip) }()
+
+	if d < 0 {
+		return newMustErr("duration", "be non-negative", d)
+	}
+
+	// Don't wrap the error since there is already an annotation deferred.
+	return srv.withLeasesStored(func() (st *dbState, err error) {
+		return srv.quarantine(ip, d)
+	})
+}
+
+// quarantine marks ip as unavailable for allocation for d and returns the state
+// to store, see [DHCPServer.Quarantine].  srv.leasesMu is expected to be locked
+// for writing.
+func (srv *DHCPServer) quarantine(ip netip.Addr, d time.Duration) (st *dbState, err error) {
+	iface := srv.ifaceForRange(ip)
+	if iface == nil {
+		return nil, errors.Error("address is not within any range")
+	}
+
+	now := srv.now()
+	for qip := range iface.quarantined {
+		if !iface.isQuarantined(qip, now) {
+			delete(iface.quarantined, qip)
+		}
+	}
+
+	until := time.Time{}
+	if d > 0 {
+		until = now.Add(d)
+	}
+
+	iface.quarantined[ip] = until
+
+	return srv.dbSnapshot(), nil
+}
+
+// ifaceForRange returns the network interface, which address space contains
+// ip, or nil if there is no such interface.  [DHCPServer.leasesMu] is expected
+// to be locked.
+func (srv *DHCPServer) ifaceForRange(ip netip.Addr) (iface *netInterface) {
+	for _, i4 := range srv.interfaces4 {
+		if i4.addrSpace.contains(ip) {
+			return &i4.netInterface
+		}
+	}
+
+	for _, i6 := range srv.interfaces6 {
+		if i6.addrSpace.contains(ip) {
+			return &i6.netInterface
+		}
+	}
+
+	return nil
+}
+
+// isQuarantined returns true if ip is quarantined on iface at now.
+// [DHCPServer.leasesMu] is expected to be locked.
+func (iface *netInterface) isQuarantined(ip netip.Addr, now time.Time) (ok bool) {
+	until, ok := iface.quarantined[ip]
+
+	return ok && (until.IsZero() || now.Before(until))
+}
@@ -0,0 +1,158 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_Quarantine(t *testing.T) {
+	const ifaceName = "eth0"
+
+	quarantinedIP := netip.MustParseAddr("192.168.0.2")
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	newSrv := func(t *testing.T) (srv *DHCPServer, now *time.Time) {
+		t.Helper()
+
+		srv = newTestServer4(t, newTestIPv4Config())
+
+		now = &time.Time{}
+		*now = time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+		srv.now = func() (t time.Time) { return *now }
+
+		return srv, now
+	}
+
+	offer := func(t *testing.T, srv *DHCPServer) (ip netip.Addr) {
+		t.Helper()
+
+		resp, err := srv.handle4(ifaceName, newTestRequest4(
+			mac,
+			layers.DHCPMsgTypeDiscover,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, quarantinedIP.AsSlice()),
+		))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		ip, ok := netip.AddrFromSlice(resp.YourClientIP.To4())
+		require.True(t, ok)
+
+		return ip
+	}
+
+	t.Run("expires", func(t *testing.T) {
+		srv, now := newSrv(t)
+		require.NoError(t, srv.Quarantine(quarantinedIP, time.Hour))
+
+		assert.NotEqual(t, quarantinedIP, offer(t, srv))
+
+		resp, err := srv.handle4(ifaceName, newTestRequest4(
+			mac,
+			layers.DHCPMsgTypeRequest,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, quarantinedIP.AsSlice()),
+		))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, layers.DHCPMsgTypeNak, msgType4(resp))
+
+		*now = now.Add(time.Hour)
+		assert.Equal(t, quarantinedIP, offer(t, srv))
+	})
+
+	t.Run("indefinitely", func(t *testing.T) {
+		srv, now := newSrv(t)
+		require.NoError(t, srv.Quarantine(quarantinedIP, 0))
+
+		*now = now.Add(100 * 365 * 24 * time.Hour)
+		assert.NotEqual(t, quarantinedIP, offer(t, srv))
+	})
+
+	t.Run("free_addrs", func(t *testing.T) {
+		srv, _ := newSrv(t)
+		before := srv.Status().Interfaces[0].IPv4.FreeAddrs
+
+		require.NoError(t, srv.Quarantine(quarantinedIP, time.Hour))
+		assert.Equal(t, before-1, srv.Status().Interfaces[0].IPv4.FreeAddrs)
+	})
+
+	t.Run("bad", func(t *testing.T) {
+		srv, _ := newSrv(t)
+
+		err := srv.Quarantine(netip.MustParseAddr("192.168.0.1"), time.Hour)
+		testutil.AssertErrorMsg(t, "quarantining 192.168.0.1: address is not within any range", err)
+
+		err = srv.Quarantine(quarantinedIP, -time.Hour)
+		testutil.AssertErrorMsg(t, "quarantining 192.168.0.2: duration -1h0m0s must be non-negative", err)
+	})
+}
+
+func TestDHCPServer_Quarantine_restart(t *testing.T) {
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+	newSrv := func(t *testing.T) (srv *DHCPServer) {
+		t.Helper()
+
+		srv, err := New(&Config{
+			Enabled:         true,
+			LocalDomainName: "local",
+			DBFilePath:      dbFilePath,
+			Interfaces: map[string]*InterfaceConfig{
+				"eth0": {
+					IPv4: newTestIPv4Config(),
+					IPv6: &IPv6Config{Enabled: false},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return srv
+	}
+
+	elapsedIP := netip.MustParseAddr("192.168.0.2")
+	pendingIP := netip.MustParseAddr("192.168.0.3")
+	foreverIP := netip.MustParseAddr("192.168.0.4")
+
+	// Quarantine the addresses two hours ago, so that one of them elapses
+	// while the server is down.
+	start := time.Now().Add(-2 * time.Hour)
+
+	srv := newSrv(t)
+	srv.now = func() (t time.Time) { return start }
+
+	require.NoError(t, srv.Quarantine(elapsedIP, time.Hour))
+	require.NoError(t, srv.Quarantine(pendingIP, 3*time.Hour))
+	require.NoError(t, srv.Quarantine(foreverIP, 0))
+
+	srv = newSrv(t)
+	now := time.Now()
+	srv.now = func() (t time.Time) { return now }
+
+	iface := srv.iface4ByName("eth0")
+	require.NotNil(t, iface)
+
+	assert.False(t, iface.isQuarantined(elapsedIP, now))
+	assert.NotContains(t, iface.quarantined, elapsedIP)
+	assert.True(t, iface.isQuarantined(pendingIP, now))
+	assert.True(t, iface.isQuarantined(foreverIP, now))
+
+	free := srv.freePredicate4(iface, now)
+	assert.True(t, free(elapsedIP))
+	assert.False(t, free(pendingIP))
+
+	// The stored time isn't truncated.
+	now = start.Add(3*time.Hour - time.Nanosecond)
+	assert.True(t, iface.isQuarantined(pendingIP, now))
+
+	now = start.Add(3 * time.Hour)
+	assert.False(t, iface.isQuarantined(pendingIP, now))
+	assert.True(t, iface.isQuarantined(foreverIP, now))
+	assert.True(t, srv.freePredicate4(iface, now)(pendingIP))
+}
@@ -0,0 +1,60 @@
+package dhcpsvc
+
+import "net/netip"
+
+// AddrRange is an inclusive range of IP addresses allocated for leasing.
+type AddrRange struct {
+	// Start is the first address of the range.
+	Start netip.Addr
+
+	// End is the last address of the range.
+	End netip.Addr
+}
+
+// newAddrRange returns the exported form of r.
+func newAddrRange(r ipRange) (ar *AddrRange) {
+	return &AddrRange{
+		Start: r.start,
+		End:   r.end,
+	}
+}
+
+// InterfaceRanges are the address ranges of a single network interface served
+// by the DHCP server.
+type InterfaceRanges struct {
+	// IPv4 is the range of DHCPv4 addresses.  It's nil if DHCPv4 is disabled
+	// on the interface.
+	IPv4 *AddrRange
+
+	// IPv6 is the range of DHCPv6 addresses.  It's nil if DHCPv6 is disabled
+	// on the interface.
+	IPv6 *AddrRange
+}
+
+// Ranges returns the address ranges of the served network interfaces by their
+// names.  ranges is empty if srv is disabled.
+func (srv *DHCPServer) Ranges() (ranges map[string]*InterfaceRanges) {
+	ranges = map[string]*InterfaceRanges{}
+	ifaceRanges := func(name string) (ir *InterfaceRanges) {
+		ir, ok := ranges[name]
+		if !ok {
+			ir = &InterfaceRanges{}
+			ranges[name] = ir
+		}
+
+		return ir
+	}
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	for _, iface := range srv.interfaces4 {
+		ifaceRanges(iface.name).IPv4 = newAddrRange(iface.addrSpace)
+	}
+
+	for _, iface := range srv.interfaces6 {
+		ifaceRanges(iface.name).IPv6 = newAddrRange(iface.addrSpace)
+	}
+
+	return ranges
+}
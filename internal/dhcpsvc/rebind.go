@@ -0,0 +1,68 @@
+package dhcpsvc
+
+import (
+	"context"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// Rebind reopens the connections of the network interface with the given name,
+// e.g. after it has been recreated, and increments its rebind counters, see
+// [FamilyStatus.Rebinds].  The paused address families are left as is.  srv
+// must be started.
+//
+// TODO(e.burkov):  Call it on hot-plug events of the network interfaces.
+func (srv *DHCPServer) Rebind(ctx context.Context, ifaceName string) (err error) {
+	defer func() { err = errors.Annotate(err, "rebinding %q: %w", ifaceName) }()
+
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+
+	if srv.startTime.IsZero() {
+		return errors.Error("server is not started")
+	}
+
+	var rebound bool
+	var errs []error
+	rebind := func(iface *netInterface, listen func() (err error)) {
+		rebound = true
+		if iface.paused {
+			return
+		}
+
+		err = iface.closeConn()
+		if err != nil {
+			log.Debug("dhcpsvc: %s", err)
+		}
+
+		err = listen()
+		if err != nil {
+			errs = append(errs, err)
+
+			return
+		}
+
+		iface.rebinds++
+	}
+
+	if iface := srv.iface4ByName(ifaceName); iface != nil {
+		rebind(&iface.netInterface, func() (err error) { return srv.listen4(ctx, iface) })
+	}
+
+	if iface := srv.iface6ByName(ifaceName); iface != nil {
+		rebind(&iface.netInterface, func() (err error) { return srv.listen6(ctx, iface) })
+	}
+
+	for _, iface := range srv.wrongFamily {
+		if iface.name == ifaceName {
+			rebind(&iface.netInterface, func() (err error) { return srv.listenWrongFamily(ctx, iface) })
+		}
+	}
+
+	if !rebound {
+		return errors.Error("no such interface")
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,84 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_Rebind(t *testing.T) {
+	listens := &atomic.Uint32{}
+	l := newTestListener(nil, nil)
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Listener: testListener(func(
+			ctx context.Context,
+			ifaceName string,
+			laddr netip.AddrPort,
+		) (conn net.PacketConn, err error) {
+			listens.Add(1)
+
+			return l(ctx, ifaceName, laddr)
+		}),
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	err = srv.Rebind(ctx, "eth0")
+	testutil.AssertErrorMsg(t, `rebinding "eth0": server is not started`, err)
+
+	assert.True(t, srv.Status().StartTime.IsZero())
+
+	// The DHCPv6 messages are also listened for to handle them according to
+	// the wrong family mode.
+	startTestServer(t, srv)
+	require.Equal(t, uint32(2), listens.Load())
+
+	st := srv.Status()
+	require.Len(t, st.Interfaces, 1)
+
+	startTime := st.StartTime
+	assert.False(t, startTime.IsZero())
+
+	fs := st.Interfaces[0].IPv4
+	require.NotNil(t, fs)
+
+	boundAt := fs.BoundAt
+	assert.False(t, boundAt.IsZero())
+	assert.Zero(t, fs.Rebinds)
+
+	// Make sure the clock moves forward even on platforms with coarse timers.
+	time.Sleep(time.Millisecond)
+
+	require.NoError(t, srv.Rebind(ctx, "eth0"))
+	assert.Equal(t, uint32(4), listens.Load())
+
+	st = srv.Status()
+	require.Len(t, st.Interfaces, 1)
+
+	fs = st.Interfaces[0].IPv4
+	require.NotNil(t, fs)
+
+	assert.Equal(t, startTime, st.StartTime)
+	assert.True(t, fs.BoundAt.After(boundAt))
+	assert.Equal(t, uint(1), fs.Rebinds)
+
+	err = srv.Rebind(ctx, "eth1")
+	testutil.AssertErrorMsg(t, `rebinding "eth1": no such interface`, err)
+}
@@ -0,0 +1,150 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// Neighbor is an entry of the neighbor table of the host, i.e. the ARP table
+// for IPv4 or the NDP one for IPv6.
+type Neighbor struct {
+	// IP is the IP address of the neighbor.
+	IP netip.Addr
+
+	// MAC is the hardware address of the neighbor.
+	MAC net.HardwareAddr
+}
+
+// NeighborSource provides the neighbor table of the host, see
+// [DHCPServer.Reconcile].
+type NeighborSource interface {
+	// Neighbors returns the current entries of the neighbor table.
+	Neighbors() (ns []*Neighbor, err error)
+}
+
+// Reconcile reclaims the active dynamic leases, which addresses have no entry
+// with the hardware address of the client within the neighbor table of
+// neighbors, e.g. those of the devices left the network without releasing.  If
+// [Config.ConflictProber] is set and [Config.ICMPTimeout] is positive, the
+// addresses of the stale DHCPv4 leases are probed first, and the ones still in
+// use are kept.  Static leases are never reclaimed.  The subscribers are
+// notified about the reclaimed leases.
+func (srv *DHCPServer) Reconcile(neighbors NeighborSource) (reclaimed int, err error) {
+	ns, err := neighbors.Neighbors()
+	if err != nil {
+		return 0, fmt.Errorf("reconciling leases: getting neighbors: %w", err)
+	}
+
+	stale := srv.staleLeases(ns)
+	stale = srv.probeStale(stale)
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	var evs []*Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		for _, s := range stale {
+			l, ok := srv.leases.leaseByAddr(s.IP)
+			if !ok || l.IsStatic || !bytes.Equal(l.HWAddr, s.HWAddr) {
+				// The lease has changed since it's been found stale.
+				continue
+			}
+
+			iface := srv.ifaceByName(l.InterfaceName, l.IP.Is4())
+			err = srv.leases.remove(l, iface)
+			if err != nil {
+				return nil, fmt.Errorf("removing lease for %s: %w", l.IP, err)
+			}
+
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeRemoved})
+		}
+
+		if len(evs) == 0 {
+			return nil, nil
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("reconciling leases: %w", err)
+	}
+
+	if len(evs) > 0 {
+		log.Info("dhcpsvc: reclaimed %d stale leases", len(evs))
+		srv.subscribers.notify(evs...)
+	}
+
+	return len(evs), nil
+}
+
+// staleLeases returns the copies of the active dynamic leases, which addresses
+// have no entry with the hardware address of the client within ns.
+func (srv *DHCPServer) staleLeases(ns []*Neighbor) (stale []*Lease) {
+	macs := make(map[netip.Addr][]net.HardwareAddr, len(ns))
+	for _, n := range ns {
+		ip := n.IP.Unmap()
+		macs[ip] = append(macs[ip], n.MAC)
+	}
+
+	now := srv.now()
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		if l.IsStatic || !l.Expiry.After(now) {
+			return true
+		}
+
+		for _, mac := range macs[l.IP] {
+			if bytes.Equal(mac, l.HWAddr) {
+				return true
+			}
+		}
+
+		stale = append(stale, l.Clone())
+
+		return true
+	})
+
+	return stale
+}
+
+// probeStale returns the leases from stale, which addresses aren't found in use
+// by [Config.ConflictProber].  stale is returned as is if probing is disabled.
+// The probing errors are logged and treated as if the address isn't in use.
+func (srv *DHCPServer) probeStale(stale []*Lease) (res []*Lease) {
+	if srv.conf.ConflictProber == nil || srv.conf.ICMPTimeout <= 0 {
+		return stale
+	}
+
+	res = stale[:0]
+	for _, l := range stale {
+		if !l.IP.Is4() || !srv.probeInUse(l) {
+			res = append(res, l)
+		}
+	}
+
+	return res
+}
+
+// probeInUse returns true if the address of l is found in use by
+// [Config.ConflictProber].
+func (srv *DHCPServer) probeInUse(l *Lease) (ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), srv.conf.ICMPTimeout)
+	defer cancel()
+
+	ok, err := srv.conf.ConflictProber(ctx, l.InterfaceName, l.IP)
+	if err != nil {
+		log.Debug("dhcpsvc: interface %q: probing %s: %s", l.InterfaceName, l.IP, err)
+
+		return false
+	}
+
+	return ok
+}
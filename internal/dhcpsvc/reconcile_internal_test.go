@@ -0,0 +1,137 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNeighborSource is a [NeighborSource] for tests.
+type fakeNeighborSource struct {
+	err error
+	ns  []*Neighbor
+}
+
+// type check
+var _ NeighborSource = (*fakeNeighborSource)(nil)
+
+// Neighbors implements the [NeighborSource] interface for *fakeNeighborSource.
+func (s *fakeNeighborSource) Neighbors() (ns []*Neighbor, err error) {
+	return s.ns, s.err
+}
+
+func TestDHCPServer_Reconcile(t *testing.T) {
+	presentMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	presentIP := netip.MustParseAddr("192.168.0.2")
+
+	staleMAC := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02}
+	staleIP := netip.MustParseAddr("192.168.0.3")
+
+	static := &Lease{
+		IP:       netip.MustParseAddr("192.168.0.100"),
+		HWAddr:   net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x03},
+		Hostname: "nas",
+		IsStatic: true,
+	}
+
+	// The neighbor table knows the stale address, but with another hardware
+	// address.
+	neighbors := &fakeNeighborSource{
+		ns: []*Neighbor{{
+			IP:  presentIP,
+			MAC: presentMAC,
+		}, {
+			IP:  staleIP,
+			MAC: net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0xFF},
+		}},
+	}
+
+	// newServer returns a new server with the leases of both clients and a
+	// static lease.  inUse is reported by the conflict prober once set.
+	newServer := func(t *testing.T, inUse *atomic.Bool) (srv *DHCPServer) {
+		t.Helper()
+
+		conf := newTestIPv4Config()
+		checkConflicts := false
+		conf.CheckConflicts = &checkConflicts
+
+		srv, err := New(&Config{
+			Enabled:         true,
+			LocalDomainName: "local",
+			ICMPTimeout:     time.Second,
+			ConflictProber: func(_ context.Context, _ string, _ netip.Addr) (ok bool, err error) {
+				return inUse.Load(), nil
+			},
+			Interfaces: map[string]*InterfaceConfig{
+				"eth0": {
+					IPv4: conf,
+					IPv6: &IPv6Config{Enabled: false},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		requestLease4(t, srv, presentMAC, presentIP, "present")
+		requestLease4(t, srv, staleMAC, staleIP, "stale")
+		require.NoError(t, srv.AddStaticLease(static.Clone()))
+
+		return srv
+	}
+
+	t.Run("reclaim", func(t *testing.T) {
+		srv := newServer(t, &atomic.Bool{})
+
+		ch := make(chan *Event, 1)
+		srv.Subscribe(ch)
+
+		reclaimed, err := srv.Reconcile(neighbors)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, reclaimed)
+		assert.Empty(t, srv.HostByIP(staleIP))
+		assert.Equal(t, "present", srv.HostByIP(presentIP))
+		assert.Equal(t, "nas", srv.HostByIP(static.IP))
+
+		require.Len(t, ch, 1)
+		ev := <-ch
+		assert.Equal(t, EventTypeRemoved, ev.Type)
+		assert.Equal(t, staleIP, ev.Lease.IP)
+
+		reclaimed, err = srv.Reconcile(neighbors)
+		require.NoError(t, err)
+
+		assert.Zero(t, reclaimed)
+	})
+
+	t.Run("in_use", func(t *testing.T) {
+		inUse := &atomic.Bool{}
+		srv := newServer(t, inUse)
+		inUse.Store(true)
+
+		reclaimed, err := srv.Reconcile(neighbors)
+		require.NoError(t, err)
+
+		assert.Zero(t, reclaimed)
+		assert.Equal(t, "stale", srv.HostByIP(staleIP))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		srv := newServer(t, &atomic.Bool{})
+
+		const testErr errors.Error = "test error"
+
+		reclaimed, err := srv.Reconcile(&fakeNeighborSource{err: testErr})
+		testutil.AssertErrorMsg(t, "reconciling leases: getting neighbors: test error", err)
+
+		assert.Zero(t, reclaimed)
+		assert.Equal(t, "stale", srv.HostByIP(staleIP))
+	})
+}
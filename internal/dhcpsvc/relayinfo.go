@@ -0,0 +1,74 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+)
+
+// dhcpOptRelayAgentInfo is the Relay Agent Information option, which is added
+// by the relay agents to the messages forwarded to the server and must be
+// echoed back within the replies to them.
+//
+// See https://datatracker.ietf.org/doc/html/rfc3046.
+const dhcpOptRelayAgentInfo layers.DHCPOpt = 82
+
+// maxRelaySubOptLen4 is the maximum length of the data of a sub-option of the
+// Relay Agent Information option echoed back.  The common sub-options, such as
+// Agent Circuit ID and Agent Remote ID, are much shorter, so the longer ones
+// are dropped instead of reflecting the arbitrary data sent by an attacker.
+const maxRelaySubOptLen4 = 64
+
+// relayInfo4 returns the data of the Relay Agent Information option of req
+// received on the network interface with ifaceName to echo back.  The
+// sub-options longer than [maxRelaySubOptLen4] are dropped.  data is nil if
+// there is no such option, it's malformed, or none of its sub-options are left.
+func relayInfo4(ifaceName string, req *layers.DHCPv4) (data []byte) {
+	opt := optData4(req, dhcpOptRelayAgentInfo)
+	for len(opt) > 0 {
+		if len(opt) < 2 || len(opt) < 2+int(opt[1]) {
+			log.Debug("dhcpsvc: interface %q: malformed relay agent information, ignoring", ifaceName)
+
+			return nil
+		}
+
+		code, subOpt := opt[0], opt[:2+int(opt[1])]
+		opt = opt[len(subOpt):]
+
+		if len(subOpt)-2 > maxRelaySubOptLen4 {
+			log.Debug(
+				"dhcpsvc: interface %q: relay agent sub-option %d exceeds %d bytes, dropping",
+				ifaceName,
+				code,
+				maxRelaySubOptLen4,
+			)
+
+			continue
+		}
+
+		data = append(data, subOpt...)
+	}
+
+	return data
+}
+
+// echoRelayInfo4 adds the Relay Agent Information option with data to resp as
+// the last option, if req has been forwarded by a relay agent, so that the
+// reply is sent to the agent.  The option is never sent directly to the
+// clients.  resp is fitted into maxSize bytes, leaving the space for the
+// option, see [fitReply4].
+//
+// See https://datatracker.ietf.org/doc/html/rfc3046#section-2.2.
+func echoRelayInfo4(resp, req *layers.DHCPv4, data []byte, maxSize int) {
+	relayIP, _ := netip.AddrFromSlice(req.RelayAgentIP.To4())
+	if len(data) == 0 || !isSet4(relayIP) {
+		fitReply4(resp, maxSize)
+
+		return
+	}
+
+	opt := layers.NewDHCPOption(dhcpOptRelayAgentInfo, data)
+	fitReply4(resp, maxSize-optLen4(opt))
+	resp.Options = append(resp.Options, opt)
+}
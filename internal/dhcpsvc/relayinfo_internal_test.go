@@ -0,0 +1,96 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRelaySubOpt returns the encoded sub-option of the Relay Agent Information
+// option with the given code and data.
+func newRelaySubOpt(code byte, data []byte) (subOpt []byte) {
+	return append([]byte{code, byte(len(data))}, data...)
+}
+
+func TestDHCPServer_handle4_relayInfo(t *testing.T) {
+	const ifaceName = "eth0"
+
+	srv := newTestServer4(t, newTestIPv4Config())
+
+	circuitID := newRelaySubOpt(1, []byte("eth0/1/3:vlan10"))
+	remoteID := newRelaySubOpt(2, []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0xFF})
+	oversized := newRelaySubOpt(1, bytes.Repeat([]byte{0xAA}, maxRelaySubOptLen4+1))
+
+	both := append(append([]byte{}, circuitID...), remoteID...)
+
+	testCases := []struct {
+		relayIP      netip.Addr
+		name         string
+		info         []byte
+		wantEchoed   []byte
+		wantRecorded []byte
+	}{{
+		relayIP:      netip.MustParseAddr("192.168.0.10"),
+		name:         "relayed",
+		info:         both,
+		wantEchoed:   both,
+		wantRecorded: both,
+	}, {
+		relayIP:      netip.IPv4Unspecified(),
+		name:         "direct",
+		info:         both,
+		wantEchoed:   nil,
+		wantRecorded: both,
+	}, {
+		relayIP:      netip.MustParseAddr("192.168.0.10"),
+		name:         "oversized",
+		info:         append(append([]byte{}, oversized...), remoteID...),
+		wantEchoed:   remoteID,
+		wantRecorded: remoteID,
+	}, {
+		relayIP:      netip.MustParseAddr("192.168.0.10"),
+		name:         "malformed",
+		info:         append(append([]byte{}, remoteID...), 1, 10, 0xAA),
+		wantEchoed:   nil,
+		wantRecorded: nil,
+	}, {
+		relayIP:      netip.MustParseAddr("192.168.0.10"),
+		name:         "absent",
+		info:         nil,
+		wantEchoed:   nil,
+		wantRecorded: nil,
+	}}
+
+	for i, tc := range testCases {
+		mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x01, byte(i)}
+
+		t.Run(tc.name, func(t *testing.T) {
+			var opts []layers.DHCPOption
+			if tc.info != nil {
+				opts = append(opts, layers.NewDHCPOption(dhcpOptRelayAgentInfo, tc.info))
+			}
+
+			req := newTestRequest4(mac, layers.DHCPMsgTypeDiscover, opts...)
+			req.RelayAgentIP = tc.relayIP.AsSlice()
+
+			offer, err := srv.handle4(ifaceName, req)
+			require.NoError(t, err)
+			require.NotNil(t, offer)
+
+			assert.Equal(t, tc.wantEchoed, optData4(offer, dhcpOptRelayAgentInfo))
+			if tc.wantEchoed != nil {
+				assert.Equal(t, dhcpOptRelayAgentInfo, offer.Options[len(offer.Options)-1].Type)
+			}
+
+			history := srv.ClientHistory(mac)
+			require.NotEmpty(t, history)
+
+			assert.Equal(t, tc.wantRecorded, history[len(history)-1].RelayInfo)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+)
+
+// foreignWarnIvl is the minimum interval between the warnings about the same
+// foreign DHCP server on a network interface.
+const foreignWarnIvl = 10 * time.Minute
+
+// ForeignServer is another DHCP server detected on a network interface.
+type ForeignServer struct {
+	// LastSeen is the time of the latest reply from the server.
+	LastSeen time.Time
+
+	// Addr is the server identifier of the server.
+	Addr netip.Addr
+}
+
+// foreignTracker records the foreign DHCP server replying to the clients on a
+// network interface.  It's safe for concurrent use.
+type foreignTracker struct {
+	// mu protects server and lastWarned.
+	mu *sync.Mutex
+
+	// server is the most recently seen foreign server.  Its address is invalid
+	// if no foreign servers have been seen.
+	server ForeignServer
+
+	// lastWarned is the time of the latest warning about server.
+	lastWarned time.Time
+}
+
+// newForeignTracker returns a new properly initialized *foreignTracker.
+func newForeignTracker() (t *foreignTracker) {
+	return &foreignTracker{
+		mu: &sync.Mutex{},
+	}
+}
+
+// track records the foreign server with the given address replied at now on
+// the network interface with the given name.  The warning is logged if the
+// server is seen for the first time or the previous warning is old enough.
+func (t *foreignTracker) track(ifaceName string, addr netip.Addr, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.server.Addr != addr || now.Sub(t.lastWarned) >= foreignWarnIvl {
+		log.Info("dhcpsvc: warning: interface %q: another dhcp server %s detected", ifaceName, addr)
+		t.lastWarned = now
+	}
+
+	t.server = ForeignServer{
+		LastSeen: now,
+		Addr:     addr,
+	}
+}
+
+// latest returns a copy of the most recently seen foreign server.  s is nil if
+// no foreign servers have been seen.
+func (t *foreignTracker) latest() (s *ForeignServer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.server.Addr.IsValid() {
+		return nil
+	}
+
+	server := t.server
+
+	return &server
+}
+
+// detectForeign4 records the server sent msg if it's a DHCPOFFER or a DHCPACK
+// from another DHCP server on iface.  The replies of srv itself, which may be
+// looped back, are ignored.
+func (iface *iface4) detectForeign4(msg *layers.DHCPv4) {
+	switch msgType4(msg) {
+	case layers.DHCPMsgTypeOffer, layers.DHCPMsgTypeAck:
+		// Go on.
+	default:
+		return
+	}
+
+	srvID := optIP4(msg, layers.DHCPOptServerID)
+	if !isSet4(srvID) {
+		log.Debug("dhcpsvc: interface %q: no server identifier in reply", iface.name)
+
+		return
+	} else if srvID == iface.gateway {
+		return
+	}
+
+	iface.foreign.track(iface.name, srvID, time.Now())
+}
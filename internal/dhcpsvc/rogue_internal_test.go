@@ -0,0 +1,138 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestReply4 returns a new DHCPv4 reply of the given type sent by the server
+// with srvID to the client with mac.
+func newTestReply4(mac net.HardwareAddr, typ layers.DHCPMsgType, srvID netip.Addr) (resp *layers.DHCPv4) {
+	resp = newTestRequest4(mac, typ, layers.NewDHCPOption(layers.DHCPOptServerID, srvID.AsSlice()))
+	resp.Operation = layers.DHCPOpReply
+	resp.YourClientIP = net.IP{192, 168, 0, 42}
+
+	return resp
+}
+
+func TestDHCPServer_detectForeign4(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	foreignIP := netip.MustParseAddr("192.168.0.254")
+	ownIP := newTestIPv4Config().GatewayIP
+
+	testCases := []struct {
+		msg         *layers.DHCPv4
+		name        string
+		wantForeign bool
+	}{{
+		msg:         newTestReply4(mac, layers.DHCPMsgTypeOffer, foreignIP),
+		name:        "foreign_offer",
+		wantForeign: true,
+	}, {
+		msg:         newTestReply4(mac, layers.DHCPMsgTypeAck, foreignIP),
+		name:        "foreign_ack",
+		wantForeign: true,
+	}, {
+		msg:         newTestReply4(mac, layers.DHCPMsgTypeNak, foreignIP),
+		name:        "foreign_nak",
+		wantForeign: false,
+	}, {
+		msg:         newTestReply4(mac, layers.DHCPMsgTypeOffer, ownIP),
+		name:        "own_offer",
+		wantForeign: false,
+	}, {
+		msg:         newTestReply4(mac, layers.DHCPMsgTypeAck, ownIP),
+		name:        "own_ack",
+		wantForeign: false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer4(t, newTestIPv4Config())
+
+			start := time.Now()
+			resp, err := srv.handle4("eth0", tc.msg)
+			require.NoError(t, err)
+
+			assert.Nil(t, resp)
+
+			s := srv.Status()
+			require.Len(t, s.Interfaces, 1)
+			require.NotNil(t, s.Interfaces[0].IPv4)
+
+			foreign := s.Interfaces[0].IPv4.ForeignServer
+			if !tc.wantForeign {
+				assert.Nil(t, foreign)
+
+				return
+			}
+
+			require.NotNil(t, foreign)
+
+			assert.Equal(t, foreignIP, foreign.Addr)
+			assert.False(t, foreign.LastSeen.Before(start))
+		})
+	}
+}
+
+func TestDHCPServer_detectForeign4_conn(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	foreignIP := netip.MustParseAddr("192.168.0.254")
+	from := &net.UDPAddr{IP: foreignIP.AsSlice(), Port: serverPort4}
+
+	reads := make(chan *testRead, 1)
+	srv := newListeningTestServer(t, "", reads)
+	startTestServer(t, srv)
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(
+		buf,
+		gopacket.SerializeOptions{FixLengths: true},
+		newTestReply4(mac, layers.DHCPMsgTypeOffer, foreignIP),
+	)
+	require.NoError(t, err)
+
+	start := time.Now()
+	reads <- &testRead{from: from, data: buf.Bytes()}
+
+	var foreign *ForeignServer
+	require.Eventually(t, func() (ok bool) {
+		foreign = srv.Status().Interfaces[0].IPv4.ForeignServer
+
+		return foreign != nil
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, foreignIP, foreign.Addr)
+	assert.False(t, foreign.LastSeen.Before(start))
+}
+
+func TestForeignTracker_track(t *testing.T) {
+	const ifaceName = "eth0"
+
+	addr1 := netip.MustParseAddr("192.168.0.253")
+	addr2 := netip.MustParseAddr("192.168.0.254")
+	start := time.Now()
+
+	tr := newForeignTracker()
+	require.Nil(t, tr.latest())
+
+	tr.track(ifaceName, addr1, start)
+	assert.Equal(t, start, tr.lastWarned)
+
+	tr.track(ifaceName, addr1, start.Add(time.Minute))
+	assert.Equal(t, start, tr.lastWarned)
+	assert.Equal(t, &ForeignServer{LastSeen: start.Add(time.Minute), Addr: addr1}, tr.latest())
+
+	tr.track(ifaceName, addr2, start.Add(2*time.Minute))
+	assert.Equal(t, start.Add(2*time.Minute), tr.lastWarned)
+
+	tr.track(ifaceName, addr2, start.Add(2*time.Minute+foreignWarnIvl))
+	assert.Equal(t, start.Add(2*time.Minute+foreignWarnIvl), tr.lastWarned)
+}
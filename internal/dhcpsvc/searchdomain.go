@@ -0,0 +1,80 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/stringutil"
+)
+
+// maxOptLen6 is the maximum length of the data of a DHCPv6 option.
+const maxOptLen6 = math.MaxUint16
+
+// searchDomains returns the domain names for the clients to search with local
+// first.  The names are normalized, see [normalizeDomainName], so that the
+// duplicates are removed case-insensitively.  Empty names are skipped.
+func searchDomains(local string, extra []string) (domains []string) {
+	set := stringutil.NewSet()
+	for _, d := range append([]string{local}, extra...) {
+		d = normalizeDomainName(d)
+		if d == "" || set.Has(d) {
+			continue
+		}
+
+		set.Add(d)
+		domains = append(domains, d)
+	}
+
+	return domains
+}
+
+// searchListData returns the data of the search list options containing
+// domains, each encoded as a sequence of labels.  Both the DHCPv4 Domain Search
+// and the DHCPv6 Domain Search List options use this encoding.  data is nil if
+// domains are empty.
+//
+// See https://datatracker.ietf.org/doc/html/rfc3397#section-2 and
+// https://datatracker.ietf.org/doc/html/rfc3646#section-4.
+func searchListData(domains []string) (data []byte) {
+	for _, d := range domains {
+		for _, label := range strings.Split(d, ".") {
+			data = append(data, byte(len(label)))
+			data = append(data, label...)
+		}
+
+		data = append(data, 0)
+	}
+
+	return data
+}
+
+// validateSearchDomains returns an error if the extra search domains of conf
+// are invalid or if the resulting search list doesn't fit the options of the
+// address families served.
+func (conf *Config) validateSearchDomains() (err error) {
+	for i, d := range conf.ExtraSearchDomains {
+		err = netutil.ValidateDomainName(normalizeDomainName(d))
+		if err != nil {
+			return fmt.Errorf("extra search domain at index %d: %w", i, err)
+		}
+	}
+
+	n := len(searchListData(searchDomains(conf.LocalDomainName, conf.ExtraSearchDomains)))
+
+	var serves4, serves6 bool
+	for _, ic := range conf.Interfaces {
+		serves4 = serves4 || ic.IPv4 != nil && ic.IPv4.Enabled
+		serves6 = serves6 || ic.IPv6 != nil && ic.IPv6.Enabled
+	}
+
+	switch {
+	case serves4 && n > maxOptLen4:
+		return fmt.Errorf("search domains: dhcpv4 option length %d must not exceed %d", n, maxOptLen4)
+	case serves6 && n > maxOptLen6:
+		return fmt.Errorf("search domains: dhcpv6 option length %d must not exceed %d", n, maxOptLen6)
+	default:
+		return nil
+	}
+}
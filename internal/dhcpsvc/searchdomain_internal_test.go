@@ -0,0 +1,79 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchDomains(t *testing.T) {
+	testCases := []struct {
+		name  string
+		local string
+		extra []string
+		want  []string
+	}{{
+		name:  "local_only",
+		local: "lan",
+		extra: nil,
+		want:  []string{"lan"},
+	}, {
+		name:  "ordered",
+		local: "lan",
+		extra: []string{"home.arpa", "example.com"},
+		want:  []string{"lan", "home.arpa", "example.com"},
+	}, {
+		name:  "duplicates",
+		local: "Lan.",
+		extra: []string{"home.arpa", "LAN", "Home.Arpa.", "example.com"},
+		want:  []string{"lan", "home.arpa", "example.com"},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, searchDomains(tc.local, tc.extra))
+		})
+	}
+}
+
+func TestDHCPServer_searchList(t *testing.T) {
+	const ifaceName = "eth0"
+
+	srv, err := New(&Config{
+		Enabled:            true,
+		LocalDomainName:    "lan",
+		ExtraSearchDomains: []string{"home.arpa", "LAN"},
+		Interfaces: map[string]*InterfaceConfig{
+			ifaceName: {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{
+					Enabled:       true,
+					RangeStart:    netip.MustParseAddr("2001:db8::1"),
+					LeaseDuration: time.Hour,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	want := []byte{3, 'l', 'a', 'n', 0, 4, 'h', 'o', 'm', 'e', 4, 'a', 'r', 'p', 'a', 0}
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	resp4, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+	require.NoError(t, err)
+	require.NotNil(t, resp4)
+
+	assert.Equal(t, want, optData4(resp4, layers.DHCPOptDomainSearch))
+	assert.Equal(t, []byte("lan"), optData4(resp4, layers.DHCPOptDomainName))
+
+	resp6, err := srv.handle6(ifaceName, newTestRequest6(layers.DHCPv6MsgTypeSolicit, newTestDUID6(mac), 1))
+	require.NoError(t, err)
+	require.NotNil(t, resp6)
+
+	assert.Equal(t, want, optData6(reencode6(t, resp6), layers.DHCPv6OptDomainList))
+}
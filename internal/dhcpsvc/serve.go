@@ -0,0 +1,350 @@
+package dhcpsvc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// maxReadSize is the size of the buffer for reading messages from the network.
+// It's the maximum size of UDP payload.
+const maxReadSize = 65_507
+
+// msgHandler handles the message received from the network address from and
+// serializes the reply into buf.  to is nil if no reply should be sent.
+type msgHandler func(data []byte, from net.Addr, buf gopacket.SerializeBuffer) (to net.Addr, err error)
+
+// listen opens the connections for every served network interface, except
+// the paused ones, and starts serving them as well as sweeping the expired
+// leases.  The messages of the address family disabled on a network interface
+// are handled according to the configured [WrongFamilyMode].  In case of an
+// error all the opened connections are closed.
+func (srv *DHCPServer) listen(ctx context.Context) (err error) {
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+
+	for _, iface := range srv.interfaces4 {
+		if iface.paused {
+			log.Info("dhcpsvc: interface %q: ipv4 is paused", iface.name)
+
+			continue
+		}
+
+		err = srv.listen4(ctx, iface)
+		if err != nil {
+			return errors.WithDeferred(err, srv.closeConns())
+		}
+	}
+
+	for _, iface := range srv.interfaces6 {
+		if iface.paused {
+			log.Info("dhcpsvc: interface %q: ipv6 is paused", iface.name)
+
+			continue
+		}
+
+		err = srv.listen6(ctx, iface)
+		if err != nil {
+			return errors.WithDeferred(err, srv.closeConns())
+		}
+	}
+
+	for _, iface := range srv.wrongFamily {
+		err = srv.listenWrongFamily(ctx, iface)
+		if err != nil {
+			return errors.WithDeferred(err, srv.closeConns())
+		}
+	}
+
+	srv.startTime = time.Now()
+	srv.startSweeper()
+
+	return nil
+}
+
+// listen4 opens the connection for iface and starts serving DHCPv4 on it.
+// srv.connsMu is expected to be locked.
+func (srv *DHCPServer) listen4(ctx context.Context, iface *iface4) (err error) {
+	port, _ := srv.conf.ports4()
+	laddr := netip.AddrPortFrom(netip.IPv4Unspecified(), port)
+
+	return srv.listenIface(ctx, &iface.netInterface, laddr, srv.newMsgHandler4(iface))
+}
+
+// listen6 opens the connection for iface and starts serving DHCPv6 on it.
+// srv.connsMu is expected to be locked.
+func (srv *DHCPServer) listen6(ctx context.Context, iface *iface6) (err error) {
+	handle := srv.newMsgHandler6(iface)
+	if iface.bindLinkLocal {
+		return srv.listenLinkLocal6(ctx, iface, handle)
+	}
+
+	laddr := netip.AddrPortFrom(netip.IPv6Unspecified(), serverPort6)
+
+	return srv.listenIface(ctx, &iface.netInterface, laddr, handle)
+}
+
+// listenWrongFamily opens the connection for iface and starts handling the
+// messages of the disabled address family on it.  srv.connsMu is expected to be
+// locked.
+func (srv *DHCPServer) listenWrongFamily(ctx context.Context, iface *wrongFamilyIface) (err error) {
+	laddr := netip.AddrPortFrom(netip.IPv6Unspecified(), serverPort6)
+	if iface.is4 {
+		port, _ := srv.conf.ports4()
+		laddr = netip.AddrPortFrom(netip.IPv4Unspecified(), port)
+	}
+
+	return srv.listenIface(ctx, &iface.netInterface, laddr, srv.newWrongFamilyHandler(iface))
+}
+
+// listenIface opens the connection for iface listening on laddr and starts
+// serving it with handle.  srv.connsMu is expected to be locked.
+func (srv *DHCPServer) listenIface(
+	ctx context.Context,
+	iface *netInterface,
+	laddr netip.AddrPort,
+	handle msgHandler,
+) (err error) {
+	conn, err := srv.listener.ListenPacket(ctx, iface.name, laddr)
+	if err != nil {
+		return fmt.Errorf("interface %q: %w", iface.name, err)
+	}
+
+	srv.startServing(iface, conn, handle, laddr)
+
+	return nil
+}
+
+// listenLinkLocal6 opens the connection for iface sending the messages from its
+// link-local address and receiving the ones sent to the DHCPv6 multicast group,
+// and starts serving it with handle.  srv.listener must be a
+// [LinkLocalListener].  srv.connsMu is expected to be locked.
+func (srv *DHCPServer) listenLinkLocal6(ctx context.Context, iface *iface6, handle msgHandler) (err error) {
+	l := srv.listener.(LinkLocalListener)
+	conn, laddr, err := l.ListenLinkLocal(ctx, iface.name, allDHCPAgents6, serverPort6)
+	if err != nil {
+		return fmt.Errorf("interface %q: link-local: %w", iface.name, err)
+	}
+
+	srv.startServing(&iface.netInterface, conn, handle, netip.AddrPortFrom(laddr, serverPort6))
+
+	return nil
+}
+
+// startServing starts serving conn opened for iface on laddr with handle.
+// srv.connsMu is expected to be locked.
+func (srv *DHCPServer) startServing(
+	iface *netInterface,
+	conn net.PacketConn,
+	handle msgHandler,
+	laddr netip.AddrPort,
+) {
+	iface.conn = conn
+	iface.boundAt = time.Now()
+
+	srv.wg.Add(1)
+	go srv.serve(iface, conn, handle)
+
+	log.Info("dhcpsvc: interface %q: listening on %s", iface.name, laddr)
+}
+
+// closeConns closes the connections of all the served network interfaces and
+// stops sweeping the expired leases.
+// srv.connsMu is expected to be locked.
+func (srv *DHCPServer) closeConns() (err error) {
+	var errs []error
+	for _, iface := range srv.interfaces4 {
+		errs = append(errs, iface.closeConn())
+	}
+
+	for _, iface := range srv.interfaces6 {
+		errs = append(errs, iface.closeConn())
+	}
+
+	for _, iface := range srv.wrongFamily {
+		errs = append(errs, iface.closeConn())
+	}
+
+	srv.startTime = time.Time{}
+	srv.stopSweeper()
+
+	return errors.Join(errs...)
+}
+
+// closeConn closes the connection of iface, if any.  [DHCPServer.connsMu] is
+// expected to be locked.
+func (iface *netInterface) closeConn() (err error) {
+	if iface.conn == nil {
+		return nil
+	}
+
+	err = iface.conn.Close()
+	iface.conn = nil
+	iface.boundAt = time.Time{}
+	if err != nil {
+		return fmt.Errorf("interface %q: closing: %w", iface.name, err)
+	}
+
+	return nil
+}
+
+// serve handles the messages read from conn of iface with handle until conn
+// is closed or fails.  It's intended to be used as a goroutine.
+func (srv *DHCPServer) serve(iface *netInterface, conn net.PacketConn, handle msgHandler) {
+	defer srv.wg.Done()
+	defer log.OnPanic("dhcpsvc: serving " + iface.name)
+
+	data := make([]byte, maxReadSize)
+	buf := gopacket.NewSerializeBuffer()
+	for {
+		n, from, err := conn.ReadFrom(data)
+		if err != nil {
+			srv.unbind(iface, conn, err)
+
+			return
+		}
+
+		err = buf.Clear()
+		if err != nil {
+			// Shouldn't happen, since the buffer is in memory.
+			panic(err)
+		}
+
+		to, err := handle(data[:n], from, buf)
+		if err != nil {
+			log.Debug("dhcpsvc: interface %q: handling message: %s", iface.name, err)
+
+			continue
+		} else if to == nil {
+			continue
+		}
+
+		_, err = conn.WriteTo(buf.Bytes(), to)
+		if err != nil {
+			log.Debug("dhcpsvc: interface %q: writing reply to %s: %s", iface.name, to, err)
+		}
+	}
+}
+
+// unbind detaches conn from iface after it failed with err, unless it's
+// already closed by the server.
+func (srv *DHCPServer) unbind(iface *netInterface, conn net.PacketConn, err error) {
+	if errors.Is(err, net.ErrClosed) {
+		// Only the server closes the connections, and it detaches them at the
+		// same time, so don't contend for srv.connsMu, since the goroutines
+		// serving the replaced connections may pile up on frequent rebinding.
+		log.Debug("dhcpsvc: interface %q: stopped serving", iface.name)
+
+		return
+	}
+
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+
+	if iface.conn != conn {
+		log.Debug("dhcpsvc: interface %q: stopped serving", iface.name)
+
+		return
+	}
+
+	log.Error("dhcpsvc: interface %q: reading: %s", iface.name, err)
+
+	iface.conn = nil
+	iface.boundAt = time.Time{}
+	if closeErr := conn.Close(); closeErr != nil {
+		log.Debug("dhcpsvc: interface %q: closing: %s", iface.name, closeErr)
+	}
+}
+
+// newMsgHandler4 returns the handler of DHCPv4 messages received on iface.  The
+// messages from unexpected source ports are handled according to the
+// configured [SourcePortMode].
+func (srv *DHCPServer) newMsgHandler4(iface *iface4) (h msgHandler) {
+	return func(data []byte, from net.Addr, buf gopacket.SerializeBuffer) (to net.Addr, err error) {
+		unexpected, ok := srv.checkSourcePort(&iface.netInterface, from, true)
+		if !ok {
+			return nil, nil
+		}
+
+		req := &layers.DHCPv4{}
+		err = req.DecodeFromBytes(data, gopacket.NilDecodeFeedback)
+		if err != nil {
+			return nil, fmt.Errorf("decoding: %w", err)
+		}
+
+		resp, err := srv.handle4(iface.name, req)
+		if err != nil || resp == nil {
+			return nil, err
+		}
+
+		err = gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, resp)
+		if err != nil {
+			return nil, fmt.Errorf("encoding: %w", err)
+		}
+
+		addr := srv.replyAddr4(req, resp)
+		if unexpected {
+			addr.Port = from.(*net.UDPAddr).Port
+		}
+
+		return addr, nil
+	}
+}
+
+// replyAddr4 returns the address to send resp replying to req to.  The ports
+// are the configured ones, see [Config.ServerPort] and [Config.ClientPort].
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-4.1.
+//
+// TODO(e.burkov):  Unicast replies to the offered address when the client
+// accepts them, which requires updating the ARP cache.
+func (srv *DHCPServer) replyAddr4(req, resp *layers.DHCPv4) (addr *net.UDPAddr) {
+	serverPort, clientPort := srv.conf.ports4()
+	if relayIP, _ := netip.AddrFromSlice(req.RelayAgentIP.To4()); isSet4(relayIP) {
+		return net.UDPAddrFromAddrPort(netip.AddrPortFrom(relayIP, serverPort))
+	}
+
+	clientIP, _ := netip.AddrFromSlice(req.ClientIP.To4())
+	if isSet4(clientIP) && msgType4(resp) != layers.DHCPMsgTypeNak {
+		return net.UDPAddrFromAddrPort(netip.AddrPortFrom(clientIP, clientPort))
+	}
+
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: int(clientPort)}
+}
+
+// newMsgHandler6 returns the handler of DHCPv6 messages received on iface.  The
+// replies are sent back to the address the request came from.  The messages
+// from unexpected source ports are handled according to the configured
+// [SourcePortMode].
+func (srv *DHCPServer) newMsgHandler6(iface *iface6) (h msgHandler) {
+	return func(data []byte, from net.Addr, buf gopacket.SerializeBuffer) (to net.Addr, err error) {
+		if _, ok := srv.checkSourcePort(&iface.netInterface, from, false); !ok {
+			return nil, nil
+		}
+
+		req := &layers.DHCPv6{}
+		err = req.DecodeFromBytes(data, gopacket.NilDecodeFeedback)
+		if err != nil {
+			return nil, fmt.Errorf("decoding: %w", err)
+		}
+
+		resp, err := srv.handle6(iface.name, req)
+		if err != nil || resp == nil {
+			return nil, err
+		}
+
+		err = gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, resp)
+		if err != nil {
+			return nil, fmt.Errorf("encoding: %w", err)
+		}
+
+		return from, nil
+	}
+}
@@ -0,0 +1,925 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/mapsutil"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/AdguardTeam/golibs/stringutil"
+	"golang.org/x/exp/slices"
+)
+
+// DHCPServer is a DHCP server for both IPv4 and IPv6 address families.
+type DHCPServer struct {
+	// enabled indicates whether the DHCP server is enabled and can provide
+	// information about its clients.
+	enabled *atomic.Bool
+
+	// conf is the configuration the server was created with.
+	conf *Config
+
+	// localTLD is the top-level domain name to use for resolving DHCP clients'
+	// hostnames.  It's normalized, see [normalizeDomainName].
+	localTLD string
+
+	// dbFilePath is the path to the database file containing the DHCP leases.
+	dbFilePath string
+
+	// dbFS is the file system the database file is written into.
+	dbFS dbFS
+
+	// listener opens the connections for serving the network interfaces.
+	listener Listener
+
+	// connsMu protects the connections of the network interfaces, startTime,
+	// and sweeperStop.
+	connsMu *sync.Mutex
+
+	// wg tracks the goroutines serving the network interfaces and sweeping the
+	// expired leases.
+	wg *sync.WaitGroup
+
+	// sweeperAlive is true while the goroutine sweeping the expired leases is
+	// running, see [DHCPServer.runSweeper].
+	sweeperAlive *atomic.Bool
+
+	// lastStored is the time the leases have been last successfully written
+	// into the database file.  It's nil if they haven't been written yet.
+	lastStored *atomic.Pointer[time.Time]
+
+	// dbBufPool is a pool of buffers used for encoding the database.
+	dbBufPool *sync.Pool
+
+	// dbLatest is the latest state taken to be written into the database file,
+	// see [DHCPServer.dbSnapshot].
+	dbLatest *atomic.Pointer[dbState]
+
+	// dbWriteMu serializes writing the database file and protects dbWritten.
+	// It's never locked while holding leasesMu.
+	dbWriteMu *sync.Mutex
+
+	// dbWritten is the sequence number of the state last written into the
+	// database file.
+	dbWritten uint64
+
+	// subscribers are the channels to notify about the changes of leases.
+	subscribers *subscribers
+
+	// leasesMu protects the leases index as well as leases in the interfaces.
+	leasesMu *sync.RWMutex
+
+	// leases stores the DHCP leases for quick lookups.
+	leases *leaseIndex
+
+	// allocFails are the counters of failures to allocate an address.
+	allocFails *allocFailCounters
+
+	// reservedHostnames are the normalized hostnames never assigned to the
+	// clients requesting them, see [Config.ReservedHostnames].
+	reservedHostnames *stringutil.Set
+
+	// pending is the queue of the clients awaiting approval, see
+	// [UnknownClientsPolicyDeny].
+	pending *pendingClients
+
+	// hostnameSpoofs is the number of attempts of clients to claim the
+	// reserved hostnames or the ones bound to other clients.
+	hostnameSpoofs *atomic.Uint64
+
+	// probeFallbacks is the number of addresses offered without probing due
+	// to the exceeded probe budget, see [Config.ProbeBudget].
+	probeFallbacks *atomic.Uint64
+
+	// history is the transaction history of the clients.  It's never
+	// persisted.
+	history *history
+
+	// duplicates are the leases skipped while loading the database, since they
+	// duplicate the ones loaded before.  It's protected by leasesMu.
+	duplicates []*DuplicateLease
+
+	// interfaces4 is the set of IPv4 interfaces sorted by interface name.
+	interfaces4 []*iface4
+
+	// interfaces6 is the set of IPv6 interfaces sorted by interface name.
+	interfaces6 []*iface6
+
+	// wrongFamily is the set of interfaces listening for the messages of the
+	// address families disabled on the served network interfaces.
+	wrongFamily []*wrongFamilyIface
+
+	// now returns the current time to calculate the lifetimes of the leases.
+	now func() (t time.Time)
+
+	// startTime is the time the server has been started.  It's zero if the
+	// server isn't running.
+	startTime time.Time
+
+	// epoch is the random identifier of the server instance, see
+	// [Config.ReleaseOnExit].
+	epoch string
+
+	// exitEpoch is the identifier written into the database file, which is
+	// epoch once the server is shut down with [Config.ReleaseOnExit], and empty
+	// otherwise.  It's protected by leasesMu.
+	exitEpoch string
+
+	// dbSeq is the sequence number of the state last taken to be written into
+	// the database file.  It's protected by leasesMu.
+	dbSeq uint64
+
+	// dbDirty is true if the leases have changed since the state has been last
+	// taken to be written into the database file, but the change isn't worth
+	// writing immediately, see [DHCPServer.storeDirty].  It's protected by
+	// leasesMu.
+	dbDirty bool
+
+	// sweeperStop stops the goroutine sweeping the expired leases.  It's nil
+	// if the goroutine isn't started.
+	sweeperStop chan struct{}
+}
+
+// New creates a new DHCP server with the given configuration.  It returns an
+// error if the given configuration can't be used.  conf must not be modified
+// after calling New.  If conf is disabled, srv doesn't serve any interfaces.
+func New(conf *Config) (srv *DHCPServer, err error) {
+	err = conf.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("validating config: %w", err)
+	}
+
+	var listener Listener = NetListener{}
+	if conf.Listener != nil {
+		listener = conf.Listener
+	}
+
+	srv = &DHCPServer{
+		enabled:      &atomic.Bool{},
+		conf:         conf,
+		localTLD:     normalizeDomainName(conf.LocalDomainName),
+		dbFilePath:   conf.dbFilePath(),
+		dbFS:         osFS{},
+		listener:     listener,
+		connsMu:      &sync.Mutex{},
+		wg:           &sync.WaitGroup{},
+		sweeperAlive: &atomic.Bool{},
+		lastStored:   &atomic.Pointer[time.Time]{},
+		dbBufPool: &sync.Pool{
+			New: func() (buf any) { return &bytes.Buffer{} },
+		},
+		dbLatest:       &atomic.Pointer[dbState]{},
+		dbWriteMu:      &sync.Mutex{},
+		subscribers:    newSubscribers(),
+		leasesMu:       &sync.RWMutex{},
+		leases:         newLeaseIndex(),
+		allocFails:     &allocFailCounters{},
+		hostnameSpoofs: &atomic.Uint64{},
+		probeFallbacks: &atomic.Uint64{},
+		history:        newHistory(),
+		pending:        newPendingClients(),
+		now:            time.Now,
+		epoch:          newEpoch(),
+	}
+	srv.enabled.Store(conf.Enabled)
+
+	if !conf.Enabled {
+		return srv, nil
+	}
+
+	// TODO(e.burkov):  Add validations scoped to the network interfaces set.
+	//
+	// The served interfaces never contain nil, since the nil configurations
+	// are rejected by [Config.Validate] with errNilConfig and the ones with the
+	// address family disabled aren't appended.
+	srv.interfaces4 = make([]*iface4, 0, len(conf.Interfaces))
+	srv.interfaces6 = make([]*iface6, 0, len(conf.Interfaces))
+
+	domains := searchDomains(conf.LocalDomainName, conf.ExtraSearchDomains)
+	srv.reservedHostnames = newReservedHostnames(conf.ReservedHostnames)
+
+	var errs []error
+	mapsutil.OrderedRange(conf.Interfaces, func(name string, iface *InterfaceConfig) (cont bool) {
+		i4, v4Err := newIface4(name, iface.IPv4, domains, conf.DNSAddrs, conf.OptionProfiles)
+		if v4Err != nil {
+			errs = append(errs, fmt.Errorf("creating ipv4 interface %q: %w", name, v4Err))
+		} else if i4 != nil {
+			i4.checkConflicts = conf.ConflictProber != nil && iface.IPv4.checkConflicts(conf.ICMPTimeout)
+			i4.authoritative = iface.authoritative(conf.Authoritative)
+			srv.interfaces4 = append(srv.interfaces4, i4)
+		}
+
+		i6, v6Err := newIface6(name, iface.IPv6, domains, conf.DNSAddrs)
+		if v6Err != nil {
+			errs = append(errs, fmt.Errorf("creating ipv6 interface %q: %w", name, v6Err))
+		} else if i6 != nil {
+			srv.interfaces6 = append(srv.interfaces6, i6)
+		}
+
+		return true
+	})
+
+	if err = errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	srv.wrongFamily = srv.newWrongFamilyIfaces()
+
+	err = ensureDBDir(srv.dbFilePath)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	err = srv.dbLoad()
+	if err != nil {
+		return nil, fmt.Errorf("loading db: %w", err)
+	}
+
+	return srv, nil
+}
+
+// type check
+var _ Interface = (*DHCPServer)(nil)
+
+// Start implements the [Interface] interface for *DHCPServer.  It opens the
+// connections for all the served network interfaces and starts reconfirming
+// the leases granted by the previous instance, see [Config.ReleaseOnExit].
+func (srv *DHCPServer) Start() (err error) {
+	srv.markExit(false)
+
+	err = srv.listen(context.Background())
+	if err != nil {
+		return fmt.Errorf("starting dhcp server: %w", err)
+	}
+
+	srv.startReconfirm()
+
+	return nil
+}
+
+// Shutdown implements the [Interface] interface for *DHCPServer.  It closes
+// the connections, waits for the served network interfaces to stop until ctx is
+// done, and flushes the leases, see [DHCPServer.Flush].  The database is marked
+// for the next instance if [Config.ReleaseOnExit] is set.
+func (srv *DHCPServer) Shutdown(ctx context.Context) (err error) {
+	defer func() { err = errors.Annotate(err, "shutting down dhcp server: %w") }()
+
+	srv.markExit(true)
+
+	srv.connsMu.Lock()
+	err = srv.closeConns()
+	srv.connsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer log.OnPanic("dhcpsvc: waiting for shutdown")
+
+		srv.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return errors.Join(err, srv.Flush(ctx))
+	case <-ctx.Done():
+		return errors.Join(err, ctx.Err())
+	}
+}
+
+// Flush writes the leases into the database file immediately.  It may be
+// called before a planned reboot, although the leases are also written on each
+// change.  It's safe for concurrent use.
+func (srv *DHCPServer) Flush(ctx context.Context) (err error) {
+	err = ctx.Err()
+	if err != nil {
+		return fmt.Errorf("flushing leases: %w", err)
+	}
+
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("flushing leases: %w", err)
+	}
+
+	return nil
+}
+
+// Config implements the [Interface] interface for *DHCPServer.  conf is a copy
+// of the configuration srv is created with, which reflects the network
+// interfaces paused by [DHCPServer.DisableInterface].
+func (srv *DHCPServer) Config() (conf *Config) {
+	srv.connsMu.Lock()
+	defer srv.connsMu.Unlock()
+
+	return srv.pausedConfig()
+}
+
+// Enabled implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) Enabled() (ok bool) {
+	return srv.enabled.Load()
+}
+
+// Leases implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) Leases() (leases []*Lease) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	leases = make([]*Lease, 0, srv.leases.len())
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		leases = append(leases, l.Clone())
+
+		return true
+	})
+
+	return leases
+}
+
+// RangeLeases calls f with a copy of each DHCP lease in an unspecified order
+// until f returns false.  Unlike [DHCPServer.Leases], it doesn't copy all the
+// leases at once, so it's suitable for iterating over lots of them.
+//
+// The addresses of the leases are collected first, and then each lease is
+// looked up by its address separately, so the leases may be changed during the
+// iteration.  No address is yielded twice, the leases removed before being
+// looked up aren't yielded, and the leases added during the iteration may or
+// may not be yielded.  Each yielded lease is in the state it had when looked
+// up.  f is called without any locks held, so it may use srv.
+func (srv *DHCPServer) RangeLeases(f func(l *Lease) (cont bool)) {
+	srv.leasesMu.RLock()
+	addrs := make([]netip.Addr, 0, srv.leases.len())
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		addrs = append(addrs, l.IP)
+
+		return true
+	})
+	srv.leasesMu.RUnlock()
+
+	for _, addr := range addrs {
+		l := srv.leaseCopy(addr)
+		if l != nil && !f(l) {
+			return
+		}
+	}
+}
+
+// leaseCopy returns a copy of the lease for addr, or nil if there is none.
+func (srv *DHCPServer) leaseCopy(addr netip.Addr) (l *Lease) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	if l, ok := srv.leases.leaseByAddr(addr); ok {
+		return l.Clone()
+	}
+
+	return nil
+}
+
+// HostByIP implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) HostByIP(ip netip.Addr) (host string) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	if l, ok := srv.leases.leaseByAddr(ip); ok {
+		return l.Hostname
+	}
+
+	return ""
+}
+
+// MACByIP implements the [Interface] interface for *DHCPServer.  If the lease
+// has no hardware address, it's derived from the client identifier, if
+// possible.  Static leases are taken into account even if the client has never
+// been seen.
+func (srv *DHCPServer) MACByIP(ip netip.Addr) (mac net.HardwareAddr) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	if l, ok := srv.leases.leaseByAddr(ip); ok {
+		return slices.Clone(l.mac())
+	}
+
+	return nil
+}
+
+// FindByClientID returns a copy of the lease of the client with the given
+// client identifier, which is the Client-identifier option value for DHCPv4
+// and the DUID for DHCPv6.
+func (srv *DHCPServer) FindByClientID(id []byte) (l *Lease, ok bool) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	l, ok = srv.leases.leaseByClientID(id)
+
+	return l.Clone(), ok
+}
+
+// IPByHost implements the [Interface] interface for *DHCPServer.  host may be
+// qualified with the local domain name and may have a trailing dot.  The
+// addresses of the DHCPv6 leases, which preferred lifetimes are over, aren't
+// returned, since the clients shouldn't use those for the new connections.
+func (srv *DHCPServer) IPByHost(host string) (ip netip.Addr) {
+	name := srv.trimLocalDomain(host)
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	if l, ok := srv.leases.leaseByName(name); ok && !l.isDeprecated(srv.now()) {
+		return l.IP
+	}
+
+	return netip.Addr{}
+}
+
+// Subscribe implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) Subscribe(ch chan<- *Event) {
+	srv.subscribers.add(ch)
+}
+
+// Unsubscribe implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) Unsubscribe(ch chan<- *Event) {
+	srv.subscribers.remove(ch)
+}
+
+// AddStaticLease implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) AddStaticLease(l *Lease) (err error) {
+	defer func() { err = errors.Annotate(err, "adding static lease: %w") }()
+
+	iface, err := srv.ifaceForAddr(l.IP)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseNoInterface, err)
+	}
+
+	err = validateStaticLease(l)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseInvalid, err)
+	}
+
+	l = l.Clone()
+	l.IsStatic = true
+	l.InterfaceName = iface.name
+	l.Vendor = srv.vendor(l.mac())
+
+	evs := []*Event{{Lease: l.Clone(), Type: EventTypeAdded}}
+	var st *dbState
+	err = srv.withLeasesLocked(func() (err error) {
+		renamed, undo := srv.yieldHostname(l)
+		err = srv.leases.add(l, iface)
+		if err != nil {
+			undo()
+
+			return newStaticLeaseErr(ErrStaticLeaseConflict, err)
+		}
+
+		if renamed != nil {
+			evs = append(evs, &Event{Lease: renamed.Clone(), Type: EventTypeRenamed})
+		}
+
+		st = srv.dbSnapshot()
+
+		return nil
+	})
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	// The change is already applied, so notify the subscribers about it even
+	// if it isn't stored.
+	err = srv.dbWrite(st)
+	srv.subscribers.notify(evs...)
+	srv.pending.remove(l.HWAddr)
+
+	return newStaticLeaseErr(ErrStaticLeaseStore, err)
+}
+
+// CheckStaticLease returns an error if l can't be added as a static lease with
+// [DHCPServer.AddStaticLease].  It performs the same validation, but doesn't
+// modify the server's state.  Same as there, the address within the range of
+// the network interface isn't rejected.
+func (srv *DHCPServer) CheckStaticLease(l *Lease) (err error) {
+	defer func() { err = errors.Annotate(err, "checking static lease: %w") }()
+
+	iface, err := srv.ifaceForAddr(l.IP)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseNoInterface, err)
+	}
+
+	err = validateStaticLease(l)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseInvalid, err)
+	}
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	// Dynamic leases yield their hostnames to static ones, see
+	// [DHCPServer.yieldHostname], so only check the hostnames of static ones.
+	if existing, ok := srv.leases.leaseByName(l.Hostname); ok && !existing.IsStatic {
+		l = l.Clone()
+		l.Hostname = ""
+	}
+
+	return newStaticLeaseErr(ErrStaticLeaseConflict, srv.leases.checkAdd(l, iface))
+}
+
+// UpdateStaticLease implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) UpdateStaticLease(old, l *Lease) (err error) {
+	defer func() { err = errors.Annotate(err, "updating static lease: %w") }()
+
+	iface, err := srv.ifaceForAddr(l.IP)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseNoInterface, err)
+	}
+
+	err = validateStaticLease(l)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseInvalid, err)
+	}
+
+	l = l.Clone()
+	l.IsStatic = true
+	l.InterfaceName = iface.name
+	l.Vendor = srv.vendor(l.mac())
+
+	evs := []*Event{{Lease: l.Clone(), Type: EventTypeUpdated}}
+	var st *dbState
+	err = srv.withLeasesLocked(func() (err error) {
+		existing, ok := srv.leases.leaseByAddr(old.IP)
+		if !ok || !existing.IsStatic {
+			return newStaticLeaseErr(
+				ErrStaticLeaseNotFound,
+				fmt.Errorf("no static lease for ip %s", old.IP),
+			)
+		}
+
+		var oldIface *netInterface
+		oldIface, err = srv.ifaceForAddr(existing.IP)
+		if err != nil {
+			return newStaticLeaseErr(ErrStaticLeaseNoInterface, err)
+		} else if oldIface != iface {
+			return newStaticLeaseErr(
+				ErrStaticLeaseInvalid,
+				fmt.Errorf("ip %s is not within the same interface as %s", l.IP, old.IP),
+			)
+		}
+
+		renamed, undo := srv.yieldHostname(l)
+		err = srv.leases.update(existing, l, iface)
+		if err != nil {
+			undo()
+
+			return newStaticLeaseErr(ErrStaticLeaseConflict, err)
+		}
+
+		evs[0].Prev = existing.Clone()
+
+		if renamed != nil {
+			evs = append(evs, &Event{Lease: renamed.Clone(), Type: EventTypeRenamed})
+		}
+
+		st = srv.dbSnapshot()
+
+		return nil
+	})
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	// The change is already applied, so notify the subscribers about it even
+	// if it isn't stored.
+	err = srv.dbWrite(st)
+	srv.subscribers.notify(evs...)
+	srv.pending.remove(l.HWAddr)
+
+	return newStaticLeaseErr(ErrStaticLeaseStore, err)
+}
+
+// RemoveStaticLease implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) RemoveStaticLease(l *Lease) (err error) {
+	defer func() { err = errors.Annotate(err, "removing static lease: %w") }()
+
+	iface, err := srv.ifaceForAddr(l.IP)
+	if err != nil {
+		return newStaticLeaseErr(ErrStaticLeaseNoInterface, err)
+	}
+
+	var removed *Lease
+	var st *dbState
+	err = srv.withLeasesLocked(func() (err error) {
+		existing, ok := srv.leases.leaseByAddr(l.IP)
+		if !ok || !existing.IsStatic {
+			return newStaticLeaseErr(
+				ErrStaticLeaseNotFound,
+				fmt.Errorf("no static lease for ip %s", l.IP),
+			)
+		}
+
+		removed = existing
+
+		err = srv.leases.remove(existing, iface)
+		if err != nil {
+			return newStaticLeaseErr(ErrStaticLeaseNotFound, err)
+		}
+
+		st = srv.dbSnapshot()
+
+		return nil
+	})
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	// The change is already applied, so notify the subscribers about it even
+	// if it isn't stored.
+	err = srv.dbWrite(st)
+	srv.subscribers.notify(&Event{Lease: removed.Clone(), Type: EventTypeRemoved})
+
+	return newStaticLeaseErr(ErrStaticLeaseStore, err)
+}
+
+// Reset implements the [Interface] interface for *DHCPServer.
+func (srv *DHCPServer) Reset() (err error) {
+	var evs []*Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeRemoved})
+
+			return true
+		})
+
+		for _, iface := range srv.interfaces4 {
+			iface.leases = map[leaseKey]*Lease{}
+		}
+
+		for _, iface := range srv.interfaces6 {
+			iface.leases = map[leaseKey]*Lease{}
+		}
+
+		srv.leases.clear()
+
+		return srv.dbSnapshot(), nil
+	})
+
+	srv.subscribers.notify(evs...)
+
+	// Don't wrap the error since it's informative enough as is.
+	return err
+}
+
+// ReplaceLeases replaces all the DHCP leases with leases, which are validated
+// against the current configuration.  If any of leases is invalid or duplicates
+// a previous one, see [DuplicateLease], the leases are left unchanged.
+func (srv *DHCPServer) ReplaceLeases(leases []*Lease) (err error) {
+	defer func() { err = errors.Annotate(err, "replacing leases: %w") }()
+
+	// Look the vendors up before locking, since the lookup may be slow.
+	clones := make([]*Lease, 0, len(leases))
+	for _, l := range leases {
+		l = l.Clone()
+		l.Vendor = srv.vendor(l.mac())
+		clones = append(clones, l)
+	}
+
+	var evs []*Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeRemoved})
+
+			return true
+		})
+
+		restore := srv.detachLeases()
+		defer func() {
+			if err != nil {
+				restore()
+			}
+		}()
+
+		for i, l := range clones {
+			if l.IsStatic {
+				err = validateStaticLease(l)
+				if err != nil {
+					return nil, fmt.Errorf("lease at index %d: %w", i, err)
+				}
+			}
+
+			err = srv.addLease(l)
+			if err != nil {
+				return nil, fmt.Errorf("lease at index %d: %w", i, err)
+			}
+
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeAdded})
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	srv.subscribers.notify(evs...)
+
+	return nil
+}
+
+// detachLeases removes all the leases from srv without persisting the change
+// and returns the function to put them back.  srv.leasesMu is expected to be
+// locked, also while calling restore.
+func (srv *DHCPServer) detachLeases() (restore func()) {
+	idx := srv.leases
+	ifaces4 := make([]map[leaseKey]*Lease, 0, len(srv.interfaces4))
+	for _, iface := range srv.interfaces4 {
+		ifaces4 = append(ifaces4, iface.leases)
+		iface.leases = map[leaseKey]*Lease{}
+	}
+
+	ifaces6 := make([]map[leaseKey]*Lease, 0, len(srv.interfaces6))
+	for _, iface := range srv.interfaces6 {
+		ifaces6 = append(ifaces6, iface.leases)
+		iface.leases = map[leaseKey]*Lease{}
+	}
+
+	srv.leases = newLeaseIndex()
+
+	return func() {
+		srv.leases = idx
+		for i, iface := range srv.interfaces4 {
+			iface.leases = ifaces4[i]
+		}
+
+		for i, iface := range srv.interfaces6 {
+			iface.leases = ifaces6[i]
+		}
+	}
+}
+
+// withLeasesLocked calls f with the leases locked for writing and returns its
+// error.
+func (srv *DHCPServer) withLeasesLocked(f func() (err error)) (err error) {
+	srv.leasesMu.Lock()
+	defer srv.leasesMu.Unlock()
+
+	return f()
+}
+
+// withLeasesStored calls f with the leases locked for writing and then writes
+// the state returned by f into the database file with the leases unlocked, see
+// [DHCPServer.dbSnapshot].  st is nil if nothing should be written.
+func (srv *DHCPServer) withLeasesStored(f func() (st *dbState, err error)) (err error) {
+	var st *dbState
+	err = srv.withLeasesLocked(func() (err error) {
+		st, err = f()
+
+		return err
+	})
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	return srv.dbWrite(st)
+}
+
+// leasesExhausted returns true if the maximum number of leases is reached, so
+// that no new dynamic leases may be granted.  The expired dynamic leases aren't
+// counted, since those are removed or reclaimed once their addresses are
+// leased again.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) leasesExhausted() (ok bool) {
+	limit := srv.conf.MaxLeases
+	if limit == 0 || uint(srv.leases.len()) < limit {
+		return false
+	}
+
+	now := srv.now()
+
+	var active uint
+	srv.leases.rangeLeases(func(l *Lease) (cont bool) {
+		if !l.isExpired4(now) && !l.isExpired6(now) {
+			active++
+		}
+
+		return active < limit
+	})
+
+	return active >= limit
+}
+
+// newExhaustedEvent records and returns the event about refusing to lease an
+// address to the client with mac on the network interface with ifaceName.
+func (srv *DHCPServer) newExhaustedEvent(
+	ifaceName string,
+	mac net.HardwareAddr,
+	clientID []byte,
+) (ev *Event) {
+	srv.recordAllocFail(ifaceName, mac, AllocFailReasonLeaseLimit)
+
+	return &Event{
+		Lease: &Lease{
+			HWAddr:        slices.Clone(mac),
+			ClientID:      slices.Clone(clientID),
+			InterfaceName: ifaceName,
+		},
+		Type: EventTypeExhausted,
+	}
+}
+
+// ifaceForAddr returns the handled network interface for ip.
+func (srv *DHCPServer) ifaceForAddr(ip netip.Addr) (iface *netInterface, err error) {
+	if ip.Is4() {
+		for _, i := range srv.interfaces4 {
+			if i.subnet.Contains(ip) {
+				return &i.netInterface, nil
+			}
+		}
+	} else {
+		for _, i := range srv.interfaces6 {
+			if i.subnet.Contains(ip) {
+				return &i.netInterface, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no interface for ip %s", ip)
+}
+
+// ifaceByName returns the handled network interface of the address family
+// with the given name.  is4 tells if the IPv4 interface is needed.  iface is
+// nil if there is no such interface.
+func (srv *DHCPServer) ifaceByName(name string, is4 bool) (iface *netInterface) {
+	if is4 {
+		for _, i := range srv.interfaces4 {
+			if i.name == name {
+				return &i.netInterface
+			}
+		}
+	} else {
+		for _, i := range srv.interfaces6 {
+			if i.name == name {
+				return &i.netInterface
+			}
+		}
+	}
+
+	return nil
+}
+
+// iface4ByName returns the handled IPv4 network interface with the given name.
+// iface is nil if there is no such interface.
+func (srv *DHCPServer) iface4ByName(name string) (iface *iface4) {
+	for _, i := range srv.interfaces4 {
+		if i.name == name {
+			return i
+		}
+	}
+
+	return nil
+}
+
+// iface6ByName returns the handled IPv6 network interface with the given name.
+// iface is nil if there is no such interface.
+func (srv *DHCPServer) iface6ByName(name string) (iface *iface6) {
+	for _, i := range srv.interfaces6 {
+		if i.name == name {
+			return i
+		}
+	}
+
+	return nil
+}
+
+// validateStaticLease returns an error if l can't be used as a static lease.
+func validateStaticLease(l *Lease) (err error) {
+	err = netutil.ValidateMAC(l.HWAddr)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	if l.Hostname != "" {
+		err = netutil.ValidateHostname(l.Hostname)
+		if err != nil {
+			// Don't wrap the error since it's informative enough as is.
+			return err
+		}
+	}
+
+	if n := utf8.RuneCountInString(l.Comment); n > MaxLeaseCommentLen {
+		return fmt.Errorf("comment length %d must not exceed %d", n, MaxLeaseCommentLen)
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_interfaces(t *testing.T) {
+	ipv6Conf := &IPv6Config{
+		Enabled:       true,
+		RangeStart:    netip.MustParseAddr("2001:db8::1"),
+		LeaseDuration: time.Hour,
+	}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+			"eth1": {
+				IPv4: &IPv4Config{Enabled: false},
+				IPv6: ipv6Conf,
+			},
+			"eth2": {
+				IPv4: &IPv4Config{Enabled: false},
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, srv.interfaces4, 1)
+	require.NotNil(t, srv.interfaces4[0])
+	assert.Equal(t, "eth0", srv.interfaces4[0].name)
+
+	require.Len(t, srv.interfaces6, 1)
+	require.NotNil(t, srv.interfaces6[0])
+	assert.Equal(t, "eth1", srv.interfaces6[0].name)
+
+	testCases := []struct {
+		conf *InterfaceConfig
+		name string
+	}{{
+		conf: nil,
+		name: "nil_interface",
+	}, {
+		conf: &InterfaceConfig{IPv4: nil, IPv6: ipv6Conf},
+		name: "nil_ipv4",
+	}, {
+		conf: &InterfaceConfig{IPv4: newTestIPv4Config(), IPv6: nil},
+		name: "nil_ipv6",
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nilSrv, newErr := New(&Config{
+				Enabled:         true,
+				LocalDomainName: "local",
+				Interfaces:      map[string]*InterfaceConfig{"eth0": tc.conf},
+			})
+			require.ErrorIs(t, newErr, errNilConfig)
+
+			assert.Nil(t, nilSrv)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// SourcePortMode defines how the server treats the messages received from
+// unexpected UDP source ports, i.e. the ones other than the well-known ports
+// of DHCP clients, servers, and relay agents.
+type SourcePortMode uint8
+
+// SourcePortMode values.
+const (
+	// SourcePortModePermissive means that such messages are counted and
+	// handled as usual, but replied to the port they're received from.  It's
+	// the default, since some relay agents use ephemeral ports.
+	SourcePortModePermissive SourcePortMode = iota
+
+	// SourcePortModeStrict means that such messages are counted and dropped.
+	SourcePortModeStrict
+)
+
+// String implements the [fmt.Stringer] interface for SourcePortMode.
+func (m SourcePortMode) String() (s string) {
+	switch m {
+	case SourcePortModePermissive:
+		return "permissive"
+	case SourcePortModeStrict:
+		return "strict"
+	default:
+		return fmt.Sprintf("!bad_source_port_mode_%d", m)
+	}
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for
+// SourcePortMode.
+func (m SourcePortMode) MarshalText() (text []byte, err error) {
+	if m > SourcePortModeStrict {
+		return nil, fmt.Errorf("bad source port mode %d", m)
+	}
+
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *SourcePortMode.
+func (m *SourcePortMode) UnmarshalText(text []byte) (err error) {
+	switch s := string(text); s {
+	case "permissive":
+		*m = SourcePortModePermissive
+	case "strict":
+		*m = SourcePortModeStrict
+	default:
+		return fmt.Errorf("source port mode %q must be either permissive or strict", s)
+	}
+
+	return nil
+}
+
+// expectedSourcePort returns true if port is a well-known source port of the
+// DHCP messages of the address family defined by is4.  The messages of clients
+// come from the client port, and the ones of relay agents and other servers
+// come from the server port.  The DHCPv4 ports are the configured ones, see
+// [Config.ServerPort] and [Config.ClientPort].
+func (srv *DHCPServer) expectedSourcePort(port int, is4 bool) (ok bool) {
+	if is4 {
+		server, client := srv.conf.ports4()
+
+		return port == int(client) || port == int(server)
+	}
+
+	return port == clientPort6 || port == serverPort6
+}
+
+// checkSourcePort counts the message received on iface from the unexpected
+// source port and returns false if it should be dropped according to the
+// configured [SourcePortMode].  is4 tells the address family of the message.
+// unexpected is true if the message came from an unexpected source port, so
+// that the reply should be sent to that port.
+func (srv *DHCPServer) checkSourcePort(
+	iface *netInterface,
+	from net.Addr,
+	is4 bool,
+) (unexpected, ok bool) {
+	udpAddr, isUDP := from.(*net.UDPAddr)
+	if !isUDP || srv.expectedSourcePort(udpAddr.Port, is4) {
+		return false, true
+	}
+
+	iface.unexpectedPorts.Add(1)
+
+	if srv.conf.SourcePortMode == SourcePortModeStrict {
+		log.Debug("dhcpsvc: interface %q: dropping message from port %d", iface.name, udpAddr.Port)
+
+		return true, false
+	}
+
+	return true, true
+}
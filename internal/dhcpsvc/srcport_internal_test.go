@@ -0,0 +1,106 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serializeTestMsg returns the wire form of msg.
+func serializeTestMsg(t *testing.T, msg gopacket.SerializableLayer) (data []byte) {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg)
+	require.NoError(t, err)
+
+	return buf.Bytes()
+}
+
+func TestDHCPServer_newMsgHandler4_sourcePort(t *testing.T) {
+	const ephemeralPort = 40_000
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	data := serializeTestMsg(t, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+
+	testCases := []struct {
+		wantTo   net.Addr
+		name     string
+		fromPort int
+		mode     SourcePortMode
+		wantCnt  uint64
+	}{{
+		wantTo:   &net.UDPAddr{IP: net.IPv4bcast, Port: clientPort4},
+		name:     "permissive_client_port",
+		fromPort: clientPort4,
+		mode:     SourcePortModePermissive,
+		wantCnt:  0,
+	}, {
+		wantTo:   &net.UDPAddr{IP: net.IPv4bcast, Port: ephemeralPort},
+		name:     "permissive_ephemeral_port",
+		fromPort: ephemeralPort,
+		mode:     SourcePortModePermissive,
+		wantCnt:  1,
+	}, {
+		wantTo:   &net.UDPAddr{IP: net.IPv4bcast, Port: clientPort4},
+		name:     "strict_client_port",
+		fromPort: clientPort4,
+		mode:     SourcePortModeStrict,
+		wantCnt:  0,
+	}, {
+		wantTo:   nil,
+		name:     "strict_ephemeral_port",
+		fromPort: ephemeralPort,
+		mode:     SourcePortModeStrict,
+		wantCnt:  1,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer4(t, newTestIPv4Config())
+			srv.conf.SourcePortMode = tc.mode
+
+			handle := srv.newMsgHandler4(srv.interfaces4[0])
+			from := &net.UDPAddr{IP: net.IPv4zero, Port: tc.fromPort}
+
+			to, err := handle(data, from, gopacket.NewSerializeBuffer())
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantTo, to)
+			assert.Equal(t, &FamilyCounters{IPv4: tc.wantCnt}, srv.Stats().UnexpectedSourcePort["eth0"])
+		})
+	}
+}
+
+func TestDHCPServer_newMsgHandler6_sourcePort(t *testing.T) {
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	data := serializeTestMsg(t, newTestRequest6(layers.DHCPv6MsgTypeSolicit, duid, 1))
+
+	from := &net.UDPAddr{IP: net.ParseIP("fe80::2"), Port: 40_000, Zone: "eth0"}
+
+	t.Run("permissive", func(t *testing.T) {
+		srv := newTestServer6(t, netip.MustParseAddr("2001:db8::1"))
+
+		to, err := srv.newMsgHandler6(srv.interfaces6[0])(data, from, gopacket.NewSerializeBuffer())
+		require.NoError(t, err)
+
+		assert.Equal(t, from, to)
+		assert.Equal(t, &FamilyCounters{IPv6: 1}, srv.Stats().UnexpectedSourcePort["eth0"])
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		srv := newTestServer6(t, netip.MustParseAddr("2001:db8::1"))
+		srv.conf.SourcePortMode = SourcePortModeStrict
+
+		to, err := srv.newMsgHandler6(srv.interfaces6[0])(data, from, gopacket.NewSerializeBuffer())
+		require.NoError(t, err)
+
+		assert.Nil(t, to)
+		assert.Equal(t, &FamilyCounters{IPv6: 1}, srv.Stats().UnexpectedSourcePort["eth0"])
+	})
+}
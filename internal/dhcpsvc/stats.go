@@ -0,0 +1,145 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// Stats are the statistics of the DHCP server.
+type Stats struct {
+	// WrongFamily are the numbers of messages received on the network
+	// interfaces, which have the address family of these messages disabled.
+	// The keys are the names of the network interfaces.
+	WrongFamily map[string]*FamilyCounters
+
+	// UnexpectedSourcePort are the numbers of messages received on the
+	// network interfaces from unexpected UDP source ports, see
+	// [SourcePortMode].  The keys are the names of the network interfaces.
+	UnexpectedSourcePort map[string]*FamilyCounters
+
+	// AllocFailures are the numbers of failures to allocate an address for a
+	// client by reason.
+	AllocFailures map[AllocFailReason]uint64
+
+	// HostnameSpoofs is the number of attempts of clients to claim the
+	// reserved hostnames or the ones bound to other clients, see
+	// [Config.ReservedHostnames].
+	HostnameSpoofs uint64
+
+	// ProbeFallbacks is the number of addresses offered without probing, since
+	// the probe budget has been exceeded, see [Config.ProbeBudget].
+	ProbeFallbacks uint64
+}
+
+// AllocFailReason is the reason for the server to fail allocating an address
+// for a client.
+type AllocFailReason uint8
+
+// AllocFailReason values.
+const (
+	// AllocFailReasonPoolExhausted means that there are no free addresses
+	// within the range of the network interface.
+	AllocFailReasonPoolExhausted AllocFailReason = iota + 1
+
+	// AllocFailReasonLeaseLimit means that the maximum number of leases is
+	// reached, see [Config.MaxLeases].
+	AllocFailReasonLeaseLimit
+)
+
+// String implements the [fmt.Stringer] interface for AllocFailReason.
+func (r AllocFailReason) String() (s string) {
+	switch r {
+	case AllocFailReasonPoolExhausted:
+		return "pool exhausted"
+	case AllocFailReasonLeaseLimit:
+		return "lease limit reached"
+	default:
+		return fmt.Sprintf("!bad_alloc_fail_reason_%d", r)
+	}
+}
+
+// allocFailCounters are the counters of allocation failures indexed by
+// [AllocFailReason].
+type allocFailCounters [AllocFailReasonLeaseLimit + 1]atomic.Uint64
+
+// recordAllocFail counts and logs the failure to allocate an address for the
+// client with mac on the network interface with ifaceName.
+func (srv *DHCPServer) recordAllocFail(ifaceName string, mac net.HardwareAddr, reason AllocFailReason) {
+	srv.allocFails[reason].Add(1)
+
+	log.Info(
+		"dhcpsvc: warning: interface %q: can't allocate address for %s: %s",
+		ifaceName,
+		mac,
+		reason,
+	)
+}
+
+// allocFailReason returns the reason for the server to have no address to
+// allocate.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) allocFailReason() (reason AllocFailReason) {
+	if srv.leasesExhausted() {
+		return AllocFailReasonLeaseLimit
+	}
+
+	return AllocFailReasonPoolExhausted
+}
+
+// FamilyCounters are the counters of a network interface for each address
+// family.
+type FamilyCounters struct {
+	// IPv4 is the counter for DHCPv4.
+	IPv4 uint64
+
+	// IPv6 is the counter for DHCPv6.
+	IPv6 uint64
+}
+
+// Stats returns the current statistics of srv.
+func (srv *DHCPServer) Stats() (s *Stats) {
+	s = &Stats{
+		WrongFamily:          make(map[string]*FamilyCounters, len(srv.wrongFamily)),
+		UnexpectedSourcePort: make(map[string]*FamilyCounters, len(srv.interfaces4)+len(srv.interfaces6)),
+		AllocFailures:        make(map[AllocFailReason]uint64, len(srv.allocFails)-1),
+		HostnameSpoofs:       srv.hostnameSpoofs.Load(),
+		ProbeFallbacks:       srv.probeFallbacks.Load(),
+	}
+
+	for r := AllocFailReasonPoolExhausted; int(r) < len(srv.allocFails); r++ {
+		s.AllocFailures[r] = srv.allocFails[r].Load()
+	}
+
+	for _, iface := range srv.interfaces4 {
+		familyCounters(s.UnexpectedSourcePort, iface.name).IPv4 = iface.unexpectedPorts.Load()
+	}
+
+	for _, iface := range srv.interfaces6 {
+		familyCounters(s.UnexpectedSourcePort, iface.name).IPv6 = iface.unexpectedPorts.Load()
+	}
+
+	for _, iface := range srv.wrongFamily {
+		c := familyCounters(s.WrongFamily, iface.name)
+		if iface.is4 {
+			c.IPv4 = iface.received.Load()
+		} else {
+			c.IPv6 = iface.received.Load()
+		}
+	}
+
+	return s
+}
+
+// familyCounters returns the counters of the network interface named ifaceName
+// from m, adding new ones if there are none.
+func familyCounters(m map[string]*FamilyCounters, ifaceName string) (c *FamilyCounters) {
+	c, ok := m[ifaceName]
+	if !ok {
+		c = &FamilyCounters{}
+		m[ifaceName] = c
+	}
+
+	return c
+}
@@ -0,0 +1,154 @@
+package dhcpsvc
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Status is the current state of the DHCP server.
+//
+// TODO(e.burkov):  Add to the HTTP status JSON once the HTTP API uses the
+// service.
+type Status struct {
+	// StartTime is the time the server has been started.  It's zero if the
+	// server isn't running.
+	StartTime time.Time
+
+	// Interfaces are the states of the served network interfaces sorted by
+	// name.
+	Interfaces []*InterfaceStatus
+}
+
+// InterfaceStatus is the current state of a single network interface served
+// by the DHCP server.
+type InterfaceStatus struct {
+	// IPv4 is the state of DHCPv4 on the interface.  It's nil if DHCPv4 is
+	// disabled on it.
+	IPv4 *FamilyStatus
+
+	// IPv6 is the state of DHCPv6 on the interface.  It's nil if DHCPv6 is
+	// disabled on it.
+	IPv6 *FamilyStatus
+
+	// Name is the name of the network interface.
+	Name string
+}
+
+// FamilyStatus is the current state of DHCP for a single address family on a
+// network interface.
+type FamilyStatus struct {
+	// ForeignServer is the most recently seen other DHCP server replying to
+	// the clients on the interface.  It's nil if none has been seen.  It's
+	// only detected for DHCPv4.
+	ForeignServer *ForeignServer
+
+	// BoundAt is the time the connection serving the interface has been
+	// opened.  It's reset on each rebind and zero if the interface isn't
+	// served.
+	BoundAt time.Time
+
+	// DynamicLeases is the number of dynamic leases granted on the interface,
+	// excluding the deprecated ones.
+	DynamicLeases int
+
+	// DeprecatedLeases is the number of dynamic leases on the interface, which
+	// addresses are no longer within its range.  Those are kept until they
+	// expire, but aren't renewed.
+	DeprecatedLeases int
+
+	// StaticLeases is the number of static leases attributed to the interface.
+	StaticLeases int
+
+	// FreeAddrs is the number of addresses within the range of the interface
+	// available for allocation.  The addresses offered to the clients aren't
+	// available until the offers expire, see [Config.OfferTimeout].
+	FreeAddrs uint64
+
+	// Rebinds is the number of times the connection serving the interface has
+	// been reopened, see [DHCPServer.Rebind].
+	Rebinds uint
+
+	// Paused is true if serving the interface is paused, see
+	// [DHCPServer.DisableInterface].  BoundAt is zero then, but the leases
+	// are still counted.
+	Paused bool
+}
+
+// newFamilyStatus returns a new status of DHCP on iface with its connection
+// state.  [DHCPServer.connsMu] is expected to be locked.
+func newFamilyStatus(iface *netInterface) (s *FamilyStatus) {
+	return &FamilyStatus{
+		BoundAt: iface.boundAt,
+		Rebinds: iface.rebinds,
+		Paused:  iface.paused,
+	}
+}
+
+// countAll accounts the leases of iface in s.  [DHCPServer.leasesMu] is
+// expected to be locked.
+func (s *FamilyStatus) countAll(iface *netInterface) {
+	for _, l := range iface.leases {
+		switch {
+		case l.IsStatic:
+			s.StaticLeases++
+		case iface.isDeprecated(l):
+			s.DeprecatedLeases++
+		default:
+			s.DynamicLeases++
+		}
+	}
+}
+
+// Status returns the current state of srv.  The leases are counted by the
+// network interfaces they have been granted on.
+func (srv *DHCPServer) Status() (s *Status) {
+	s = &Status{}
+	statuses := map[string]*InterfaceStatus{}
+	ifaceStatus := func(name string) (is *InterfaceStatus) {
+		is, ok := statuses[name]
+		if !ok {
+			is = &InterfaceStatus{Name: name}
+			statuses[name] = is
+			s.Interfaces = append(s.Interfaces, is)
+		}
+
+		return is
+	}
+
+	srv.connsMu.Lock()
+	s.StartTime = srv.startTime
+	for _, iface := range srv.interfaces4 {
+		fs := newFamilyStatus(&iface.netInterface)
+		fs.ForeignServer = iface.foreign.latest()
+		ifaceStatus(iface.name).IPv4 = fs
+	}
+
+	for _, iface := range srv.interfaces6 {
+		ifaceStatus(iface.name).IPv6 = newFamilyStatus(&iface.netInterface)
+	}
+	srv.connsMu.Unlock()
+
+	slices.SortFunc(s.Interfaces, func(a, b *InterfaceStatus) (res int) {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	for _, iface := range srv.interfaces4 {
+		fs := statuses[iface.name].IPv4
+		fs.countAll(&iface.netInterface)
+		fs.FreeAddrs = srv.freeAddrs(&iface.netInterface, iface.gateway, iface.offers)
+	}
+
+	for _, iface := range srv.interfaces6 {
+		fs := statuses[iface.name].IPv6
+		fs.countAll(&iface.netInterface)
+		fs.FreeAddrs = srv.freeAddrs(&iface.netInterface, netip.Addr{}, nil)
+	}
+
+	return s
+}
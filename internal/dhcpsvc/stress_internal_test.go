@@ -0,0 +1,167 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stressDuration is the duration of the concurrent access stress test.
+const stressDuration = 2 * time.Second
+
+// stressClients is the number of distinct clients in the stress test.
+const stressClients = 32
+
+// newStressMAC returns the hardware address of the i-th client of the stress
+// test.
+func newStressMAC(i int) (mac net.HardwareAddr) {
+	return net.HardwareAddr{0x2, 0x0, 0x0, 0x0, 0x0, byte(i % stressClients)}
+}
+
+// newStressConfig returns the configuration for the stress test with the given
+// lease duration.
+func newStressConfig(dbFilePath string, l Listener, ttl time.Duration) (conf *Config) {
+	v4Conf := newTestIPv4Config()
+	v4Conf.LeaseDuration = ttl
+
+	return &Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		DBFilePath:      dbFilePath,
+		Listener:        l,
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: v4Conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	}
+}
+
+// TestDHCPServer_concurrency drives all the public entry points of the server
+// concurrently.  It's intended to be run with the race detector enabled.
+func TestDHCPServer_concurrency(t *testing.T) {
+	reads := make(chan *testRead)
+	dbFilePath := filepath.Join(t.TempDir(), "leases.json")
+	l := newTestListener(reads, nil)
+
+	srv, err := New(newStressConfig(dbFilePath, l, time.Hour))
+	require.NoError(t, err)
+
+	startTestServer(t, srv)
+
+	evs := make(chan *Event, stressClients)
+	srv.Subscribe(evs)
+	t.Cleanup(func() { srv.Unsubscribe(evs) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), stressDuration)
+	defer cancel()
+
+	wg := &sync.WaitGroup{}
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := 0; ctx.Err() == nil; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	from := &net.UDPAddr{IP: net.IPv4bcast, Port: clientPort4}
+	run(func(i int) {
+		typ := layers.DHCPMsgTypeDiscover
+		if i%2 == 1 {
+			typ = layers.DHCPMsgTypeRequest
+		}
+
+		buf := gopacket.NewSerializeBuffer()
+		serErr := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, newTestRequest4(
+			newStressMAC(i/2),
+			typ,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 0, byte(2 + i/2%stressClients)}),
+		))
+		if !assert.NoError(t, serErr) {
+			return
+		}
+
+		select {
+		case reads <- &testRead{from: from, data: buf.Bytes()}:
+		case <-ctx.Done():
+		}
+	})
+
+	run(func(i int) {
+		// The errors are expected here, since the requested addresses may be
+		// taken by the static leases added below.
+		_, _ = srv.handle4("eth0", newTestRequest4(
+			newStressMAC(i),
+			layers.DHCPMsgTypeRequest,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, []byte{192, 168, 0, byte(100 + i%stressClients)}),
+		))
+	})
+
+	run(func(i int) {
+		ip := netip.AddrFrom4([4]byte{192, 168, 0, byte(100 + i%stressClients)})
+
+		_ = srv.Leases()
+		_ = srv.HostByIP(ip)
+		_ = srv.MACByIP(ip)
+		_ = srv.Status()
+	})
+
+	run(func(i int) {
+		sl := newHealthTestLease(byte(i % stressClients))
+
+		// Conflicts with the dynamic leases are expected here.
+		if srv.AddStaticLease(sl) == nil {
+			assert.NoError(t, srv.RemoveStaticLease(sl))
+		}
+	})
+
+	run(func(i int) {
+		ttl := time.Hour
+		if i%2 == 1 {
+			ttl = time.Minute
+		}
+
+		assert.NoError(t, srv.UpdateConfig(newStressConfig(dbFilePath, l, ttl)))
+	})
+
+	run(func(_ int) {
+		for {
+			select {
+			case <-evs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	run(func(_ int) {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+		defer shutdownCancel()
+
+		assert.NoError(t, srv.Shutdown(shutdownCtx))
+		assert.NoError(t, srv.Start())
+	})
+
+	run(func(_ int) {
+		// The errors are expected here, since the server may be shut down at
+		// the moment.
+		_ = srv.Rebind(ctx, "eth0")
+		_ = srv.ForceRenew(ctx, "eth0")
+	})
+
+	wg.Wait()
+}
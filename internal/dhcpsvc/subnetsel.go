@@ -0,0 +1,62 @@
+package dhcpsvc
+
+import (
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// dhcpOptSubnetSelection is the Subnet Selection option, which contains an
+// address of the subnet the client should be leased an address from.
+//
+// See https://datatracker.ietf.org/doc/html/rfc3011.
+const dhcpOptSubnetSelection layers.DHCPOpt = 118
+
+// selectIface4 returns the IPv4 network interface to serve req received on
+// iface.  If req contains the Subnet Selection option, the interface, which
+// subnet contains the selected address, is returned regardless of the relay
+// agent address, and selected is true.  sel is nil if the option is malformed
+// or the selected subnet isn't served, so that req should be dropped.
+// Otherwise, sel is iface.
+func (srv *DHCPServer) selectIface4(
+	iface *iface4,
+	req *layers.DHCPv4,
+) (sel *iface4, selected bool) {
+	if optData4(req, dhcpOptSubnetSelection) == nil {
+		return iface, false
+	}
+
+	subnetIP := optIP4(req, dhcpOptSubnetSelection)
+	if !subnetIP.IsValid() {
+		log.Debug("dhcpsvc: interface %q: bad subnet selection, dropping message", iface.name)
+
+		return nil, true
+	}
+
+	for _, i := range srv.interfaces4 {
+		if i.subnet.Contains(subnetIP) {
+			return i, true
+		}
+	}
+
+	log.Debug(
+		"dhcpsvc: interface %q: selected subnet of %s is not served, dropping message",
+		iface.name,
+		subnetIP,
+	)
+
+	return nil, true
+}
+
+// echoSubnetSelection4 adds the copy of the Subnet Selection option of req to
+// resp, if there is one.
+//
+// See https://datatracker.ietf.org/doc/html/rfc3011#section-3.
+func echoSubnetSelection4(resp, req *layers.DHCPv4) {
+	data := optData4(req, dhcpOptSubnetSelection)
+	if data == nil {
+		return
+	}
+
+	setOpt4(resp, layers.NewDHCPOption(dhcpOptSubnetSelection, slices.Clone(data)))
+}
@@ -0,0 +1,103 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_handle4_subnetSelection(t *testing.T) {
+	conf1 := &IPv4Config{
+		Enabled:       true,
+		GatewayIP:     netip.MustParseAddr("172.16.0.1"),
+		SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+		RangeStart:    netip.MustParseAddr("172.16.0.2"),
+		RangeEnd:      netip.MustParseAddr("172.16.0.254"),
+		LeaseDuration: 1 * time.Hour,
+	}
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+			"eth1": {
+				IPv4: conf1,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	relayIP := netip.MustParseAddr("192.168.0.10")
+	subnet1 := netip.MustParsePrefix("172.16.0.0/24")
+
+	newSelecting := func(typ layers.DHCPMsgType, data []byte, opts ...layers.DHCPOption) (req *layers.DHCPv4) {
+		opts = append(opts, layers.NewDHCPOption(dhcpOptSubnetSelection, data))
+		req = newTestRequest4(mac, typ, opts...)
+		req.RelayAgentIP = relayIP.AsSlice()
+
+		return req
+	}
+
+	t.Run("selected", func(t *testing.T) {
+		selData := []byte{172, 16, 0, 0}
+
+		offer, handleErr := srv.handle4("eth0", newSelecting(layers.DHCPMsgTypeDiscover, selData))
+		require.NoError(t, handleErr)
+		require.NotNil(t, offer)
+
+		offered, ok := netip.AddrFromSlice(offer.YourClientIP.To4())
+		require.True(t, ok)
+
+		assert.True(t, subnet1.Contains(offered))
+		assert.Equal(t, selData, optData4(offer, dhcpOptSubnetSelection))
+		assert.Equal(t, conf1.GatewayIP, optIP4(offer, layers.DHCPOptServerID))
+
+		ack, handleErr := srv.handle4("eth0", newSelecting(
+			layers.DHCPMsgTypeRequest,
+			selData,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, offered.AsSlice()),
+		))
+		require.NoError(t, handleErr)
+		require.NotNil(t, ack)
+
+		assert.Equal(t, layers.DHCPMsgTypeAck, msgType4(ack))
+		assert.Equal(t, selData, optData4(ack, dhcpOptSubnetSelection))
+
+		leases := srv.Leases()
+		require.Len(t, leases, 1)
+
+		assert.Equal(t, offered, leases[0].IP)
+		assert.Equal(t, "eth1", leases[0].InterfaceName)
+	})
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{{
+		name: "not_served",
+		data: []byte{10, 0, 0, 0},
+	}, {
+		name: "malformed",
+		data: []byte{172, 16, 0},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, handleErr := srv.handle4("eth0", newSelecting(layers.DHCPMsgTypeDiscover, tc.data))
+			require.NoError(t, handleErr)
+
+			assert.Nil(t, resp)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package dhcpsvc
+
+import (
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// sweepInterval is the interval between the sweeps of the expired leases.
+const sweepInterval = 1 * time.Minute
+
+// startSweeper starts the goroutine removing the expired DHCPv6 leases and
+// reporting the expired DHCPv4 ones, unless it's already running, so that
+// those aren't kept until a message is received on the network interface.
+// srv.connsMu is expected to be locked.
+func (srv *DHCPServer) startSweeper() {
+	if srv.sweeperStop != nil {
+		return
+	}
+
+	srv.sweeperStop = make(chan struct{})
+	srv.sweeperAlive.Store(true)
+
+	srv.wg.Add(1)
+	go srv.runSweeper(srv.sweeperStop, srv.sweepInterval(), srv.sweep)
+}
+
+// minShortSweepInterval is the minimum interval between the sweeps when
+// [Config.AllowShortLeases] is set.
+const minShortSweepInterval = 10 * time.Millisecond
+
+// sweepInterval returns the interval between the sweeps of the expired leases.
+// If srv allows short leases, the interval is shortened to the half of the
+// shortest lease duration, so that the expiry is observed timely.
+func (srv *DHCPServer) sweepInterval() (d time.Duration) {
+	d = sweepInterval
+	if !srv.conf.AllowShortLeases {
+		return d
+	}
+
+	if half := srv.conf.shortestLeaseDuration() / 2; half < d {
+		d = half
+	}
+
+	if d < minShortSweepInterval {
+		d = minShortSweepInterval
+	}
+
+	return d
+}
+
+// stopSweeper stops the goroutine started by [DHCPServer.startSweeper], if
+// any.  srv.connsMu is expected to be locked.
+func (srv *DHCPServer) stopSweeper() {
+	if srv.sweeperStop == nil {
+		return
+	}
+
+	close(srv.sweeperStop)
+	srv.sweeperStop = nil
+}
+
+// runSweeper calls sweep every interval until stop is closed.  It's intended to
+// be used as a goroutine.  srv.sweeperAlive is reset once it returns, including
+// the case of a panic in sweep.
+func (srv *DHCPServer) runSweeper(stop <-chan struct{}, interval time.Duration, sweep func()) {
+	defer srv.wg.Done()
+	defer srv.sweeperAlive.Store(false)
+	defer log.OnPanic("dhcpsvc: sweeping leases")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sweep()
+		}
+	}
+}
+
+// sweep removes the expired DHCPv6 leases and reports the expired DHCPv4 ones
+// of all the served network interfaces.  It also writes the changes deferred
+// since the previous sweep into the database file.
+func (srv *DHCPServer) sweep() {
+	for _, iface := range srv.interfaces4 {
+		srv.reportExpired4(iface)
+	}
+
+	for _, iface := range srv.interfaces6 {
+		err := srv.expireLeases6(iface)
+		if err != nil {
+			log.Error("dhcpsvc: interface %q: %s", iface.name, err)
+		}
+	}
+
+	srv.storeDirty()
+}
+
+// storeDirty writes the leases into the database file, if those have changed
+// without being written, e.g. renewed without being reported to the
+// subscribers, see [Config.RenewalUpdateInterval].  So that such changes are
+// lost on crash only if made within the last [sweepInterval].
+func (srv *DHCPServer) storeDirty() {
+	err := srv.withLeasesStored(func() (st *dbState, err error) {
+		if !srv.dbDirty {
+			return nil, nil
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		log.Error("dhcpsvc: storing renewed leases: %s", err)
+	}
+}
@@ -0,0 +1,57 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_sweep_shortLease(t *testing.T) {
+	const leaseDuration = 200 * time.Millisecond
+
+	conf := newTestIPv4Config()
+	conf.LeaseDuration = leaseDuration
+
+	srv, err := New(&Config{
+		Enabled:          true,
+		LocalDomainName:  "local",
+		Listener:         newTestListener(nil, nil),
+		AllowShortLeases: true,
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: conf,
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, leaseDuration/2, srv.sweepInterval())
+
+	ch := make(chan *Event, 8)
+	srv.Subscribe(ch)
+	t.Cleanup(func() { srv.Unsubscribe(ch) })
+
+	startTestServer(t, srv)
+
+	ip := netip.MustParseAddr("192.168.0.2")
+	requestLease4(t, srv, net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}, ip, "")
+
+	timeout := time.After(10 * leaseDuration)
+	for {
+		select {
+		case ev := <-ch:
+			if ev.Type == EventTypeExpired {
+				assert.Equal(t, ip, ev.Lease.IP)
+
+				return
+			}
+		case <-timeout:
+			t.Fatal("lease isn't expired")
+		}
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package dhcpsvc
+
+import (
+	"os"
+
+	"github.com/AdguardTeam/golibs/errors"
+)
+
+// syncDir commits the entries of the directory to stable storage.
+func syncDir(dir string) (err error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return err
+	}
+
+	return errors.WithDeferred(d.Sync(), d.Close())
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package dhcpsvc
+
+// syncDir commits the entries of the directory to stable storage.  Windows
+// doesn't support syncing directories, but commits renames itself, so it does
+// nothing.
+func syncDir(_ string) (err error) {
+	return nil
+}
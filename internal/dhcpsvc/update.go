@@ -0,0 +1,247 @@
+package dhcpsvc
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/AdguardTeam/golibs/log"
+)
+
+// ExpiryPolicy defines how the expiration times of the existing dynamic leases
+// are treated when the lease duration of the network interface is changed.
+type ExpiryPolicy uint8
+
+// ExpiryPolicy values.
+const (
+	// ExpiryPolicyCap means that the expiration times are capped at the
+	// current time plus the new lease duration, so that the change propagates
+	// to the clients at the next renewal.
+	ExpiryPolicyCap ExpiryPolicy = iota
+
+	// ExpiryPolicyKeep means that the expiration times are left untouched, so
+	// that the change only applies to the leases granted or renewed after it.
+	ExpiryPolicyKeep
+)
+
+// String implements the [fmt.Stringer] interface for ExpiryPolicy.
+func (p ExpiryPolicy) String() (s string) {
+	switch p {
+	case ExpiryPolicyCap:
+		return "cap"
+	case ExpiryPolicyKeep:
+		return "keep"
+	default:
+		return fmt.Sprintf("!bad_expiry_policy_%d", p)
+	}
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for
+// ExpiryPolicy.
+func (p ExpiryPolicy) MarshalText() (text []byte, err error) {
+	if p > ExpiryPolicyKeep {
+		return nil, fmt.Errorf("bad expiry policy %d", p)
+	}
+
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *ExpiryPolicy.
+func (p *ExpiryPolicy) UnmarshalText(text []byte) (err error) {
+	switch s := string(text); s {
+	case "cap":
+		*p = ExpiryPolicyCap
+	case "keep":
+		*p = ExpiryPolicyKeep
+	default:
+		return fmt.Errorf("expiry policy %q must be either cap or keep", s)
+	}
+
+	return nil
+}
+
+// UpdateConfig applies the lease durations and the address ranges of the
+// network interfaces from conf to srv.  The existing dynamic leases are treated
+// according to conf.ExpiryPolicy, and the subscribers are notified about the
+// updated ones at once.  The dynamic leases left outside the shrunk ranges
+// become deprecated, see [EventTypeDeprecated].  conf must serve the same
+// network interfaces, address families, and subnets as srv, its other
+// properties are ignored.  conf must not be modified after calling
+// UpdateConfig.
+//
+// TODO(e.burkov):  Apply the rest of conf.
+func (srv *DHCPServer) UpdateConfig(conf *Config) (err error) {
+	defer func() { err = errors.Annotate(err, "updating config: %w") }()
+
+	err = conf.Validate()
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	ups4, ups6, err := srv.newIfaceUpdates(conf)
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	var capped, deprecated []*Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		now := srv.now()
+		for i, iface := range srv.interfaces4 {
+			iface.leaseTTL = ups4[i].leaseTTL
+			capped = srv.capExpiries(capped, &iface.netInterface, conf.ExpiryPolicy, now)
+			deprecated = iface.setAddrSpace(deprecated, ups4[i].addrSpace)
+		}
+
+		for i, iface := range srv.interfaces6 {
+			iface.leaseTTL = ups6[i].leaseTTL
+			capped = srv.capExpiries(capped, &iface.netInterface, conf.ExpiryPolicy, now)
+			deprecated = iface.setAddrSpace(deprecated, ups6[i].addrSpace)
+		}
+
+		if len(capped) == 0 {
+			return nil, nil
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		// Don't wrap the error since there is already an annotation deferred.
+		return err
+	}
+
+	if len(capped) > 0 {
+		log.Info("dhcpsvc: capped expiration times of %d leases", len(capped))
+	}
+
+	if len(deprecated) > 0 {
+		log.Info("dhcpsvc: deprecated %d leases outside the new ranges", len(deprecated))
+	}
+
+	srv.subscribers.notify(append(capped, deprecated...)...)
+
+	return nil
+}
+
+// ifaceUpdate is the part of the configuration of a network interface applied
+// by [DHCPServer.UpdateConfig].
+type ifaceUpdate struct {
+	// addrSpace is the new address space allocated for leasing.
+	addrSpace ipRange
+
+	// leaseTTL is the new lease duration.
+	leaseTTL time.Duration
+}
+
+// newIfaceUpdates returns the updates from conf for each of srv.interfaces4 and
+// srv.interfaces6 respectively.  It returns an error if conf doesn't serve any
+// of those or changes their subnets.  conf must be valid.
+func (srv *DHCPServer) newIfaceUpdates(conf *Config) (ups4, ups6 []*ifaceUpdate, err error) {
+	if !conf.Enabled {
+		return nil, nil, errors.Error("disabling the server is not supported")
+	}
+
+	ups4 = make([]*ifaceUpdate, 0, len(srv.interfaces4))
+	for _, iface := range srv.interfaces4 {
+		ic := conf.Interfaces[iface.name]
+		if ic == nil || !ic.IPv4.Enabled {
+			return nil, nil, fmt.Errorf("interface %q: ipv4 must be enabled", iface.name)
+		}
+
+		var up *ifaceUpdate
+		up, err = newIfaceUpdate(&iface.netInterface, ic.IPv4.addrSpace, ic.IPv4.LeaseDuration)
+		if err != nil {
+			return nil, nil, fmt.Errorf("interface %q: ipv4: %w", iface.name, err)
+		}
+
+		ups4 = append(ups4, up)
+	}
+
+	ups6 = make([]*ifaceUpdate, 0, len(srv.interfaces6))
+	for _, iface := range srv.interfaces6 {
+		ic := conf.Interfaces[iface.name]
+		if ic == nil || !ic.IPv6.Enabled {
+			return nil, nil, fmt.Errorf("interface %q: ipv6 must be enabled", iface.name)
+		}
+
+		var up *ifaceUpdate
+		up, err = newIfaceUpdate(&iface.netInterface, ic.IPv6.addrSpace, ic.IPv6.LeaseDuration)
+		if err != nil {
+			return nil, nil, fmt.Errorf("interface %q: ipv6: %w", iface.name, err)
+		}
+
+		ups6 = append(ups6, up)
+	}
+
+	return ups4, ups6, nil
+}
+
+// newIfaceUpdate returns the update of iface with the address space returned by
+// addrSpace and the lease duration ttl.  It returns an error if the address
+// space can't be used or the subnet differs from the one of iface.
+func newIfaceUpdate(
+	iface *netInterface,
+	addrSpace func() (subnet netip.Prefix, r ipRange, err error),
+	ttl time.Duration,
+) (up *ifaceUpdate, err error) {
+	subnet, r, err := addrSpace()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	} else if subnet != iface.subnet {
+		return nil, fmt.Errorf("changing subnet %s to %s is not supported", iface.subnet, subnet)
+	}
+
+	return &ifaceUpdate{
+		addrSpace: r,
+		leaseTTL:  ttl,
+	}, nil
+}
+
+// setAddrSpace sets the address space of iface to r.  It appends the events
+// about the dynamic leases, which have become deprecated, to evs and returns
+// the result.  [DHCPServer.leasesMu] is expected to be locked.
+func (iface *netInterface) setAddrSpace(evs []*Event, r ipRange) (res []*Event) {
+	prev := iface.addrSpace
+	iface.addrSpace = r
+	for _, l := range iface.leases {
+		if iface.isDeprecated(l) && prev.contains(l.IP) {
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeDeprecated})
+		}
+	}
+
+	return evs
+}
+
+// capExpiries caps the expiration times of the dynamic leases of iface at now
+// plus its lease duration, unless policy says otherwise.  It appends the events
+// about the updated leases to evs and returns the result.  srv.leasesMu is
+// expected to be locked.
+func (srv *DHCPServer) capExpiries(
+	evs []*Event,
+	iface *netInterface,
+	policy ExpiryPolicy,
+	now time.Time,
+) (res []*Event) {
+	if policy == ExpiryPolicyKeep {
+		return evs
+	}
+
+	maxExpiry := now.Add(iface.leaseTTL)
+	for _, l := range iface.leases {
+		if l.IsStatic || !l.Expiry.After(maxExpiry) {
+			continue
+		}
+
+		// Don't update the lease within the index, since the expiration time
+		// isn't indexed.
+		prev := l.Clone()
+		l.Expiry = maxExpiry
+		evs = append(evs, &Event{Lease: l.Clone(), Prev: prev, Type: EventTypeUpdated})
+	}
+
+	return evs
+}
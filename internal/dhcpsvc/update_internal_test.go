@@ -0,0 +1,268 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_UpdateConfig(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+
+		oldTTL = 24 * time.Hour
+		newTTL = 1 * time.Hour
+	)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+	ip := netip.MustParseAddr("192.168.0.2")
+
+	newConf := func(ttl time.Duration, policy ExpiryPolicy) (conf *Config) {
+		ipv4Conf := newTestIPv4Config()
+		ipv4Conf.LeaseDuration = ttl
+
+		return &Config{
+			Enabled:         true,
+			LocalDomainName: "local",
+			ExpiryPolicy:    policy,
+			Interfaces: map[string]*InterfaceConfig{
+				ifaceName: {
+					IPv4: ipv4Conf,
+					IPv6: &IPv6Config{Enabled: false},
+				},
+			},
+		}
+	}
+
+	request := func(t *testing.T, srv *DHCPServer) (resp *layers.DHCPv4) {
+		t.Helper()
+
+		req := newTestRequest4(
+			mac,
+			layers.DHCPMsgTypeRequest,
+			layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice()),
+		)
+
+		resp, err := srv.handle4(ifaceName, req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+		return resp
+	}
+
+	wantLeaseTime := binary.BigEndian.AppendUint32(nil, uint32(newTTL/time.Second))
+
+	t.Run("cap", func(t *testing.T) {
+		srv, err := New(newConf(oldTTL, ExpiryPolicyCap))
+		require.NoError(t, err)
+
+		request(t, srv)
+
+		ch := make(chan *Event, 1)
+		srv.Subscribe(ch)
+
+		now := time.Now()
+		err = srv.UpdateConfig(newConf(newTTL, ExpiryPolicyCap))
+		require.NoError(t, err)
+
+		l := srv.leases.byAddr[ip]
+		require.NotNil(t, l)
+
+		assert.WithinDuration(t, now.Add(newTTL), l.Expiry, time.Second)
+
+		require.Len(t, ch, 1)
+		ev := <-ch
+		assert.Equal(t, EventTypeUpdated, ev.Type)
+		assert.Equal(t, l.Expiry, ev.Lease.Expiry)
+
+		now = time.Now()
+		resp := request(t, srv)
+		assert.Equal(t, wantLeaseTime, optData4(resp, layers.DHCPOptLeaseTime))
+		assert.WithinDuration(t, now.Add(newTTL), srv.leases.byAddr[ip].Expiry, time.Second)
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		srv, err := New(newConf(oldTTL, ExpiryPolicyKeep))
+		require.NoError(t, err)
+
+		request(t, srv)
+		oldExpiry := srv.leases.byAddr[ip].Expiry
+
+		ch := make(chan *Event, 1)
+		srv.Subscribe(ch)
+
+		err = srv.UpdateConfig(newConf(newTTL, ExpiryPolicyKeep))
+		require.NoError(t, err)
+
+		assert.Equal(t, oldExpiry, srv.leases.byAddr[ip].Expiry)
+		assert.Empty(t, ch)
+
+		now := time.Now()
+		resp := request(t, srv)
+		assert.Equal(t, wantLeaseTime, optData4(resp, layers.DHCPOptLeaseTime))
+		assert.WithinDuration(t, now.Add(newTTL), srv.leases.byAddr[ip].Expiry, time.Second)
+	})
+
+	t.Run("interface_removed", func(t *testing.T) {
+		srv, err := New(newConf(oldTTL, ExpiryPolicyCap))
+		require.NoError(t, err)
+
+		conf := newConf(newTTL, ExpiryPolicyCap)
+		conf.Interfaces["eth1"] = conf.Interfaces[ifaceName]
+		delete(conf.Interfaces, ifaceName)
+
+		err = srv.UpdateConfig(conf)
+		testutil.AssertErrorMsg(t, `updating config: interface "eth0": ipv4 must be enabled`, err)
+	})
+}
+
+func TestDHCPServer_UpdateConfig_deprecated(t *testing.T) {
+	const (
+		ifaceName = "eth0"
+		hostname  = "host"
+	)
+
+	mac := net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+	newConf := func(ipv4Conf *IPv4Config, ipv6Conf *IPv6Config) (conf *Config) {
+		return &Config{
+			Enabled:         true,
+			LocalDomainName: "local",
+			Interfaces: map[string]*InterfaceConfig{
+				ifaceName: {
+					IPv4: ipv4Conf,
+					IPv6: ipv6Conf,
+				},
+			},
+		}
+	}
+
+	t.Run("ipv4", func(t *testing.T) {
+		oldIP := netip.MustParseAddr("192.168.0.200")
+
+		ipv4Conf := newTestIPv4Config()
+		srv := newTestServer4(t, ipv4Conf)
+		requestLease4(t, srv, mac, oldIP, hostname)
+
+		ch := make(chan *Event, 1)
+		srv.Subscribe(ch)
+
+		shrunk := newTestIPv4Config()
+		shrunk.RangeEnd = netip.MustParseAddr("192.168.0.100")
+		err := srv.UpdateConfig(newConf(shrunk, &IPv6Config{Enabled: false}))
+		require.NoError(t, err)
+
+		require.Len(t, ch, 1)
+		ev := <-ch
+		assert.Equal(t, EventTypeDeprecated, ev.Type)
+		assert.Equal(t, oldIP, ev.Lease.IP)
+
+		fs := srv.Status().Interfaces[0].IPv4
+		assert.Equal(t, 1, fs.DeprecatedLeases)
+		assert.Zero(t, fs.DynamicLeases)
+		assert.Equal(t, hostname, srv.HostByIP(oldIP))
+
+		reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, oldIP.AsSlice())
+		resp, err := srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeRequest, reqIPOpt))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, layers.DHCPMsgTypeNak, msgType4(resp))
+
+		resp, err = srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeDiscover))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		newIP, ok := netip.AddrFromSlice(resp.YourClientIP.To4())
+		require.True(t, ok)
+		require.True(t, netip.MustParseAddr("192.168.0.100").Compare(newIP) >= 0)
+
+		reqIPOpt = layers.NewDHCPOption(layers.DHCPOptRequestIP, newIP.AsSlice())
+		resp, err = srv.handle4(ifaceName, newTestRequest4(mac, layers.DHCPMsgTypeRequest, reqIPOpt))
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		assert.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+		assert.Empty(t, srv.HostByIP(oldIP))
+		assert.Equal(t, hostname, srv.HostByIP(newIP))
+
+		fs = srv.Status().Interfaces[0].IPv4
+		assert.Zero(t, fs.DeprecatedLeases)
+		assert.Equal(t, 1, fs.DynamicLeases)
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		const iaid = 1
+
+		duid := newTestDUID6(mac)
+		srv := newTestServer6(t, netip.MustParseAddr("2001:db8::1"))
+		srvIDOpt := srv.iface6ByName(ifaceName).srvIDOpt
+
+		req := newTestRequest6(layers.DHCPv6MsgTypeRequest, duid, iaid)
+		req.Options = append(req.Options, srvIDOpt)
+		resp, err := srv.handle6(ifaceName, req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		granted := iaNAs6(reencode6(t, resp))
+		require.Len(t, granted, 1)
+
+		oldIP := granted[0].addr
+		require.True(t, oldIP.IsValid())
+
+		newStart := netip.MustParseAddr("2001:db8::1:1")
+		err = srv.UpdateConfig(newConf(&IPv4Config{Enabled: false}, &IPv6Config{
+			Enabled:       true,
+			RangeStart:    newStart,
+			LeaseDuration: 1 * time.Hour,
+		}))
+		require.NoError(t, err)
+
+		req.MsgType = layers.DHCPv6MsgTypeRenew
+		resp, err = srv.handle6(ifaceName, req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		iaNAData := optData6(reencode6(t, resp), layers.DHCPv6OptIANA)
+		require.Len(t, iaNAData, iaNAHdrLen6+optHdrLen6+2+len("lease is deprecated"))
+
+		statusData := iaNAData[iaNAHdrLen6+optHdrLen6:]
+		assert.Equal(t, uint16(layers.DHCPv6StatusCodeNoBinding), binary.BigEndian.Uint16(statusData))
+
+		req.MsgType = layers.DHCPv6MsgTypeRequest
+		resp, err = srv.handle6(ifaceName, req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		granted = iaNAs6(reencode6(t, resp))
+		require.Len(t, granted, 1)
+		assert.Equal(t, newStart, granted[0].addr)
+
+		assert.NotContains(t, srv.leases.byAddr, oldIP)
+	})
+
+	t.Run("subnet_changed", func(t *testing.T) {
+		srv := newTestServer4(t, newTestIPv4Config())
+
+		conf := newTestIPv4Config()
+		conf.GatewayIP = netip.MustParseAddr("192.168.1.1")
+		conf.RangeStart = netip.MustParseAddr("192.168.1.2")
+		conf.RangeEnd = netip.MustParseAddr("192.168.1.254")
+
+		err := srv.UpdateConfig(newConf(conf, &IPv6Config{Enabled: false}))
+		testutil.AssertErrorMsg(
+			t,
+			`updating config: interface "eth0": ipv4: `+
+				`changing subnet 192.168.0.0/24 to 192.168.1.0/24 is not supported`,
+			err,
+		)
+	})
+}
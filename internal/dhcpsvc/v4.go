@@ -0,0 +1,597 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/golibs/errors"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// defaultMaxHops4 is the default maximum value of the hops field of the
+	// DHCPv4 requests to serve.
+	//
+	// See https://datatracker.ietf.org/doc/html/rfc1542#section-4.1.1.
+	defaultMaxHops4 uint8 = 4
+
+	// maxHops4 is the greatest allowed maximum value of the hops field of the
+	// DHCPv4 requests to serve.
+	maxHops4 uint8 = 16
+)
+
+// AllocDirection defines the order in which the free addresses of the range
+// are allocated to the clients.
+type AllocDirection uint8
+
+// AllocDirection values.
+const (
+	// AllocDirectionAscending means that the free addresses are looked for
+	// from the start of the range upward.
+	AllocDirectionAscending AllocDirection = iota
+
+	// AllocDirectionDescending means that the free addresses are looked for
+	// from the end of the range downward, so that the addresses at its start
+	// are the last to be allocated, e.g. when those are assigned manually.
+	AllocDirectionDescending
+)
+
+// String implements the [fmt.Stringer] interface for AllocDirection.
+func (d AllocDirection) String() (s string) {
+	switch d {
+	case AllocDirectionAscending:
+		return "ascending"
+	case AllocDirectionDescending:
+		return "descending"
+	default:
+		return fmt.Sprintf("!bad_alloc_direction_%d", d)
+	}
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for
+// AllocDirection.
+func (d AllocDirection) MarshalText() (text []byte, err error) {
+	if d > AllocDirectionDescending {
+		return nil, fmt.Errorf("bad alloc direction %d", d)
+	}
+
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *AllocDirection.
+func (d *AllocDirection) UnmarshalText(text []byte) (err error) {
+	switch s := string(text); s {
+	case "ascending":
+		*d = AllocDirectionAscending
+	case "descending":
+		*d = AllocDirectionDescending
+	default:
+		return fmt.Errorf("alloc direction %q must be either ascending or descending", s)
+	}
+
+	return nil
+}
+
+// validateV4 returns an error in conf if any.
+func validateV4(conf *IPv4Config) (err error) {
+	if conf == nil {
+		return errNilConfig
+	} else if !conf.Enabled {
+		return nil
+	}
+
+	switch {
+	case !conf.GatewayIP.Is4():
+		return newMustErr("gateway ip", "be a valid ipv4", conf.GatewayIP)
+	case conf.Subnet.IsValid() && !conf.Subnet.Addr().Is4():
+		return newMustErr("subnet", "be a valid ipv4 network", conf.Subnet)
+	case !conf.Subnet.IsValid() && !conf.SubnetMask.Is4():
+		return newMustErr("subnet mask", "be a valid ipv4 cidr mask", conf.SubnetMask)
+	case conf.RangeStart.IsValid() && conf.RangeStartOffset != 0:
+		return errors.Error("range start and range start offset are mutually exclusive")
+	case conf.RangeEnd.IsValid() && conf.RangeEndOffset != 0:
+		return errors.Error("range end and range end offset are mutually exclusive")
+	case conf.LeaseDuration <= 0:
+		return newMustErr("lease duration", "be positive", conf.LeaseDuration)
+	case conf.MaxHops > maxHops4:
+		return fmt.Errorf("max hops %d must not exceed %d", conf.MaxHops, maxHops4)
+	case conf.AllocDirection > AllocDirectionDescending:
+		return newMustErr("alloc direction", "be either ascending or descending", conf.AllocDirection)
+	}
+
+	if conf.SubnetMask.IsValid() {
+		ones, ok := maskToPrefixLen(conf.SubnetMask)
+		if !ok || !conf.SubnetMask.Is4() {
+			return newMustErr("subnet mask", "be a valid ipv4 cidr mask", conf.SubnetMask)
+		} else if conf.Subnet.IsValid() && ones != conf.Subnet.Bits() {
+			return newMustErr("subnet mask", "match subnet "+conf.Subnet.String(), conf.SubnetMask)
+		}
+	}
+
+	// Validate the range after resolving the offsets, since those depend on
+	// the subnet.
+	start, end := conf.rangeBounds()
+	switch {
+	case !start.Is4():
+		return newMustErr("range start", "be a valid ipv4", start)
+	case !end.Is4():
+		return newMustErr("range end", "be a valid ipv4", end)
+	}
+
+	err = validateTimezone4("timezone posix", conf.TimezonePOSIX)
+	if err != nil {
+		return err
+	}
+
+	err = validateTimezone4("timezone tzdb", conf.TimezoneTZDB)
+	if err != nil {
+		return err
+	}
+
+	err = validateCiscoTFTPServers4(conf.CiscoTFTPServers)
+	if err != nil {
+		return err
+	}
+
+	err = validateOpts4(conf.Options)
+	if err != nil {
+		return err
+	}
+
+	err = validateOptTmpls4(conf.dhcpOptions())
+	if err != nil {
+		return err
+	}
+
+	err = validateClasses4(conf.LeaseClasses)
+	if err != nil {
+		return err
+	}
+
+	err = conf.Netboot.validate()
+	if err != nil {
+		return err
+	}
+
+	return validateGateway4(conf.GatewayIP, conf.subnet())
+}
+
+// maxOptLen4 is the maximum length of the data of a DHCPv4 option.
+const maxOptLen4 = 255
+
+// validateTimezone4 returns an error if tz, which is the value of the property
+// with the given name, is set but can't be sent within an option.
+func validateTimezone4(name, tz string) (err error) {
+	switch {
+	case tz == "":
+		return nil
+	case strings.TrimSpace(tz) == "":
+		return fmt.Errorf("%s %q must not be blank", name, tz)
+	case len(tz) > maxOptLen4:
+		return fmt.Errorf("%s %q must not be longer than %d bytes", name, tz, maxOptLen4)
+	default:
+		return nil
+	}
+}
+
+// validateCiscoTFTPServers4 returns an error if addrs can't be sent within the
+// TFTP Server Address option.
+func validateCiscoTFTPServers4(addrs []netip.Addr) (err error) {
+	for _, addr := range addrs {
+		if !addr.Is4() {
+			return newMustErr("cisco tftp server", "be a valid ipv4", addr)
+		}
+	}
+
+	if l := len(addrs) * net.IPv4len; l > maxOptLen4 {
+		return fmt.Errorf("cisco tftp servers length %d must not exceed %d", l, maxOptLen4)
+	}
+
+	return nil
+}
+
+// validateGateway4 returns an error if gw isn't a valid host address within
+// subnet, i.e. it's outside of subnet or it's the network or the broadcast
+// address of it.  The networks having no such addresses, i.e. /31 and /32, are
+// only checked to contain gw.
+func validateGateway4(gw netip.Addr, subnet netip.Prefix) (err error) {
+	switch {
+	case !subnet.Contains(gw):
+		return newMustErr("gateway ip", "be within "+subnet.String(), gw)
+	case subnet.Bits() >= 31:
+		return nil
+	case gw == subnet.Addr():
+		return newMustErr("gateway ip", "not be the network address of "+subnet.String(), gw)
+	case gw == broadcast4(subnet):
+		return newMustErr("gateway ip", "not be the broadcast address of "+subnet.String(), gw)
+	default:
+		return nil
+	}
+}
+
+// rangeConfigured returns true if both bounds of the address range are set in
+// conf, either as addresses or as offsets.
+func (conf *IPv4Config) rangeConfigured() (ok bool) {
+	return (conf.RangeStart.IsValid() || conf.RangeStartOffset != 0) &&
+		(conf.RangeEnd.IsValid() || conf.RangeEndOffset != 0)
+}
+
+// rangeBounds returns the bounds of the address range configured by conf with
+// the offsets resolved against its subnet.  The subnet of conf must be valid.
+func (conf *IPv4Config) rangeBounds() (start, end netip.Addr) {
+	start, end = conf.RangeStart, conf.RangeEnd
+	if conf.RangeStartOffset != 0 {
+		start = offsetAddr4(conf.subnet(), conf.RangeStartOffset)
+	}
+
+	if conf.RangeEndOffset != 0 {
+		end = offsetAddr4(conf.subnet(), conf.RangeEndOffset)
+	}
+
+	return start, end
+}
+
+// offsetAddr4 returns the address at the given offset from the network address
+// of subnet.  The result isn't necessarily within subnet.
+func offsetAddr4(subnet netip.Prefix, offset uint32) (ip netip.Addr) {
+	data := subnet.Masked().Addr().As4()
+	binary.BigEndian.PutUint32(data[:], binary.BigEndian.Uint32(data[:])+offset)
+
+	return netip.AddrFrom4(data)
+}
+
+// subnet returns the network configured by conf.  conf must be valid.
+func (conf *IPv4Config) subnet() (subnet netip.Prefix) {
+	if conf.Subnet.IsValid() {
+		return conf.Subnet.Masked()
+	}
+
+	maskLen, _ := maskToPrefixLen(conf.SubnetMask)
+
+	return netip.PrefixFrom(conf.GatewayIP, maskLen).Masked()
+}
+
+// broadcast4 returns the broadcast address of the IPv4 subnet.
+func broadcast4(subnet netip.Prefix) (bcast netip.Addr) {
+	data := subnet.Masked().Addr().As4()
+	hostMask := uint32(1)<<(32-subnet.Bits()) - 1
+	binary.BigEndian.PutUint32(data[:], binary.BigEndian.Uint32(data[:])|hostMask)
+
+	return netip.AddrFrom4(data)
+}
+
+// iface4 is a DHCP interface for IPv4 address family.
+type iface4 struct {
+	// gateway is the IP address of the network gateway.
+	gateway netip.Addr
+
+	// nextAddr is the address to start looking for a free one to offer from.
+	// It's the one following the last dynamically leased address in the
+	// direction of the search, see [iface4.descending].  It's protected by
+	// [DHCPServer.leasesMu].
+	nextAddr netip.Addr
+
+	// srvIDOpt is the Server Identifier option sent within every reply.
+	srvIDOpt layers.DHCPOption
+
+	// replyOpts are the options sent within every DHCPOFFER and DHCPACK.  These
+	// are built once on creation to avoid allocations on each reply, so the
+	// data of these must not be modified.
+	replyOpts layers.DHCPOptions
+
+	// dnsAddrs provides the addresses of the DNS server to advertise.  It's
+	// nil if the Domain Name Server option is configured explicitly or the
+	// gateway address should always be advertised.
+	dnsAddrs DNSAddrsFunc
+
+	// defaultDNSOpt is the Domain Name Server option advertising the gateway
+	// address.  It's sent when dnsAddrs provide no addresses.  It has zero
+	// type if the option is configured explicitly.
+	defaultDNSOpt layers.DHCPOption
+
+	// foreign records the other DHCP servers seen on the interface.
+	foreign *foreignTracker
+
+	// netInterface is embedded here to provide some common network interface
+	// logic.
+	netInterface
+
+	// classes are the classes of clients granted leases with durations other
+	// than the default one.
+	classes []*LeaseClass
+
+	// profiles are the option profiles of the clients, see
+	// [Config.OptionProfiles].
+	profiles []*ifaceProfile4
+
+	// netboot is the configuration of network booting of the clients.  It's
+	// nil if network booting is disabled.
+	netboot *NetbootConfig
+
+	// checkConflicts defines if the addresses should be probed before being
+	// offered.
+	checkConflicts bool
+
+	// authoritative defines if the DHCPREQUEST messages for the addresses
+	// outside of subnet are replied with DHCPNAK instead of being dropped.
+	authoritative bool
+
+	// echoHostname defines if the effective hostname of the client should be
+	// sent back to it in acknowledgements.
+	echoHostname bool
+
+	// descending defines if the free addresses are looked for from the end of
+	// the address space downward.
+	descending bool
+
+	// offers are the addresses offered to the clients, which haven't requested
+	// those yet.
+	offers *offers
+
+	// expirySwept is the time the expired dynamic leases have been last
+	// reported at, see [DHCPServer.reportExpired4].  It's protected by
+	// [DHCPServer.leasesMu].
+	expirySwept time.Time
+
+	// maxHops is the maximum value of the hops field of the requests to serve.
+	maxHops uint8
+}
+
+// advance moves the start of the search for a free address past ip, which has
+// just been leased, in the direction of the search.  [DHCPServer.leasesMu] is
+// expected to be locked.
+func (iface *iface4) advance(ip netip.Addr) {
+	if iface.descending {
+		iface.nextAddr = ip.Prev()
+	} else {
+		iface.nextAddr = ip.Next()
+	}
+}
+
+// options returns the options configured by conf, including the deprecated
+// [IPv4Config.DHCPOptions].
+func (conf *IPv4Config) options() (opts []Option) {
+	if len(conf.DHCPOptions) == 0 {
+		return conf.Options
+	}
+
+	opts = slices.Clip(conf.Options)
+	for _, opt := range conf.DHCPOptions {
+		opts = append(opts, newOptionFromDHCP(opt))
+	}
+
+	return opts
+}
+
+// dhcpOptions returns the options configured by conf converted to the ones of
+// [layers], see [IPv4Config.options].
+func (conf *IPv4Config) dhcpOptions() (opts layers.DHCPOptions) {
+	return dhcpOptions(conf.options())
+}
+
+// addrSpace returns the subnet and the address space for leasing configured by
+// conf.  It returns an error if those can't be used.  conf must be valid, see
+// [validateV4].
+func (conf *IPv4Config) addrSpace() (subnet netip.Prefix, addrSpace ipRange, err error) {
+	subnet = conf.subnet()
+	start, end := conf.rangeBounds()
+
+	switch {
+	case !subnet.Contains(start):
+		return subnet, addrSpace, fmt.Errorf("range start %s is not within %s", start, subnet)
+	case !subnet.Contains(end):
+		return subnet, addrSpace, fmt.Errorf("range end %s is not within %s", end, subnet)
+	}
+
+	addrSpace, err = newIPRange(start, end)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return subnet, addrSpace, err
+	}
+
+	if addrSpace.contains(conf.GatewayIP) {
+		return subnet, addrSpace, fmt.Errorf(
+			"gateway ip %s in the ip range %s",
+			conf.GatewayIP,
+			addrSpace,
+		)
+	}
+
+	return subnet, addrSpace, nil
+}
+
+// newIface4 creates a new DHCP interface for IPv4 address family with the given
+// configuration.  It returns an error if the given configuration can't be used.
+// conf must be valid, see [validateV4].  domains are the search domains with
+// the local domain name first, see [searchDomains].  dnsAddrs may be nil.  i is
+// nil if conf is disabled.
+func newIface4(
+	name string,
+	conf *IPv4Config,
+	domains []string,
+	dnsAddrs DNSAddrsFunc,
+	profiles []*OptionProfile,
+) (i *iface4, err error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	subnet, addrSpace, err := conf.addrSpace()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	ni := newNetInterface(name, subnet, addrSpace, conf.LeaseDuration, domains)
+	ni.paused = conf.Paused
+
+	replyOpts, err := replyOpts4(conf, &ni, domains)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	ifaceProfiles, err := newIfaceProfiles4(profiles, conf.GatewayIP)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	i = &iface4{
+		gateway:      conf.GatewayIP,
+		srvIDOpt:     layers.NewDHCPOption(layers.DHCPOptServerID, conf.GatewayIP.AsSlice()),
+		replyOpts:    replyOpts,
+		foreign:      newForeignTracker(),
+		offers:       newOffers(),
+		netInterface: ni,
+		classes:      conf.LeaseClasses,
+		profiles:     ifaceProfiles,
+		echoHostname: conf.EchoHostname,
+		descending:   conf.AllocDirection == AllocDirectionDescending,
+		maxHops:      conf.MaxHops,
+	}
+
+	if conf.Netboot != nil && conf.Netboot.Enabled {
+		i.netboot = conf.Netboot
+	}
+
+	if i.maxHops == 0 {
+		i.maxHops = defaultMaxHops4
+	}
+
+	if !slices.ContainsFunc(conf.dhcpOptions(), func(o layers.DHCPOption) (ok bool) {
+		return o.Type == layers.DHCPOptDNS
+	}) {
+		i.dnsAddrs = dnsAddrs
+		i.defaultDNSOpt = layers.NewDHCPOption(layers.DHCPOptDNS, conf.GatewayIP.AsSlice())
+	}
+
+	return i, nil
+}
+
+// dnsOpt returns the Domain Name Server option to send within DHCPOFFER and
+// DHCPACK.  ok is false if the option is configured explicitly, so that it's
+// already within iface.replyOpts.
+func (iface *iface4) dnsOpt() (opt layers.DHCPOption, ok bool) {
+	if iface.defaultDNSOpt.Type == 0 {
+		return opt, false
+	} else if iface.dnsAddrs == nil {
+		return iface.defaultDNSOpt, true
+	}
+
+	var data []byte
+	for _, addr := range iface.dnsAddrs(iface.name, true) {
+		if addr.Is4() {
+			addrData := addr.As4()
+			data = append(data, addrData[:]...)
+		}
+	}
+
+	if len(data) == 0 {
+		return iface.defaultDNSOpt, true
+	}
+
+	return layers.NewDHCPOption(layers.DHCPOptDNS, data), true
+}
+
+// Options of DHCPv4 not defined in [layers].
+const (
+	// dhcpOptPCode is the option containing the timezone as the POSIX TZ
+	// string.  See RFC 4833.
+	dhcpOptPCode layers.DHCPOpt = 100
+
+	// dhcpOptTCode is the option containing the timezone as the name of the TZ
+	// database entry.  See RFC 4833.
+	dhcpOptTCode layers.DHCPOpt = 101
+
+	// dhcpOptTFTPServers is the option containing the IPv4 addresses of the
+	// TFTP servers, used by Cisco IP phones.  See RFC 5859.
+	dhcpOptTFTPServers layers.DHCPOpt = 150
+)
+
+// ipsData4 returns the data of an option containing the IPv4 addresses from
+// ips.  ips must all be IPv4.
+func ipsData4(ips []netip.Addr) (data []byte) {
+	data = make([]byte, 0, len(ips)*net.IPv4len)
+	for _, ip := range ips {
+		ipData := ip.As4()
+		data = append(data, ipData[:]...)
+	}
+
+	return data
+}
+
+// replyOpts4 returns the options to send within every DHCPOFFER and DHCPACK on
+// ni configured by conf.  domains are the search domains with the local domain
+// name first, see [searchDomains].  Explicitly configured options override the
+// default ones, and the templates within their string values are expanded.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-4.3.1.
+func replyOpts4(
+	conf *IPv4Config,
+	ni *netInterface,
+	domains []string,
+) (opts layers.DHCPOptions, err error) {
+	leaseTime := binary.BigEndian.AppendUint32(nil, uint32(conf.LeaseDuration/time.Second))
+
+	confOpts := dropSubnetMask4(conf.dhcpOptions(), fmt.Sprintf("interface %q", ni.name))
+	opts = make(layers.DHCPOptions, 0, 8+len(confOpts))
+	opts = append(
+		opts,
+		layers.NewDHCPOption(layers.DHCPOptLeaseTime, leaseTime),
+		subnetMaskOpt4(ni.subnet),
+		layers.NewDHCPOption(layers.DHCPOptRouter, conf.GatewayIP.AsSlice()),
+	)
+
+	if len(domains) > 0 {
+		opts = append(
+			opts,
+			layers.NewDHCPOption(layers.DHCPOptDomainName, []byte(domains[0])),
+			layers.NewDHCPOption(layers.DHCPOptDomainSearch, ni.searchList),
+		)
+	}
+
+	if conf.TimezonePOSIX != "" {
+		opts = append(opts, layers.NewDHCPOption(dhcpOptPCode, []byte(conf.TimezonePOSIX)))
+	}
+
+	if conf.TimezoneTZDB != "" {
+		opts = append(opts, layers.NewDHCPOption(dhcpOptTCode, []byte(conf.TimezoneTZDB)))
+	}
+
+	if len(conf.CiscoTFTPServers) > 0 {
+		opts = append(opts, layers.NewDHCPOption(dhcpOptTFTPServers, ipsData4(conf.CiscoTFTPServers)))
+	}
+
+	// The server identifies itself with the gateway address, see
+	// [iface4.srvIDOpt].
+	vars := &optTmplVars{
+		serverIP:  conf.GatewayIP,
+		gatewayIP: conf.GatewayIP,
+	}
+
+	for _, opt := range confOpts {
+		if isOptTmpl(opt.Data) {
+			var data []byte
+			data, err = expandOptTmpl(opt.Data, vars)
+			if err != nil {
+				return nil, fmt.Errorf("option %d: %w", opt.Type, err)
+			}
+
+			opt = layers.NewDHCPOption(opt.Type, data)
+		}
+
+		opts = slices.DeleteFunc(opts, func(o layers.DHCPOption) (ok bool) {
+			return o.Type == opt.Type
+		})
+		opts = append(opts, opt)
+	}
+
+	return opts, nil
+}
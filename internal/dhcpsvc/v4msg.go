@@ -0,0 +1,949 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/AdguardTeam/golibs/netutil"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// handle4 processes the DHCPv4 message req received on the network interface
+// with the given name and returns the reply to send back.  resp is nil if no
+// reply should be sent.  resp fits into the maximum message size accepted by
+// the client.  The Relay Agent Information option is only echoed within the
+// replies sent to the relay agent.  err is a [*PoolExhaustedError] if there are
+// no free addresses left within the range of the network interface.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-4.3.
+func (srv *DHCPServer) handle4(ifaceName string, req *layers.DHCPv4) (resp *layers.DHCPv4, err error) {
+	relayInfo := relayInfo4(ifaceName, req)
+	resp, err = srv.handleByType4(ifaceName, req)
+	srv.record4(ifaceName, req, resp, relayInfo, err)
+	if resp != nil {
+		echoSubnetSelection4(resp, req)
+		orderOpts4(resp, req)
+		echoRelayInfo4(resp, req, relayInfo, maxMsgSize4(req))
+	}
+
+	return resp, err
+}
+
+// orderOpts4 reorders the options of resp according to the Parameter Request
+// List option of req, since some clients expect the options in the order they
+// requested them.  The options mandatory for the reply go first, then the
+// requested ones in the requested order, and then the rest in their original
+// order.  resp is left intact if req contains no Parameter Request List.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2132#section-9.8.
+func orderOpts4(resp, req *layers.DHCPv4) {
+	prl := optData4(req, layers.DHCPOptParamsRequest)
+	if len(prl) == 0 {
+		return
+	}
+
+	rank := func(typ layers.DHCPOpt) (r int) {
+		switch typ {
+		case layers.DHCPOptMessageType, layers.DHCPOptServerID, layers.DHCPOptLeaseTime:
+			return 0
+		default:
+			if i := bytes.IndexByte(prl, byte(typ)); i >= 0 {
+				return i + 1
+			}
+
+			return len(prl) + 1
+		}
+	}
+
+	slices.SortStableFunc(resp.Options, func(a, b layers.DHCPOption) (res int) {
+		return rank(a.Type) - rank(b.Type)
+	})
+}
+
+// handleByType4 processes the DHCPv4 message req received on the network
+// interface with the given name according to its type.
+func (srv *DHCPServer) handleByType4(
+	ifaceName string,
+	req *layers.DHCPv4,
+) (resp *layers.DHCPv4, err error) {
+	iface := srv.iface4ByName(ifaceName)
+	if iface == nil {
+		log.Debug("dhcpsvc: no ipv4 interface %q, dropping message", ifaceName)
+
+		return nil, nil
+	}
+
+	if req.Operation == layers.DHCPOpReply {
+		// Replies from other servers are seen when they're broadcast.
+		iface.detectForeign4(req)
+
+		return nil, nil
+	} else if req.Operation != layers.DHCPOpRequest {
+		log.Debug("dhcpsvc: unexpected operation %s, dropping message", req.Operation)
+
+		return nil, nil
+	} else if hops := req.HardwareOpts; hops > iface.maxHops {
+		// The hops field is decoded into HardwareOpts by [layers].
+		log.Debug(
+			"dhcpsvc: interface %q: hops %d exceed %d, dropping message",
+			iface.name,
+			hops,
+			iface.maxHops,
+		)
+
+		return nil, nil
+	} else if msgType4(req) == dhcpMsgTypeLeaseQuery {
+		// The queries don't necessarily contain the client hardware address.
+		return srv.handleLeaseQuery4(iface, req), nil
+	}
+
+	correctFields4(iface.name, req)
+
+	err = netutil.ValidateMAC(req.ClientHWAddr)
+	if err != nil {
+		return nil, fmt.Errorf("client hardware address: %w", err)
+	}
+
+	// The selected subnet takes precedence over the relay agent address.
+	iface, selected := srv.selectIface4(iface, req)
+	if iface == nil {
+		return nil, nil
+	}
+
+	// Don't serve the clients from other networks, since the message may have
+	// arrived on this interface due to misconfiguration or bridging.
+	if relayIP, _ := netip.AddrFromSlice(req.RelayAgentIP.To4()); !selected &&
+		isSet4(relayIP) &&
+		!iface.subnet.Contains(relayIP) {
+		log.Debug(
+			"dhcpsvc: interface %q: relay address %s is not within %s, dropping message",
+			iface.name,
+			relayIP,
+			iface.subnet,
+		)
+
+		return nil, nil
+	}
+
+	srv.reportExpired4(iface)
+
+	typ := msgType4(req)
+	var class string
+	if typ == layers.DHCPMsgTypeDiscover || typ == layers.DHCPMsgTypeRequest {
+		class = srv.classify4(iface, req)
+		if !srv.admitClient4(iface, req, class) {
+			return nil, nil
+		}
+	}
+
+	switch typ {
+	case layers.DHCPMsgTypeDiscover:
+		return srv.handleDiscover4(iface, req, class)
+	case layers.DHCPMsgTypeRequest:
+		return srv.handleRequest4(iface, req, class)
+	case layers.DHCPMsgTypeRelease:
+		return nil, srv.handleRelease4(iface, req)
+	default:
+		log.Debug("dhcpsvc: unsupported message type %s, dropping message", typ)
+
+		return nil, nil
+	}
+}
+
+// correctFields4 resets the fields of req received on the network interface
+// with the given name, which contradict RFC 2131 and would make the reply
+// undeliverable, e.g. the ones set by broken relay agents.  The corrections are
+// logged.  The address offered or acknowledged is never taken from req.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-4.1.
+func correctFields4(ifaceName string, req *layers.DHCPv4) {
+	if relayIP, _ := netip.AddrFromSlice(req.RelayAgentIP.To4()); isSet4(relayIP) &&
+		!isUnicast4(relayIP) {
+		log.Debug("dhcpsvc: interface %q: relay address %s is not unicast, ignoring", ifaceName, relayIP)
+		req.RelayAgentIP = net.IPv4zero
+	}
+
+	if yourIP, _ := netip.AddrFromSlice(req.YourClientIP.To4()); isSet4(yourIP) {
+		log.Debug("dhcpsvc: interface %q: your address %s set by client, ignoring", ifaceName, yourIP)
+		req.YourClientIP = net.IPv4zero
+	}
+
+	clientIP, _ := netip.AddrFromSlice(req.ClientIP.To4())
+	if !isSet4(clientIP) {
+		return
+	}
+
+	// The client address must be zero in DHCPDISCOVER and in DHCPREQUEST
+	// containing the Requested IP Address option, since the client has no
+	// address to be replied at yet.
+	typ := msgType4(req)
+	if typ == layers.DHCPMsgTypeDiscover ||
+		(typ == layers.DHCPMsgTypeRequest && requestedIP4(req).IsValid()) {
+		log.Debug("dhcpsvc: interface %q: client address %s in %s, ignoring", ifaceName, clientIP, typ)
+		req.ClientIP = net.IPv4zero
+	}
+}
+
+// isUnicast4 returns true if ip is an IPv4 address, which a reply may be
+// unicast to.
+func isUnicast4(ip netip.Addr) (ok bool) {
+	return ip.Is4() &&
+		!ip.IsUnspecified() &&
+		!ip.IsLoopback() &&
+		!ip.IsMulticast() &&
+		ip != netip.AddrFrom4([4]byte{255, 255, 255, 255})
+}
+
+// handleDiscover4 handles the DHCPDISCOVER message from the client of the given
+// class and returns the DHCPOFFER reply.  resp is nil if there are no addresses
+// to offer, see [DHCPServer.offer4].
+func (srv *DHCPServer) handleDiscover4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	class string,
+) (resp *layers.DHCPv4, err error) {
+	var fpBuf [maxFingerprintLen4]byte
+	fp := appendFingerprint4(fpBuf[:0], req)
+	if srv.fingerprintChanged4(iface, req.ClientHWAddr, fp) {
+		srv.updateFingerprint4(iface, req.ClientHWAddr, fp)
+	}
+
+	return srv.offer4(iface, req, class)
+}
+
+// offer4 returns the DHCPOFFER reply to req from the client of the given class.
+// resp is nil if there are no addresses to offer.  err is a
+// [*PoolExhaustedError] if there are no free addresses left within the range
+// of iface.  The offered address is reserved for the client, see
+// [Config.OfferTimeout].  The candidates are probed with srv.leasesMu unlocked,
+// since probing may take as long as [Config.ProbeBudget].
+func (srv *DHCPServer) offer4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	class string,
+) (resp *layers.DHCPv4, err error) {
+	budget, cancel := srv.newProbeBudget()
+	defer cancel()
+
+	mac, reqIP := req.ClientHWAddr, requestedIP4(req)
+	resolved := srv.resolveStatic4(mac)
+
+	// skipped are the candidates found in use or taken by other clients while
+	// being probed.
+	var skipped []netip.Addr
+	for {
+		ip, probe, reason := srv.nextOffer4(iface, mac, reqIP, resolved, skipped)
+		if !ip.IsValid() {
+			srv.recordAllocFail(iface.name, mac, reason)
+			if reason == AllocFailReasonPoolExhausted {
+				return nil, newPoolExhaustedErr(iface.name)
+			}
+
+			return nil, nil
+		}
+
+		if probe && srv.addrInUse4(iface, ip, budget) {
+			skipped = append(skipped, ip)
+
+			continue
+		}
+
+		resp = srv.reserveOffer4(iface, req, class, ip, probe)
+		if resp != nil {
+			return resp, nil
+		}
+
+		skipped = append(skipped, ip)
+	}
+}
+
+// nextOffer4 returns the next candidate address to offer to the client with mac
+// on iface, see [DHCPServer.offerAddr4].  If ip is invalid, reason is the
+// reason for it.
+func (srv *DHCPServer) nextOffer4(
+	iface *iface4,
+	mac net.HardwareAddr,
+	reqIP netip.Addr,
+	resolved netip.Addr,
+	skipped []netip.Addr,
+) (ip netip.Addr, probe bool, reason AllocFailReason) {
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	ip, probe = srv.offerAddr4(iface, mac, reqIP, resolved, skipped)
+	if !ip.IsValid() {
+		reason = srv.allocFailReason()
+	}
+
+	return ip, probe, reason
+}
+
+// reserveOffer4 reserves ip for the client, which has sent req, and returns the
+// DHCPOFFER reply offering it.  resp is nil if ip can't be reserved, see
+// [DHCPServer.reserveAddr4].  The reply is built with srv.leasesMu unlocked,
+// since [Config.DNSAddrs] may be slow.
+func (srv *DHCPServer) reserveOffer4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	class string,
+	ip netip.Addr,
+	probed bool,
+) (resp *layers.DHCPv4) {
+	ttl, ok := srv.reserveAddr4(iface, req, class, ip, probed)
+	if !ok {
+		return nil
+	}
+
+	resp = iface.newReply4(req, layers.DHCPMsgTypeOffer, ip)
+	setProfile4(iface, resp, req, class)
+	setLeaseTime4(resp, ttl)
+	iface.setNetboot4(resp, req)
+
+	return resp
+}
+
+// reserveAddr4 reserves ip on iface for the client of the given class, which
+// has sent req, and returns the duration of the lease to offer.  If probed is
+// true, ip is checked to still be leasable to the client, since srv.leasesMu
+// has been unlocked for probing it, and ok is false if it isn't.
+func (srv *DHCPServer) reserveAddr4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	class string,
+	ip netip.Addr,
+	probed bool,
+) (ttl time.Duration, ok bool) {
+	srv.leasesMu.Lock()
+	defer srv.leasesMu.Unlock()
+
+	mac := req.ClientHWAddr
+	if probed && !srv.addrLeasable4(iface, mac, ip) {
+		log.Debug("dhcpsvc: interface %q: %s has been taken while probing", iface.name, ip)
+
+		return 0, false
+	}
+
+	now := srv.now()
+	iface.offers.reserve(ip, mac, now, now.Add(srv.offerTimeout()))
+
+	return srv.leaseTTL4(iface, req, class), true
+}
+
+// offerAddr4 returns the address to offer to the client with mac on iface.
+// reqIP is the address requested by the client, if any, and resolved is the
+// one assigned to it by [DHCPServer.resolveStatic4], if any.  ip is invalid if
+// there are no free addresses.  The skipped addresses and the ones offered to
+// other clients aren't offered, and the one already offered to the client is
+// offered again.  The client holding a deprecated lease is offered another
+// address, see [netInterface.isDeprecated].  probe is true if ip is picked
+// among the free addresses, so it should be probed before offering, see
+// [DHCPServer.addrInUse4].  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) offerAddr4(
+	iface *iface4,
+	mac net.HardwareAddr,
+	reqIP netip.Addr,
+	resolved netip.Addr,
+	skipped []netip.Addr,
+) (ip netip.Addr, probe bool) {
+	l, ok := iface.leases[leaseKey{mac: macToKey(mac)}]
+	switch {
+	case ok && !iface.isDeprecated(l):
+		return l.IP, false
+	case !ok && srv.leasesExhausted():
+		return netip.Addr{}, false
+	}
+
+	if ip = srv.leasableResolved4(iface, mac, resolved); ip.IsValid() {
+		return ip, false
+	}
+
+	now := srv.now()
+	isFree := allOf(
+		exceptAddrs(skipped...),
+		srv.freePredicate4(iface, now),
+		func(ip netip.Addr) (ok bool) { return !iface.offers.isReservedForOther(ip, mac, now) },
+	)
+
+	if iface.addrSpace.contains(reqIP) && isFree(reqIP) {
+		return reqIP, true
+	}
+
+	// Offer the client the address already offered to it, since it's counted
+	// as unavailable below.
+	ip = iface.offers.reservedFor(mac, now)
+	if iface.addrSpace.contains(ip) && isFree(ip) {
+		return ip, true
+	} else if srv.freeAddrs(&iface.netInterface, iface.gateway, iface.offers) == 0 {
+		return netip.Addr{}, false
+	}
+
+	if iface.descending {
+		return iface.addrSpace.findFromDesc(iface.nextAddr, isFree), true
+	}
+
+	return iface.addrSpace.findFrom(iface.nextAddr, isFree), true
+}
+
+// resolveStatic4 returns the address assigned to the client with mac by
+// [Config.StaticResolver].  ip is invalid if there is no resolver or it doesn't
+// know the client.  srv.leasesMu must not be locked, since the resolver may be
+// slow, so ip should be checked with [DHCPServer.leasableResolved4] once it's
+// locked.
+func (srv *DHCPServer) resolveStatic4(mac net.HardwareAddr) (ip netip.Addr) {
+	if srv.conf.StaticResolver == nil {
+		return netip.Addr{}
+	}
+
+	ip, ok := srv.conf.StaticResolver(mac)
+	if !ok {
+		return netip.Addr{}
+	}
+
+	return ip
+}
+
+// lookups4 contains what is looked up for a DHCPv4 client using the configured
+// functions.  It's looked up with srv.leasesMu unlocked, since the functions may
+// be slow.
+type lookups4 struct {
+	// resolved is the address assigned to the client by
+	// [DHCPServer.resolveStatic4], if any.
+	resolved netip.Addr
+
+	// vendor is the vendor of the client's network interface, see
+	// [DHCPServer.vendor].
+	vendor string
+}
+
+// lookup4 looks up the client with mac.  srv.leasesMu must not be locked.
+func (srv *DHCPServer) lookup4(mac net.HardwareAddr) (lk lookups4) {
+	return lookups4{
+		resolved: srv.resolveStatic4(mac),
+		vendor:   srv.vendor(mac),
+	}
+}
+
+// leasableResolved4 returns resolved, the address resolved for the client with
+// mac by [DHCPServer.resolveStatic4], if it can be leased on iface.  ip is
+// invalid otherwise.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) leasableResolved4(
+	iface *iface4,
+	mac net.HardwareAddr,
+	resolved netip.Addr,
+) (ip netip.Addr) {
+	if !resolved.IsValid() {
+		return netip.Addr{}
+	} else if !iface.subnet.Contains(resolved) || !srv.addrFree4(iface, resolved) {
+		log.Info(
+			"dhcpsvc: warning: interface %q: resolved address %s for %s can't be leased",
+			iface.name,
+			resolved,
+			mac,
+		)
+
+		return netip.Addr{}
+	}
+
+	return resolved
+}
+
+// addrFree4 returns true if ip may be leased on iface, see
+// [DHCPServer.freePredicate4].  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) addrFree4(iface *iface4, ip netip.Addr) (ok bool) {
+	return srv.freePredicate4(iface, srv.now())(ip)
+}
+
+// addrLeasable4 returns true if ip may be leased to the client with mac on
+// iface, i.e. it's within the address space, free, and not offered to another
+// client.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) addrLeasable4(iface *iface4, mac net.HardwareAddr, ip netip.Addr) (ok bool) {
+	return iface.addrSpace.contains(ip) &&
+		srv.addrFree4(iface, ip) &&
+		!iface.offers.isReservedForOther(ip, mac, srv.now())
+}
+
+// offerTimeout returns the time an offered address stays reserved for the
+// client, see [Config.OfferTimeout].
+func (srv *DHCPServer) offerTimeout() (d time.Duration) {
+	if srv.conf.OfferTimeout > 0 {
+		return srv.conf.OfferTimeout
+	}
+
+	return defaultOfferTimeout
+}
+
+// handleRequest4 handles the DHCPREQUEST message from the client of the given
+// class and returns either the DHCPACK or the DHCPNAK reply.  resp is nil if
+// the message is addressed to another server or if it requests an address
+// outside of the subnet of the non-authoritative iface.
+func (srv *DHCPServer) handleRequest4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	class string,
+) (resp *layers.DHCPv4, err error) {
+	if srvID := optIP4(req, layers.DHCPOptServerID); srvID.IsValid() && srvID != iface.gateway {
+		log.Debug("dhcpsvc: client selected server %s, dropping message", srvID)
+
+		return nil, nil
+	}
+
+	reqIP := requestedIP4(req)
+	if !reqIP.IsValid() {
+		reqIP, _ = netip.AddrFromSlice(req.ClientIP.To4())
+	}
+
+	if !isSet4(reqIP) {
+		log.Debug("dhcpsvc: no requested address, dropping message")
+
+		return nil, nil
+	} else if !iface.subnet.Contains(reqIP) {
+		return iface.replyWrongNetwork4(req, reqIP), nil
+	}
+
+	// Look the client up before locking, since the lookups may be slow.
+	lk := srv.lookup4(req.ClientHWAddr)
+
+	var ttl time.Duration
+	var evs []*Event
+	var l *Lease
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		ttl = srv.jitterTTL(srv.leaseTTL4(iface, req, class))
+		l, evs, err = srv.commitLease4(iface, req, reqIP, lk, ttl)
+		if err != nil || len(evs) == 0 || evs[0].Type == EventTypeExhausted {
+			return nil, err
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("committing lease: %w", err)
+	}
+
+	srv.subscribers.notify(evs...)
+
+	if l == nil {
+		log.Debug("dhcpsvc: interface %q: can't lease %s to %s", iface.name, reqIP, req.ClientHWAddr)
+
+		return iface.newReply4(req, layers.DHCPMsgTypeNak, netip.Addr{}), nil
+	}
+
+	return srv.newAck4(iface, req, l, ttl, class), nil
+}
+
+// replyWrongNetwork4 returns the reply to req for reqIP, which is outside of
+// the subnet of iface.  resp is the DHCPNAK reply if iface is authoritative,
+// and nil otherwise.
+func (iface *iface4) replyWrongNetwork4(req *layers.DHCPv4, reqIP netip.Addr) (resp *layers.DHCPv4) {
+	if !iface.authoritative {
+		log.Debug(
+			"dhcpsvc: interface %q: requested address %s is not within %s, dropping message",
+			iface.name,
+			reqIP,
+			iface.subnet,
+		)
+
+		return nil
+	}
+
+	log.Debug(
+		"dhcpsvc: interface %q: requested address %s is not within %s, rejecting",
+		iface.name,
+		reqIP,
+		iface.subnet,
+	)
+
+	return iface.newReply4(req, layers.DHCPMsgTypeNak, netip.Addr{})
+}
+
+// newAck4 returns the DHCPACK reply to req received on iface from the client of
+// the given class granting l for ttl.
+func (srv *DHCPServer) newAck4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	l *Lease,
+	ttl time.Duration,
+	class string,
+) (resp *layers.DHCPv4) {
+	resp = iface.newReply4(req, layers.DHCPMsgTypeAck, l.IP)
+	resp.ClientIP = req.ClientIP
+	setProfile4(iface, resp, req, class)
+	setLeaseTime4(resp, ttl)
+	iface.setNetboot4(resp, req)
+	if iface.echoHostname && l.Hostname != "" {
+		resp.Options = append(resp.Options, layers.NewDHCPOption(
+			layers.DHCPOptHostname,
+			[]byte(l.Hostname),
+		))
+	}
+
+	return resp
+}
+
+// commitLease4 grants the lease for reqIP to the client sent req on iface for
+// ttl.  lk is what is looked up for the client with srv.leasesMu unlocked.  l
+// is a copy of the granted lease, and it's nil if reqIP can't be leased to the
+// client.  evs are the events to notify subscribers about, the first of which
+// is about l or about reaching the maximum number of leases.  evs are empty if
+// no leases changed.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) commitLease4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	reqIP netip.Addr,
+	lk lookups4,
+	ttl time.Duration,
+) (l *Lease, evs []*Event, err error) {
+	requested := requestedHostname4(req)
+	expiry := srv.now().Add(ttl)
+
+	prev, ok := iface.leases[leaseKey{mac: macToKey(req.ClientHWAddr)}]
+	if ok && iface.isDeprecated(prev) {
+		return srv.moveDeprecated4(iface, req, prev, reqIP, lk, expiry)
+	} else if ok && !prev.IsStatic && prev.IP != reqIP {
+		// Keep at most a single dynamic lease per client on iface, so release
+		// the previous address of the client.
+		return srv.moveLease4(iface, req, prev, reqIP, lk, expiry)
+	} else if ok {
+		if prev.IP != reqIP {
+			return nil, nil, nil
+		} else if prev.IsStatic {
+			return prev.Clone(), nil, nil
+		}
+
+		l = prev.Clone()
+		l.Expiry = expiry
+		l.Vendor = lk.vendor
+		l.Fingerprint = fingerprint4(req)
+
+		renamed, undo := srv.assignHostname(l, requested, prev)
+		if renamed == nil && l.sameExceptExpiry(prev) && !srv.renewalReportable(prev, expiry) {
+			// Only extend the lease, since nothing else has changed, and defer
+			// writing it, see [DHCPServer.storeDirty].
+			srv.dbDirty = srv.dbDirty || !expiry.Equal(prev.Expiry)
+			prev.Expiry = expiry
+
+			return prev.Clone(), nil, nil
+		}
+
+		l.reportedAt = srv.now()
+		err = srv.leases.update(prev, l, &iface.netInterface)
+		if err != nil {
+			undo()
+
+			return nil, nil, err
+		}
+
+		return l.Clone(), newCommitEvents(l, prev, EventTypeUpdated, renamed), nil
+	}
+
+	if ip := srv.leasableResolved4(iface, req.ClientHWAddr, lk.resolved); ip.IsValid() {
+		if ip != reqIP {
+			return nil, nil, nil
+		}
+	} else if !srv.addrLeasable4(iface, req.ClientHWAddr, reqIP) {
+		return nil, nil, nil
+	}
+
+	if srv.leasesExhausted() {
+		clientID := optData4(req, layers.DHCPOptClientID)
+
+		return nil, []*Event{srv.newExhaustedEvent(iface.name, req.ClientHWAddr, clientID)}, nil
+	}
+
+	l = &Lease{
+		IP:            reqIP,
+		Expiry:        expiry,
+		HWAddr:        slices.Clone(req.ClientHWAddr),
+		ClientID:      slices.Clone(optData4(req, layers.DHCPOptClientID)),
+		Fingerprint:   fingerprint4(req),
+		InterfaceName: iface.name,
+		Vendor:        lk.vendor,
+		reportedAt:    srv.now(),
+	}
+
+	reclaimed, err := srv.reclaimExpired4(reqIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	renamed, undo := srv.assignHostname(l, requested, nil)
+	err = srv.leases.add(l, &iface.netInterface)
+	if err != nil {
+		undo()
+
+		return nil, nil, err
+	}
+
+	iface.advance(reqIP)
+	iface.offers.release(reqIP)
+
+	evs = newCommitEvents(l, nil, EventTypeAdded, renamed)
+	if reclaimed != nil {
+		evs = append(evs, reclaimed)
+	}
+
+	return l.Clone(), evs, nil
+}
+
+// renewalReportable returns true if the renewal of prev until expiry, which
+// doesn't change it otherwise, should be reported, see
+// [Config.RenewalUpdateInterval].
+func (srv *DHCPServer) renewalReportable(prev *Lease, expiry time.Time) (ok bool) {
+	if expiry.Equal(prev.Expiry) {
+		return false
+	}
+
+	interval := srv.conf.RenewalUpdateInterval
+
+	return interval == 0 || !srv.now().Before(prev.reportedAt.Add(interval))
+}
+
+// moveDeprecated4 moves the deprecated lease prev of the client sent req on
+// iface to reqIP until expiry.  lk is the same as in
+// [DHCPServer.commitLease4].  l is nil if reqIP is the deprecated address
+// itself, so that the client is refused to renew it and acquires another one,
+// or if reqIP can't be leased to the client.  srv.leasesMu is expected to be
+// locked.
+func (srv *DHCPServer) moveDeprecated4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	prev *Lease,
+	reqIP netip.Addr,
+	lk lookups4,
+	expiry time.Time,
+) (l *Lease, evs []*Event, err error) {
+	if reqIP == prev.IP {
+		log.Debug("dhcpsvc: interface %q: lease for %s is deprecated", iface.name, reqIP)
+
+		return nil, nil, nil
+	}
+
+	return srv.moveLease4(iface, req, prev, reqIP, lk, expiry)
+}
+
+// moveLease4 moves the dynamic lease prev of the client sent req on iface to
+// reqIP until expiry, releasing the previous address.  lk is the same as in
+// [DHCPServer.commitLease4].  l is nil if reqIP can't be leased to the
+// client.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) moveLease4(
+	iface *iface4,
+	req *layers.DHCPv4,
+	prev *Lease,
+	reqIP netip.Addr,
+	lk lookups4,
+	expiry time.Time,
+) (l *Lease, evs []*Event, err error) {
+	if ip := srv.leasableResolved4(iface, req.ClientHWAddr, lk.resolved); ip.IsValid() {
+		if ip != reqIP {
+			return nil, nil, nil
+		}
+	} else if !srv.addrLeasable4(iface, req.ClientHWAddr, reqIP) {
+		return nil, nil, nil
+	}
+
+	l = prev.Clone()
+	l.IP = reqIP
+	l.Expiry = expiry
+	l.reportedAt = srv.now()
+	l.Vendor = lk.vendor
+	l.Fingerprint = fingerprint4(req)
+
+	reclaimed, err := srv.reclaimExpired4(reqIP)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	renamed, undo := srv.assignHostname(l, requestedHostname4(req), prev)
+	err = srv.leases.update(prev, l, &iface.netInterface)
+	if err != nil {
+		undo()
+
+		return nil, nil, err
+	}
+
+	iface.advance(reqIP)
+	iface.offers.release(reqIP)
+
+	evs = newCommitEvents(l, prev, EventTypeUpdated, renamed)
+	if reclaimed != nil {
+		evs = append(evs, reclaimed)
+	}
+
+	return l.Clone(), evs, nil
+}
+
+// newCommitEvents returns the events about committing l, which has changed from
+// prev as described by typ.  prev is nil for the added leases.  renamed is the
+// lease, which has yielded its hostname to l, if any.
+func newCommitEvents(l, prev *Lease, typ EventType, renamed *Lease) (evs []*Event) {
+	evs = []*Event{{Lease: l.Clone(), Prev: prev.Clone(), Type: typ}}
+	if renamed != nil {
+		evs = append(evs, &Event{Lease: renamed.Clone(), Type: EventTypeRenamed})
+	}
+
+	return evs
+}
+
+// handleRelease4 handles the DHCPRELEASE message by removing the dynamic lease
+// of the client, if any.
+func (srv *DHCPServer) handleRelease4(iface *iface4, req *layers.DHCPv4) (err error) {
+	ip, _ := netip.AddrFromSlice(req.ClientIP.To4())
+
+	var ev *Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		l, ok := iface.leases[leaseKey{mac: macToKey(req.ClientHWAddr)}]
+		if !ok || l.IsStatic || l.IP != ip {
+			return nil, nil
+		}
+
+		err = srv.leases.remove(l, &iface.netInterface)
+		if err != nil {
+			return nil, err
+		}
+
+		ev = &Event{Lease: l.Clone(), Type: EventTypeRemoved}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return fmt.Errorf("releasing lease: %w", err)
+	}
+
+	if ev != nil {
+		srv.subscribers.notify(ev)
+	}
+
+	return nil
+}
+
+// newReply4 returns a new reply of the given type to req, leasing ip.  ip is
+// ignored for DHCPNAK replies.  The data of resp's options must not be
+// modified.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-4.3.1.
+func (iface *iface4) newReply4(
+	req *layers.DHCPv4,
+	typ layers.DHCPMsgType,
+	ip netip.Addr,
+) (resp *layers.DHCPv4) {
+	resp = &layers.DHCPv4{
+		Operation:    layers.DHCPOpReply,
+		HardwareType: req.HardwareType,
+		HardwareLen:  req.HardwareLen,
+		Xid:          req.Xid,
+		Flags:        req.Flags,
+		RelayAgentIP: req.RelayAgentIP,
+		ClientHWAddr: req.ClientHWAddr,
+	}
+
+	if typ == layers.DHCPMsgTypeNak {
+		resp.Options = layers.DHCPOptions{newMsgTypeOpt4(typ), iface.srvIDOpt}
+
+		return resp
+	}
+
+	resp.YourClientIP = ip.AsSlice()
+
+	// Reserve slots for the Domain Name Server option and the options added by
+	// the caller, e.g. Host Name.
+	resp.Options = make(layers.DHCPOptions, 0, len(iface.replyOpts)+4)
+	resp.Options = append(resp.Options, newMsgTypeOpt4(typ), iface.srvIDOpt)
+	resp.Options = append(resp.Options, iface.replyOpts...)
+	if dnsOpt, ok := iface.dnsOpt(); ok {
+		resp.Options = append(resp.Options, dnsOpt)
+	}
+
+	return resp
+}
+
+// msgTypesData contains the data for the DHCP Message Type option of each
+// message type, so that the option is built without allocations.
+var msgTypesData = [...]byte{
+	byte(layers.DHCPMsgTypeUnspecified),
+	byte(layers.DHCPMsgTypeDiscover),
+	byte(layers.DHCPMsgTypeOffer),
+	byte(layers.DHCPMsgTypeRequest),
+	byte(layers.DHCPMsgTypeDecline),
+	byte(layers.DHCPMsgTypeAck),
+	byte(layers.DHCPMsgTypeNak),
+	byte(layers.DHCPMsgTypeRelease),
+	byte(layers.DHCPMsgTypeInform),
+	byte(dhcpMsgTypeForceRenew),
+	byte(dhcpMsgTypeLeaseQuery),
+	byte(dhcpMsgTypeLeaseUnassigned),
+	byte(dhcpMsgTypeLeaseUnknown),
+	byte(dhcpMsgTypeLeaseActive),
+}
+
+// newMsgTypeOpt4 returns the DHCP Message Type option of the given type.  typ
+// must be a valid message type.  The data of opt must not be modified.
+func newMsgTypeOpt4(typ layers.DHCPMsgType) (opt layers.DHCPOption) {
+	return layers.NewDHCPOption(layers.DHCPOptMessageType, msgTypesData[typ:typ+1])
+}
+
+// isSet4 returns true if ip is a valid IPv4 address, which is not unspecified.
+// It's used to check the address fields of DHCPv4 messages, which are zeroed
+// when not set.
+func isSet4(ip netip.Addr) (ok bool) {
+	return ip.Is4() && !ip.IsUnspecified()
+}
+
+// msgType4 returns the type of the DHCPv4 message.  typ is
+// [layers.DHCPMsgTypeUnspecified] if msg has no valid message type option.
+func msgType4(msg *layers.DHCPv4) (typ layers.DHCPMsgType) {
+	data := optData4(msg, layers.DHCPOptMessageType)
+	if len(data) != 1 {
+		return layers.DHCPMsgTypeUnspecified
+	}
+
+	return layers.DHCPMsgType(data[0])
+}
+
+// requestedIP4 returns the value of the Requested IP Address option of msg.  ip
+// is invalid if there is no such option.
+func requestedIP4(msg *layers.DHCPv4) (ip netip.Addr) {
+	return optIP4(msg, layers.DHCPOptRequestIP)
+}
+
+// optIP4 returns the IPv4 address contained in the option of the given type
+// within msg.  ip is invalid if there is no such option or it doesn't contain
+// an IPv4 address.
+func optIP4(msg *layers.DHCPv4, typ layers.DHCPOpt) (ip netip.Addr) {
+	data := optData4(msg, typ)
+	if len(data) != net.IPv4len {
+		return netip.Addr{}
+	}
+
+	return netip.AddrFrom4([net.IPv4len]byte(data))
+}
+
+// optData4 returns the data of the first option of the given type within msg.
+// data is nil if there is no such option.
+func optData4(msg *layers.DHCPv4, typ layers.DHCPOpt) (data []byte) {
+	for _, opt := range msg.Options {
+		if opt.Type == typ {
+			return opt.Data
+		}
+	}
+
+	return nil
+}
+
+// setOpt4 sets opt within the options of msg replacing the one of the same
+// type, if any.
+func setOpt4(msg *layers.DHCPv4, opt layers.DHCPOption) {
+	for i, o := range msg.Options {
+		if o.Type == opt.Type {
+			// Don't modify the data of the option, since it may be shared.
+			msg.Options[i] = opt
+
+			return
+		}
+	}
+
+	msg.Options = append(msg.Options, opt)
+}
@@ -0,0 +1,194 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+)
+
+// Sizes of DHCPv4 messages and their parts.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-2.
+const (
+	// minMsgSize4 is the minimum size of a DHCPv4 message, including the IP
+	// and UDP headers, every client must be able to receive.
+	minMsgSize4 = 576
+
+	// ipUDPHdrLen4 is the length of the IPv4 header without options and the
+	// UDP header.
+	ipUDPHdrLen4 = 20 + 8
+
+	// fixedLen4 is the length of the fixed-format part of a DHCPv4 message,
+	// including the magic cookie.
+	fixedLen4 = 240
+
+	// snameLen4 is the length of the sname field of a DHCPv4 message.
+	snameLen4 = 64
+
+	// fileLen4 is the length of the file field of a DHCPv4 message.
+	fileLen4 = 128
+)
+
+// Values of the Option Overload option.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2132#section-9.3.
+const (
+	overloadFile  byte = 1
+	overloadSname byte = 2
+)
+
+// maxMsgSize4 returns the maximum size of a DHCPv4 message the client sent req
+// accepts, including the IP and UDP headers.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2132#section-9.10.
+func maxMsgSize4(req *layers.DHCPv4) (size int) {
+	data := optData4(req, layers.DHCPOptMaxMessageSize)
+	if len(data) != 2 {
+		return minMsgSize4
+	}
+
+	size = int(binary.BigEndian.Uint16(data))
+	if size < minMsgSize4 {
+		return minMsgSize4
+	}
+
+	return size
+}
+
+// optArea4 is a part of DHCPv4 message containing options.
+type optArea4 struct {
+	// opts are the options put into the area.
+	opts layers.DHCPOptions
+
+	// avail is the number of bytes left in the area.
+	avail int
+
+	// overload is the value of Option Overload option meaning the options are
+	// put into this area.  It's zero for the options field.
+	overload byte
+}
+
+// add puts opt into a if there is enough space for it.
+func (a *optArea4) add(opt layers.DHCPOption) (ok bool) {
+	l := optLen4(opt)
+	if l > a.avail {
+		return false
+	}
+
+	a.opts = append(a.opts, opt)
+	a.avail -= l
+
+	return true
+}
+
+// fitReply4 makes resp fit into a message of maxSize bytes, including the IP
+// and UDP headers.  If the options don't fit into the options field, the unused
+// sname and file fields are overloaded with them.  The options, that still
+// don't fit, are dropped, so the options in resp should be ordered by their
+// importance.
+//
+// See https://datatracker.ietf.org/doc/html/rfc2131#section-4.1.
+func fitReply4(resp *layers.DHCPv4, maxSize int) {
+	// Reserve a byte for the End option.
+	avail := maxSize - ipUDPHdrLen4 - fixedLen4 - 1
+	if optsLen4(resp.Options) <= avail {
+		return
+	}
+
+	var areas []*optArea4
+	if len(resp.File) == 0 {
+		// Reserve a byte for the End option.
+		areas = append(areas, &optArea4{avail: fileLen4 - 1, overload: overloadFile})
+	}
+
+	if len(resp.ServerName) == 0 {
+		areas = append(areas, &optArea4{avail: snameLen4 - 1, overload: overloadSname})
+	}
+
+	if len(areas) > 0 {
+		// Reserve space for the Option Overload option itself.
+		avail -= 3
+	}
+
+	// The options field goes first since the most important options must be
+	// put there.
+	areas = append([]*optArea4{{avail: avail}}, areas...)
+
+	for _, opt := range resp.Options {
+		if !addToAreas4(areas, opt) {
+			log.Info("dhcpsvc: warning: dropping option %s: reply exceeds %d bytes", opt.Type, maxSize)
+		}
+	}
+
+	resp.Options = areas[0].opts
+
+	var overload byte
+	for _, a := range areas[1:] {
+		if len(a.opts) == 0 {
+			continue
+		}
+
+		overload |= a.overload
+		if a.overload == overloadFile {
+			resp.File = encodeOpts4(a.opts, fileLen4)
+		} else {
+			resp.ServerName = encodeOpts4(a.opts, snameLen4)
+		}
+	}
+
+	if overload != 0 {
+		resp.Options = append(resp.Options, layers.NewDHCPOption(
+			layers.DHCPOptExtOptions,
+			[]byte{overload},
+		))
+	}
+}
+
+// addToAreas4 puts opt into the first of areas having enough space for it.
+func addToAreas4(areas []*optArea4, opt layers.DHCPOption) (ok bool) {
+	for _, a := range areas {
+		if a.add(opt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// optLen4 returns the length of encoded opt.
+func optLen4(opt layers.DHCPOption) (l int) {
+	if opt.Type == layers.DHCPOptPad || opt.Type == layers.DHCPOptEnd {
+		return 1
+	}
+
+	return 2 + len(opt.Data)
+}
+
+// optsLen4 returns the length of encoded opts.
+func optsLen4(opts layers.DHCPOptions) (l int) {
+	for _, opt := range opts {
+		l += optLen4(opt)
+	}
+
+	return l
+}
+
+// encodeOpts4 encodes opts terminated with the End option into a field of the
+// given size.  opts must fit into it.
+func encodeOpts4(opts layers.DHCPOptions, size int) (data []byte) {
+	data = make([]byte, 0, size)
+	for _, opt := range opts {
+		if opt.Type == layers.DHCPOptPad {
+			data = append(data, byte(opt.Type))
+		} else {
+			data = append(data, byte(opt.Type), byte(len(opt.Data)))
+			data = append(data, opt.Data...)
+		}
+	}
+
+	data = append(data, byte(layers.DHCPOptEnd))
+
+	// Pad the rest of the field.
+	return data[:size]
+}
@@ -0,0 +1,152 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestOpts4 returns the site-specific options with data of the given
+// lengths.
+func newTestOpts4(lens ...int) (opts layers.DHCPOptions) {
+	for i, l := range lens {
+		opts = append(opts, layers.NewDHCPOption(layers.DHCPOpt(224+i), make([]byte, l)))
+	}
+
+	return opts
+}
+
+// decodeOptsField4 decodes the options put into the overloaded field of a
+// DHCPv4 message.
+func decodeOptsField4(t *testing.T, field []byte) (opts layers.DHCPOptions) {
+	t.Helper()
+
+	data := make([]byte, fixedLen4, fixedLen4+len(field))
+	binary.BigEndian.PutUint32(data[fixedLen4-4:], layers.DHCPMagic)
+	data = append(data, field...)
+
+	msg := &layers.DHCPv4{}
+	err := msg.DecodeFromBytes(data, gopacket.NilDecodeFeedback)
+	require.NoError(t, err)
+
+	return msg.Options
+}
+
+func TestDHCPServer_handle4_size(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	testCases := []struct {
+		name         string
+		opts         layers.DHCPOptions
+		wantOpts     layers.DHCPOptions
+		maxSize      uint16
+		wantOverload byte
+	}{{
+		name:         "fits",
+		opts:         newTestOpts4(100, 100),
+		wantOpts:     newTestOpts4(100, 100),
+		maxSize:      0,
+		wantOverload: 0,
+	}, {
+		name:         "overload_file",
+		opts:         newTestOpts4(100, 100, 100),
+		wantOpts:     newTestOpts4(100, 100, 100),
+		maxSize:      0,
+		wantOverload: overloadFile,
+	}, {
+		name:         "overload_both",
+		opts:         newTestOpts4(100, 100, 100, 60, 44),
+		wantOpts:     newTestOpts4(100, 100, 100, 60, 44),
+		maxSize:      0,
+		wantOverload: overloadFile | overloadSname,
+	}, {
+		name:         "max_size",
+		opts:         newTestOpts4(100, 100, 100, 100, 100, 100),
+		wantOpts:     newTestOpts4(100, 100, 100, 100, 100, 100),
+		maxSize:      1500,
+		wantOverload: 0,
+	}, {
+		name:         "drop",
+		opts:         newTestOpts4(100, 100, 100, 100, 100, 100),
+		wantOpts:     newTestOpts4(100, 100, 100),
+		maxSize:      0,
+		wantOverload: overloadFile,
+	}, {
+		name:         "small_max_size",
+		opts:         newTestOpts4(100, 100, 100),
+		wantOpts:     newTestOpts4(100, 100, 100),
+		maxSize:      300,
+		wantOverload: overloadFile,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := newTestIPv4Config()
+			for _, opt := range tc.opts {
+				conf.Options = append(conf.Options, newOptionFromDHCP(opt))
+			}
+			srv := newTestServer4(t, conf)
+
+			wantSize := minMsgSize4
+			var reqOpts []layers.DHCPOption
+			if tc.maxSize != 0 {
+				reqOpts = append(reqOpts, layers.NewDHCPOption(
+					layers.DHCPOptMaxMessageSize,
+					binary.BigEndian.AppendUint16(nil, tc.maxSize),
+				))
+
+				if int(tc.maxSize) > wantSize {
+					wantSize = int(tc.maxSize)
+				}
+			}
+
+			req := newTestRequest4(mac, layers.DHCPMsgTypeDiscover, reqOpts...)
+			resp, err := srv.handle4("eth0", req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			buf := gopacket.NewSerializeBuffer()
+			err = gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, resp)
+			require.NoError(t, err)
+
+			assert.LessOrEqual(t, len(buf.Bytes()), wantSize-ipUDPHdrLen4)
+
+			pkt := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeDHCPv4, gopacket.Default)
+			require.Nil(t, pkt.ErrorLayer())
+
+			decoded, ok := pkt.Layer(layers.LayerTypeDHCPv4).(*layers.DHCPv4)
+			require.True(t, ok)
+
+			opts := decoded.Options
+			assert.Equal(t, layers.DHCPMsgTypeOffer, msgType4(decoded))
+
+			var overload byte
+			if data := optData4(decoded, layers.DHCPOptExtOptions); len(data) == 1 {
+				overload = data[0]
+			}
+			require.Equal(t, tc.wantOverload, overload)
+
+			if overload&overloadFile != 0 {
+				opts = append(opts, decodeOptsField4(t, decoded.File)...)
+			}
+
+			if overload&overloadSname != 0 {
+				opts = append(opts, decodeOptsField4(t, decoded.ServerName)...)
+			}
+
+			var gotOpts layers.DHCPOptions
+			for _, opt := range opts {
+				if opt.Type >= 224 && opt.Type < 255 {
+					gotOpts = append(gotOpts, opt)
+				}
+			}
+
+			assert.ElementsMatch(t, tc.wantOpts, gotOpts)
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// v6PrefixLen is the length of the prefix of the network served by DHCPv6.
+const v6PrefixLen = 64
+
+// validateV6 returns an error in conf if any.
+func validateV6(conf *IPv6Config) (err error) {
+	switch {
+	case conf == nil:
+		return errNilConfig
+	case !conf.Enabled:
+		return nil
+	case !conf.RangeStart.Is6():
+		return newMustErr("range start", "be a valid ipv6", conf.RangeStart)
+	case conf.LeaseDuration <= 0:
+		return newMustErr("lease duration", "be positive", conf.LeaseDuration)
+	case conf.PreferredDuration < 0:
+		return newMustErr("preferred duration", "be non-negative", conf.PreferredDuration)
+	case conf.PreferredDuration > conf.LeaseDuration:
+		return newMustErr(
+			"preferred duration",
+			"not exceed lease duration "+conf.LeaseDuration.String(),
+			conf.PreferredDuration,
+		)
+	default:
+		return validateOpts6(conf.Options)
+	}
+}
+
+// serverOpts6 are the DHCPv6 options which are controlled by the server or
+// only sent by clients and relay agents, so those can't be configured.
+var serverOpts6 = []layers.DHCPv6Opt{
+	layers.DHCPv6OptClientID,
+	layers.DHCPv6OptServerID,
+	layers.DHCPv6OptIANA,
+	layers.DHCPv6OptIATA,
+	layers.DHCPv6OptIAAddr,
+	layers.DHCPv6OptOro,
+	layers.DHCPv6OptElapsedTime,
+	layers.DHCPv6OptRelayMessage,
+	layers.DHCPv6OptAuth,
+	layers.DHCPv6OptStatusCode,
+	layers.DHCPv6OptRapidCommit,
+	layers.DHCPv6OptInterfaceID,
+	layers.DHCPv6OptReconfigureMessage,
+	layers.DHCPv6OptReconfigureAccept,
+	layers.DHCPv6OptIAPD,
+	layers.DHCPv6OptIAPrefix,
+}
+
+// validateOpts6 returns an error if any of opts can't be sent to clients.
+//
+// See https://www.iana.org/assignments/dhcpv6-parameters/dhcpv6-parameters.xhtml#dhcpv6-parameters-2.
+func validateOpts6(opts layers.DHCPv6Options) (err error) {
+	for i, opt := range opts {
+		switch {
+		case opt.Code == 0:
+			return fmt.Errorf("option at index %d: code 0 is reserved", i)
+		case slices.Contains(serverOpts6, opt.Code):
+			return fmt.Errorf("option at index %d: %s is controlled by the server", i, opt.Code)
+		case int(opt.Length) != len(opt.Data):
+			return fmt.Errorf(
+				"option at index %d: length %d must be equal to the data length %d, "+
+					"consider using layers.NewDHCPv6Option",
+				i,
+				opt.Length,
+				len(opt.Data),
+			)
+		}
+	}
+
+	return nil
+}
+
+// iface6 is a DHCP interface for IPv6 address family.
+//
+// Its address space starts with the configured range start and ends with the
+// address having the same first 15 bytes and the last byte of 0xFF.
+type iface6 struct {
+	// nextAddr is the address to start looking for a free one from.  It's
+	// protected by [DHCPServer.leasesMu].
+	nextAddr netip.Addr
+
+	// srvIDOpt is the Server Identifier option containing the DUID of the
+	// server on this interface.
+	srvIDOpt layers.DHCPv6Option
+
+	// replyOpts are the options configured explicitly and the default Domain
+	// Search List option to be sent within every Advertise and Reply, except
+	// the replies to Release.  The data of these
+	// must not be modified.
+	replyOpts layers.DHCPv6Options
+
+	// dnsAddrs provides the addresses of the DNS server to advertise.  It's
+	// nil if the DNS Recursive Name Server option is configured explicitly.
+	dnsAddrs DNSAddrsFunc
+
+	// preferredTTL is the preferred lifetime of the leased addresses.  The
+	// granted one is capped at the valid lifetime, see [iface6.preferred].
+	preferredTTL time.Duration
+
+	// netInterface is embedded here to provide some common network interface
+	// logic.
+	netInterface
+
+	// raSLAACOnly defines if DHCP should send ICMPv6.RA packets without MO
+	// flags.
+	raSLAACOnly bool
+
+	// raAllowSLAAC defines if DHCP should send ICMPv6.RA packets with MO flags.
+	raAllowSLAAC bool
+
+	// rapidCommit defines if the Solicit messages with the Rapid Commit option
+	// are replied with Reply committing the leases.
+	rapidCommit bool
+
+	// bindLinkLocal defines if the connection is opened via the link-local
+	// address of the interface.
+	bindLinkLocal bool
+}
+
+// addrSpace returns the subnet and the address space for leasing configured by
+// conf.  It returns an error if those can't be used.  conf must be valid, see
+// [validateV6].
+func (conf *IPv6Config) addrSpace() (subnet netip.Prefix, addrSpace ipRange, err error) {
+	endData := conf.RangeStart.As16()
+	endData[15] = 0xFF
+
+	addrSpace, err = newIPRange(conf.RangeStart, netip.AddrFrom16(endData))
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return subnet, addrSpace, err
+	}
+
+	return netip.PrefixFrom(conf.RangeStart, v6PrefixLen).Masked(), addrSpace, nil
+}
+
+// newIface6 creates a new DHCP interface for IPv6 address family with the given
+// configuration.  It returns an error if the given configuration can't be used.
+// conf must be valid, see [validateV6].  domains are the search domains, see
+// [searchDomains].  dnsAddrs may be nil.  i is nil if conf is disabled.
+func newIface6(
+	name string,
+	conf *IPv6Config,
+	domains []string,
+	dnsAddrs DNSAddrsFunc,
+) (i *iface6, err error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	subnet, addrSpace, err := conf.addrSpace()
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	preferredTTL := conf.PreferredDuration
+	if preferredTTL == 0 {
+		preferredTTL = conf.LeaseDuration
+	}
+
+	ni := newNetInterface(name, subnet, addrSpace, conf.LeaseDuration, domains)
+	ni.paused = conf.Paused
+
+	replyOpts := conf.Options
+	if len(ni.searchList) > 0 && !slices.ContainsFunc(conf.Options, func(o layers.DHCPv6Option) (ok bool) {
+		return o.Code == layers.DHCPv6OptDomainList
+	}) {
+		replyOpts = append(layers.DHCPv6Options{
+			layers.NewDHCPv6Option(layers.DHCPv6OptDomainList, ni.searchList),
+		}, conf.Options...)
+	}
+
+	i = &iface6{
+		nextAddr:      addrSpace.start,
+		preferredTTL:  preferredTTL,
+		srvIDOpt:      layers.NewDHCPv6Option(layers.DHCPv6OptServerID, newServerDUID6()),
+		replyOpts:     replyOpts,
+		dnsAddrs:      dnsAddrs,
+		netInterface:  ni,
+		raSLAACOnly:   conf.RASLAACOnly,
+		raAllowSLAAC:  conf.RAAllowSLAAC,
+		rapidCommit:   conf.RapidCommit,
+		bindLinkLocal: conf.BindLinkLocal,
+	}
+
+	if slices.ContainsFunc(conf.Options, func(o layers.DHCPv6Option) (ok bool) {
+		return o.Code == layers.DHCPv6OptDNSServers
+	}) {
+		i.dnsAddrs = nil
+	}
+
+	return i, nil
+}
+
+// preferred returns the preferred lifetime of an address leased for the valid
+// lifetime of ttl.
+func (iface *iface6) preferred(ttl time.Duration) (preferred time.Duration) {
+	if iface.preferredTTL < ttl {
+		return iface.preferredTTL
+	}
+
+	return ttl
+}
+
+// dnsOpt returns the DNS Recursive Name Server option to send within Advertise
+// and Reply messages.  ok is false if there are no addresses to advertise.
+//
+// TODO(e.burkov):  Advertise the address of the network interface when
+// iface.dnsAddrs provide none, as it's done for DHCPv4.
+func (iface *iface6) dnsOpt() (opt layers.DHCPv6Option, ok bool) {
+	if iface.dnsAddrs == nil {
+		return opt, false
+	}
+
+	var data []byte
+	for _, addr := range iface.dnsAddrs(iface.name, false) {
+		if addr.Is6() && !addr.Is4In6() {
+			addrData := addr.As16()
+			data = append(data, addrData[:]...)
+		}
+	}
+
+	if len(data) == 0 {
+		return opt, false
+	}
+
+	return layers.NewDHCPv6Option(layers.DHCPv6OptDNSServers, data), true
+}
+
+// duidTypeUUID is the type of DUID based on Universally Unique Identifier.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-11.5.
+const duidTypeUUID = 4
+
+// newServerDUID6 returns a new DUID to identify the server with.
+//
+// TODO(e.burkov):  Persist the DUID, since the clients expect it to be stable.
+func newServerDUID6() (duid []byte) {
+	id := uuid.New()
+	duid = binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(id)), duidTypeUUID)
+
+	return append(duid, id[:]...)
+}
@@ -0,0 +1,649 @@
+package dhcpsvc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/exp/slices"
+)
+
+// Lengths of the fixed-format parts of DHCPv6 options.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-21.
+const (
+	// optHdrLen6 is the length of the option code and the option length.
+	optHdrLen6 = 4
+
+	// iaNAHdrLen6 is the length of IAID, T1, and T2 fields of the IA_NA
+	// option.
+	iaNAHdrLen6 = 12
+
+	// iaAddrHdrLen6 is the length of the address, the preferred lifetime, and
+	// the valid lifetime fields of the IA Address option.
+	iaAddrHdrLen6 = net.IPv6len + 8
+)
+
+// handle6 processes the DHCPv6 message req received on the network interface
+// with the given name and returns the reply to send back.  resp is nil if no
+// reply should be sent.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-18.3.
+func (srv *DHCPServer) handle6(ifaceName string, req *layers.DHCPv6) (resp *layers.DHCPv6, err error) {
+	defer func() { srv.record6(ifaceName, req, resp, err) }()
+
+	iface := srv.iface6ByName(ifaceName)
+	if iface == nil {
+		log.Debug("dhcpsvc: no ipv6 interface %q, dropping message", ifaceName)
+
+		return nil, nil
+	}
+
+	duid := optData6(req, layers.DHCPv6OptClientID)
+	if len(duid) == 0 {
+		log.Debug("dhcpsvc: no client identifier in %s, dropping message", req.MsgType)
+
+		return nil, nil
+	}
+
+	// TODO(e.burkov):  Support DUIDs without hardware addresses.
+	mac := macFromClientID(duid, false)
+	if mac == nil {
+		log.Debug("dhcpsvc: no hardware address in duid %x, dropping message", duid)
+
+		return nil, nil
+	}
+
+	if req.MsgType != layers.DHCPv6MsgTypeSolicit {
+		srvID := optData6(req, layers.DHCPv6OptServerID)
+		if !bytes.Equal(srvID, iface.srvIDOpt.Data) {
+			log.Debug("dhcpsvc: client selected server %x, dropping message", srvID)
+
+			return nil, nil
+		}
+	}
+
+	err = srv.expireLeases6(iface)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	// TODO(e.burkov):  Handle relayed messages.
+	switch req.MsgType {
+	case layers.DHCPv6MsgTypeSolicit:
+		if iface.rapidCommit && hasOpt6(req, layers.DHCPv6OptRapidCommit) {
+			return srv.handleRapidCommit6(iface, req, mac, duid)
+		}
+
+		return srv.handleSolicit6(iface, req, mac), nil
+	case layers.DHCPv6MsgTypeRequest, layers.DHCPv6MsgTypeRenew:
+		return srv.handleRequest6(iface, req, mac, duid)
+	case layers.DHCPv6MsgTypeRelease:
+		return srv.handleRelease6(iface, req, mac)
+	default:
+		log.Debug("dhcpsvc: unsupported message type %s, dropping message", req.MsgType)
+
+		return nil, nil
+	}
+}
+
+// handleSolicit6 handles the Solicit message and returns the Advertise reply
+// offering an address for each IA_NA requested.
+func (srv *DHCPServer) handleSolicit6(
+	iface *iface6,
+	req *layers.DHCPv6,
+	mac net.HardwareAddr,
+) (resp *layers.DHCPv6) {
+	ias := iaNAs6(req)
+	resp = iface.newReply6(req, layers.DHCPv6MsgTypeAdverstise, len(ias))
+
+	srv.leasesMu.RLock()
+	defer srv.leasesMu.RUnlock()
+
+	taken := make([]netip.Addr, 0, len(ias))
+	for _, ia := range ias {
+		ip := srv.offerAddr6(iface, mac, ia, taken)
+		if ip.IsValid() {
+			taken = append(taken, ip)
+		} else {
+			srv.recordAllocFail(iface.name, mac, srv.allocFailReason())
+		}
+
+		ttl := iface.leaseTTL
+		resp.Options = append(resp.Options, newIANAOpt6(ia.iaid, ip, iface.preferred(ttl), ttl))
+	}
+
+	return resp
+}
+
+// handleRapidCommit6 handles the Solicit message containing the Rapid Commit
+// option and returns the Reply granting an address for each IA_NA requested.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-18.3.1.
+func (srv *DHCPServer) handleRapidCommit6(
+	iface *iface6,
+	req *layers.DHCPv6,
+	mac net.HardwareAddr,
+	duid []byte,
+) (resp *layers.DHCPv6, err error) {
+	resp, err = srv.handleRequest6(iface, req, mac, duid)
+	if err != nil {
+		// Don't wrap the error since it's informative enough as is.
+		return nil, err
+	}
+
+	resp.Options = append(resp.Options, layers.NewDHCPv6Option(layers.DHCPv6OptRapidCommit, nil))
+
+	return resp, nil
+}
+
+// offerAddr6 returns the address to offer to the client with mac for ia on
+// iface.  taken are the addresses already offered to the client within the
+// same message.  ip is invalid if there are no free addresses.  The client
+// holding a deprecated lease is offered another address, see
+// [netInterface.isDeprecated].  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) offerAddr6(
+	iface *iface6,
+	mac net.HardwareAddr,
+	ia iaNA6,
+	taken []netip.Addr,
+) (ip netip.Addr) {
+	l := leaseForIA6(iface, mac, ia.iaid)
+	deprecated := l != nil && iface.isDeprecated(l)
+	if l != nil && !deprecated && !slices.Contains(taken, l.IP) {
+		return l.IP
+	} else if !deprecated && srv.leasesExhausted() {
+		return netip.Addr{}
+	}
+
+	return srv.freeAddr6(iface, ia.addr, taken)
+}
+
+// leaseForIA6 returns the lease of the client with mac for the identity
+// association with iaid on iface.  The static lease of the client matches any
+// identity association.  l is nil if there is no such lease.
+// srv.leasesMu is expected to be locked.
+func leaseForIA6(iface *iface6, mac net.HardwareAddr, iaid uint32) (l *Lease) {
+	mk := macToKey(mac)
+	if l = iface.leases[leaseKey{mac: mk, iaid: iaid}]; l != nil {
+		return l
+	}
+
+	if l = iface.leases[leaseKey{mac: mk}]; l != nil && l.IsStatic {
+		return l
+	}
+
+	return nil
+}
+
+// freeAddr6 returns the address on iface, which isn't leased and isn't within
+// taken.  hint is the address requested by the client, if any.  ip is invalid
+// if there are no free addresses.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) freeAddr6(iface *iface6, hint netip.Addr, taken []netip.Addr) (ip netip.Addr) {
+	now := srv.now()
+	isFree := func(ip netip.Addr) (ok bool) {
+		_, ok = srv.leases.leaseByAddr(ip)
+
+		return !ok && !slices.Contains(taken, ip) && !iface.isQuarantined(ip, now)
+	}
+
+	if iface.addrSpace.contains(hint) && isFree(hint) {
+		return hint
+	}
+
+	return iface.addrSpace.findFrom(iface.nextAddr, isFree)
+}
+
+// handleRequest6 handles the Request and Renew messages and returns the Reply
+// granting an address for each IA_NA requested.  The deprecated leases aren't
+// renewed, and the NoBinding status is sent for those instead, so that the
+// client acquires other addresses, see [netInterface.isDeprecated].
+func (srv *DHCPServer) handleRequest6(
+	iface *iface6,
+	req *layers.DHCPv6,
+	mac net.HardwareAddr,
+	duid []byte,
+) (resp *layers.DHCPv6, err error) {
+	ias := iaNAs6(req)
+	ips := make([]netip.Addr, 0, len(ias))
+	leases := make([]*Lease, 0, len(ias))
+	noBinding := make([]bool, len(ias))
+	isRenew := req.MsgType == layers.DHCPv6MsgTypeRenew
+
+	// Look the vendor up before locking, since the lookup may be slow.
+	vendor := srv.vendor(mac)
+
+	var ttl time.Duration
+	var evs []*Event
+	now := srv.now()
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		ttl = srv.jitterTTL(iface.leaseTTL)
+		for i, ia := range ias {
+			prev := leaseForIA6(iface, mac, ia.iaid)
+			if isRenew && prev != nil && iface.isDeprecated(prev) {
+				noBinding[i] = true
+				ips = append(ips, netip.Addr{})
+				leases = append(leases, nil)
+
+				continue
+			}
+
+			var l *Lease
+			var ev *Event
+			l, ev, err = srv.commitLease6(iface, mac, duid, vendor, ia, ips, now, ttl)
+			if err != nil {
+				return nil, fmt.Errorf("iaid %d: %w", ia.iaid, err)
+			}
+
+			var ip netip.Addr
+			if l != nil {
+				ip = l.IP
+			}
+
+			ips = append(ips, ip)
+			leases = append(leases, l)
+			if ev != nil {
+				evs = append(evs, ev)
+			}
+		}
+
+		changed := slices.ContainsFunc(evs, func(ev *Event) (ok bool) {
+			return ev.Type != EventTypeExhausted
+		})
+		if !changed {
+			return nil, nil
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("committing leases: %w", err)
+	}
+
+	if len(evs) > 0 {
+		srv.subscribers.notify(evs...)
+	}
+
+	resp = iface.newReply6(req, layers.DHCPv6MsgTypeReply, len(ias))
+	for i, ia := range ias {
+		l := leases[i]
+		if noBinding[i] {
+			log.Debug("dhcpsvc: interface %q: lease of %s for iaid %d is deprecated", iface.name, mac, ia.iaid)
+			resp.Options = append(resp.Options, newIANAStatusOpt6(
+				ia.iaid,
+				layers.DHCPv6StatusCodeNoBinding,
+				"lease is deprecated",
+			))
+
+			continue
+		} else if l == nil {
+			log.Debug("dhcpsvc: interface %q: can't lease address to %s for iaid %d", iface.name, mac, ia.iaid)
+			resp.Options = append(resp.Options, newIANAOpt6(ia.iaid, netip.Addr{}, 0, 0))
+
+			continue
+		}
+
+		preferred, valid := iface.lifetimes(l, now, ttl)
+		resp.Options = append(resp.Options, newIANAOpt6(ia.iaid, l.IP, preferred, valid))
+	}
+
+	return resp, nil
+}
+
+// lifetimes returns the preferred and valid lifetimes of the address of l
+// remaining at now.  The lifetimes of static leases are derived from ttl.
+func (iface *iface6) lifetimes(
+	l *Lease,
+	now time.Time,
+	ttl time.Duration,
+) (preferred, valid time.Duration) {
+	if l.IsStatic {
+		return iface.preferred(ttl), ttl
+	}
+
+	return l.PreferredUntil.Sub(now), l.Expiry.Sub(now)
+}
+
+// commitLease6 grants the lease for ia to the client with mac, duid and vendor
+// on iface for ttl starting at now.  taken are the addresses already granted to
+// the client within the same message.  l is a copy of the granted lease, and
+// it's nil if there are no addresses to lease.  ev is the event to notify
+// subscribers about, it's nil if no leases changed and the maximum number of
+// leases isn't reached.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) commitLease6(
+	iface *iface6,
+	mac net.HardwareAddr,
+	duid []byte,
+	vendor string,
+	ia iaNA6,
+	taken []netip.Addr,
+	now time.Time,
+	ttl time.Duration,
+) (l *Lease, ev *Event, err error) {
+	expiry := now.Add(ttl)
+	preferredUntil := now.Add(iface.preferred(ttl))
+
+	prev := leaseForIA6(iface, mac, ia.iaid)
+	switch {
+	case prev == nil, slices.Contains(taken, prev.IP):
+		// Go on and lease a new address.
+	case prev.IsStatic:
+		return prev.Clone(), nil, nil
+	case iface.isDeprecated(prev):
+		return srv.moveDeprecated6(iface, prev, duid, ia, taken, expiry, preferredUntil)
+	default:
+		l = prev.Clone()
+		l.Expiry = expiry
+		l.PreferredUntil = preferredUntil
+		l.ClientID = slices.Clone(duid)
+		l.Vendor = vendor
+
+		err = srv.leases.update(prev, l, &iface.netInterface)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return l.Clone(), &Event{Lease: l.Clone(), Prev: prev.Clone(), Type: EventTypeUpdated}, nil
+	}
+
+	if srv.leasesExhausted() {
+		return nil, srv.newExhaustedEvent(iface.name, mac, duid), nil
+	}
+
+	ip := srv.freeAddr6(iface, ia.addr, taken)
+	if !ip.IsValid() {
+		srv.recordAllocFail(iface.name, mac, AllocFailReasonPoolExhausted)
+
+		return nil, nil, nil
+	}
+
+	// TODO(e.burkov):  Assign hostnames from the Client FQDN option.
+	l = &Lease{
+		IP:             ip,
+		Expiry:         expiry,
+		PreferredUntil: preferredUntil,
+		HWAddr:         slices.Clone(mac),
+		Vendor:         vendor,
+		ClientID:       slices.Clone(duid),
+		InterfaceName:  iface.name,
+		IAID:           ia.iaid,
+	}
+
+	err = srv.leases.add(l, &iface.netInterface)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iface.nextAddr = ip.Next()
+
+	return l.Clone(), &Event{Lease: l.Clone(), Type: EventTypeAdded}, nil
+}
+
+// moveDeprecated6 moves the deprecated lease prev of the client with duid to a
+// free address for ia on iface until expiry.  taken are the addresses already
+// granted to the client within the same message.  l is nil if there are no
+// addresses to lease.  srv.leasesMu is expected to be locked.
+func (srv *DHCPServer) moveDeprecated6(
+	iface *iface6,
+	prev *Lease,
+	duid []byte,
+	ia iaNA6,
+	taken []netip.Addr,
+	expiry time.Time,
+	preferredUntil time.Time,
+) (l *Lease, ev *Event, err error) {
+	ip := srv.freeAddr6(iface, ia.addr, taken)
+	if !ip.IsValid() {
+		srv.recordAllocFail(iface.name, prev.HWAddr, AllocFailReasonPoolExhausted)
+
+		return nil, nil, nil
+	}
+
+	l = prev.Clone()
+	l.IP = ip
+	l.Expiry = expiry
+	l.PreferredUntil = preferredUntil
+	l.ClientID = slices.Clone(duid)
+
+	err = srv.leases.update(prev, l, &iface.netInterface)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	iface.nextAddr = ip.Next()
+
+	return l.Clone(), &Event{Lease: l.Clone(), Prev: prev.Clone(), Type: EventTypeUpdated}, nil
+}
+
+// handleRelease6 handles the Release message by removing the dynamic leases of
+// the client for each IA_NA released.
+func (srv *DHCPServer) handleRelease6(
+	iface *iface6,
+	req *layers.DHCPv6,
+	mac net.HardwareAddr,
+) (resp *layers.DHCPv6, err error) {
+	var evs []*Event
+	err = srv.withLeasesStored(func() (st *dbState, err error) {
+		mk := macToKey(mac)
+		for _, ia := range iaNAs6(req) {
+			l, ok := iface.leases[leaseKey{mac: mk, iaid: ia.iaid}]
+			if !ok || l.IsStatic || l.IP != ia.addr {
+				continue
+			}
+
+			err = srv.leases.remove(l, &iface.netInterface)
+			if err != nil {
+				return nil, fmt.Errorf("iaid %d: %w", ia.iaid, err)
+			}
+
+			evs = append(evs, &Event{Lease: l.Clone(), Type: EventTypeRemoved})
+		}
+
+		if len(evs) == 0 {
+			return nil, nil
+		}
+
+		return srv.dbSnapshot(), nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("releasing leases: %w", err)
+	}
+
+	if len(evs) > 0 {
+		srv.subscribers.notify(evs...)
+	}
+
+	resp = iface.newReply6(req, layers.DHCPv6MsgTypeReply, 1)
+	resp.Options = append(resp.Options, newStatusOpt6(layers.DHCPv6StatusCodeSuccess, "released"))
+
+	return resp, nil
+}
+
+// newReply6 returns a new reply of the given type to req.  n is the number of
+// options the caller is going to add.  The data of resp's options must not be
+// modified.
+func (iface *iface6) newReply6(req *layers.DHCPv6, typ layers.DHCPv6MsgType, n int) (resp *layers.DHCPv6) {
+	resp = &layers.DHCPv6{
+		MsgType:       typ,
+		TransactionID: req.TransactionID,
+		Options:       make(layers.DHCPv6Options, 0, 3+len(iface.replyOpts)+n),
+	}
+
+	resp.Options = append(
+		resp.Options,
+		layers.NewDHCPv6Option(layers.DHCPv6OptClientID, optData6(req, layers.DHCPv6OptClientID)),
+		iface.srvIDOpt,
+	)
+
+	if req.MsgType == layers.DHCPv6MsgTypeRelease {
+		return resp
+	}
+
+	if dnsOpt, ok := iface.dnsOpt(); ok {
+		resp.Options = append(resp.Options, dnsOpt)
+	}
+
+	resp.Options = append(resp.Options, iface.replyOpts...)
+
+	return resp
+}
+
+// iaNA6 is the Identity Association for Non-temporary Addresses requested by
+// the client.
+type iaNA6 struct {
+	// addr is the address the client hints at or releases, if any.
+	addr netip.Addr
+
+	// iaid is the identifier of the identity association.
+	iaid uint32
+}
+
+// iaNAs6 returns the IA_NA options of msg in the order of their appearance.
+// The malformed options and the ones repeating an IAID are skipped.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-21.4.
+func iaNAs6(msg *layers.DHCPv6) (ias []iaNA6) {
+	for _, opt := range msg.Options {
+		if opt.Code != layers.DHCPv6OptIANA || len(opt.Data) < iaNAHdrLen6 {
+			continue
+		}
+
+		ia := iaNA6{iaid: binary.BigEndian.Uint32(opt.Data)}
+		if slices.ContainsFunc(ias, func(other iaNA6) (ok bool) { return other.iaid == ia.iaid }) {
+			log.Debug("dhcpsvc: duplicate iaid %d, skipping", ia.iaid)
+
+			continue
+		}
+
+		addrData := nestedOptData6(opt.Data[iaNAHdrLen6:], layers.DHCPv6OptIAAddr)
+		if len(addrData) >= iaAddrHdrLen6 {
+			ia.addr = netip.AddrFrom16([net.IPv6len]byte(addrData))
+		}
+
+		ias = append(ias, ia)
+	}
+
+	return ias
+}
+
+// newIANAOpt6 returns the IA_NA option for the identity association with iaid
+// leasing ip for the given preferred and valid lifetimes.  The option contains
+// the NoAddrsAvail status instead if ip is invalid.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-21.4.
+func newIANAOpt6(
+	iaid uint32,
+	ip netip.Addr,
+	preferred time.Duration,
+	valid time.Duration,
+) (opt layers.DHCPv6Option) {
+	if !ip.IsValid() {
+		return newIANAStatusOpt6(iaid, layers.DHCPv6StatusCodeNoAddrsAvail, "no addresses available")
+	}
+
+	data := make([]byte, iaNAHdrLen6, iaNAHdrLen6+optHdrLen6+iaAddrHdrLen6)
+	binary.BigEndian.PutUint32(data, iaid)
+
+	// Use the recommended values of T1 and T2, which are based on the
+	// preferred lifetime.  See RFC 8415, Section 21.4.
+	preferredSecs, validSecs := lifetimeSecs6(preferred), lifetimeSecs6(valid)
+	binary.BigEndian.PutUint32(data[4:], preferredSecs/2)
+	binary.BigEndian.PutUint32(data[8:], preferredSecs/5*4)
+
+	addrData := ip.As16()
+	iaAddr := make([]byte, 0, iaAddrHdrLen6)
+	iaAddr = append(iaAddr, addrData[:]...)
+	iaAddr = binary.BigEndian.AppendUint32(iaAddr, preferredSecs)
+	iaAddr = binary.BigEndian.AppendUint32(iaAddr, validSecs)
+
+	data = appendOpt6(data, layers.DHCPv6OptIAAddr, iaAddr)
+
+	return layers.NewDHCPv6Option(layers.DHCPv6OptIANA, data)
+}
+
+// newIANAStatusOpt6 returns the IA_NA option for the identity association with
+// iaid containing no addresses, but the Status Code option with the given code
+// and message.
+func newIANAStatusOpt6(iaid uint32, code layers.DHCPv6StatusCode, msg string) (opt layers.DHCPv6Option) {
+	status := newStatusOpt6(code, msg)
+
+	data := make([]byte, iaNAHdrLen6, iaNAHdrLen6+optHdrLen6+len(status.Data))
+	binary.BigEndian.PutUint32(data, iaid)
+	data = appendOpt6(data, status.Code, status.Data)
+
+	return layers.NewDHCPv6Option(layers.DHCPv6OptIANA, data)
+}
+
+// lifetimeSecs6 returns d in whole seconds as sent within DHCPv6 messages.
+// Negative durations are turned into zero.
+func lifetimeSecs6(d time.Duration) (secs uint32) {
+	if d <= 0 {
+		return 0
+	}
+
+	return uint32(d / time.Second)
+}
+
+// newStatusOpt6 returns the Status Code option with the given code and
+// message.
+//
+// See https://datatracker.ietf.org/doc/html/rfc8415#section-21.13.
+func newStatusOpt6(code layers.DHCPv6StatusCode, msg string) (opt layers.DHCPv6Option) {
+	data := make([]byte, 0, 2+len(msg))
+	data = binary.BigEndian.AppendUint16(data, uint16(code))
+	data = append(data, msg...)
+
+	return layers.NewDHCPv6Option(layers.DHCPv6OptStatusCode, data)
+}
+
+// appendOpt6 appends the encoded option with the given code and data to b.
+func appendOpt6(b []byte, code layers.DHCPv6Opt, data []byte) (res []byte) {
+	b = binary.BigEndian.AppendUint16(b, uint16(code))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+
+	return append(b, data...)
+}
+
+// hasOpt6 returns true if msg contains the option with the given code.
+func hasOpt6(msg *layers.DHCPv6, code layers.DHCPv6Opt) (ok bool) {
+	return slices.ContainsFunc(msg.Options, func(o layers.DHCPv6Option) (found bool) {
+		return o.Code == code
+	})
+}
+
+// optData6 returns the data of the first option of the given type within msg.
+// data is nil if there is no such option.
+func optData6(msg *layers.DHCPv6, code layers.DHCPv6Opt) (data []byte) {
+	for _, opt := range msg.Options {
+		if opt.Code == code {
+			return opt.Data
+		}
+	}
+
+	return nil
+}
+
+// nestedOptData6 returns the data of the first option of the given type
+// encoded within opts, which is the data of an encapsulating option.  data is
+// nil if there is no such option or opts are malformed.
+func nestedOptData6(opts []byte, code layers.DHCPv6Opt) (data []byte) {
+	for len(opts) >= optHdrLen6 {
+		c := layers.DHCPv6Opt(binary.BigEndian.Uint16(opts))
+		l := int(binary.BigEndian.Uint16(opts[2:])) + optHdrLen6
+		if l > len(opts) {
+			return nil
+		} else if c == code {
+			return opts[optHdrLen6:l]
+		}
+
+		opts = opts[l:]
+	}
+
+	return nil
+}
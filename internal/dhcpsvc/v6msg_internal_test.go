@@ -0,0 +1,400 @@
+package dhcpsvc
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer6 returns a new DHCP server with a single IPv6 interface named
+// "eth0" leasing addresses starting from rangeStart.
+func newTestServer6(t testing.TB, rangeStart netip.Addr) (srv *DHCPServer) {
+	t.Helper()
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: &IPv4Config{Enabled: false},
+				IPv6: &IPv6Config{
+					Enabled:       true,
+					RangeStart:    rangeStart,
+					LeaseDuration: 1 * time.Hour,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return srv
+}
+
+// newTestDUID6 returns a new DUID-LL of the client with mac.
+func newTestDUID6(mac net.HardwareAddr) (duid []byte) {
+	duid = binary.BigEndian.AppendUint16(nil, duidTypeLL)
+	duid = binary.BigEndian.AppendUint16(duid, hwTypeEthernet)
+
+	return append(duid, mac...)
+}
+
+// newTestRequest6 returns a new DHCPv6 message of the given type from the
+// client with duid requesting an address for each of iaids.
+func newTestRequest6(
+	typ layers.DHCPv6MsgType,
+	duid []byte,
+	iaids ...uint32,
+) (req *layers.DHCPv6) {
+	req = &layers.DHCPv6{
+		MsgType:       typ,
+		TransactionID: []byte{1, 2, 3},
+		Options: layers.DHCPv6Options{
+			layers.NewDHCPv6Option(layers.DHCPv6OptClientID, duid),
+		},
+	}
+
+	for _, iaid := range iaids {
+		data := binary.BigEndian.AppendUint32(make([]byte, 0, iaNAHdrLen6), iaid)
+		data = append(data, make([]byte, 8)...)
+		req.Options = append(req.Options, layers.NewDHCPv6Option(layers.DHCPv6OptIANA, data))
+	}
+
+	return req
+}
+
+// reencode6 serializes msg and decodes it back, so that the lengths of options
+// are set as they'd be on the wire.
+func reencode6(t testing.TB, msg *layers.DHCPv6) (decoded *layers.DHCPv6) {
+	t.Helper()
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, msg)
+	require.NoError(t, err)
+
+	decoded = &layers.DHCPv6{}
+	err = decoded.DecodeFromBytes(buf.Bytes(), gopacket.NilDecodeFeedback)
+	require.NoError(t, err)
+
+	return decoded
+}
+
+func TestDHCPServer_handle6_multipleIANA(t *testing.T) {
+	const ifaceName = "eth0"
+
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	duid := newTestDUID6(mac)
+	iaids := []uint32{1, 2, 3}
+
+	testCases := []struct {
+		rangeStart netip.Addr
+		name       string
+		wantAddrs  int
+	}{{
+		rangeStart: netip.MustParseAddr("2001:db8::1"),
+		name:       "enough",
+		wantAddrs:  3,
+	}, {
+		rangeStart: netip.MustParseAddr("2001:db8::fe"),
+		name:       "exhausted",
+		wantAddrs:  2,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer6(t, tc.rangeStart)
+
+			req := newTestRequest6(layers.DHCPv6MsgTypeSolicit, duid, iaids...)
+			resp, err := srv.handle6(ifaceName, req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			resp = reencode6(t, resp)
+			assert.Equal(t, layers.DHCPv6MsgTypeAdverstise, resp.MsgType)
+			offered := iaNAs6(resp)
+			assertIANAs6(t, iaids, tc.wantAddrs, offered)
+
+			req = newTestRequest6(layers.DHCPv6MsgTypeRequest, duid, iaids...)
+			req.Options = append(req.Options, layers.NewDHCPv6Option(
+				layers.DHCPv6OptServerID,
+				optData6(resp, layers.DHCPv6OptServerID),
+			))
+
+			resp, err = srv.handle6(ifaceName, req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			resp = reencode6(t, resp)
+			assert.Equal(t, layers.DHCPv6MsgTypeReply, resp.MsgType)
+			granted := iaNAs6(resp)
+			assertIANAs6(t, iaids, tc.wantAddrs, granted)
+			assert.Equal(t, offered, granted)
+
+			leases := srv.Leases()
+			require.Len(t, leases, tc.wantAddrs)
+
+			for _, l := range leases {
+				assert.Contains(t, granted, iaNA6{addr: l.IP, iaid: l.IAID})
+				assert.Equal(t, mac, l.HWAddr)
+				assert.Equal(t, duid, l.ClientID)
+			}
+
+			// Renewal keeps the addresses of each identity association.
+			req.MsgType = layers.DHCPv6MsgTypeRenew
+			resp, err = srv.handle6(ifaceName, req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, granted, iaNAs6(reencode6(t, resp)))
+		})
+	}
+}
+
+// assertIANAs6 checks that ias are given for each of iaids in the same order
+// and that exactly wantAddrs of them contain distinct addresses.
+func assertIANAs6(t *testing.T, iaids []uint32, wantAddrs int, ias []iaNA6) {
+	t.Helper()
+
+	require.Len(t, ias, len(iaids))
+
+	addrs := map[netip.Addr]struct{}{}
+	for i, ia := range ias {
+		assert.Equal(t, iaids[i], ia.iaid)
+
+		if ia.addr.IsValid() {
+			addrs[ia.addr] = struct{}{}
+		}
+	}
+
+	assert.Len(t, addrs, wantAddrs)
+}
+
+func TestDHCPServer_handle6_release(t *testing.T) {
+	const ifaceName = "eth0"
+
+	srv := newTestServer6(t, netip.MustParseAddr("2001:db8::1"))
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	srvIDOpt := srv.iface6ByName(ifaceName).srvIDOpt
+
+	req := newTestRequest6(layers.DHCPv6MsgTypeRequest, duid, 1, 2)
+	req.Options = append(req.Options, srvIDOpt)
+
+	resp, err := srv.handle6(ifaceName, req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	granted := iaNAs6(reencode6(t, resp))
+	require.Len(t, granted, 2)
+
+	// Release only the second identity association.
+	iaAddr := granted[1].addr.As16()
+	iaNA := binary.BigEndian.AppendUint32(nil, granted[1].iaid)
+	iaNA = append(iaNA, make([]byte, 8)...)
+	iaNA = appendOpt6(iaNA, layers.DHCPv6OptIAAddr, append(iaAddr[:], make([]byte, 8)...))
+
+	req = newTestRequest6(layers.DHCPv6MsgTypeRelease, duid)
+	req.Options = append(req.Options, srvIDOpt, layers.NewDHCPv6Option(layers.DHCPv6OptIANA, iaNA))
+
+	resp, err = srv.handle6(ifaceName, req)
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+
+	leases := srv.Leases()
+	require.Len(t, leases, 1)
+
+	assert.Equal(t, granted[0].addr, leases[0].IP)
+	assert.Equal(t, granted[0].iaid, leases[0].IAID)
+}
+
+func TestDHCPServer_handle6_dnsAddrs(t *testing.T) {
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	dnsAddr := netip.MustParseAddr("2001:db8::53")
+	dnsData := dnsAddr.As16()
+
+	newServer := func(t *testing.T, dnsAddrs DNSAddrsFunc) (srv *DHCPServer) {
+		srv, err := New(&Config{
+			Enabled:         true,
+			LocalDomainName: "local",
+			DNSAddrs:        dnsAddrs,
+			Interfaces: map[string]*InterfaceConfig{
+				"eth0": {
+					IPv4: &IPv4Config{Enabled: false},
+					IPv6: &IPv6Config{
+						Enabled:       true,
+						RangeStart:    netip.MustParseAddr("2001:db8::1"),
+						LeaseDuration: 1 * time.Hour,
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		return srv
+	}
+
+	testCases := []struct {
+		dnsAddrs DNSAddrsFunc
+		name     string
+		want     []byte
+	}{{
+		dnsAddrs: nil,
+		name:     "no_provider",
+		want:     nil,
+	}, {
+		dnsAddrs: func(_ string, is4 bool) (addrs []netip.Addr) {
+			require.False(t, is4)
+
+			return []netip.Addr{netip.MustParseAddr("192.168.0.1"), dnsAddr}
+		},
+		name: "provider",
+		want: dnsData[:],
+	}, {
+		dnsAddrs: func(_ string, _ bool) (addrs []netip.Addr) {
+			return []netip.Addr{netip.MustParseAddr("192.168.0.1")}
+		},
+		name: "provider_other_family",
+		want: nil,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newServer(t, tc.dnsAddrs)
+
+			req := newTestRequest6(layers.DHCPv6MsgTypeSolicit, duid, 1)
+			resp, err := srv.handle6("eth0", req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.want, optData6(reencode6(t, resp), layers.DHCPv6OptDNSServers))
+		})
+	}
+}
+
+func TestDHCPServer_handle6_options(t *testing.T) {
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	ntpData := []byte("ntp.example")
+	dnsAddr := netip.MustParseAddr("2001:db8::53")
+	dnsData := dnsAddr.As16()
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		DNSAddrs: func(_ string, _ bool) (addrs []netip.Addr) {
+			return []netip.Addr{netip.MustParseAddr("2001:db8::1")}
+		},
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: &IPv4Config{Enabled: false},
+				IPv6: &IPv6Config{
+					Enabled:    true,
+					RangeStart: netip.MustParseAddr("2001:db8::1"),
+					Options: layers.DHCPv6Options{
+						layers.NewDHCPv6Option(layers.DHCPv6OptSNTPServers, ntpData),
+						layers.NewDHCPv6Option(layers.DHCPv6OptDNSServers, dnsData[:]),
+					},
+					LeaseDuration: 1 * time.Hour,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		typ  layers.DHCPv6MsgType
+	}{{
+		name: "advertise",
+		typ:  layers.DHCPv6MsgTypeSolicit,
+	}, {
+		name: "reply",
+		typ:  layers.DHCPv6MsgTypeRequest,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newTestRequest6(tc.typ, duid, 1)
+			if tc.typ == layers.DHCPv6MsgTypeRequest {
+				req.Options = append(req.Options, srv.interfaces6[0].srvIDOpt)
+			}
+
+			resp, hErr := srv.handle6("eth0", req)
+			require.NoError(t, hErr)
+			require.NotNil(t, resp)
+
+			decoded := reencode6(t, resp)
+			assert.Equal(t, ntpData, optData6(decoded, layers.DHCPv6OptSNTPServers))
+			assert.Equal(t, dnsData[:], optData6(decoded, layers.DHCPv6OptDNSServers))
+
+			var dnsOpts int
+			for _, opt := range decoded.Options {
+				if opt.Code == layers.DHCPv6OptDNSServers {
+					dnsOpts++
+				}
+			}
+
+			assert.Equal(t, 1, dnsOpts)
+		})
+	}
+}
+
+func TestDHCPServer_handle6_rapidCommit(t *testing.T) {
+	duid := newTestDUID6(net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	rapidCommitOpt := layers.NewDHCPv6Option(layers.DHCPv6OptRapidCommit, nil)
+
+	testCases := []struct {
+		name       string
+		opts       layers.DHCPv6Options
+		wantType   layers.DHCPv6MsgType
+		wantLeases int
+		enabled    bool
+	}{{
+		name:       "committed",
+		opts:       layers.DHCPv6Options{rapidCommitOpt},
+		wantType:   layers.DHCPv6MsgTypeReply,
+		wantLeases: 1,
+		enabled:    true,
+	}, {
+		name:       "no_option",
+		opts:       nil,
+		wantType:   layers.DHCPv6MsgTypeAdverstise,
+		wantLeases: 0,
+		enabled:    true,
+	}, {
+		name:       "disabled",
+		opts:       layers.DHCPv6Options{rapidCommitOpt},
+		wantType:   layers.DHCPv6MsgTypeAdverstise,
+		wantLeases: 0,
+		enabled:    false,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer6(t, netip.MustParseAddr("2001:db8::1"))
+			srv.interfaces6[0].rapidCommit = tc.enabled
+
+			req := newTestRequest6(layers.DHCPv6MsgTypeSolicit, duid, 1)
+			req.Options = append(req.Options, tc.opts...)
+
+			resp, err := srv.handle6("eth0", reencode6(t, req))
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+
+			assert.Equal(t, tc.wantType, resp.MsgType)
+			assert.Equal(t, tc.wantType == layers.DHCPv6MsgTypeReply, hasOpt6(resp, layers.DHCPv6OptRapidCommit))
+			assert.NotNil(t, optData6(resp, layers.DHCPv6OptIANA))
+
+			leases := srv.Leases()
+			require.Len(t, leases, tc.wantLeases)
+
+			if tc.wantLeases > 0 {
+				assert.Equal(t, uint32(1), leases[0].IAID)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package dhcpsvc
+
+import "net"
+
+// VendorFunc returns the name of the vendor assigned the organizationally
+// unique identifier oui, e.g. "Apple".  vendor is empty if oui is unknown.  It's
+// never called while the leases are locked, so it may take a while, but it
+// must be safe for concurrent use.
+type VendorFunc func(oui [3]byte) (vendor string)
+
+// VendorLocal is the vendor reported for the locally administered hardware
+// addresses, which aren't assigned by any vendor.
+const VendorLocal = "Locally administered"
+
+// vendor returns the vendor of the network interface with mac.  v is empty if
+// there is no lookup function configured or the vendor is unknown.
+func (srv *DHCPServer) vendor(mac net.HardwareAddr) (v string) {
+	if srv.conf.Vendor == nil || len(mac) < 3 {
+		return ""
+	}
+
+	// The second-least-significant bit of the first octet is the U/L bit,
+	// which is set for locally administered addresses.
+	if mac[0]&0b10 != 0 {
+		return VendorLocal
+	}
+
+	return srv.conf.Vendor([3]byte{mac[0], mac[1], mac[2]})
+}
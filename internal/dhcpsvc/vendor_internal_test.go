@@ -0,0 +1,134 @@
+package dhcpsvc
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOUIs is the table of organizationally unique identifiers for tests.
+var testOUIs = map[[3]byte]string{
+	{0x24, 0x0A, 0xC4}: "Espressif",
+	{0xF0, 0x18, 0x98}: "Apple",
+}
+
+// newTestVendorServer returns a new DHCPv4 server resolving vendors with
+// vendor.
+func newTestVendorServer(t *testing.T, vendor VendorFunc) (srv *DHCPServer) {
+	t.Helper()
+
+	srv, err := New(&Config{
+		Enabled:         true,
+		LocalDomainName: "local",
+		Vendor:          vendor,
+		Interfaces: map[string]*InterfaceConfig{
+			"eth0": {
+				IPv4: newTestIPv4Config(),
+				IPv6: &IPv6Config{Enabled: false},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return srv
+}
+
+func TestDHCPServer_vendor(t *testing.T) {
+	lookup := func(oui [3]byte) (vendor string) { return testOUIs[oui] }
+	ip := netip.MustParseAddr("192.168.0.2")
+	reqIPOpt := layers.NewDHCPOption(layers.DHCPOptRequestIP, ip.AsSlice())
+
+	testCases := []struct {
+		vendor VendorFunc
+		name   string
+		want   string
+		mac    net.HardwareAddr
+	}{{
+		vendor: lookup,
+		name:   "known",
+		want:   "Espressif",
+		mac:    net.HardwareAddr{0x24, 0x0A, 0xC4, 0x01, 0x02, 0x03},
+	}, {
+		vendor: lookup,
+		name:   "unknown",
+		want:   "",
+		mac:    net.HardwareAddr{0x00, 0x00, 0x01, 0x01, 0x02, 0x03},
+	}, {
+		vendor: lookup,
+		name:   "locally_administered",
+		want:   VendorLocal,
+		mac:    net.HardwareAddr{0x02, 0x0A, 0xC4, 0x01, 0x02, 0x03},
+	}, {
+		vendor: nil,
+		name:   "no_lookup",
+		want:   "",
+		mac:    net.HardwareAddr{0x24, 0x0A, 0xC4, 0x01, 0x02, 0x03},
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestVendorServer(t, tc.vendor)
+
+			req := newTestRequest4(tc.mac, layers.DHCPMsgTypeRequest, reqIPOpt)
+			resp, err := srv.handle4("eth0", req)
+			require.NoError(t, err)
+			require.NotNil(t, resp)
+			require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+			leases := srv.Leases()
+			require.Len(t, leases, 1)
+
+			assert.Equal(t, tc.want, leases[0].Vendor)
+		})
+	}
+
+	t.Run("mac_changed", func(t *testing.T) {
+		srv := newTestVendorServer(t, lookup)
+
+		l := &Lease{
+			Hostname: "host",
+			HWAddr:   net.HardwareAddr{0x24, 0x0A, 0xC4, 0x01, 0x02, 0x03},
+			IP:       ip,
+		}
+		require.NoError(t, srv.AddStaticLease(l))
+
+		updated := l.Clone()
+		updated.HWAddr = net.HardwareAddr{0xF0, 0x18, 0x98, 0x01, 0x02, 0x03}
+		require.NoError(t, srv.UpdateStaticLease(l, updated))
+
+		leases := srv.Leases()
+		require.Len(t, leases, 1)
+
+		assert.Equal(t, "Apple", leases[0].Vendor)
+	})
+
+	t.Run("unlocked", func(t *testing.T) {
+		var srv *DHCPServer
+		srv = newTestVendorServer(t, func(oui [3]byte) (vendor string) {
+			// Make sure the leases aren't locked while looking up.
+			require.True(t, srv.leasesMu.TryLock())
+			srv.leasesMu.Unlock()
+
+			return lookup(oui)
+		})
+
+		mac := net.HardwareAddr{0x24, 0x0A, 0xC4, 0x01, 0x02, 0x03}
+		req := newTestRequest4(mac, layers.DHCPMsgTypeRequest, reqIPOpt)
+		resp, err := srv.handle4("eth0", req)
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, layers.DHCPMsgTypeAck, msgType4(resp))
+
+		err = srv.ReplaceLeases(srv.Leases())
+		require.NoError(t, err)
+
+		leases := srv.Leases()
+		require.Len(t, leases, 1)
+
+		assert.Equal(t, "Espressif", leases[0].Vendor)
+	})
+}
@@ -0,0 +1,170 @@
+package dhcpsvc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/AdguardTeam/golibs/log"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// WrongFamilyMode defines how the server treats the messages of the address
+// family disabled on the network interface they're received on.
+type WrongFamilyMode uint8
+
+// WrongFamilyMode values.
+const (
+	// WrongFamilyModeCount means that such messages are silently counted.
+	WrongFamilyModeCount WrongFamilyMode = iota
+
+	// WrongFamilyModeLog means that such messages are counted, and the first
+	// message of each client is also logged.
+	WrongFamilyModeLog
+)
+
+// String implements the [fmt.Stringer] interface for WrongFamilyMode.
+func (m WrongFamilyMode) String() (s string) {
+	switch m {
+	case WrongFamilyModeCount:
+		return "count"
+	case WrongFamilyModeLog:
+		return "log"
+	default:
+		return fmt.Sprintf("!bad_wrong_family_mode_%d", m)
+	}
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface for
+// WrongFamilyMode.
+func (m WrongFamilyMode) MarshalText() (text []byte, err error) {
+	if m > WrongFamilyModeLog {
+		return nil, fmt.Errorf("bad wrong family mode %d", m)
+	}
+
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface for
+// *WrongFamilyMode.
+func (m *WrongFamilyMode) UnmarshalText(text []byte) (err error) {
+	switch s := string(text); s {
+	case "count":
+		*m = WrongFamilyModeCount
+	case "log":
+		*m = WrongFamilyModeLog
+	default:
+		return fmt.Errorf("wrong family mode %q must be either count or log", s)
+	}
+
+	return nil
+}
+
+// maxLoggedClients is the maximum number of clients remembered by a single
+// wrongFamilyIface to log their messages once.  The messages of other clients
+// aren't logged.
+const maxLoggedClients = 1024
+
+// wrongFamilyIface is a network interface serving a single address family,
+// which listens for the messages of the other one.
+type wrongFamilyIface struct {
+	// mu protects logged.
+	mu *sync.Mutex
+
+	// logged is the set of identifiers of the clients already logged.
+	logged map[string]struct{}
+
+	// received is the number of messages received.
+	received *atomic.Uint64
+
+	// netInterface is embedded here to reuse the connection handling.  Only
+	// its name and connection are used.
+	netInterface
+
+	// is4 is true if the interface listens for DHCPv4 messages.
+	is4 bool
+}
+
+// newWrongFamilyIfaces returns the interfaces to listen for the messages of
+// the address families disabled on the network interfaces served by srv.
+// srv.interfaces4 and srv.interfaces6 must be sorted by name.
+func (srv *DHCPServer) newWrongFamilyIfaces() (ifaces []*wrongFamilyIface) {
+	newIface := func(name string, is4 bool) (iface *wrongFamilyIface) {
+		return &wrongFamilyIface{
+			mu:           &sync.Mutex{},
+			logged:       map[string]struct{}{},
+			received:     &atomic.Uint64{},
+			netInterface: netInterface{name: name},
+			is4:          is4,
+		}
+	}
+
+	for _, iface := range srv.interfaces4 {
+		if srv.iface6ByName(iface.name) == nil {
+			ifaces = append(ifaces, newIface(iface.name, false))
+		}
+	}
+
+	for _, iface := range srv.interfaces6 {
+		if srv.iface4ByName(iface.name) == nil {
+			ifaces = append(ifaces, newIface(iface.name, true))
+		}
+	}
+
+	return ifaces
+}
+
+// newWrongFamilyHandler returns the handler of the messages received on iface,
+// which never replies.
+func (srv *DHCPServer) newWrongFamilyHandler(iface *wrongFamilyIface) (h msgHandler) {
+	return func(data []byte, _ net.Addr, _ gopacket.SerializeBuffer) (to net.Addr, err error) {
+		iface.received.Add(1)
+
+		if srv.conf.WrongFamilyMode == WrongFamilyModeLog {
+			iface.logOnce(data)
+		}
+
+		return nil, nil
+	}
+}
+
+// logOnce logs the message data, unless the message from the same client has
+// already been logged.
+func (iface *wrongFamilyIface) logOnce(data []byte) {
+	family, client := "dhcpv6", ""
+	if iface.is4 {
+		family = "dhcpv4"
+		msg := &layers.DHCPv4{}
+		if msg.DecodeFromBytes(data, gopacket.NilDecodeFeedback) == nil {
+			client = msg.ClientHWAddr.String()
+		}
+	} else {
+		msg := &layers.DHCPv6{}
+		if msg.DecodeFromBytes(data, gopacket.NilDecodeFeedback) == nil {
+			client = hex.EncodeToString(optData6(msg, layers.DHCPv6OptClientID))
+		}
+	}
+
+	if client == "" {
+		return
+	}
+
+	iface.mu.Lock()
+	defer iface.mu.Unlock()
+
+	if _, ok := iface.logged[client]; ok || len(iface.logged) >= maxLoggedClients {
+		return
+	}
+
+	iface.logged[client] = struct{}{}
+
+	log.Info(
+		"dhcpsvc: interface %q: %s is disabled, ignoring messages from client %s",
+		iface.name,
+		family,
+		client,
+	)
+}
@@ -0,0 +1,110 @@
+package dhcpsvc
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/AdguardTeam/golibs/testutil/fakenet"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDHCPServer_wrongFamily(t *testing.T) {
+	mac := net.HardwareAddr{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+	from := &net.UDPAddr{IP: net.IPv4zero, Port: clientPort4}
+
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(
+		buf,
+		gopacket.SerializeOptions{FixLengths: true},
+		newTestRequest4(mac, layers.DHCPMsgTypeDiscover),
+	)
+	require.NoError(t, err)
+
+	discover := &testRead{from: from, data: buf.Bytes()}
+
+	testCases := []struct {
+		name       string
+		mode       WrongFamilyMode
+		wantLogged int
+	}{{
+		name:       "count",
+		mode:       WrongFamilyModeCount,
+		wantLogged: 0,
+	}, {
+		name:       "log",
+		mode:       WrongFamilyModeLog,
+		wantLogged: 1,
+	}}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reads4 := make(chan *testRead, 2)
+			written := &atomic.Uint64{}
+			base := newTestListener(reads4, nil)
+
+			srv, newErr := New(&Config{
+				Enabled:         true,
+				LocalDomainName: "local",
+				WrongFamilyMode: tc.mode,
+				Listener: testListener(func(
+					ctx context.Context,
+					ifaceName string,
+					laddr netip.AddrPort,
+				) (conn net.PacketConn, err error) {
+					conn, err = base(ctx, ifaceName, laddr)
+					require.NoError(t, err)
+
+					fakeConn := conn.(*fakenet.PacketConn)
+					fakeConn.OnWriteTo = func(b []byte, _ net.Addr) (n int, err error) {
+						written.Add(1)
+
+						return len(b), nil
+					}
+
+					return fakeConn, nil
+				}),
+				Interfaces: map[string]*InterfaceConfig{
+					"eth0": {
+						IPv4: &IPv4Config{Enabled: false},
+						IPv6: &IPv6Config{
+							Enabled:       true,
+							RangeStart:    netip.MustParseAddr("2001:db8::1"),
+							LeaseDuration: 1 * time.Hour,
+						},
+					},
+				},
+			})
+			require.NoError(t, newErr)
+
+			startTestServer(t, srv)
+
+			reads4 <- discover
+			reads4 <- discover
+
+			require.Eventually(t, func() (ok bool) {
+				c := srv.Stats().WrongFamily["eth0"]
+
+				return c != nil && c.IPv4 == 2
+			}, time.Second, time.Millisecond)
+
+			assert.Equal(t, &FamilyCounters{IPv4: 2, IPv6: 0}, srv.Stats().WrongFamily["eth0"])
+			assert.Zero(t, written.Load())
+			assert.Empty(t, srv.Leases())
+
+			require.Len(t, srv.wrongFamily, 1)
+
+			iface := srv.wrongFamily[0]
+			iface.mu.Lock()
+			defer iface.mu.Unlock()
+
+			assert.Len(t, iface.logged, tc.wantLogged)
+		})
+	}
+}
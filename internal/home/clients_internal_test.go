@@ -10,6 +10,7 @@ import (
 	"github.com/AdguardTeam/AdGuardHome/internal/client"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpd"
 	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc"
+	"github.com/AdguardTeam/AdGuardHome/internal/dhcpsvc/dhcpdcompat"
 	"github.com/AdguardTeam/AdGuardHome/internal/filtering"
 	"github.com/AdguardTeam/AdGuardHome/internal/whois"
 	"github.com/stretchr/testify/assert"
@@ -338,6 +339,53 @@ func TestClientsAddExisting(t *testing.T) {
 		require.NoError(t, err)
 		assert.True(t, ok)
 	})
+
+	t.Run("dhcpsvc_adapter", func(t *testing.T) {
+		ip := netip.MustParseAddr("1.2.3.5")
+		mac := net.HardwareAddr{0xBB, 0xBB, 0xBB, 0xBB, 0xBB, 0xBB}
+
+		srv, err := dhcpsvc.New(&dhcpsvc.Config{
+			Enabled:         true,
+			LocalDomainName: "lan",
+			Interfaces: map[string]*dhcpsvc.InterfaceConfig{
+				"eth0": {
+					IPv4: &dhcpsvc.IPv4Config{
+						Enabled:       true,
+						GatewayIP:     netip.MustParseAddr("1.2.3.1"),
+						SubnetMask:    netip.MustParseAddr("255.255.255.0"),
+						RangeStart:    netip.MustParseAddr("1.2.3.2"),
+						RangeEnd:      netip.MustParseAddr("1.2.3.10"),
+						LeaseDuration: 1 * time.Hour,
+					},
+					IPv6: &dhcpsvc.IPv6Config{Enabled: false},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		dhcpServer := dhcpdcompat.New(srv, &dhcpd.ServerConfig{})
+		clients.dhcp = dhcpServer
+
+		err = dhcpServer.AddStaticLease(&dhcpd.Lease{
+			HWAddr:   mac,
+			IP:       ip,
+			Hostname: "adapterhost",
+			IsStatic: true,
+		})
+		require.NoError(t, err)
+
+		ok, err := clients.Add(&Client{
+			IDs:  []string{mac.String()},
+			Name: "client4",
+		})
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		c, ok := clients.Find(ip.String())
+		require.True(t, ok)
+
+		assert.Equal(t, "client4", c.Name)
+	})
 }
 
 func TestClientsCustomUpstream(t *testing.T) {